@@ -5,15 +5,28 @@ package main
 
 import (
 	// "encoding/json"
+	"context"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"trader/internal/config"
+	"trader/internal/core/instrument"
+	"trader/internal/core/orderbook"
+	"trader/internal/core/ws"
 	"trader/internal/logger"
 	"trader/internal/manager"
+	"trader/internal/monitor"
+	"trader/internal/monitor/clickhouse"
+	"trader/internal/task"
+	"trader/internal/trader"
+
+	// Регистрация драйверов бирж в реестре exchange
+	_ "trader/internal/core/exchange/drivers"
 )
 
 // Version - текущая версия приложения
@@ -22,6 +35,9 @@ const (
 	Version = "2.0.2"
 )
 
+// migrateTimeout - предельное время применения миграций ClickHouse
+const migrateTimeout = 5 * time.Minute
+
 // main - основная функция приложения
 // Порядок инициализации критичен:
 // 1. Загрузить конфигурацию (нужна для всех компонентов)
@@ -31,9 +47,15 @@ const (
 // 5. Обработать сигналы OS для корректного завершения
 func main() {
 	// Парсируем флаги командной строки
-	// Использование: trader -c path/to/config.yaml
+	// Использование: trader -c path/to/config.yaml [migrate]
+	// Команда migrate применяет миграции схемы ClickHouse и завершает работу
 	configFile := flag.String("c", "conf/config.yaml", "Path to configuration file")
 	flag.Parse()
+	command := flag.Arg(0)
+	if command != "" && command != "migrate" {
+		fmt.Printf("Unknown command: %s\n", command)
+		os.Exit(2)
+	}
 
 	// 1. ЗАГРУЗКА КОНФИГУРАЦИИ
 	// Конфигурация хранится в YAML файле с параметрами для всех компонентов:
@@ -71,13 +93,35 @@ func main() {
 	log.Info("INIT START trader", "version", Version)
 	log.Info("Starting trader", "config", *configFile)
 
+	// МИГРАЦИИ CLICKHOUSE
+	// По команде migrate - только миграции, при auto_migrate - перед запуском монитора:
+	// монитор не должен писать в таблицы, которых еще нет
+	if command == "migrate" {
+		if err := migrateClickHouse(cfg.ClickHouse, log); err != nil {
+			log.Error("ClickHouse migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if cfg.ClickHouse.AutoMigrate && isMonitor(cfg) {
+		if err := migrateClickHouse(cfg.ClickHouse, log); err != nil {
+			log.Error("ClickHouse migration failed", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	// 3. ИНИЦИАЛИЗАЦИЯ МЕНЕДЖЕРА
 	// Менеджер - это сердце приложения
 	// Отвечает за:
 	// - Получение задач и управление жизненным циклом через CTS-Core
 	// - Управление подписками на WebSocket потоки
 	// - Запуск/остановку Monitor и Trader компонентов
-	mgr := manager.New(cfg)
+	runtime, err := buildRuntime(cfg)
+	if err != nil {
+		log.Error("Failed to build runtime", "error", err)
+		os.Exit(1)
+	}
+	mgr := manager.New(cfg, runtime)
 
 	// 4. ЗАПУСК МЕНЕДЖЕРА
 	// Trader работает как outbound-клиент (WS/REST к CTS-Core и биржам)
@@ -113,3 +157,98 @@ func main() {
 	// jsonData, _ := json.MarshalIndent(cfg, "", "  ")
 	// fmt.Println(string(jsonData))
 }
+
+// migrateClickHouse применяет непримененные миграции схемы ClickHouse
+func migrateClickHouse(cfg config.ClickHouseConfig, log *slog.Logger) error {
+	migrator, err := clickhouse.NewMigrator(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	applied, err := migrator.Run(ctx)
+	if err != nil {
+		return err
+	}
+	log.Info("ClickHouse schema is up to date", "database", cfg.Database, "applied", applied)
+	return nil
+}
+
+// isMonitor - демон собирает рыночные данные в ClickHouse (роль monitor или both)
+func isMonitor(cfg *config.Config) bool {
+	return cfg.Role == "monitor" || cfg.Role == "both"
+}
+
+// isTrader - демон исполняет торговые задачи (роль trader или both)
+func isTrader(cfg *config.Config) bool {
+	return cfg.Role == "trader" || cfg.Role == "both"
+}
+
+// buildRuntime строит загрузку задач из MySQL и рыночные компоненты: WS пул бирж,
+// книги ордеров из его сообщений, подписки пула по задачам и каталог инструментов бирж,
+// для ролей trader/both - проверка ордеров по книгам и лимитам задач,
+// для ролей monitor/both - монитор, пишущий снимки книг в ClickHouse,
+// и история последних книг и сделок пар в памяти
+// Если задан spool_dir, монитор пишет через дисковый буфер: batch, которые не удалось
+// отправить, сохраняются на диск и переотправляются при запуске и после возврата сервера
+func buildRuntime(cfg *config.Config) (manager.Runtime, error) {
+	// Задачи мониторинга и торговли из MySQL, без host демон работает без задач
+	var tasks *task.Fetcher
+	if cfg.MySQL.Host != "" {
+		db, err := task.OpenDB(cfg.MySQL)
+		if err != nil {
+			return manager.Runtime{}, err
+		}
+		tasks = task.NewFetcher(db, time.Duration(cfg.MySQL.FetchInterval)*time.Second)
+	}
+
+	pool := ws.NewPool()
+	// Watchdog зависших потоков: stale_threshold в секундах, -1 выключает
+	pool.SetStaleThreshold(time.Duration(cfg.WebSocket.StaleThreshold) * time.Second)
+
+	books := orderbook.NewManager(pool)
+	pool.AddHandler(books.HandleMessage)
+
+	runtime := manager.Runtime{
+		Tasks:         tasks,
+		Pool:          pool,
+		Books:         books,
+		Subscriptions: task.NewSubscriptionManager(pool),
+		Instruments:   instrument.NewCatalog(pool, instrument.DefaultTTL),
+	}
+	if isTrader(cfg) {
+		runtime.Orders = trader.NewOrderChecker(books)
+	}
+	if !isMonitor(cfg) {
+		return runtime, nil
+	}
+
+	// closeAll освобождает уже созданные компоненты, если монитор построить не удалось
+	closeAll := func() {
+		if tasks != nil {
+			_ = tasks.Stop()
+		}
+		pool.Close()
+		books.Close()
+	}
+	client, err := clickhouse.NewClient(cfg.ClickHouse)
+	if err != nil {
+		closeAll()
+		return manager.Runtime{}, fmt.Errorf("clickhouse client: %w", err)
+	}
+	var sink monitor.Sink = client
+	if cfg.ClickHouse.SpoolDir != "" {
+		spool, err := clickhouse.NewSpool(client, cfg.ClickHouse.SpoolDir, int64(cfg.ClickHouse.SpoolMaxSizeMB)<<20)
+		if err != nil {
+			closeAll()
+			return manager.Runtime{}, fmt.Errorf("clickhouse spool: %w", err)
+		}
+		runtime.Spool = spool
+		sink = spool
+	}
+	runtime.Monitor = monitor.New(cfg.Monitor, books, sink)
+	runtime.History = orderbook.NewHistory(books, cfg.Monitor.RingBufferSize)
+	return runtime, nil
+}
@@ -28,6 +28,44 @@ type Config struct {
 	Trader TraderConfig `yaml:"trader"`
 	// ClickHouse - параметры подключения к ClickHouse для исторических данных
 	ClickHouse ClickHouseConfig `yaml:"clickhouse"`
+	// WebSocket - параметры WS соединений к биржам
+	WebSocket WebSocketConfig `yaml:"websocket"`
+	// MySQL - параметры подключения к MySQL с задачами мониторинга и торговли
+	MySQL MySQLConfig `yaml:"mysql"`
+}
+
+// MySQLConfig - конфигурация подключения к MySQL
+// Из таблиц MONITORING и TRADE периодически загружаются задачи демона
+type MySQLConfig struct {
+	// Host - адрес хоста MySQL, пустой = задачи не загружаются
+	Host string `yaml:"host"`
+
+	// Port - порт MySQL (обычно 3306)
+	Port int `yaml:"port"`
+
+	// Database - название базы данных с задачами
+	Database string `yaml:"database"`
+
+	// Username - имя пользователя для подключения
+	Username string `yaml:"username"`
+
+	// Password - пароль для подключения
+	Password string `yaml:"password"`
+
+	// ConnectTimeout - таймаут подключения в секундах
+	ConnectTimeout int `yaml:"connect_timeout"`
+
+	// FetchInterval - интервал загрузки задач в секундах
+	// Изменения задач применяются к подпискам, монитору и истории не позже чем через N секунд
+	FetchInterval int `yaml:"fetch_interval"`
+}
+
+// WebSocketConfig - настройки WS пула бирж
+type WebSocketConfig struct {
+	// StaleThreshold - через сколько секунд тишины по паре поток считается зависшим
+	// Зависшая пара переподписывается, молчащее соединение пересоздается
+	// -1 = watchdog выключен
+	StaleThreshold int `yaml:"stale_threshold"`
 }
 
 // OrderBookConfig - настройки для управления книгой ордеров
@@ -146,7 +184,8 @@ type ClickHouseConfig struct {
 	// ConnectTimeout - таймаут подключения в секундах
 	ConnectTimeout int `yaml:"connect_timeout"`
 
-	// MaxRetries - максимальное количество попыток подключения
+	// MaxRetries - количество повторов запроса после временной ошибки (сеть, 5xx)
+	// Всего выполняется до MaxRetries+1 попыток
 	MaxRetries int `yaml:"max_retries"`
 
 	// Compression - включить ли сжатие данных при отправке
@@ -161,6 +200,22 @@ type ClickHouseConfig struct {
 	// 1 = без репликации (быстро но рискованно)
 	// 2+ = с репликацией (надежно но медленнее)
 	ReplicationFactor int `yaml:"replication_factor"`
+
+	// Cluster - имя кластера из remote_servers, на котором создаются реплицируемые таблицы
+	// При ReplicationFactor > 1 DDL выполняется с ON CLUSTER на всех репликах
+	Cluster string `yaml:"cluster"`
+
+	// AutoMigrate - применять миграции схемы ClickHouse при старте монитора
+	// Без него миграции применяются командой "trader migrate"
+	AutoMigrate bool `yaml:"auto_migrate"`
+
+	// SpoolDir - каталог дискового буфера batch на время недоступности ClickHouse
+	// Batch сохраняются в сегменты и переотправляются по порядку, когда сервер вернется
+	SpoolDir string `yaml:"spool_dir"`
+
+	// SpoolMaxSizeMB - предельный размер дискового буфера в мегабайтах
+	// При превышении удаляются самые старые данные, 0 = без ограничения
+	SpoolMaxSizeMB int `yaml:"spool_max_size_mb"`
 }
 
 // Load загружает конфигурацию из YAML файла.
@@ -222,6 +277,14 @@ func defaultConfig() *Config {
 			Compression:       true,
 			MaxBatchSize:      10000,
 			ReplicationFactor: 1,
+			SpoolDir:          "./spool/clickhouse",
+			SpoolMaxSizeMB:    1024,
+		},
+		WebSocket: WebSocketConfig{StaleThreshold: 60},
+		MySQL: MySQLConfig{
+			Port:           3306,
+			ConnectTimeout: 10,
+			FetchInterval:  5,
 		},
 	}
 }
@@ -304,6 +367,23 @@ func applyDefaults(c *Config) {
 	if c.ClickHouse.ReplicationFactor == 0 {
 		c.ClickHouse.ReplicationFactor = 1
 	}
+	if c.ClickHouse.SpoolDir == "" {
+		c.ClickHouse.SpoolDir = "./spool/clickhouse"
+	}
+
+	if c.WebSocket.StaleThreshold == 0 {
+		c.WebSocket.StaleThreshold = 60
+	}
+
+	if c.MySQL.Port == 0 {
+		c.MySQL.Port = 3306
+	}
+	if c.MySQL.ConnectTimeout == 0 {
+		c.MySQL.ConnectTimeout = 10
+	}
+	if c.MySQL.FetchInterval == 0 {
+		c.MySQL.FetchInterval = 5
+	}
 }
 
 func applyEnvOverrides(c *Config) {
@@ -328,6 +408,20 @@ func applyEnvOverrides(c *Config) {
 	c.ClickHouse.TLSSkipVerify = envBool("TRADER_CLICKHOUSE_TLS_SKIP_VERIFY", c.ClickHouse.TLSSkipVerify)
 	c.ClickHouse.ConnectTimeout = envInt("TRADER_CLICKHOUSE_CONNECT_TIMEOUT", c.ClickHouse.ConnectTimeout)
 	c.ClickHouse.MaxRetries = envInt("TRADER_CLICKHOUSE_MAX_RETRIES", c.ClickHouse.MaxRetries)
+	c.ClickHouse.Cluster = envString("TRADER_CLICKHOUSE_CLUSTER", c.ClickHouse.Cluster)
+	c.ClickHouse.AutoMigrate = envBool("TRADER_CLICKHOUSE_AUTO_MIGRATE", c.ClickHouse.AutoMigrate)
+	c.ClickHouse.SpoolDir = envString("TRADER_CLICKHOUSE_SPOOL_DIR", c.ClickHouse.SpoolDir)
+	c.ClickHouse.SpoolMaxSizeMB = envInt("TRADER_CLICKHOUSE_SPOOL_MAX_SIZE_MB", c.ClickHouse.SpoolMaxSizeMB)
+
+	c.WebSocket.StaleThreshold = envInt("TRADER_WS_STALE_THRESHOLD", c.WebSocket.StaleThreshold)
+
+	c.MySQL.Host = envString("TRADER_MYSQL_HOST", c.MySQL.Host)
+	c.MySQL.Port = envInt("TRADER_MYSQL_PORT", c.MySQL.Port)
+	c.MySQL.Database = envString("TRADER_MYSQL_DATABASE", c.MySQL.Database)
+	c.MySQL.Username = envString("TRADER_MYSQL_USERNAME", c.MySQL.Username)
+	c.MySQL.Password = envString("TRADER_MYSQL_PASSWORD", c.MySQL.Password)
+	c.MySQL.ConnectTimeout = envInt("TRADER_MYSQL_CONNECT_TIMEOUT", c.MySQL.ConnectTimeout)
+	c.MySQL.FetchInterval = envInt("TRADER_MYSQL_FETCH_INTERVAL", c.MySQL.FetchInterval)
 }
 
 func envString(key, fallback string) string {
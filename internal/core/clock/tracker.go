@@ -0,0 +1,271 @@
+// Package clock оценивает расхождение часов бирж с локальными часами
+// и приводит время сообщений к общей шкале
+//
+// Offset оценивается по схеме NTP: запрос серверного времени ts отправлен в t0,
+// ответ получен в t1, offset = ts - (t0 + t1) / 2. Из нескольких замеров за синхронизацию
+// берется замер с минимальным RTT - у него меньше всего асимметрии сети.
+// Drift - скорость изменения offset между синхронизациями, используется для экстраполяции.
+package clock
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+	"trader/internal/logger"
+)
+
+const (
+	// DefaultSyncInterval - как часто запрашивать серверное время
+	DefaultSyncInterval = 5 * time.Minute
+	// samplesPerSync - замеров за одну синхронизацию
+	samplesPerSync = 3
+	// syncTimeout - таймаут одной синхронизации
+	syncTimeout = 15 * time.Second
+	// driftSmoothing - вес нового замера drift в скользящем среднем
+	driftSmoothing = 0.3
+)
+
+// Estimate - оценка часов биржи для (exchange, market)
+type Estimate struct {
+	// Offset - время биржи минус локальное время на момент UpdatedAt
+	Offset time.Duration
+	// RTT - время запроса, по которому получен Offset (верхняя граница ошибки: ±RTT/2)
+	RTT time.Duration
+	// Drift - изменение offset за секунду локального времени (1e-6 = 1 ppm)
+	Drift float64
+	// UpdatedAt - локальное время замера
+	UpdatedAt time.Time
+	// Syncs - сколько успешных синхронизаций было
+	Syncs int
+}
+
+// OffsetAt экстраполирует offset на момент at с учетом drift
+func (e Estimate) OffsetAt(at time.Time) time.Duration {
+	elapsed := at.Sub(e.UpdatedAt).Seconds()
+	return e.Offset + time.Duration(e.Drift*elapsed*float64(time.Second))
+}
+
+// source - биржа и рынок, для которых ведется оценка
+type source struct {
+	exchangeID string
+	marketType string
+	clock      exchange.ServerClock
+}
+
+// Tracker периодически синхронизирует часы зарегистрированных бирж
+// Безопасен для конкурентного использования, nil *Tracker ничего не делает
+type Tracker struct {
+	interval time.Duration
+	log      *slog.Logger
+	// now - локальные часы замеров, тесты подменяют их ручными
+	now func() time.Time
+
+	mu        sync.RWMutex
+	sources   map[string]source   // key = "exchange:market"
+	estimates map[string]Estimate // key = "exchange:market"
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewTracker создает трекер и запускает периодическую синхронизацию
+// interval <= 0 - DefaultSyncInterval
+func NewTracker(interval time.Duration) *Tracker {
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &Tracker{
+		interval:  interval,
+		log:       logger.Get("clock"),
+		now:       time.Now,
+		sources:   make(map[string]source),
+		estimates: make(map[string]Estimate),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	go t.loop()
+	return t
+}
+
+// Track регистрирует (exchange, market) и сразу запускает первую синхронизацию
+// Повторная регистрация игнорируется
+func (t *Tracker) Track(exchangeID, marketType string, clock exchange.ServerClock) {
+	if t == nil || clock == nil {
+		return
+	}
+	key := sourceKey(exchangeID, marketType)
+
+	t.mu.Lock()
+	if _, ok := t.sources[key]; ok {
+		t.mu.Unlock()
+		return
+	}
+	src := source{exchangeID: exchangeID, marketType: marketType, clock: clock}
+	t.sources[key] = src
+	t.mu.Unlock()
+
+	go t.sync(src)
+}
+
+// Estimate возвращает текущую оценку часов (exchange, market)
+// false если синхронизаций еще не было
+func (t *Tracker) Estimate(exchangeID, marketType string) (Estimate, bool) {
+	if t == nil {
+		return Estimate{}, false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	estimate, ok := t.estimates[sourceKey(exchangeID, marketType)]
+	return estimate, ok
+}
+
+// Normalize заполняет время получения и оценку часов биржи в сообщениях
+// receivedAt - локальное время получения фрейма
+// Сообщения без времени события получают его из receivedAt по часам биржи
+func (t *Tracker) Normalize(messages []*messaging.Message, receivedAt time.Time) {
+	if len(messages) == 0 {
+		return
+	}
+
+	var offset int64
+	if estimate, ok := t.Estimate(messages[0].ExchangeID, messages[0].MarketType); ok {
+		offset = estimate.OffsetAt(receivedAt).Microseconds()
+	}
+	received := receivedAt.UTC().UnixMicro()
+
+	for _, msg := range messages {
+		msg.ReceivedAt = received
+		msg.ClockOffset = offset
+		if msg.Timestamp == 0 {
+			msg.Timestamp = received + offset
+		}
+	}
+}
+
+// Close останавливает синхронизацию
+func (t *Tracker) Close() {
+	if t == nil {
+		return
+	}
+	t.cancel()
+}
+
+func (t *Tracker) loop() {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+			t.mu.RLock()
+			sources := make([]source, 0, len(t.sources))
+			for _, src := range t.sources {
+				sources = append(sources, src)
+			}
+			t.mu.RUnlock()
+
+			for _, src := range sources {
+				t.sync(src)
+			}
+		}
+	}
+}
+
+// sync делает samplesPerSync замеров и сохраняет лучший
+func (t *Tracker) sync(src source) {
+	ctx, cancel := context.WithTimeout(t.ctx, syncTimeout)
+	defer cancel()
+
+	var best sample
+	var lastErr error
+	for i := 0; i < samplesPerSync; i++ {
+		s, err := t.measure(ctx, src)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if best.at.IsZero() || s.rtt < best.rtt {
+			best = s
+		}
+	}
+
+	if best.at.IsZero() {
+		if t.ctx.Err() == nil {
+			t.log.Warn("clock sync failed",
+				"exchange_id", src.exchangeID,
+				"market_type", src.marketType,
+				"error", lastErr,
+			)
+		}
+		return
+	}
+
+	estimate := t.update(src, best)
+	t.log.Info("clock sync",
+		"exchange_id", src.exchangeID,
+		"market_type", src.marketType,
+		"offset_ms", float64(estimate.Offset.Microseconds())/1000.0,
+		"rtt_ms", float64(estimate.RTT.Microseconds())/1000.0,
+		"drift_ppm", estimate.Drift*1e6,
+	)
+}
+
+// sample - один замер серверного времени
+type sample struct {
+	offset time.Duration
+	rtt    time.Duration
+	at     time.Time
+}
+
+func (t *Tracker) measure(ctx context.Context, src source) (sample, error) {
+	sent := t.now()
+	serverMicros, err := src.clock.ServerTime(ctx, src.marketType)
+	received := t.now()
+	if err != nil {
+		return sample{}, err
+	}
+
+	rtt := received.Sub(sent)
+	midpoint := sent.Add(rtt / 2)
+	return sample{
+		offset: time.UnixMicro(serverMicros).Sub(midpoint),
+		rtt:    rtt,
+		at:     midpoint.UTC(),
+	}, nil
+}
+
+// update сохраняет замер и пересчитывает drift
+func (t *Tracker) update(src source, s sample) Estimate {
+	key := sourceKey(src.exchangeID, src.marketType)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, ok := t.estimates[key]
+	estimate := Estimate{Offset: s.offset, RTT: s.rtt, UpdatedAt: s.at, Syncs: 1}
+	if ok {
+		estimate.Syncs = prev.Syncs + 1
+		estimate.Drift = prev.Drift
+		if elapsed := s.at.Sub(prev.UpdatedAt).Seconds(); elapsed > 0 {
+			drift := (s.offset - prev.Offset).Seconds() / elapsed
+			if prev.Syncs == 1 {
+				estimate.Drift = drift
+			} else {
+				estimate.Drift = prev.Drift + driftSmoothing*(drift-prev.Drift)
+			}
+		}
+	}
+	t.estimates[key] = estimate
+	return estimate
+}
+
+func sourceKey(exchangeID, marketType string) string {
+	return exchangeID + ":" + marketType
+}
@@ -0,0 +1,219 @@
+package clock
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+)
+
+// fakeSample - один запрос серверного времени: длится rtt, сервер отвечает временем
+// середины запроса плюс skew (асимметрия сети)
+type fakeSample struct {
+	rtt  time.Duration
+	skew time.Duration
+	err  error
+}
+
+// fakeExchange - биржа, чьи часы убежали на offset от локальных
+// Локальные часы теста двигаются только запросами и syncAt
+type fakeExchange struct {
+	mu      sync.Mutex
+	local   time.Time
+	offset  time.Duration
+	samples []fakeSample
+}
+
+func (f *fakeExchange) now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.local
+}
+
+func (f *fakeExchange) ServerTime(context.Context, string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.samples[0]
+	f.samples = f.samples[1:]
+	mid := f.local.Add(s.rtt / 2)
+	f.local = f.local.Add(s.rtt)
+	if s.err != nil {
+		return 0, s.err
+	}
+	return mid.Add(f.offset + s.skew).UnixMicro(), nil
+}
+
+var start = time.Unix(1700000000, 0).UTC()
+
+func newTestTracker(t *testing.T, f *fakeExchange) *Tracker {
+	t.Helper()
+	tracker := NewTracker(time.Hour)
+	t.Cleanup(tracker.Close)
+	tracker.now = f.now
+	return tracker
+}
+
+// syncAt синхронизирует часы биржи в момент at с заданным offset
+// и одинаковыми замерами rtt 10ms без асимметрии
+func syncAt(tracker *Tracker, f *fakeExchange, at time.Time, offset time.Duration) Estimate {
+	f.mu.Lock()
+	f.local = at
+	f.offset = offset
+	for range samplesPerSync {
+		f.samples = append(f.samples, fakeSample{rtt: 10 * time.Millisecond})
+	}
+	f.mu.Unlock()
+
+	tracker.sync(source{exchangeID: exchange.Binance, marketType: exchange.MarketSpot, clock: f})
+	estimate, _ := tracker.Estimate(exchange.Binance, exchange.MarketSpot)
+	return estimate
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-12
+}
+
+func TestSyncKeepsLowestRTTSample(t *testing.T) {
+	f := &fakeExchange{local: start, offset: 250 * time.Millisecond, samples: []fakeSample{
+		// Медленные ответы с асимметрией сети дают смещенный offset
+		{rtt: 200 * time.Millisecond, skew: 80 * time.Millisecond},
+		{rtt: 10 * time.Millisecond},
+		{rtt: 300 * time.Millisecond, skew: -120 * time.Millisecond},
+	}}
+	tracker := newTestTracker(t, f)
+	tracker.sync(source{exchangeID: exchange.Binance, marketType: exchange.MarketSpot, clock: f})
+
+	estimate, ok := tracker.Estimate(exchange.Binance, exchange.MarketSpot)
+	if !ok {
+		t.Fatal("no estimate after sync")
+	}
+	if estimate.Offset != 250*time.Millisecond || estimate.RTT != 10*time.Millisecond {
+		t.Errorf("estimate offset %v rtt %v, want 250ms rtt 10ms", estimate.Offset, estimate.RTT)
+	}
+	// Время замера - середина второго запроса
+	if want := start.Add(205 * time.Millisecond); !estimate.UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt = %v, want %v", estimate.UpdatedAt, want)
+	}
+	if estimate.Syncs != 1 || estimate.Drift != 0 {
+		t.Errorf("first sync: syncs %d drift %v", estimate.Syncs, estimate.Drift)
+	}
+
+	// Неудачные замеры пропускаются, синхронизация без удачных замеров не меняет оценку
+	f.samples = []fakeSample{
+		{err: errors.New("timeout")},
+		{rtt: 40 * time.Millisecond, skew: 20 * time.Millisecond},
+		{err: errors.New("timeout")},
+	}
+	tracker.sync(source{exchangeID: exchange.Binance, marketType: exchange.MarketSpot, clock: f})
+	if estimate, _ = tracker.Estimate(exchange.Binance, exchange.MarketSpot); estimate.Offset != 270*time.Millisecond || estimate.Syncs != 2 {
+		t.Errorf("sync with failed samples: offset %v syncs %d, want 270ms, 2", estimate.Offset, estimate.Syncs)
+	}
+
+	f.samples = make([]fakeSample, samplesPerSync)
+	for i := range f.samples {
+		f.samples[i].err = errors.New("unavailable")
+	}
+	tracker.sync(source{exchangeID: exchange.Binance, marketType: exchange.MarketSpot, clock: f})
+	if after, _ := tracker.Estimate(exchange.Binance, exchange.MarketSpot); after != estimate {
+		t.Errorf("failed sync changed estimate: %+v, was %+v", after, estimate)
+	}
+}
+
+func TestDriftSmoothing(t *testing.T) {
+	f := &fakeExchange{}
+	tracker := newTestTracker(t, f)
+
+	syncAt(tracker, f, start, 100*time.Millisecond)
+
+	// Вторая синхронизация берет drift как есть: +10ms за 100s
+	estimate := syncAt(tracker, f, start.Add(100*time.Second), 110*time.Millisecond)
+	if !near(estimate.Drift, 1e-4) {
+		t.Fatalf("drift after 2 syncs = %v, want 1e-4", estimate.Drift)
+	}
+
+	// Дальше drift сглаживается: новый замер 2e-4 входит с весом driftSmoothing
+	estimate = syncAt(tracker, f, start.Add(200*time.Second), 130*time.Millisecond)
+	if want := 1e-4 + driftSmoothing*(2e-4-1e-4); !near(estimate.Drift, want) {
+		t.Fatalf("drift after 3 syncs = %v, want %v", estimate.Drift, want)
+	}
+	if estimate.Offset != 130*time.Millisecond || estimate.Syncs != 3 {
+		t.Errorf("estimate after 3 syncs = %+v", estimate)
+	}
+
+	// Offset экстраполируется по сглаженному drift: 1.3e-4 * 10s = 1.3ms
+	at := estimate.UpdatedAt.Add(10 * time.Second)
+	if got := estimate.OffsetAt(at); (got - 131300*time.Microsecond).Abs() > time.Microsecond {
+		t.Errorf("OffsetAt(+10s) = %v, want 131.3ms", got)
+	}
+	if got := estimate.OffsetAt(estimate.UpdatedAt); got != estimate.Offset {
+		t.Errorf("OffsetAt(UpdatedAt) = %v, want %v", got, estimate.Offset)
+	}
+
+	// Скачок offset (смена сервера за балансировщиком) сдвигает drift лишь на долю
+	prev := estimate.Drift
+	estimate = syncAt(tracker, f, start.Add(300*time.Second), 30*time.Millisecond)
+	if want := prev + driftSmoothing*(-1e-3-prev); !near(estimate.Drift, want) {
+		t.Errorf("drift after offset jump = %v, want %v", estimate.Drift, want)
+	}
+}
+
+func TestUnknownExchange(t *testing.T) {
+	f := &fakeExchange{}
+	tracker := newTestTracker(t, f)
+	syncAt(tracker, f, start, 500*time.Millisecond)
+
+	for _, key := range [][2]string{{"unknown", exchange.MarketSpot}, {exchange.Binance, exchange.MarketFutures}} {
+		if estimate, ok := tracker.Estimate(key[0], key[1]); ok {
+			t.Errorf("Estimate(%s, %s) = %+v, want none", key[0], key[1], estimate)
+		}
+	}
+
+	receivedAt := start.Add(time.Second)
+	received := receivedAt.UnixMicro()
+	for _, tc := range []struct {
+		name       string
+		tracker    *Tracker
+		exchangeID string
+		offset     int64
+	}{
+		{"tracked exchange", tracker, exchange.Binance, (500 * time.Millisecond).Microseconds()},
+		{"unknown exchange", tracker, "unknown", 0},
+		{"nil tracker", nil, exchange.Binance, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withoutTime := &messaging.Message{ExchangeID: tc.exchangeID, MarketType: exchange.MarketSpot}
+			withTime := &messaging.Message{ExchangeID: tc.exchangeID, MarketType: exchange.MarketSpot, Timestamp: 42}
+			tc.tracker.Normalize([]*messaging.Message{withoutTime, withTime}, receivedAt)
+
+			if withoutTime.ReceivedAt != received || withoutTime.ClockOffset != tc.offset {
+				t.Errorf("received %d offset %d, want %d, %d", withoutTime.ReceivedAt, withoutTime.ClockOffset, received, tc.offset)
+			}
+			if withoutTime.Timestamp != received+tc.offset {
+				t.Errorf("Timestamp = %d, want %d", withoutTime.Timestamp, received+tc.offset)
+			}
+			if withTime.Timestamp != 42 || withTime.ClockOffset != tc.offset {
+				t.Errorf("message with exchange time: timestamp %d offset %d", withTime.Timestamp, withTime.ClockOffset)
+			}
+		})
+	}
+
+	// Биржа без ServerClock и nil трекер не регистрируются
+	tracker.Track("unknown", exchange.MarketSpot, nil)
+	tracker.mu.RLock()
+	_, tracked := tracker.sources[sourceKey("unknown", exchange.MarketSpot)]
+	tracker.mu.RUnlock()
+	if tracked {
+		t.Error("Track registered a source without ServerClock")
+	}
+	var none *Tracker
+	none.Track(exchange.Binance, exchange.MarketSpot, f)
+	none.Close()
+	if _, ok := none.Estimate(exchange.Binance, exchange.MarketSpot); ok {
+		t.Error("nil tracker has an estimate")
+	}
+}
@@ -0,0 +1,207 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// ============================================================================
+// Аналитика книги ордеров: цена исполнения, проскальзывание, глубина, дисбаланс
+// ============================================================================
+
+// Все расчеты идут по уровням книги как есть: рыночный ордер покупки проходит
+// asks от лучшей цены, продажи - bids. Книга должна быть валидной
+// (orderbook.Manager.GetOrderBook возвращает nil для невалидной).
+
+var (
+	// ErrEmptyBook - на нужной стороне книги нет уровней
+	ErrEmptyBook = errors.New("order book side is empty")
+	// ErrInsufficientLiquidity - объема книги не хватает для ордера
+	ErrInsufficientLiquidity = errors.New("insufficient order book liquidity")
+	// ErrSlippageExceeded - ожидаемое проскальзывание больше допустимого
+	ErrSlippageExceeded = errors.New("expected slippage exceeds limit")
+	// ErrAmountExceeded - стоимость ордера больше допустимой для задачи
+	ErrAmountExceeded = errors.New("order amount exceeds limit")
+)
+
+var (
+	hundred  = decimal.NewFromInt(100)
+	bpsScale = decimal.NewFromInt(10000)
+)
+
+// Fill - оценка исполнения рыночного ордера по книге
+type Fill struct {
+	// Quantity - исполненный объем (меньше запрошенного при ErrInsufficientLiquidity)
+	Quantity decimal.Decimal
+	// Notional - стоимость исполненного объема в котируемом активе
+	Notional decimal.Decimal
+	// AvgPrice - средняя цена исполнения (VWAP), Notional / Quantity
+	AvgPrice decimal.Decimal
+	// WorstPrice - цена последнего задетого уровня
+	WorstPrice decimal.Decimal
+	// Levels - сколько уровней задето
+	Levels int
+}
+
+// Mid возвращает среднюю цену между лучшими bid и ask, false если сторона пуста
+func (b *OrderBook) Mid() (decimal.Decimal, bool) {
+	if len(b.Bids) == 0 || len(b.Asks) == 0 {
+		return decimal.Zero, false
+	}
+	return b.Bids[0].Price.Add(b.Asks[0].Price).Div(decimal.NewFromInt(2)), true
+}
+
+// takerSide возвращает уровни, по которым исполняется рыночный ордер стороны side
+func (b *OrderBook) takerSide(side string) ([]Level, error) {
+	switch side {
+	case SideBuy:
+		return b.Asks, nil
+	case SideSell:
+		return b.Bids, nil
+	default:
+		return nil, fmt.Errorf("invalid order side %q", side)
+	}
+}
+
+// VWAP оценивает исполнение рыночного ордера side на quantity базового актива
+// Если объема книги не хватает, возвращает частичное исполнение и ErrInsufficientLiquidity
+func (b *OrderBook) VWAP(side string, quantity decimal.Decimal) (Fill, error) {
+	levels, err := b.takerSide(side)
+	if err != nil {
+		return Fill{}, err
+	}
+	if !quantity.IsPositive() {
+		return Fill{}, fmt.Errorf("invalid quantity %s", quantity)
+	}
+	if len(levels) == 0 {
+		return Fill{}, fmt.Errorf("%w: %s %s %s", ErrEmptyBook, b.ExchangeID, b.Pair, side)
+	}
+
+	var fill Fill
+	remaining := quantity
+	for _, level := range levels {
+		if !remaining.IsPositive() {
+			break
+		}
+		take := decimal.Min(remaining, level.Amount)
+		fill.Quantity = fill.Quantity.Add(take)
+		fill.Notional = fill.Notional.Add(take.Mul(level.Price))
+		fill.WorstPrice = level.Price
+		fill.Levels++
+		remaining = remaining.Sub(take)
+	}
+	if fill.Quantity.IsPositive() {
+		fill.AvgPrice = fill.Notional.Div(fill.Quantity)
+	}
+
+	if remaining.IsPositive() {
+		return fill, fmt.Errorf("%w: %s %s %s %s of %s available", ErrInsufficientLiquidity,
+			b.ExchangeID, b.Pair, side, fill.Quantity, quantity)
+	}
+	return fill, nil
+}
+
+// SlippagePercent возвращает ожидаемое проскальзывание рыночного ордера в процентах от mid:
+// насколько средняя цена исполнения хуже средней цены книги (всегда >= 0 для валидной книги)
+func (b *OrderBook) SlippagePercent(side string, quantity decimal.Decimal) (decimal.Decimal, error) {
+	mid, ok := b.Mid()
+	if !ok {
+		return decimal.Zero, fmt.Errorf("%w: %s %s", ErrEmptyBook, b.ExchangeID, b.Pair)
+	}
+	fill, err := b.VWAP(side, quantity)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return slippagePercent(side, fill.AvgPrice, mid), nil
+}
+
+func slippagePercent(side string, price, mid decimal.Decimal) decimal.Decimal {
+	diff := price.Sub(mid)
+	if side == SideSell {
+		diff = diff.Neg()
+	}
+	return diff.Div(mid).Mul(hundred)
+}
+
+// DepthWithin возвращает суммарный объем стороны книги в пределах bps базисных пунктов от mid
+// (bids не ниже mid - bps, asks не выше mid + bps) и его стоимость в котируемом активе
+// side - сторона книги: SideBuy = bids, SideSell = asks
+func (b *OrderBook) DepthWithin(side string, bps decimal.Decimal) (amount, notional decimal.Decimal, err error) {
+	mid, ok := b.Mid()
+	if !ok {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("%w: %s %s", ErrEmptyBook, b.ExchangeID, b.Pair)
+	}
+	offset := mid.Mul(bps).Div(bpsScale)
+
+	var levels []Level
+	var inRange func(price decimal.Decimal) bool
+	switch side {
+	case SideBuy:
+		levels, inRange = b.Bids, func(price decimal.Decimal) bool { return price.GreaterThanOrEqual(mid.Sub(offset)) }
+	case SideSell:
+		levels, inRange = b.Asks, func(price decimal.Decimal) bool { return price.LessThanOrEqual(mid.Add(offset)) }
+	default:
+		return decimal.Zero, decimal.Zero, fmt.Errorf("invalid book side %q", side)
+	}
+
+	for _, level := range levels {
+		if !inRange(level.Price) {
+			break
+		}
+		amount = amount.Add(level.Amount)
+		notional = notional.Add(level.Amount.Mul(level.Price))
+	}
+	return amount, notional, nil
+}
+
+// Imbalance возвращает дисбаланс объемов лучших levels уровней (levels <= 0 - всей книги):
+// (bids - asks) / (bids + asks) от -1 (только продавцы) до 1 (только покупатели)
+func (b *OrderBook) Imbalance(levels int) decimal.Decimal {
+	bids := sumAmount(b.Bids, levels)
+	asks := sumAmount(b.Asks, levels)
+	total := bids.Add(asks)
+	if total.IsZero() {
+		return decimal.Zero
+	}
+	return bids.Sub(asks).Div(total)
+}
+
+func sumAmount(levels []Level, limit int) decimal.Decimal {
+	if limit > 0 && len(levels) > limit {
+		levels = levels[:limit]
+	}
+	total := decimal.Zero
+	for _, level := range levels {
+		total = total.Add(level.Amount)
+	}
+	return total
+}
+
+// CheckOrder проверяет рыночный ордер перед отправкой по реальной ликвидности книги:
+// стоимость не больше maxNotional (MAX_AMOUNT_TRADE, в котируемом активе)
+// и проскальзывание от mid не больше maxSlippagePercent (SLIPPAGE_PERCENT)
+// Нулевой лимит не проверяется. Возвращает оценку исполнения
+func (b *OrderBook) CheckOrder(side string, quantity, maxNotional, maxSlippagePercent decimal.Decimal) (Fill, error) {
+	mid, ok := b.Mid()
+	if !ok {
+		return Fill{}, fmt.Errorf("%w: %s %s", ErrEmptyBook, b.ExchangeID, b.Pair)
+	}
+	fill, err := b.VWAP(side, quantity)
+	if err != nil {
+		return fill, err
+	}
+
+	if maxNotional.IsPositive() && fill.Notional.GreaterThan(maxNotional) {
+		return fill, fmt.Errorf("%w: %s %s notional %s, limit %s", ErrAmountExceeded,
+			b.ExchangeID, b.Pair, fill.Notional.StringFixed(8), maxNotional)
+	}
+	if maxSlippagePercent.IsPositive() {
+		if slippage := slippagePercent(side, fill.AvgPrice, mid); slippage.GreaterThan(maxSlippagePercent) {
+			return fill, fmt.Errorf("%w: %s %s %s %s slippage %s%%, limit %s%%", ErrSlippageExceeded,
+				b.ExchangeID, b.Pair, side, quantity, slippage.StringFixed(4), maxSlippagePercent)
+		}
+	}
+	return fill, nil
+}
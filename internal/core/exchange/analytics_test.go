@@ -0,0 +1,214 @@
+package exchange
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// side собирает уровни стороны книги из пар "цена", "объем"
+func side(values ...string) []Level {
+	levels := make([]Level, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		levels = append(levels, Level{Price: dec(values[i]), Amount: dec(values[i+1])})
+	}
+	return levels
+}
+
+func book(bids, asks []Level) *OrderBook {
+	return &OrderBook{ExchangeID: Binance, Pair: "BTC/USDT", MarketType: MarketSpot, Bids: bids, Asks: asks}
+}
+
+func TestVWAP(t *testing.T) {
+	full := book(side("100", "1", "99", "2", "98", "3"), side("101", "1.5", "102", "1", "105", "2"))
+
+	for _, tc := range []struct {
+		name     string
+		book     *OrderBook
+		side     string
+		quantity string
+		want     Fill
+		err      error
+		invalid  bool
+	}{
+		{
+			name: "inside best level", book: full, side: SideBuy, quantity: "1",
+			want: Fill{Quantity: dec("1"), Notional: dec("101"), AvgPrice: dec("101"), WorstPrice: dec("101"), Levels: 1},
+		},
+		{
+			name: "across two levels", book: full, side: SideBuy, quantity: "2",
+			want: Fill{Quantity: dec("2"), Notional: dec("202.5"), AvgPrice: dec("101.25"), WorstPrice: dec("102"), Levels: 2},
+		},
+		{
+			name: "exactly two levels", book: full, side: SideBuy, quantity: "2.5",
+			want: Fill{Quantity: dec("2.5"), Notional: dec("253.5"), AvgPrice: dec("101.4"), WorstPrice: dec("102"), Levels: 2},
+		},
+		{
+			name: "sell repeating average", book: full, side: SideSell, quantity: "3",
+			want: Fill{Quantity: dec("3"), Notional: dec("298"), AvgPrice: dec("298").Div(dec("3")), WorstPrice: dec("99"), Levels: 2},
+		},
+		{
+			name: "amount larger than book", book: full, side: SideBuy, quantity: "10",
+			want: Fill{Quantity: dec("4.5"), Notional: dec("463.5"), AvgPrice: dec("103"), WorstPrice: dec("105"), Levels: 3},
+			err:  ErrInsufficientLiquidity,
+		},
+		{
+			// float64: 0.1 + 0.2 = 0.30000000000000004
+			name: "decimal precision", book: book(nil, side("0.3", "0.1", "0.3000001", "0.2")), side: SideBuy, quantity: "0.3",
+			want: Fill{Quantity: dec("0.3"), Notional: dec("0.09000002"), AvgPrice: dec("0.09000002").Div(dec("0.3")), WorstPrice: dec("0.3000001"), Levels: 2},
+		},
+		{
+			name: "tiny quantity", book: full, side: SideSell, quantity: "0.00000001",
+			want: Fill{Quantity: dec("0.00000001"), Notional: dec("0.000001"), AvgPrice: dec("100"), WorstPrice: dec("100"), Levels: 1},
+		},
+		{name: "empty asks", book: book(side("100", "1"), nil), side: SideBuy, quantity: "1", err: ErrEmptyBook},
+		{name: "empty bids", book: book(nil, side("101", "1")), side: SideSell, quantity: "1", err: ErrEmptyBook},
+		{name: "zero quantity", book: full, side: SideBuy, quantity: "0", invalid: true},
+		{name: "negative quantity", book: full, side: SideBuy, quantity: "-1", invalid: true},
+		{name: "invalid side", book: full, side: "hold", quantity: "1", invalid: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fill, err := tc.book.VWAP(tc.side, dec(tc.quantity))
+			switch {
+			case tc.invalid:
+				if err == nil {
+					t.Fatalf("VWAP(%s, %s) = %+v, want error", tc.side, tc.quantity, fill)
+				}
+				return
+			case tc.err != nil:
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("VWAP(%s, %s) error = %v, want %v", tc.side, tc.quantity, err, tc.err)
+				}
+			case err != nil:
+				t.Fatalf("VWAP(%s, %s): %v", tc.side, tc.quantity, err)
+			}
+
+			if !fill.Quantity.Equal(tc.want.Quantity) || !fill.Notional.Equal(tc.want.Notional) ||
+				!fill.AvgPrice.Equal(tc.want.AvgPrice) || !fill.WorstPrice.Equal(tc.want.WorstPrice) ||
+				fill.Levels != tc.want.Levels {
+				t.Errorf("VWAP(%s, %s) = %+v, want %+v", tc.side, tc.quantity, fill, tc.want)
+			}
+		})
+	}
+}
+
+func TestSlippagePercent(t *testing.T) {
+	// mid = 100
+	full := book(side("99", "1", "98", "1"), side("101", "1", "103", "1"))
+
+	for _, tc := range []struct {
+		name     string
+		book     *OrderBook
+		side     string
+		quantity string
+		want     string
+		err      error
+	}{
+		{name: "buy best level", book: full, side: SideBuy, quantity: "1", want: "1"},
+		{name: "buy two levels", book: full, side: SideBuy, quantity: "2", want: "2"},
+		{name: "sell two levels", book: full, side: SideSell, quantity: "2", want: "1.5"},
+		{name: "partial level", book: full, side: SideSell, quantity: "0.5", want: "1"},
+		{name: "amount larger than book", book: full, side: SideBuy, quantity: "3", err: ErrInsufficientLiquidity},
+		{name: "empty bids", book: book(nil, side("101", "1")), side: SideBuy, quantity: "1", err: ErrEmptyBook},
+		{name: "empty asks", book: book(side("99", "1"), nil), side: SideSell, quantity: "1", err: ErrEmptyBook},
+		{
+			// mid = 27000.005: результат не округляется до float64
+			name: "decimal precision", book: book(side("27000", "1"), side("27000.01", "1")), side: SideBuy, quantity: "1",
+			want: dec("0.005").Div(dec("27000.005")).Mul(dec("100")).String(),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.book.SlippagePercent(tc.side, dec(tc.quantity))
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("SlippagePercent(%s, %s) error = %v, want %v", tc.side, tc.quantity, err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SlippagePercent(%s, %s): %v", tc.side, tc.quantity, err)
+			}
+			if !got.Equal(dec(tc.want)) {
+				t.Errorf("SlippagePercent(%s, %s) = %s, want %s", tc.side, tc.quantity, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDepthWithin(t *testing.T) {
+	// mid = 100, 100 bps = 1
+	full := book(side("99.5", "1", "99", "2", "98", "3"), side("100.5", "1", "101", "2", "102", "3"))
+
+	for _, tc := range []struct {
+		name     string
+		book     *OrderBook
+		side     string
+		bps      string
+		amount   string
+		notional string
+		err      error
+		invalid  bool
+	}{
+		{name: "bids up to boundary", book: full, side: SideBuy, bps: "100", amount: "3", notional: "297.5"},
+		{name: "asks up to boundary", book: full, side: SideSell, bps: "100", amount: "3", notional: "302.5"},
+		{name: "whole book", book: full, side: SideBuy, bps: "250", amount: "6", notional: "591.5"},
+		{name: "nothing within range", book: full, side: SideSell, bps: "10", amount: "0", notional: "0"},
+		{name: "zero bps", book: full, side: SideBuy, bps: "0", amount: "0", notional: "0"},
+		{
+			// 1 bps от mid 100 = 0.01: граница считается без ошибок float64
+			name: "fractional bps", book: book(side("99.99", "0.1", "99.98", "0.2"), side("100.01", "0.3")),
+			side: SideBuy, bps: "1", amount: "0.1", notional: "9.999",
+		},
+		{name: "empty bids", book: book(nil, side("101", "1")), side: SideSell, bps: "100", err: ErrEmptyBook},
+		{name: "empty asks", book: book(side("99", "1"), nil), side: SideBuy, bps: "100", err: ErrEmptyBook},
+		{name: "invalid side", book: full, side: "both", bps: "100", invalid: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			amount, notional, err := tc.book.DepthWithin(tc.side, dec(tc.bps))
+			switch {
+			case tc.invalid:
+				if err == nil {
+					t.Fatalf("DepthWithin(%s, %s) = %s, want error", tc.side, tc.bps, amount)
+				}
+				return
+			case tc.err != nil:
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("DepthWithin(%s, %s) error = %v, want %v", tc.side, tc.bps, err, tc.err)
+				}
+				return
+			case err != nil:
+				t.Fatalf("DepthWithin(%s, %s): %v", tc.side, tc.bps, err)
+			}
+			if !amount.Equal(dec(tc.amount)) || !notional.Equal(dec(tc.notional)) {
+				t.Errorf("DepthWithin(%s, %s) = %s, %s, want %s, %s", tc.side, tc.bps, amount, notional, tc.amount, tc.notional)
+			}
+		})
+	}
+}
+
+func TestImbalance(t *testing.T) {
+	full := book(side("100", "3", "99", "1"), side("101", "1", "102", "5"))
+
+	for _, tc := range []struct {
+		name   string
+		book   *OrderBook
+		levels int
+		want   decimal.Decimal
+	}{
+		{name: "best level", book: full, levels: 1, want: dec("0.5")},
+		{name: "whole book", book: full, levels: 0, want: dec("-0.2")},
+		{name: "levels beyond book", book: full, levels: 10, want: dec("-0.2")},
+		{name: "only bids", book: book(side("100", "1"), nil), levels: 0, want: dec("1")},
+		{name: "only asks", book: book(nil, side("101", "1")), levels: 0, want: dec("-1")},
+		{name: "empty book", book: book(nil, nil), levels: 0, want: decimal.Zero},
+		{name: "balanced", book: book(side("100", "0.1", "99", "0.2"), side("101", "0.3")), levels: 0, want: decimal.Zero},
+		{name: "repeating fraction", book: book(side("100", "2"), side("101", "1")), levels: 0, want: dec("1").Div(dec("3"))},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.book.Imbalance(tc.levels); !got.Equal(tc.want) {
+				t.Errorf("Imbalance(%d) = %s, want %s", tc.levels, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"trader/internal/core/messaging"
+)
+
+// ErrUnsupportedMarket - драйвер не поддерживает запрошенный тип рынка
+var ErrUnsupportedMarket = errors.New("market type is not supported by exchange driver")
+
+// ============================================================================
+// ExchangeDriver - протокол market-data конкретной биржи
+// ============================================================================
+
+// ExchangeDriver описывает всё, что нужно WS пулу для работы с биржей:
+// куда подключаться, как подписаться/отписаться и как разобрать входящий фрейм
+// Реализации живут в internal/core/exchange/drivers/<exchange> и регистрируются через Register
+// Драйвер не держит соединений и должен быть безопасен для конкурентного использования
+type ExchangeDriver interface {
+	// GetExchangeID возвращает ID биржи (одна из констант Binance, Bybit, ...)
+	GetExchangeID() string
+
+	// GetName возвращает читаемое название биржи для логов
+	GetName() string
+
+	// GetWSEndpoint возвращает публичный WS URL для типа рынка
+	// Для неподдерживаемого рынка возвращает ErrUnsupportedMarket
+	GetWSEndpoint(marketType string) (string, error)
+
+	// CreateSubscribeMessage строит фреймы подписки на пары в формате "BTC/USDT"
+	// Несколько фреймов возвращается если биржа ограничивает размер одного запроса
+	CreateSubscribeMessage(marketType string, pairs []string, depth int) ([][]byte, error)
+
+	// CreateUnsubscribeMessage строит фреймы отписки
+	// depth передается тот же, что был при подписке (входит в имя канала у многих бирж)
+	CreateUnsubscribeMessage(marketType string, pairs []string, depth int) ([][]byte, error)
+
+	// ParseMessage разбирает входящий фрейм в единый формат
+	// Служебные фреймы (ack подписки, pong) возвращают пустой список без ошибки
+	ParseMessage(marketType string, data []byte) ([]*messaging.Message, error)
+}
+
+// ============================================================================
+// Опциональные возможности драйверов
+// ============================================================================
+
+// SnapshotProvider - драйвер умеет загружать полный снимок книги ордеров по REST
+// Нужен биржам с delta-потоками: снимок задает начальное состояние и SeqNum
+type SnapshotProvider interface {
+	// FetchOrderBookSnapshot возвращает сообщение TypeOrderBook с Snapshot = true
+	FetchOrderBookSnapshot(ctx context.Context, marketType, pair string, depth int) (*messaging.Message, error)
+}
+
+// SeqAlignment - результат сверки delta-обновления с состоянием книги
+type SeqAlignment int
+
+const (
+	// SeqApply - дельта продолжает последовательность, ее нужно применить
+	SeqApply SeqAlignment = iota
+	// SeqStale - дельта уже учтена в снимке или дублируется, ее нужно пропустить
+	SeqStale
+	// SeqGap - пропущены обновления, книга невалидна до нового снимка
+	SeqGap
+)
+
+// SequenceAligner - драйвер знает правила нумерации delta-обновлений своей биржи
+type SequenceAligner interface {
+	// AlignDelta сверяет дельту с последним примененным SeqNum
+	// afterSnapshot = true если lastSeqNum взят из снимка и дельт еще не применялось
+	AlignDelta(marketType string, lastSeqNum int64, afterSnapshot bool, delta *messaging.Message) SeqAlignment
+}
+
+// FrameDecoder - биржа присылает сжатые или бинарные фреймы (HTX gzip, CoinEx deflate)
+// Пул вызывает DecodeFrame до ReplyTo и ParseMessage, так что они получают уже распакованные данные
+type FrameDecoder interface {
+	DecodeFrame(data []byte) ([]byte, error)
+}
+
+// Pinger - биржа требует keepalive от клиента (OKX "ping", Bybit {"op":"ping"}, KuCoin {"type":"ping"})
+// Пул отправляет ping каждые PingInterval вне очереди лимитеров и по ответу измеряет RTT соединения
+// Серверные ping обслуживаются отдельно: WS ping control frame (Binance) - автоматически,
+// JSON ping (HTX) - через FrameResponder, RTT по ним оценивается через ServerPinger
+type Pinger interface {
+	// PingInterval - период отправки ping, 0 = клиентский ping на этом рынке не нужен
+	PingInterval(marketType string) time.Duration
+	// PingFrame строит ping фрейм, nil = WS ping control frame (ответ - pong control frame)
+	PingFrame(marketType string) []byte
+	// IsPong сообщает, что фрейм - ответ на ping, такие фреймы не передаются в ParseMessage
+	IsPong(marketType string, data []byte) bool
+}
+
+// ServerClock - биржа отдает серверное время по REST
+// По нему оценивается расхождение часов биржи с локальными, чтобы время событий
+// разных бирж было сравнимым
+type ServerClock interface {
+	// ServerTime возвращает время сервера биржи в Unix микросекундах
+	ServerTime(ctx context.Context, marketType string) (int64, error)
+}
+
+// FrameResponder - биржа требует ответа на служебные фреймы сервера (HTX {"ping"} -> {"pong"})
+type FrameResponder interface {
+	// ReplyTo возвращает фрейм ответа или nil если ответ не нужен
+	ReplyTo(marketType string, data []byte) []byte
+}
+
+// ServerPinger - сервер сам присылает ping с меткой своего времени (HTX {"ping": N})
+// По метке пул оценивает RTT соединения, как по ответу на клиентский ping
+type ServerPinger interface {
+	// ServerPingTime возвращает время отправки серверного ping в Unix микросекундах,
+	// false если фрейм не ping
+	ServerPingTime(marketType string, data []byte) (int64, bool)
+}
+
+// EndpointResolver - WS URL нельзя получить без запроса к бирже (KuCoin bullet token)
+// Пул вызывает ResolveWSEndpoint при каждом подключении вместо GetWSEndpoint
+type EndpointResolver interface {
+	ResolveWSEndpoint(ctx context.Context, marketType string) (string, error)
+}
+
+// ConnectionLimits - ограничения биржи на подписки одного WS соединения
+type ConnectionLimits struct {
+	// MaxStreams - сколько потоков (каналов) биржа разрешает на одном соединении, 0 = без ограничения
+	MaxStreams int
+	// StreamsPerPair - сколько потоков занимает одна подписанная пара (обычно книга + сделки)
+	StreamsPerPair int
+}
+
+// MaxPairs возвращает сколько пар помещается в одно соединение, 0 = без ограничения
+func (l ConnectionLimits) MaxPairs() int {
+	if l.MaxStreams <= 0 {
+		return 0
+	}
+	perPair := l.StreamsPerPair
+	if perPair <= 0 {
+		perPair = 1
+	}
+	if pairs := l.MaxStreams / perPair; pairs > 0 {
+		return pairs
+	}
+	return 1
+}
+
+// ConnectionLimiter - биржа ограничивает число потоков на соединение
+// Пул распределяет подписки по нескольким соединениям так, чтобы не превысить лимит
+type ConnectionLimiter interface {
+	ConnectionLimits(marketType string) ConnectionLimits
+}
+
+// RateLimit - token bucket: PerSecond запросов в секунду с накоплением до Burst
+// Нулевое значение означает отсутствие ограничения
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+// RateLimits - ограничения биржи на исходящие запросы
+type RateLimits struct {
+	// Connection - управляющие фреймы (subscribe/unsubscribe) на одно WS соединение
+	Connection RateLimit
+	// Exchange - управляющие фреймы суммарно по всем соединениям биржи (лимит на IP)
+	Exchange RateLimit
+	// REST - REST запросы драйвера (снимки книги, токены)
+	REST RateLimit
+}
+
+// RateLimited - биржа ограничивает частоту исходящих запросов
+// Пул ставит управляющие фреймы в очередь согласно Connection и Exchange,
+// драйвер сам ограничивает свои REST запросы по REST
+type RateLimited interface {
+	RateLimits() RateLimits
+}
+
+// InstrumentProvider - драйвер загружает параметры торгуемых инструментов рынка по REST
+// (шаг цены, шаг объема, минимальный ордер, размер контракта)
+type InstrumentProvider interface {
+	// FetchInstruments возвращает все торгуемые инструменты рынка
+	FetchInstruments(ctx context.Context, marketType string) ([]Instrument, error)
+}
+
+// SymbolMapper - драйвер переводит пары "BTC/USDT" в символы биржи и обратно
+// (BTCUSDT, BTC-USDT, btcusdt, BTC-USDT-SWAP, XBTUSDTM), учитывая другие имена
+// активов на бирже (XBT вместо BTC)
+type SymbolMapper interface {
+	// Symbol возвращает символ биржи для пары на рынке
+	Symbol(marketType, pair string) (string, error)
+	// Pair возвращает пару "BASE/QUOTE" для символа биржи на рынке
+	Pair(marketType, symbol string) string
+}
+
+// DepthBBO - глубина подписки "только лучшие bid/ask" (без остальной книги и сделок)
+// Драйвер с BBOStreamer подписывается на поток лучших цен биржи, для остальных
+// пул подписывает книгу BBOFallbackDepth уровней, а лучшие цены выводятся из нее
+const DepthBBO = -1
+
+// BBOFallbackDepth - глубина книги для DepthBBO у бирж без потока лучших цен
+// Драйвер выбирает самый мелкий канал книги (Bybit orderbook.1, KuCoin level2Depth5),
+// сделки пары при этом тоже подписываются
+const BBOFallbackDepth = 1
+
+// BBOStreamer - биржа публикует отдельный поток лучших цен (Binance bookTicker, OKX bbo-tbt)
+// Для DepthBBO драйвер строит подписку только на этот поток, а ParseMessage
+// возвращает сообщения messaging.TypeBBO
+type BBOStreamer interface {
+	// SupportsBBO сообщает есть ли поток лучших цен на рынке
+	SupportsBBO(marketType string) bool
+}
+
+// SubscriptionDepth возвращает глубину, с которой пара подписывается у драйвера:
+// DepthBBO заменяется на BBOFallbackDepth, если у биржи нет потока лучших цен
+func SubscriptionDepth(driver ExchangeDriver, marketType string, depth int) int {
+	if depth != DepthBBO {
+		return depth
+	}
+	if streamer, ok := driver.(BBOStreamer); ok && streamer.SupportsBBO(marketType) {
+		return DepthBBO
+	}
+	return BBOFallbackDepth
+}
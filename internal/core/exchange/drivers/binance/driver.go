@@ -0,0 +1,737 @@
+// Package binance реализует market-data драйвер Binance (spot и USDT-M futures)
+//
+// Используется combined stream endpoint (/stream): подписки отправляются методом SUBSCRIBE,
+// а каждый входящий фрейм содержит имя потока, из которого восстанавливается символ.
+// Глубина 5/10/20 идет partial book потоком (каждое сообщение - снимок),
+// остальная глубина - diff потоком @depth@100ms с REST снимком и сверкой lastUpdateId.
+// Подписка DepthBBO - только поток @bookTicker (лучшие цены) без сделок.
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/exchange/drivers/common"
+	"trader/internal/core/messaging"
+	"trader/internal/core/ratelimit"
+	"trader/internal/logger"
+)
+
+const (
+	// SpotWSEndpoint - combined stream endpoint спота
+	SpotWSEndpoint = "wss://stream.binance.com:9443/stream"
+	// FuturesWSEndpoint - combined stream endpoint USDT-M фьючерсов
+	FuturesWSEndpoint = "wss://fstream.binance.com/stream"
+	// SpotRESTEndpoint - базовый URL REST API спота
+	SpotRESTEndpoint = "https://api.binance.com"
+	// FuturesRESTEndpoint - базовый URL REST API USDT-M фьючерсов
+	FuturesRESTEndpoint = "https://fapi.binance.com"
+
+	// maxStreamsPerFrame - сколько потоков отправляем в одном SUBSCRIBE
+	maxStreamsPerFrame = 200
+	// maxSpotStreamsPerConnection - spot разрешает не более 1024 потоков на соединение
+	maxSpotStreamsPerConnection = 1024
+	// maxFuturesStreamsPerConnection - USDT-M futures разрешает не более 200 потоков на соединение
+	maxFuturesStreamsPerConnection = 200
+	// defaultSnapshotLimit - глубина REST снимка для полной книги (depth = 0)
+	defaultSnapshotLimit = 1000
+)
+
+// rateLimits - spot принимает не более 5 входящих сообщений в секунду на соединение
+// (futures - 10, берем меньшее). REST: 6000 weight в минуту на IP, снимок limit=1000
+// стоит 50 weight - не больше 2 снимков в секунду с небольшим запасом на пачку
+var rateLimits = exchange.RateLimits{
+	Connection: exchange.RateLimit{PerSecond: 5, Burst: 5},
+	REST:       exchange.RateLimit{PerSecond: 2, Burst: 10},
+}
+
+// snapshotLimits - допустимые значения limit для /depth (общие для spot и futures)
+var snapshotLimits = []int{5, 10, 20, 50, 100, 500, 1000}
+
+// Endpoints - адреса WS и REST API, переопределяются для тестового стенда
+type Endpoints struct {
+	SpotWS      string
+	FuturesWS   string
+	SpotREST    string
+	FuturesREST string
+}
+
+// DefaultEndpoints возвращает боевые адреса Binance
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		SpotWS:      SpotWSEndpoint,
+		FuturesWS:   FuturesWSEndpoint,
+		SpotREST:    SpotRESTEndpoint,
+		FuturesREST: FuturesRESTEndpoint,
+	}
+}
+
+func init() {
+	exchange.Register(exchange.Binance, func() exchange.ExchangeDriver {
+		return New(DefaultEndpoints())
+	})
+}
+
+// Driver - драйвер Binance
+type Driver struct {
+	endpoints Endpoints
+	client    *http.Client
+	restLog   *slog.Logger
+	// restLimit - очередь REST запросов по rateLimits.REST
+	restLimit *ratelimit.Limiter
+
+	requestID atomic.Int64
+
+	// symbols - соответствие символа биржи (BTCUSDT) паре (BTC/USDT)
+	symbols *common.Symbols
+}
+
+// New создает драйвер с указанными адресами
+func New(endpoints Endpoints) *Driver {
+	return &Driver{
+		endpoints: endpoints,
+		client:    common.NewHTTPClient(),
+		restLog:   logger.GetOutRequest("binance"),
+		restLimit: ratelimit.New(rateLimits.REST.PerSecond, rateLimits.REST.Burst),
+		symbols:   common.NewSymbols(symbolFormats),
+	}
+}
+
+// GetExchangeID возвращает ID биржи
+func (d *Driver) GetExchangeID() string {
+	return exchange.Binance
+}
+
+// GetName возвращает название биржи
+func (d *Driver) GetName() string {
+	return "Binance"
+}
+
+// GetWSEndpoint возвращает combined stream endpoint для рынка
+func (d *Driver) GetWSEndpoint(marketType string) (string, error) {
+	switch marketType {
+	case exchange.MarketSpot:
+		return d.endpoints.SpotWS, nil
+	case exchange.MarketFutures:
+		return d.endpoints.FuturesWS, nil
+	default:
+		return "", fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+}
+
+// SupportsBBO - поток <symbol>@bookTicker есть на обоих рынках
+func (d *Driver) SupportsBBO(marketType string) bool {
+	return true
+}
+
+// ConnectionLimits - каждая пара занимает два потока: книга и сделки
+// Лимит потоков на соединение у futures меньше, чем у spot
+func (d *Driver) ConnectionLimits(marketType string) exchange.ConnectionLimits {
+	maxStreams := maxSpotStreamsPerConnection
+	if marketType == exchange.MarketFutures {
+		maxStreams = maxFuturesStreamsPerConnection
+	}
+	return exchange.ConnectionLimits{MaxStreams: maxStreams, StreamsPerPair: 2}
+}
+
+// RateLimits возвращает лимиты Binance на управляющие фреймы и REST
+func (d *Driver) RateLimits() exchange.RateLimits {
+	return rateLimits
+}
+
+// CreateSubscribeMessage строит SUBSCRIBE фреймы для потоков книги и сделок
+func (d *Driver) CreateSubscribeMessage(marketType string, pairs []string, depth int) ([][]byte, error) {
+	return d.buildFrames("SUBSCRIBE", marketType, pairs, depth)
+}
+
+// CreateUnsubscribeMessage строит UNSUBSCRIBE фреймы
+func (d *Driver) CreateUnsubscribeMessage(marketType string, pairs []string, depth int) ([][]byte, error) {
+	return d.buildFrames("UNSUBSCRIBE", marketType, pairs, depth)
+}
+
+type controlFrame struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	ID     int64    `json:"id"`
+}
+
+func (d *Driver) buildFrames(method, marketType string, pairs []string, depth int) ([][]byte, error) {
+	if _, err := d.GetWSEndpoint(marketType); err != nil {
+		return nil, err
+	}
+
+	streams := make([]string, 0, len(pairs)*2)
+	for _, pair := range pairs {
+		symbol, err := d.symbols.Symbol(marketType, pair)
+		if err != nil {
+			return nil, err
+		}
+		lower := strings.ToLower(symbol)
+		if depth == exchange.DepthBBO {
+			streams = append(streams, lower+"@bookTicker")
+			continue
+		}
+		streams = append(streams, depthStream(lower, depth), tradeStream(lower, marketType))
+	}
+
+	var frames [][]byte
+	for _, chunk := range common.Chunk(streams, maxStreamsPerFrame) {
+		frame, err := json.Marshal(controlFrame{
+			Method: method,
+			Params: chunk,
+			ID:     d.requestID.Add(1),
+		})
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// depthStream выбирает поток книги: partial book для 5/10/20 уровней, иначе diff поток
+func depthStream(symbol string, depth int) string {
+	switch {
+	case depth > 0 && depth <= 5:
+		return symbol + "@depth5@100ms"
+	case depth > 5 && depth <= 10:
+		return symbol + "@depth10@100ms"
+	case depth > 10 && depth <= 20:
+		return symbol + "@depth20@100ms"
+	default:
+		return symbol + "@depth@100ms"
+	}
+}
+
+// tradeStream - на фьючерсах публичные сделки идут только агрегированными
+func tradeStream(symbol, marketType string) string {
+	if marketType == exchange.MarketFutures {
+		return symbol + "@aggTrade"
+	}
+	return symbol + "@trade"
+}
+
+// symbolFormats - слитный символ BTCUSDT на обоих рынках (в именах потоков - в нижнем регистре)
+var symbolFormats = map[string]common.SymbolFormat{
+	exchange.MarketSpot:    {},
+	exchange.MarketFutures: {},
+}
+
+// Symbol переводит "BTC/USDT" в "BTCUSDT"
+func (d *Driver) Symbol(marketType, pair string) (string, error) {
+	return d.symbols.Symbol(marketType, pair)
+}
+
+// Pair переводит символ Binance обратно в "BTC/USDT"
+func (d *Driver) Pair(marketType, symbol string) string {
+	return d.symbols.Pair(marketType, symbol)
+}
+
+// ============================================================================
+// Разбор входящих фреймов
+// ============================================================================
+
+type combinedFrame struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+	Error  *struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	} `json:"error"`
+}
+
+// depthPayload покрывает partial book спота, partial/diff book фьючерсов и diff book спота
+// encoding/json сопоставляет ключи без учета регистра, поэтому "e" и "E", "u" и "U"
+// объявлены отдельными полями
+type depthPayload struct {
+	EventType string `json:"e"`
+
+	LastUpdateID int64      `json:"lastUpdateId"`
+	Bids         [][]string `json:"bids"`
+	Asks         [][]string `json:"asks"`
+
+	EventTime     int64      `json:"E"`
+	Symbol        string     `json:"s"`
+	FirstUpdateID int64      `json:"U"`
+	FinalUpdateID int64      `json:"u"`
+	PrevUpdateID  int64      `json:"pu"`
+	BidDeltas     [][]string `json:"b"`
+	AskDeltas     [][]string `json:"a"`
+}
+
+// bookTickerPayload - лучшие цены; у спота нет времени события, только update id
+type bookTickerPayload struct {
+	UpdateID  int64  `json:"u"`
+	EventTime int64  `json:"E"`
+	Symbol    string `json:"s"`
+	BidPrice  string `json:"b"`
+	BidQty    string `json:"B"`
+	AskPrice  string `json:"a"`
+	AskQty    string `json:"A"`
+}
+
+type tradePayload struct {
+	EventType    string `json:"e"`
+	EventTime    int64  `json:"E"`
+	Symbol       string `json:"s"`
+	TradeID      int64  `json:"t"`
+	AggTradeID   int64  `json:"a"`
+	Price        string `json:"p"`
+	Quantity     string `json:"q"`
+	TradeTime    int64  `json:"T"`
+	IsBuyerMaker bool   `json:"m"`
+	BestMatch    bool   `json:"M"`
+}
+
+// ParseMessage разбирает фрейм combined stream
+func (d *Driver) ParseMessage(marketType string, data []byte) ([]*messaging.Message, error) {
+	var frame combinedFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return nil, fmt.Errorf("decode binance frame: %w", err)
+	}
+
+	// Ответ на SUBSCRIBE/UNSUBSCRIBE: {"result":null,"id":1}
+	if frame.Stream == "" {
+		if frame.Error != nil {
+			return nil, fmt.Errorf("binance error %d: %s", frame.Error.Code, frame.Error.Msg)
+		}
+		return nil, nil
+	}
+
+	symbol, kind, _ := strings.Cut(frame.Stream, "@")
+	pair := d.symbols.Pair(marketType, symbol)
+
+	switch {
+	case kind == "trade" || kind == "aggTrade":
+		msg, err := parseTrade(marketType, pair, kind, frame.Data)
+		if err != nil {
+			return nil, err
+		}
+		return []*messaging.Message{msg}, nil
+	case strings.HasPrefix(kind, "depth"):
+		msg, err := parseDepth(marketType, pair, kind, frame.Data)
+		if err != nil {
+			return nil, err
+		}
+		return []*messaging.Message{msg}, nil
+	case kind == "bookTicker":
+		msg, err := parseBookTicker(marketType, pair, frame.Data)
+		if err != nil {
+			return nil, err
+		}
+		return []*messaging.Message{msg}, nil
+	default:
+		return nil, fmt.Errorf("unsupported binance stream %q", frame.Stream)
+	}
+}
+
+func parseDepth(marketType, pair, kind string, raw json.RawMessage) (*messaging.Message, error) {
+	var payload depthPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("decode binance depth: %w", err)
+	}
+
+	// Partial book: "depth5@100ms", "depth20@100ms"; diff book: "depth@100ms"
+	levels := strings.TrimPrefix(strings.SplitN(kind, "@", 2)[0], "depth")
+	partial := levels != ""
+
+	bidsRaw, asksRaw := payload.Bids, payload.Asks
+	if bidsRaw == nil && asksRaw == nil {
+		bidsRaw, asksRaw = payload.BidDeltas, payload.AskDeltas
+	}
+	bids, err := common.ParseLevels(bidsRaw)
+	if err != nil {
+		return nil, err
+	}
+	asks, err := common.ParseLevels(asksRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	book := &messaging.OrderBookData{
+		Bids:        bids,
+		Asks:        asks,
+		Snapshot:    partial,
+		FirstSeqNum: payload.FirstUpdateID,
+		PrevSeqNum:  payload.PrevUpdateID,
+	}
+
+	seqNum := payload.FinalUpdateID
+	if partial {
+		if _, err := fmt.Sscanf(levels, "%d", &book.Depth); err != nil {
+			return nil, fmt.Errorf("invalid binance depth stream %q", kind)
+		}
+		// Partial book спота не содержит E/U/u, только lastUpdateId
+		if seqNum == 0 {
+			seqNum = payload.LastUpdateID
+		}
+		book.FirstSeqNum = seqNum
+	}
+
+	timestamp := common.MillisToMicros(payload.EventTime)
+	if timestamp == 0 {
+		timestamp = common.NowMicros()
+	}
+
+	return &messaging.Message{
+		Timestamp:  timestamp,
+		ExchangeID: exchange.Binance,
+		MarketType: marketType,
+		Type:       messaging.TypeOrderBook,
+		Pair:       pair,
+		SeqNum:     seqNum,
+		OrderBook:  book,
+	}, nil
+}
+
+func parseBookTicker(marketType, pair string, raw json.RawMessage) (*messaging.Message, error) {
+	var payload bookTickerPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("decode binance bookTicker: %w", err)
+	}
+
+	bbo, err := common.ParseBBO(payload.BidPrice, payload.BidQty, payload.AskPrice, payload.AskQty)
+	if err != nil {
+		return nil, fmt.Errorf("binance bookTicker: %w", err)
+	}
+
+	timestamp := common.MillisToMicros(payload.EventTime)
+	if timestamp == 0 {
+		timestamp = common.NowMicros()
+	}
+
+	return &messaging.Message{
+		Timestamp:  timestamp,
+		ExchangeID: exchange.Binance,
+		MarketType: marketType,
+		Type:       messaging.TypeBBO,
+		Pair:       pair,
+		SeqNum:     payload.UpdateID,
+		BBO:        bbo,
+	}, nil
+}
+
+func parseTrade(marketType, pair, kind string, raw json.RawMessage) (*messaging.Message, error) {
+	var payload tradePayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("decode binance trade: %w", err)
+	}
+
+	price, err := common.ParseDecimal(payload.Price)
+	if err != nil {
+		return nil, fmt.Errorf("invalid binance trade price %q: %w", payload.Price, err)
+	}
+	amount, err := common.ParseDecimal(payload.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("invalid binance trade quantity %q: %w", payload.Quantity, err)
+	}
+
+	// m = true: покупатель был мейкером, значит инициатор - продавец
+	side := "buy"
+	if payload.IsBuyerMaker {
+		side = "sell"
+	}
+
+	tradeID := payload.TradeID
+	if kind == "aggTrade" {
+		tradeID = payload.AggTradeID
+	}
+
+	return &messaging.Message{
+		Timestamp:  common.MillisToMicros(payload.TradeTime),
+		ExchangeID: exchange.Binance,
+		MarketType: marketType,
+		Type:       messaging.TypeTrade,
+		Pair:       pair,
+		SeqNum:     tradeID,
+		Trade: &messaging.TradeData{
+			Price:   price,
+			Amount:  amount,
+			Side:    side,
+			TradeID: fmt.Sprintf("%d", tradeID),
+		},
+	}, nil
+}
+
+// ============================================================================
+// REST снимок и сверка последовательности
+// ============================================================================
+
+type snapshotResponse struct {
+	LastUpdateID int64      `json:"lastUpdateId"`
+	EventTime    int64      `json:"E"`
+	Bids         [][]string `json:"bids"`
+	Asks         [][]string `json:"asks"`
+}
+
+// FetchOrderBookSnapshot загружает снимок книги через /api/v3/depth или /fapi/v1/depth
+// SeqNum снимка = lastUpdateId, с ним сверяются дельты в AlignDelta
+func (d *Driver) FetchOrderBookSnapshot(ctx context.Context, marketType, pair string, depth int) (*messaging.Message, error) {
+	symbol, err := d.symbols.Symbol(marketType, pair)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoint string
+	switch marketType {
+	case exchange.MarketSpot:
+		endpoint = d.endpoints.SpotREST + "/api/v3/depth"
+	case exchange.MarketFutures:
+		endpoint = d.endpoints.FuturesREST + "/fapi/v1/depth"
+	default:
+		return nil, fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+
+	query := url.Values{}
+	query.Set("symbol", symbol)
+	query.Set("limit", fmt.Sprintf("%d", snapshotLimit(depth)))
+
+	var resp snapshotResponse
+	if err := common.GetJSON(ctx, d.client, d.restLog, d.restLimit, endpoint+"?"+query.Encode(), &resp); err != nil {
+		return nil, err
+	}
+
+	bids, err := common.ParseLevels(resp.Bids)
+	if err != nil {
+		return nil, err
+	}
+	asks, err := common.ParseLevels(resp.Asks)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := common.MillisToMicros(resp.EventTime)
+	if timestamp == 0 {
+		timestamp = common.NowMicros()
+	}
+
+	return &messaging.Message{
+		Timestamp:  timestamp,
+		ExchangeID: exchange.Binance,
+		MarketType: marketType,
+		Type:       messaging.TypeOrderBook,
+		Pair:       pair,
+		SeqNum:     resp.LastUpdateID,
+		OrderBook: &messaging.OrderBookData{
+			Bids:        bids,
+			Asks:        asks,
+			Depth:       depth,
+			Snapshot:    true,
+			FirstSeqNum: resp.LastUpdateID,
+		},
+	}, nil
+}
+
+// snapshotLimit подбирает минимальный допустимый limit не меньше depth
+func snapshotLimit(depth int) int {
+	if depth <= 0 {
+		return defaultSnapshotLimit
+	}
+	for _, limit := range snapshotLimits {
+		if limit >= depth {
+			return limit
+		}
+	}
+	return defaultSnapshotLimit
+}
+
+// AlignDelta сверяет diff-событие (U = FirstSeqNum, u = SeqNum, pu = PrevSeqNum)
+// с последним примененным update id по правилам Binance:
+//   - spot: первое событие после снимка должно покрывать lastUpdateId+1,
+//     далее каждое U = предыдущий u + 1
+//   - futures: первое событие должно покрывать lastUpdateId (U <= id <= u),
+//     далее каждое pu = предыдущий u
+func (d *Driver) AlignDelta(marketType string, lastSeqNum int64, afterSnapshot bool, delta *messaging.Message) exchange.SeqAlignment {
+	if delta.OrderBook == nil || delta.OrderBook.Snapshot {
+		return exchange.SeqApply
+	}
+	first, final := delta.OrderBook.FirstSeqNum, delta.SeqNum
+
+	if marketType == exchange.MarketFutures {
+		if afterSnapshot {
+			switch {
+			case final < lastSeqNum:
+				return exchange.SeqStale
+			case first <= lastSeqNum:
+				return exchange.SeqApply
+			default:
+				return exchange.SeqGap
+			}
+		}
+		switch {
+		case delta.OrderBook.PrevSeqNum == lastSeqNum:
+			return exchange.SeqApply
+		case final <= lastSeqNum:
+			return exchange.SeqStale
+		default:
+			return exchange.SeqGap
+		}
+	}
+
+	switch {
+	case final <= lastSeqNum:
+		return exchange.SeqStale
+	case afterSnapshot && first <= lastSeqNum+1:
+		return exchange.SeqApply
+	case !afterSnapshot && first == lastSeqNum+1:
+		return exchange.SeqApply
+	default:
+		return exchange.SeqGap
+	}
+}
+
+// pingInterval - как часто отправлять WS ping для измерения RTT
+// Сам keepalive Binance серверный: ping control frame, на который gorilla отвечает pong автоматически
+const pingInterval = 30 * time.Second
+
+// PingInterval - клиентский ping используется только для измерения RTT
+func (d *Driver) PingInterval(marketType string) time.Duration {
+	return pingInterval
+}
+
+// PingFrame - Binance отвечает на WS ping control frame, JSON ping не нужен
+func (d *Driver) PingFrame(marketType string) []byte {
+	return nil
+}
+
+// IsPong - pong приходит control frame и до ParseMessage не доходит
+func (d *Driver) IsPong(marketType string, data []byte) bool {
+	return false
+}
+
+type serverTimeResponse struct {
+	ServerTime int64 `json:"serverTime"`
+}
+
+// ServerTime запрашивает /api/v3/time или /fapi/v1/time
+func (d *Driver) ServerTime(ctx context.Context, marketType string) (int64, error) {
+	var endpoint string
+	switch marketType {
+	case exchange.MarketSpot:
+		endpoint = d.endpoints.SpotREST + "/api/v3/time"
+	case exchange.MarketFutures:
+		endpoint = d.endpoints.FuturesREST + "/fapi/v1/time"
+	default:
+		return 0, fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+
+	var resp serverTimeResponse
+	if err := common.GetJSON(ctx, d.client, d.restLog, d.restLimit, endpoint, &resp); err != nil {
+		return 0, err
+	}
+	if resp.ServerTime == 0 {
+		return 0, fmt.Errorf("binance server time: empty serverTime")
+	}
+	return common.MillisToMicros(resp.ServerTime), nil
+}
+
+// ============================================================================
+// Инструменты
+// ============================================================================
+
+type exchangeInfoResponse struct {
+	Symbols []struct {
+		Symbol       string         `json:"symbol"`
+		Status       string         `json:"status"`
+		BaseAsset    string         `json:"baseAsset"`
+		QuoteAsset   string         `json:"quoteAsset"`
+		ContractType string         `json:"contractType"`
+		Filters      []symbolFilter `json:"filters"`
+	} `json:"symbols"`
+}
+
+// symbolFilter - фильтр символа; у каждого filterType заполнены свои поля
+// Минимальная стоимость: spot NOTIONAL/MIN_NOTIONAL.minNotional, futures MIN_NOTIONAL.notional
+type symbolFilter struct {
+	FilterType  string `json:"filterType"`
+	TickSize    string `json:"tickSize"`
+	StepSize    string `json:"stepSize"`
+	MinQty      string `json:"minQty"`
+	MinNotional string `json:"minNotional"`
+	Notional    string `json:"notional"`
+}
+
+// FetchInstruments загружает /api/v3/exchangeInfo или /fapi/v1/exchangeInfo
+// Возвращаются только торгуемые символы (status TRADING), для фьючерсов - только бессрочные
+func (d *Driver) FetchInstruments(ctx context.Context, marketType string) ([]exchange.Instrument, error) {
+	var endpoint string
+	switch marketType {
+	case exchange.MarketSpot:
+		endpoint = d.endpoints.SpotREST + "/api/v3/exchangeInfo"
+	case exchange.MarketFutures:
+		endpoint = d.endpoints.FuturesREST + "/fapi/v1/exchangeInfo"
+	default:
+		return nil, fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+
+	var resp exchangeInfoResponse
+	if err := common.GetJSON(ctx, d.client, d.restLog, d.restLimit, endpoint, &resp); err != nil {
+		return nil, err
+	}
+
+	instruments := make([]exchange.Instrument, 0, len(resp.Symbols))
+	for _, symbol := range resp.Symbols {
+		if symbol.Status != "TRADING" {
+			continue
+		}
+		if marketType == exchange.MarketFutures && symbol.ContractType != "PERPETUAL" {
+			continue
+		}
+
+		instrument := exchange.Instrument{
+			ExchangeID:   exchange.Binance,
+			MarketType:   marketType,
+			Pair:         symbol.BaseAsset + "/" + symbol.QuoteAsset,
+			Symbol:       symbol.Symbol,
+			ContractSize: decimal.NewFromInt(1),
+		}
+		for _, filter := range symbol.Filters {
+			var err error
+			switch filter.FilterType {
+			case "PRICE_FILTER":
+				instrument.TickSize, err = common.ParseDecimal(filter.TickSize)
+			case "LOT_SIZE":
+				if instrument.StepSize, err = common.ParseDecimal(filter.StepSize); err == nil {
+					instrument.MinQuantity, err = common.ParseDecimal(filter.MinQty)
+				}
+			case "NOTIONAL", "MIN_NOTIONAL":
+				minNotional := filter.MinNotional
+				if minNotional == "" {
+					minNotional = filter.Notional
+				}
+				instrument.MinNotional, err = common.ParseDecimal(minNotional)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid binance %s filter %s: %w", symbol.Symbol, filter.FilterType, err)
+			}
+		}
+
+		d.symbols.Remember(marketType, symbol.Symbol, instrument.Pair)
+		instruments = append(instruments, instrument)
+	}
+	return instruments, nil
+}
+
+// Проверка реализации интерфейсов на этапе компиляции
+var (
+	_ exchange.ExchangeDriver     = (*Driver)(nil)
+	_ exchange.SnapshotProvider   = (*Driver)(nil)
+	_ exchange.SequenceAligner    = (*Driver)(nil)
+	_ exchange.ConnectionLimiter  = (*Driver)(nil)
+	_ exchange.RateLimited        = (*Driver)(nil)
+	_ exchange.Pinger             = (*Driver)(nil)
+	_ exchange.ServerClock        = (*Driver)(nil)
+	_ exchange.InstrumentProvider = (*Driver)(nil)
+	_ exchange.SymbolMapper       = (*Driver)(nil)
+	_ exchange.BBOStreamer        = (*Driver)(nil)
+)
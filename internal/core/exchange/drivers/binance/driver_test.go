@@ -0,0 +1,153 @@
+package binance
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/exchange/drivers/drivertest"
+	"trader/internal/core/messaging"
+)
+
+type (
+	level   = drivertest.Level
+	message = drivertest.Message
+	trade   = drivertest.Trade
+)
+
+func TestParseMessage(t *testing.T) {
+	drivertest.Run(t, New(DefaultEndpoints()), []drivertest.Case{
+		{
+			Name: "subscribe ack", File: "subscribe_ack.json", MarketType: exchange.MarketSpot,
+		},
+		{
+			Name: "spot partial book", File: "spot_depth20.json", MarketType: exchange.MarketSpot,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "BTC/USDT", SeqNum: 55461237811,
+				Snapshot: true, Depth: 20,
+				Bids: []level{{"67234.10", "1.5231"}, {"67234.09", "0.0014"}, {"67233.88", "0.09"}},
+				Asks: []level{{"67234.11", "2.71022"}, {"67234.12", "0.00016"}, {"67234.50", "0.1488"}},
+			}},
+		},
+		{
+			Name: "spot diff book", File: "spot_diff_depth.json", MarketType: exchange.MarketSpot,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "BTC/USDT", Timestamp: 1718102400123000, SeqNum: 55461237820,
+				Bids: []level{{"67234.1", "1.4021"}, {"67233.88", "0"}},
+				Asks: []level{{"67234.11", "2.80022"}},
+			}},
+		},
+		{
+			Name: "spot trade", File: "spot_trade.json", MarketType: exchange.MarketSpot,
+			Want: []message{{
+				Type: messaging.TypeTrade, Pair: "BTC/USDT", Timestamp: 1718102400130000, SeqNum: 3634573001,
+				Trade: &trade{Price: "67234.11", Amount: "0.0015", Side: "buy", ID: "3634573001"},
+			}},
+		},
+		{
+			Name: "futures partial book", File: "futures_depth20.json", MarketType: exchange.MarketFutures,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "ETH/USDT", Timestamp: 1718102400251000, SeqNum: 4812937418120,
+				Snapshot: true, Depth: 20,
+				Bids: []level{{"3512.41", "12.304"}, {"3512.40", "0.518"}},
+				Asks: []level{{"3512.42", "41.877"}, {"3512.43", "2.061"}},
+			}},
+		},
+		{
+			Name: "futures diff book", File: "futures_diff_depth.json", MarketType: exchange.MarketFutures,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "ETH/USDT", Timestamp: 1718102400351000, SeqNum: 4812937419002,
+				Bids: []level{{"3512.41", "11.904"}},
+				Asks: []level{{"3512.42", "0"}, {"3512.44", "5.12"}},
+			}},
+		},
+		{
+			Name: "futures aggregated trade", File: "futures_aggtrade.json", MarketType: exchange.MarketFutures,
+			Want: []message{{
+				Type: messaging.TypeTrade, Pair: "ETH/USDT", Timestamp: 1718102400400000, SeqNum: 2180093127,
+				Trade: &trade{Price: "3512.41", Amount: "0.25", Side: "sell", ID: "2180093127"},
+			}},
+		},
+	})
+}
+
+func TestAlignDeltaSpot(t *testing.T) {
+	driver := New(DefaultEndpoints())
+	// U = 55461237812, u = 55461237820
+	delta := drivertest.Parse(t, driver, exchange.MarketSpot, "spot_diff_depth.json")[0]
+	if delta.OrderBook.FirstSeqNum != 55461237812 {
+		t.Fatalf("FirstSeqNum = %d", delta.OrderBook.FirstSeqNum)
+	}
+
+	drivertest.RunAlign(t, driver, exchange.MarketSpot, delta, []drivertest.Align{
+		{Name: "covers snapshot id", LastSeqNum: 55461237815, AfterSnapshot: true, Want: exchange.SeqApply},
+		{Name: "older than snapshot", LastSeqNum: 55461237820, AfterSnapshot: true, Want: exchange.SeqStale},
+		{Name: "snapshot ahead of stream", LastSeqNum: 55461237800, AfterSnapshot: true, Want: exchange.SeqGap},
+		{Name: "continues previous", LastSeqNum: 55461237811, Want: exchange.SeqApply},
+		{Name: "duplicate", LastSeqNum: 55461237820, Want: exchange.SeqStale},
+		{Name: "overlaps previous", LastSeqNum: 55461237815, Want: exchange.SeqGap},
+		{Name: "missed updates", LastSeqNum: 55461237805, Want: exchange.SeqGap},
+	})
+}
+
+func TestAlignDeltaFutures(t *testing.T) {
+	driver := New(DefaultEndpoints())
+	// U = 4812937418121, u = 4812937419002, pu = 4812937418120
+	delta := drivertest.Parse(t, driver, exchange.MarketFutures, "futures_diff_depth.json")[0]
+	if delta.OrderBook.PrevSeqNum != 4812937418120 {
+		t.Fatalf("PrevSeqNum = %d", delta.OrderBook.PrevSeqNum)
+	}
+
+	drivertest.RunAlign(t, driver, exchange.MarketFutures, delta, []drivertest.Align{
+		{Name: "covers snapshot id", LastSeqNum: 4812937418500, AfterSnapshot: true, Want: exchange.SeqApply},
+		{Name: "older than snapshot", LastSeqNum: 4812937419500, AfterSnapshot: true, Want: exchange.SeqStale},
+		{Name: "snapshot ahead of stream", LastSeqNum: 4812937418100, AfterSnapshot: true, Want: exchange.SeqGap},
+		{Name: "pu matches previous u", LastSeqNum: 4812937418120, Want: exchange.SeqApply},
+		{Name: "duplicate", LastSeqNum: 4812937419002, Want: exchange.SeqStale},
+		{Name: "missed updates", LastSeqNum: 4812937418000, Want: exchange.SeqGap},
+	})
+}
+
+func TestFetchOrderBookSnapshot(t *testing.T) {
+	url, paths := drivertest.Serve(t, "spot_depth_snapshot.json")
+	endpoints := DefaultEndpoints()
+	endpoints.SpotREST = url
+	driver := New(endpoints)
+
+	snapshot, err := driver.FetchOrderBookSnapshot(context.Background(), exchange.MarketSpot, "BTC/USDT", 50)
+	if err != nil {
+		t.Fatalf("FetchOrderBookSnapshot: %v", err)
+	}
+	if path := <-paths; !strings.HasPrefix(path, "/api/v3/depth?") ||
+		!strings.Contains(path, "symbol=BTCUSDT") || !strings.Contains(path, "limit=50") {
+		t.Errorf("request = %s", path)
+	}
+	drivertest.Check(t, exchange.Binance, exchange.MarketSpot, []*messaging.Message{snapshot}, []message{{
+		Type: messaging.TypeOrderBook, Pair: "BTC/USDT", SeqNum: 55461237815,
+		Snapshot: true, Depth: 50,
+		Bids: []level{{"67234.10", "1.5231"}, {"67234.09", "0.0014"}},
+		Asks: []level{{"67234.11", "2.71022"}, {"67234.12", "0.00016"}},
+	}})
+
+	// Первая дельта после снимка покрывает lastUpdateId + 1
+	delta := drivertest.Parse(t, driver, exchange.MarketSpot, "spot_diff_depth.json")[0]
+	if got := driver.AlignDelta(exchange.MarketSpot, snapshot.SeqNum, true, delta); got != exchange.SeqApply {
+		t.Errorf("AlignDelta after snapshot = %d, want SeqApply", got)
+	}
+}
+
+func TestConnectionLimits(t *testing.T) {
+	driver := New(DefaultEndpoints())
+	for _, tc := range []struct {
+		marketType string
+		maxPairs   int
+	}{
+		{exchange.MarketSpot, 512},
+		{exchange.MarketFutures, 100},
+	} {
+		if got := driver.ConnectionLimits(tc.marketType).MaxPairs(); got != tc.maxPairs {
+			t.Errorf("%s: MaxPairs = %d, want %d", tc.marketType, got, tc.maxPairs)
+		}
+	}
+}
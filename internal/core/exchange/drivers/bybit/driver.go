@@ -0,0 +1,535 @@
+// Package bybit реализует market-data драйвер Bybit v5 (spot и linear perpetual)
+//
+// Книга приходит в topic orderbook.{depth}.{symbol}: первое сообщение после подписки
+// имеет type = "snapshot", далее идут "delta" с последовательным update id "u".
+// Если Bybit присылает новый snapshot (например после рестарта сервиса), книга заменяется.
+package bybit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/exchange/drivers/common"
+	"trader/internal/core/messaging"
+	"trader/internal/logger"
+)
+
+const (
+	// SpotWSEndpoint - публичный WS спота
+	SpotWSEndpoint = "wss://stream.bybit.com/v5/public/spot"
+	// LinearWSEndpoint - публичный WS USDT linear perpetual
+	LinearWSEndpoint = "wss://stream.bybit.com/v5/public/linear"
+	// RESTEndpoint - базовый URL REST API v5 (общий для всех рынков)
+	RESTEndpoint = "https://api.bybit.com"
+
+	// maxArgsPerRequest - Bybit spot принимает не более 10 args в одном subscribe
+	maxArgsPerRequest = 10
+	// maxArgsPerConnection - суммарная длина args на соединение ограничена 21000 символами,
+	// 500 топиков длиной до 40 символов укладываются в лимит с запасом
+	maxArgsPerConnection = 500
+)
+
+// Поддерживаемые глубины orderbook.{depth} по рынкам
+var (
+	spotDepths   = []int{1, 50, 200, 1000}
+	linearDepths = []int{1, 50, 200, 500}
+)
+
+// Endpoints - адреса WS и REST API, переопределяются для тестового стенда
+type Endpoints struct {
+	SpotWS   string
+	LinearWS string
+	REST     string
+}
+
+// DefaultEndpoints возвращает боевые адреса Bybit
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		SpotWS:   SpotWSEndpoint,
+		LinearWS: LinearWSEndpoint,
+		REST:     RESTEndpoint,
+	}
+}
+
+func init() {
+	exchange.Register(exchange.Bybit, func() exchange.ExchangeDriver {
+		return New(DefaultEndpoints())
+	})
+}
+
+// Driver - драйвер Bybit v5
+type Driver struct {
+	endpoints Endpoints
+	client    *http.Client
+	restLog   *slog.Logger
+	requestID atomic.Int64
+	symbols   *common.Symbols
+}
+
+// New создает драйвер с указанными адресами
+func New(endpoints Endpoints) *Driver {
+	return &Driver{
+		endpoints: endpoints,
+		client:    common.NewHTTPClient(),
+		restLog:   logger.GetOutRequest("bybit"),
+		symbols:   common.NewSymbols(symbolFormats),
+	}
+}
+
+// GetExchangeID возвращает ID биржи
+func (d *Driver) GetExchangeID() string {
+	return exchange.Bybit
+}
+
+// GetName возвращает название биржи
+func (d *Driver) GetName() string {
+	return "Bybit"
+}
+
+// GetWSEndpoint возвращает публичный WS для рынка (futures = linear perpetual)
+func (d *Driver) GetWSEndpoint(marketType string) (string, error) {
+	switch marketType {
+	case exchange.MarketSpot:
+		return d.endpoints.SpotWS, nil
+	case exchange.MarketFutures:
+		return d.endpoints.LinearWS, nil
+	default:
+		return "", fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+}
+
+// ConnectionLimits - каждая пара занимает два топика: orderbook и publicTrade
+func (d *Driver) ConnectionLimits(marketType string) exchange.ConnectionLimits {
+	return exchange.ConnectionLimits{MaxStreams: maxArgsPerConnection, StreamsPerPair: 2}
+}
+
+// CreateSubscribeMessage строит subscribe фреймы для orderbook и publicTrade
+func (d *Driver) CreateSubscribeMessage(marketType string, pairs []string, depth int) ([][]byte, error) {
+	return d.buildFrames("subscribe", marketType, pairs, depth)
+}
+
+// CreateUnsubscribeMessage строит unsubscribe фреймы
+func (d *Driver) CreateUnsubscribeMessage(marketType string, pairs []string, depth int) ([][]byte, error) {
+	return d.buildFrames("unsubscribe", marketType, pairs, depth)
+}
+
+type controlFrame struct {
+	Op    string   `json:"op"`
+	Args  []string `json:"args"`
+	ReqID string   `json:"req_id"`
+}
+
+func (d *Driver) buildFrames(op, marketType string, pairs []string, depth int) ([][]byte, error) {
+	if _, err := d.GetWSEndpoint(marketType); err != nil {
+		return nil, err
+	}
+
+	bookDepth := topicDepth(marketType, depth)
+	args := make([]string, 0, len(pairs)*2)
+	for _, pair := range pairs {
+		symbol, err := d.symbols.Symbol(marketType, pair)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args,
+			fmt.Sprintf("orderbook.%d.%s", bookDepth, symbol),
+			"publicTrade."+symbol,
+		)
+	}
+
+	var frames [][]byte
+	for _, chunk := range common.Chunk(args, maxArgsPerRequest) {
+		frame, err := json.Marshal(controlFrame{
+			Op:    op,
+			Args:  chunk,
+			ReqID: strconv.FormatInt(d.requestID.Add(1), 10),
+		})
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// topicDepth подбирает минимальную поддерживаемую глубину не меньше запрошенной
+// depth = 0 (полная книга) - максимальная доступная глубина
+func topicDepth(marketType string, depth int) int {
+	depths := spotDepths
+	if marketType == exchange.MarketFutures {
+		depths = linearDepths
+	}
+	if depth <= 0 {
+		return depths[len(depths)-1]
+	}
+	for _, d := range depths {
+		if d >= depth {
+			return d
+		}
+	}
+	return depths[len(depths)-1]
+}
+
+// symbolFormats - слитный символ BTCUSDT на spot и linear
+var symbolFormats = map[string]common.SymbolFormat{
+	exchange.MarketSpot:    {},
+	exchange.MarketFutures: {},
+}
+
+// Symbol переводит "BTC/USDT" в "BTCUSDT"
+func (d *Driver) Symbol(marketType, pair string) (string, error) {
+	return d.symbols.Symbol(marketType, pair)
+}
+
+// Pair переводит символ Bybit обратно в "BTC/USDT"
+func (d *Driver) Pair(marketType, symbol string) string {
+	return d.symbols.Pair(marketType, symbol)
+}
+
+// ============================================================================
+// Разбор входящих фреймов
+// ============================================================================
+
+type pushFrame struct {
+	Topic string          `json:"topic"`
+	Type  string          `json:"type"`
+	TS    int64           `json:"ts"`
+	Data  json.RawMessage `json:"data"`
+
+	// Ответы на операции: {"success":true,"ret_msg":"","op":"subscribe"}
+	Op      string `json:"op"`
+	Success *bool  `json:"success"`
+	RetMsg  string `json:"ret_msg"`
+}
+
+type bookPayload struct {
+	Symbol   string     `json:"s"`
+	Bids     [][]string `json:"b"`
+	Asks     [][]string `json:"a"`
+	UpdateID int64      `json:"u"`
+	Seq      int64      `json:"seq"`
+}
+
+// tradePayload - encoding/json сравнивает ключи без учета регистра,
+// поэтому "S" (side) и "s" (symbol) объявлены отдельными полями
+type tradePayload struct {
+	Time    int64  `json:"T"`
+	Symbol  string `json:"s"`
+	Side    string `json:"S"`
+	Size    string `json:"v"`
+	Price   string `json:"p"`
+	TradeID string `json:"i"`
+}
+
+// ParseMessage разбирает push фрейм Bybit v5
+func (d *Driver) ParseMessage(marketType string, data []byte) ([]*messaging.Message, error) {
+	var frame pushFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return nil, fmt.Errorf("decode bybit frame: %w", err)
+	}
+
+	if frame.Topic == "" {
+		if frame.Success != nil && !*frame.Success {
+			return nil, fmt.Errorf("bybit %s failed: %s", frame.Op, frame.RetMsg)
+		}
+		return nil, nil
+	}
+
+	switch {
+	case strings.HasPrefix(frame.Topic, "orderbook."):
+		msg, err := d.parseBook(marketType, &frame)
+		if err != nil {
+			return nil, err
+		}
+		return []*messaging.Message{msg}, nil
+	case strings.HasPrefix(frame.Topic, "publicTrade."):
+		return d.parseTrades(marketType, frame.Data)
+	default:
+		return nil, fmt.Errorf("unsupported bybit topic %q", frame.Topic)
+	}
+}
+
+func (d *Driver) parseBook(marketType string, frame *pushFrame) (*messaging.Message, error) {
+	var payload bookPayload
+	if err := json.Unmarshal(frame.Data, &payload); err != nil {
+		return nil, fmt.Errorf("decode bybit orderbook: %w", err)
+	}
+
+	bids, err := common.ParseLevels(payload.Bids)
+	if err != nil {
+		return nil, err
+	}
+	asks, err := common.ParseLevels(payload.Asks)
+	if err != nil {
+		return nil, err
+	}
+
+	// topic = orderbook.{depth}.{symbol}
+	parts := strings.SplitN(frame.Topic, ".", 3)
+	depth := 0
+	if len(parts) == 3 {
+		depth, _ = strconv.Atoi(parts[1])
+	}
+
+	snapshot := frame.Type == "snapshot"
+	if !snapshot && frame.Type != "delta" {
+		return nil, fmt.Errorf("unknown bybit orderbook type %q", frame.Type)
+	}
+
+	return &messaging.Message{
+		Timestamp:  common.MillisToMicros(frame.TS),
+		ExchangeID: exchange.Bybit,
+		MarketType: marketType,
+		Type:       messaging.TypeOrderBook,
+		Pair:       d.symbols.Pair(marketType, payload.Symbol),
+		SeqNum:     payload.UpdateID,
+		OrderBook: &messaging.OrderBookData{
+			Bids:        bids,
+			Asks:        asks,
+			Depth:       depth,
+			Snapshot:    snapshot,
+			FirstSeqNum: payload.UpdateID,
+		},
+	}, nil
+}
+
+func (d *Driver) parseTrades(marketType string, raw json.RawMessage) ([]*messaging.Message, error) {
+	var payloads []tradePayload
+	if err := json.Unmarshal(raw, &payloads); err != nil {
+		return nil, fmt.Errorf("decode bybit trades: %w", err)
+	}
+
+	messages := make([]*messaging.Message, 0, len(payloads))
+	for _, payload := range payloads {
+		price, err := common.ParseDecimal(payload.Price)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bybit trade price %q: %w", payload.Price, err)
+		}
+		amount, err := common.ParseDecimal(payload.Size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bybit trade size %q: %w", payload.Size, err)
+		}
+
+		messages = append(messages, &messaging.Message{
+			Timestamp:  common.MillisToMicros(payload.Time),
+			ExchangeID: exchange.Bybit,
+			MarketType: marketType,
+			Type:       messaging.TypeTrade,
+			Pair:       d.symbols.Pair(marketType, payload.Symbol),
+			Trade: &messaging.TradeData{
+				Price:   price,
+				Amount:  amount,
+				Side:    strings.ToLower(payload.Side),
+				TradeID: payload.TradeID,
+			},
+		})
+	}
+	return messages, nil
+}
+
+// AlignDelta - update id Bybit увеличивается на 1 с каждой дельтой
+// Snapshot всегда применяется (в том числе повторный snapshot с u = 1 после рестарта Bybit)
+func (d *Driver) AlignDelta(marketType string, lastSeqNum int64, afterSnapshot bool, delta *messaging.Message) exchange.SeqAlignment {
+	if delta.OrderBook == nil || delta.OrderBook.Snapshot {
+		return exchange.SeqApply
+	}
+	switch {
+	case delta.SeqNum <= lastSeqNum:
+		return exchange.SeqStale
+	case delta.SeqNum == lastSeqNum+1:
+		return exchange.SeqApply
+	default:
+		return exchange.SeqGap
+	}
+}
+
+// pingInterval - Bybit рекомендует ping каждые 20 секунд
+const pingInterval = 20 * time.Second
+
+// PingInterval возвращает период {"op":"ping"}
+func (d *Driver) PingInterval(marketType string) time.Duration {
+	return pingInterval
+}
+
+// PingFrame строит {"op":"ping","req_id":"N"}
+func (d *Driver) PingFrame(marketType string) []byte {
+	frame, _ := json.Marshal(controlFrame{
+		Op:    "ping",
+		Args:  []string{},
+		ReqID: strconv.FormatInt(d.requestID.Add(1), 10),
+	})
+	return frame
+}
+
+// IsPong - spot отвечает {"op":"ping","ret_msg":"pong",...}, linear - {"op":"pong",...}
+func (d *Driver) IsPong(marketType string, data []byte) bool {
+	if !bytes.Contains(data, []byte(`"pong"`)) {
+		return false
+	}
+	var frame pushFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return false
+	}
+	return frame.Op == "pong" || (frame.Op == "ping" && frame.RetMsg == "pong")
+}
+
+type serverTimeResponse struct {
+	RetCode int    `json:"retCode"`
+	RetMsg  string `json:"retMsg"`
+	Result  struct {
+		TimeNano string `json:"timeNano"`
+	} `json:"result"`
+}
+
+// ServerTime запрашивает /v5/market/time (одинаковый для всех рынков)
+func (d *Driver) ServerTime(ctx context.Context, marketType string) (int64, error) {
+	if _, err := d.GetWSEndpoint(marketType); err != nil {
+		return 0, err
+	}
+
+	var resp serverTimeResponse
+	if err := common.GetJSON(ctx, d.client, d.restLog, nil, d.endpoints.REST+"/v5/market/time", &resp); err != nil {
+		return 0, err
+	}
+	if resp.RetCode != 0 {
+		return 0, fmt.Errorf("bybit market time %d: %s", resp.RetCode, resp.RetMsg)
+	}
+	nanos, err := strconv.ParseInt(resp.Result.TimeNano, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bybit timeNano %q: %w", resp.Result.TimeNano, err)
+	}
+	return nanos / 1000, nil
+}
+
+// ============================================================================
+// Инструменты
+// ============================================================================
+
+// instrumentsPageLimit - максимальный размер страницы /v5/market/instruments-info
+const instrumentsPageLimit = 1000
+
+type instrumentsResponse struct {
+	RetCode int    `json:"retCode"`
+	RetMsg  string `json:"retMsg"`
+	Result  struct {
+		List []struct {
+			Symbol       string `json:"symbol"`
+			BaseCoin     string `json:"baseCoin"`
+			QuoteCoin    string `json:"quoteCoin"`
+			Status       string `json:"status"`
+			ContractType string `json:"contractType"`
+			PriceFilter  struct {
+				TickSize string `json:"tickSize"`
+			} `json:"priceFilter"`
+			// Спот: basePrecision, minOrderQty, minOrderAmt
+			// Linear: qtyStep, minOrderQty, minNotionalValue
+			LotSizeFilter struct {
+				BasePrecision    string `json:"basePrecision"`
+				QtyStep          string `json:"qtyStep"`
+				MinOrderQty      string `json:"minOrderQty"`
+				MinOrderAmt      string `json:"minOrderAmt"`
+				MinNotionalValue string `json:"minNotionalValue"`
+			} `json:"lotSizeFilter"`
+		} `json:"list"`
+		NextPageCursor string `json:"nextPageCursor"`
+	} `json:"result"`
+}
+
+// FetchInstruments загружает /v5/market/instruments-info (category spot или linear)
+// постранично по nextPageCursor. Возвращаются только символы в статусе Trading,
+// для linear - только бессрочные контракты
+func (d *Driver) FetchInstruments(ctx context.Context, marketType string) ([]exchange.Instrument, error) {
+	category := "spot"
+	switch marketType {
+	case exchange.MarketSpot:
+	case exchange.MarketFutures:
+		category = "linear"
+	default:
+		return nil, fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+
+	var instruments []exchange.Instrument
+	cursor := ""
+	for {
+		query := url.Values{}
+		query.Set("category", category)
+		query.Set("limit", strconv.Itoa(instrumentsPageLimit))
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+
+		var resp instrumentsResponse
+		if err := common.GetJSON(ctx, d.client, d.restLog, nil, d.endpoints.REST+"/v5/market/instruments-info?"+query.Encode(), &resp); err != nil {
+			return nil, err
+		}
+		if resp.RetCode != 0 {
+			return nil, fmt.Errorf("bybit instruments-info %d: %s", resp.RetCode, resp.RetMsg)
+		}
+
+		for _, item := range resp.Result.List {
+			if item.Status != "Trading" {
+				continue
+			}
+			if marketType == exchange.MarketFutures && item.ContractType != "LinearPerpetual" {
+				continue
+			}
+
+			step, minNotional := item.LotSizeFilter.BasePrecision, item.LotSizeFilter.MinOrderAmt
+			if marketType == exchange.MarketFutures {
+				step, minNotional = item.LotSizeFilter.QtyStep, item.LotSizeFilter.MinNotionalValue
+			}
+
+			instrument := exchange.Instrument{
+				ExchangeID:   exchange.Bybit,
+				MarketType:   marketType,
+				Pair:         item.BaseCoin + "/" + item.QuoteCoin,
+				Symbol:       item.Symbol,
+				ContractSize: decimal.NewFromInt(1),
+			}
+			var err error
+			if instrument.TickSize, err = common.ParseDecimal(item.PriceFilter.TickSize); err != nil {
+				return nil, fmt.Errorf("invalid bybit %s tickSize: %w", item.Symbol, err)
+			}
+			if instrument.StepSize, err = common.ParseDecimal(step); err != nil {
+				return nil, fmt.Errorf("invalid bybit %s quantity step: %w", item.Symbol, err)
+			}
+			if instrument.MinQuantity, err = common.ParseDecimal(item.LotSizeFilter.MinOrderQty); err != nil {
+				return nil, fmt.Errorf("invalid bybit %s minOrderQty: %w", item.Symbol, err)
+			}
+			if instrument.MinNotional, err = common.ParseDecimal(minNotional); err != nil {
+				return nil, fmt.Errorf("invalid bybit %s min notional: %w", item.Symbol, err)
+			}
+
+			d.symbols.Remember(marketType, item.Symbol, instrument.Pair)
+			instruments = append(instruments, instrument)
+		}
+
+		cursor = resp.Result.NextPageCursor
+		if cursor == "" || len(resp.Result.List) == 0 {
+			return instruments, nil
+		}
+	}
+}
+
+// Проверка реализации интерфейсов на этапе компиляции
+var (
+	_ exchange.ExchangeDriver     = (*Driver)(nil)
+	_ exchange.SequenceAligner    = (*Driver)(nil)
+	_ exchange.ConnectionLimiter  = (*Driver)(nil)
+	_ exchange.Pinger             = (*Driver)(nil)
+	_ exchange.ServerClock        = (*Driver)(nil)
+	_ exchange.InstrumentProvider = (*Driver)(nil)
+	_ exchange.SymbolMapper       = (*Driver)(nil)
+)
@@ -0,0 +1,74 @@
+package bybit
+
+import (
+	"testing"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/exchange/drivers/drivertest"
+	"trader/internal/core/messaging"
+)
+
+type (
+	level   = drivertest.Level
+	message = drivertest.Message
+	trade   = drivertest.Trade
+)
+
+func TestParseMessage(t *testing.T) {
+	drivertest.Run(t, New(DefaultEndpoints()), []drivertest.Case{
+		{
+			Name: "subscribe ack", File: "subscribe_ack.json", MarketType: exchange.MarketSpot,
+		},
+		{
+			Name: "spot book snapshot", File: "spot_orderbook_snapshot.json", MarketType: exchange.MarketSpot,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "BTC/USDT", Timestamp: 1718102400800000, SeqNum: 481029,
+				Snapshot: true, Depth: 50,
+				Bids: []level{{"67249.99", "0.412"}, {"67249.50", "1.002"}},
+				Asks: []level{{"67250.00", "0.318"}, {"67250.10", "0.05"}},
+			}},
+		},
+		{
+			Name: "spot book delta", File: "spot_orderbook_delta.json", MarketType: exchange.MarketSpot,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "BTC/USDT", Timestamp: 1718102400820000, SeqNum: 481030,
+				Depth: 50,
+				Bids:  []level{{"67249.50", "0"}},
+				Asks:  []level{{"67250.00", "0.211"}},
+			}},
+		},
+		{
+			Name: "linear trades", File: "linear_public_trade.json", MarketType: exchange.MarketFutures,
+			Want: []message{
+				{
+					Type: messaging.TypeTrade, Pair: "ETH/USDT", Timestamp: 1718102400899000,
+					Trade: &trade{Price: "3512.40", Amount: "1.25", Side: "sell", ID: "7a3c1e0f-8d5b-5b55-9f3c-1f0b5e3a7d11"},
+				},
+				{
+					Type: messaging.TypeTrade, Pair: "ETH/USDT", Timestamp: 1718102400899000,
+					Trade: &trade{Price: "3512.39", Amount: "0.4", Side: "sell", ID: "0f4b7c1e-2a0d-5c4e-8e2f-6b1a9d3c5e22"},
+				},
+			},
+		},
+	})
+}
+
+func TestAlignDelta(t *testing.T) {
+	driver := New(DefaultEndpoints())
+	snapshot := drivertest.Parse(t, driver, exchange.MarketSpot, "spot_orderbook_snapshot.json")[0]
+	delta := drivertest.Parse(t, driver, exchange.MarketSpot, "spot_orderbook_delta.json")[0]
+
+	// Снимок из потока применяется всегда и задает u для следующих дельт
+	if got := driver.AlignDelta(exchange.MarketSpot, 0, false, snapshot); got != exchange.SeqApply {
+		t.Errorf("AlignDelta(snapshot) = %d, want SeqApply", got)
+	}
+
+	// u дельты = 481030
+	drivertest.RunAlign(t, driver, exchange.MarketSpot, delta, []drivertest.Align{
+		{Name: "next after snapshot", LastSeqNum: snapshot.SeqNum, AfterSnapshot: true, Want: exchange.SeqApply},
+		{Name: "next update", LastSeqNum: 481029, Want: exchange.SeqApply},
+		{Name: "duplicate", LastSeqNum: 481030, Want: exchange.SeqStale},
+		{Name: "already applied", LastSeqNum: 481100, Want: exchange.SeqStale},
+		{Name: "missed update", LastSeqNum: 481027, Want: exchange.SeqGap},
+	})
+}
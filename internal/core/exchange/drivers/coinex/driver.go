@@ -0,0 +1,497 @@
+// Package coinex реализует market-data драйвер CoinEx API v2 (spot и futures)
+//
+// Сервер сжимает все фреймы, они распаковываются в DecodeFrame.
+// Книга подписывается с is_full = true: каждое depth.update содержит полный снимок
+// запрошенной глубины, поэтому сверка последовательности не нужна.
+package coinex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/exchange/drivers/common"
+	"trader/internal/core/messaging"
+	"trader/internal/logger"
+)
+
+const (
+	// SpotWSEndpoint - публичный WS спота
+	SpotWSEndpoint = "wss://socket.coinex.com/v2/spot"
+	// FuturesWSEndpoint - публичный WS фьючерсов
+	FuturesWSEndpoint = "wss://socket.coinex.com/v2/futures"
+	// RESTEndpoint - базовый URL REST API v2 (общий для спота и фьючерсов)
+	RESTEndpoint = "https://api.coinex.com"
+
+	// depthInterval - шаг агрегации цены, "0" = без агрегации
+	depthInterval = "0"
+)
+
+// depthLimits - допустимые значения limit в depth.subscribe
+var depthLimits = []int{5, 10, 20, 50}
+
+// Endpoints - адреса WS и REST API, переопределяются для тестового стенда
+type Endpoints struct {
+	SpotWS    string
+	FuturesWS string
+	REST      string
+}
+
+// DefaultEndpoints возвращает боевые адреса CoinEx
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		SpotWS:    SpotWSEndpoint,
+		FuturesWS: FuturesWSEndpoint,
+		REST:      RESTEndpoint,
+	}
+}
+
+func init() {
+	exchange.Register(exchange.Coinex, func() exchange.ExchangeDriver {
+		return New(DefaultEndpoints())
+	})
+}
+
+// Driver - драйвер CoinEx v2
+type Driver struct {
+	endpoints Endpoints
+	client    *http.Client
+	restLog   *slog.Logger
+	requestID atomic.Int64
+	symbols   *common.Symbols
+}
+
+// New создает драйвер с указанными адресами
+func New(endpoints Endpoints) *Driver {
+	return &Driver{
+		endpoints: endpoints,
+		client:    common.NewHTTPClient(),
+		restLog:   logger.GetOutRequest("coinex"),
+		symbols:   common.NewSymbols(symbolFormats),
+	}
+}
+
+// GetExchangeID возвращает ID биржи
+func (d *Driver) GetExchangeID() string {
+	return exchange.Coinex
+}
+
+// GetName возвращает название биржи
+func (d *Driver) GetName() string {
+	return "CoinEx"
+}
+
+// GetWSEndpoint возвращает публичный WS для рынка
+func (d *Driver) GetWSEndpoint(marketType string) (string, error) {
+	switch marketType {
+	case exchange.MarketSpot:
+		return d.endpoints.SpotWS, nil
+	case exchange.MarketFutures:
+		return d.endpoints.FuturesWS, nil
+	default:
+		return "", fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+}
+
+type controlFrame struct {
+	Method string `json:"method"`
+	Params any    `json:"params"`
+	ID     int64  `json:"id"`
+}
+
+type marketListParams struct {
+	MarketList []any `json:"market_list"`
+}
+
+// CreateSubscribeMessage строит depth.subscribe и deals.subscribe
+// Элемент market_list для книги: [market, limit, interval, is_full]
+func (d *Driver) CreateSubscribeMessage(marketType string, pairs []string, depth int) ([][]byte, error) {
+	if _, err := d.GetWSEndpoint(marketType); err != nil {
+		return nil, err
+	}
+	symbols, err := d.symbolsFor(marketType, pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := depthLimit(depth)
+	books := make([]any, 0, len(symbols))
+	deals := make([]any, 0, len(symbols))
+	for _, symbol := range symbols {
+		books = append(books, []any{symbol, limit, depthInterval, true})
+		deals = append(deals, symbol)
+	}
+	return d.marshalFrames(
+		controlFrame{Method: "depth.subscribe", Params: marketListParams{MarketList: books}},
+		controlFrame{Method: "deals.subscribe", Params: marketListParams{MarketList: deals}},
+	)
+}
+
+// CreateUnsubscribeMessage строит depth.unsubscribe и deals.unsubscribe
+func (d *Driver) CreateUnsubscribeMessage(marketType string, pairs []string, depth int) ([][]byte, error) {
+	if _, err := d.GetWSEndpoint(marketType); err != nil {
+		return nil, err
+	}
+	symbols, err := d.symbolsFor(marketType, pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]any, 0, len(symbols))
+	for _, symbol := range symbols {
+		list = append(list, symbol)
+	}
+	return d.marshalFrames(
+		controlFrame{Method: "depth.unsubscribe", Params: marketListParams{MarketList: list}},
+		controlFrame{Method: "deals.unsubscribe", Params: marketListParams{MarketList: list}},
+	)
+}
+
+func (d *Driver) marshalFrames(requests ...controlFrame) ([][]byte, error) {
+	frames := make([][]byte, 0, len(requests))
+	for _, request := range requests {
+		request.ID = d.requestID.Add(1)
+		frame, err := json.Marshal(request)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// symbolsFor переводит пары "BTC/USDT" в "BTCUSDT" и запоминает обратное соответствие
+func (d *Driver) symbolsFor(marketType string, pairs []string) ([]string, error) {
+	symbols := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		symbol, err := d.symbols.Symbol(marketType, pair)
+		if err != nil {
+			return nil, err
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols, nil
+}
+
+// depthLimit подбирает минимальный допустимый limit не меньше запрошенной глубины
+// Полная книга (depth = 0) ограничена 50 уровнями - максимумом CoinEx
+func depthLimit(depth int) int {
+	if depth > 0 {
+		for _, limit := range depthLimits {
+			if limit >= depth {
+				return limit
+			}
+		}
+	}
+	return depthLimits[len(depthLimits)-1]
+}
+
+// symbolFormats - слитный символ BTCUSDT на обоих рынках
+var symbolFormats = map[string]common.SymbolFormat{
+	exchange.MarketSpot:    {},
+	exchange.MarketFutures: {},
+}
+
+// Symbol переводит "BTC/USDT" в "BTCUSDT"
+func (d *Driver) Symbol(marketType, pair string) (string, error) {
+	return d.symbols.Symbol(marketType, pair)
+}
+
+// Pair переводит символ CoinEx обратно в "BTC/USDT"
+func (d *Driver) Pair(marketType, symbol string) string {
+	return d.symbols.Pair(marketType, symbol)
+}
+
+// ============================================================================
+// Разбор входящих фреймов
+// ============================================================================
+
+// DecodeFrame распаковывает сжатый фрейм CoinEx
+func (d *Driver) DecodeFrame(data []byte) ([]byte, error) {
+	return common.Decompress(data)
+}
+
+type pushFrame struct {
+	Method string          `json:"method"`
+	Data   json.RawMessage `json:"data"`
+
+	// Ответ на запрос: {"id":1,"code":0,"message":"OK"}
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type depthPayload struct {
+	Market string `json:"market"`
+	IsFull bool   `json:"is_full"`
+	Depth  struct {
+		Asks      [][]string `json:"asks"`
+		Bids      [][]string `json:"bids"`
+		UpdatedAt int64      `json:"updated_at"`
+	} `json:"depth"`
+}
+
+type dealsPayload struct {
+	Market   string `json:"market"`
+	DealList []struct {
+		DealID    int64  `json:"deal_id"`
+		CreatedAt int64  `json:"created_at"`
+		Side      string `json:"side"`
+		Price     string `json:"price"`
+		Amount    string `json:"amount"`
+	} `json:"deal_list"`
+}
+
+// ParseMessage разбирает распакованный фрейм CoinEx v2
+func (d *Driver) ParseMessage(marketType string, data []byte) ([]*messaging.Message, error) {
+	var frame pushFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return nil, fmt.Errorf("decode coinex frame: %w", err)
+	}
+
+	switch frame.Method {
+	case "":
+		if frame.Code != 0 {
+			return nil, fmt.Errorf("coinex error %d: %s", frame.Code, frame.Message)
+		}
+		return nil, nil
+	case "depth.update":
+		msg, err := d.parseDepth(marketType, frame.Data)
+		if err != nil {
+			return nil, err
+		}
+		return []*messaging.Message{msg}, nil
+	case "deals.update":
+		return d.parseDeals(marketType, frame.Data)
+	default:
+		return nil, fmt.Errorf("unsupported coinex method %q", frame.Method)
+	}
+}
+
+func (d *Driver) parseDepth(marketType string, raw json.RawMessage) (*messaging.Message, error) {
+	var payload depthPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("decode coinex depth: %w", err)
+	}
+
+	bids, err := common.ParseLevels(payload.Depth.Bids)
+	if err != nil {
+		return nil, err
+	}
+	asks, err := common.ParseLevels(payload.Depth.Asks)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := common.MillisToMicros(payload.Depth.UpdatedAt)
+	if timestamp == 0 {
+		timestamp = common.NowMicros()
+	}
+
+	return &messaging.Message{
+		Timestamp:  timestamp,
+		ExchangeID: exchange.Coinex,
+		MarketType: marketType,
+		Type:       messaging.TypeOrderBook,
+		Pair:       d.symbols.Pair(marketType, payload.Market),
+		OrderBook: &messaging.OrderBookData{
+			Bids:     bids,
+			Asks:     asks,
+			Snapshot: payload.IsFull,
+		},
+	}, nil
+}
+
+func (d *Driver) parseDeals(marketType string, raw json.RawMessage) ([]*messaging.Message, error) {
+	var payload dealsPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("decode coinex deals: %w", err)
+	}
+
+	pair := d.symbols.Pair(marketType, payload.Market)
+	messages := make([]*messaging.Message, 0, len(payload.DealList))
+	for _, deal := range payload.DealList {
+		price, err := common.ParseDecimal(deal.Price)
+		if err != nil {
+			return nil, fmt.Errorf("invalid coinex deal price %q: %w", deal.Price, err)
+		}
+		amount, err := common.ParseDecimal(deal.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid coinex deal amount %q: %w", deal.Amount, err)
+		}
+
+		messages = append(messages, &messaging.Message{
+			Timestamp:  common.MillisToMicros(deal.CreatedAt),
+			ExchangeID: exchange.Coinex,
+			MarketType: marketType,
+			Type:       messaging.TypeTrade,
+			Pair:       pair,
+			SeqNum:     deal.DealID,
+			Trade: &messaging.TradeData{
+				Price:   price,
+				Amount:  amount,
+				Side:    deal.Side,
+				TradeID: strconv.FormatInt(deal.DealID, 10),
+			},
+		})
+	}
+	return messages, nil
+}
+
+// pingInterval - CoinEx закрывает соединение без активности клиента, ping каждые 20 секунд
+const pingInterval = 20 * time.Second
+
+// PingInterval возвращает период server.ping
+func (d *Driver) PingInterval(marketType string) time.Duration {
+	return pingInterval
+}
+
+// PingFrame строит {"method":"server.ping","params":{},"id":N}
+func (d *Driver) PingFrame(marketType string) []byte {
+	frame, _ := json.Marshal(controlFrame{
+		Method: "server.ping",
+		Params: struct{}{},
+		ID:     d.requestID.Add(1),
+	})
+	return frame
+}
+
+type pongPayload struct {
+	Result string `json:"result"`
+}
+
+// IsPong - ответ {"id":N,"code":0,"message":"OK","data":{"result":"pong"}}
+func (d *Driver) IsPong(marketType string, data []byte) bool {
+	if !bytes.Contains(data, []byte(`"pong"`)) {
+		return false
+	}
+	var frame pushFrame
+	if err := json.Unmarshal(data, &frame); err != nil || frame.Method != "" {
+		return false
+	}
+	var payload pongPayload
+	if err := json.Unmarshal(frame.Data, &payload); err != nil {
+		return false
+	}
+	return payload.Result == "pong"
+}
+
+type timeResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		Timestamp int64 `json:"timestamp"`
+	} `json:"data"`
+}
+
+// ServerTime запрашивает /v2/time (одинаковый для спота и фьючерсов)
+func (d *Driver) ServerTime(ctx context.Context, marketType string) (int64, error) {
+	if _, err := d.GetWSEndpoint(marketType); err != nil {
+		return 0, err
+	}
+
+	var resp timeResponse
+	if err := common.GetJSON(ctx, d.client, d.restLog, nil, d.endpoints.REST+"/v2/time", &resp); err != nil {
+		return 0, err
+	}
+	if resp.Code != 0 {
+		return 0, fmt.Errorf("coinex time %d: %s", resp.Code, resp.Message)
+	}
+	return common.MillisToMicros(resp.Data.Timestamp), nil
+}
+
+// ============================================================================
+// Инструменты
+// ============================================================================
+
+// marketsResponse - /v2/spot/market и /v2/futures/market
+// Шаги цены и объема спота заданы точностью (знаков после запятой), у фьючерсов есть tick_size
+type marketsResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    []struct {
+		Market            string `json:"market"`
+		BaseCcy           string `json:"base_ccy"`
+		QuoteCcy          string `json:"quote_ccy"`
+		BaseCcyPrecision  int32  `json:"base_ccy_precision"`
+		QuoteCcyPrecision int32  `json:"quote_ccy_precision"`
+		MinAmount         string `json:"min_amount"`
+		TickSize          string `json:"tick_size"`
+		ContractType      string `json:"contract_type"`
+		Status            string `json:"status"`
+	} `json:"data"`
+}
+
+// FetchInstruments загружает /v2/spot/market или /v2/futures/market
+// Возвращаются только рынки в статусе online, для фьючерсов - только линейные
+// Объем фьючерсов CoinEx задается в базовом активе, поэтому ContractSize = 1
+func (d *Driver) FetchInstruments(ctx context.Context, marketType string) ([]exchange.Instrument, error) {
+	var path string
+	switch marketType {
+	case exchange.MarketSpot:
+		path = "/v2/spot/market"
+	case exchange.MarketFutures:
+		path = "/v2/futures/market"
+	default:
+		return nil, fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+
+	var resp marketsResponse
+	if err := common.GetJSON(ctx, d.client, d.restLog, nil, d.endpoints.REST+path, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("coinex market %d: %s", resp.Code, resp.Message)
+	}
+
+	instruments := make([]exchange.Instrument, 0, len(resp.Data))
+	for _, item := range resp.Data {
+		if item.Status != "online" {
+			continue
+		}
+		if marketType == exchange.MarketFutures && item.ContractType != "linear" {
+			continue
+		}
+
+		instrument := exchange.Instrument{
+			ExchangeID:   exchange.Coinex,
+			MarketType:   marketType,
+			Pair:         item.BaseCcy + "/" + item.QuoteCcy,
+			Symbol:       item.Market,
+			TickSize:     decimal.New(1, -item.QuoteCcyPrecision),
+			StepSize:     decimal.New(1, -item.BaseCcyPrecision),
+			ContractSize: decimal.NewFromInt(1),
+		}
+		var err error
+		if marketType == exchange.MarketFutures {
+			if instrument.TickSize, err = common.ParseDecimal(item.TickSize); err != nil {
+				return nil, fmt.Errorf("invalid coinex %s tick_size: %w", item.Market, err)
+			}
+		}
+		if instrument.MinQuantity, err = common.ParseDecimal(item.MinAmount); err != nil {
+			return nil, fmt.Errorf("invalid coinex %s min_amount: %w", item.Market, err)
+		}
+
+		d.symbols.Remember(marketType, item.Market, instrument.Pair)
+		instruments = append(instruments, instrument)
+	}
+	return instruments, nil
+}
+
+// Проверка реализации интерфейсов на этапе компиляции
+var (
+	_ exchange.ExchangeDriver     = (*Driver)(nil)
+	_ exchange.FrameDecoder       = (*Driver)(nil)
+	_ exchange.Pinger             = (*Driver)(nil)
+	_ exchange.ServerClock        = (*Driver)(nil)
+	_ exchange.SymbolMapper       = (*Driver)(nil)
+	_ exchange.InstrumentProvider = (*Driver)(nil)
+)
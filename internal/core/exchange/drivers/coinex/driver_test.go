@@ -0,0 +1,69 @@
+package coinex
+
+import (
+	"testing"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/exchange/drivers/drivertest"
+	"trader/internal/core/messaging"
+)
+
+type (
+	level   = drivertest.Level
+	message = drivertest.Message
+	trade   = drivertest.Trade
+)
+
+func TestParseMessage(t *testing.T) {
+	drivertest.Run(t, New(DefaultEndpoints()), []drivertest.Case{
+		{
+			Name: "subscribe ack", File: "subscribe_ack.json.deflate", MarketType: exchange.MarketSpot,
+		},
+		{
+			Name: "spot full depth", File: "spot_depth_update.json.deflate", MarketType: exchange.MarketSpot,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "BTC/USDT", Timestamp: 1731898619520000,
+				Snapshot: true,
+				Bids:     []level{{"67215.00", "1.20441"}, {"67214.90", "0.0451"}},
+				Asks:     []level{{"67215.10", "0.51234"}, {"67215.20", "0.00015"}},
+			}},
+		},
+		{
+			Name: "futures deals", File: "futures_deals_update.json.deflate", MarketType: exchange.MarketFutures,
+			Want: []message{
+				{
+					Type: messaging.TypeTrade, Pair: "BTC/USDT", Timestamp: 1731898619527000, SeqNum: 3514376759,
+					Trade: &trade{Price: "67201.1", Amount: "0.0125", Side: "buy", ID: "3514376759"},
+				},
+				{
+					Type: messaging.TypeTrade, Pair: "BTC/USDT", Timestamp: 1731898619520000, SeqNum: 3514376758,
+					Trade: &trade{Price: "67201.0", Amount: "0.3", Side: "sell", ID: "3514376758"},
+				},
+			},
+		},
+	})
+}
+
+func TestFetchInstruments(t *testing.T) {
+	withREST := func(url string) Endpoints {
+		endpoints := DefaultEndpoints()
+		endpoints.REST = url
+		return endpoints
+	}
+	drivertest.RunInstruments(t, New, withREST, []drivertest.InstrumentCase{
+		{
+			MarketType: exchange.MarketSpot, File: "spot_market.json", Path: "/v2/spot/market",
+			Want: []drivertest.Instrument{{
+				Pair: "BTC/USDT", Symbol: "BTCUSDT",
+				TickSize: "0.01", StepSize: "0.00000001", MinQuantity: "0.0001", ContractSize: "1",
+			}},
+		},
+		{
+			MarketType: exchange.MarketFutures, File: "futures_market.json", Path: "/v2/futures/market",
+			Want: []drivertest.Instrument{{
+				Pair: "ETH/USDT", Symbol: "ETHUSDT",
+				TickSize: "0.01", StepSize: "0.00000001", MinQuantity: "0.005", ContractSize: "1",
+			}},
+		},
+	})
+}
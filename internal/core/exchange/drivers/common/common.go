@@ -0,0 +1,245 @@
+// Package common содержит вспомогательные функции, общие для драйверов бирж:
+// REST запросы с логированием в out_request, разбор уровней книги, работу с символами
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/messaging"
+	"trader/internal/core/ratelimit"
+)
+
+// DefaultHTTPTimeout - таймаут REST запросов драйверов по умолчанию
+const DefaultHTTPTimeout = 10 * time.Second
+
+// maxErrorBody - сколько байт тела ответа включать в текст ошибки
+const maxErrorBody = 512
+
+// NewHTTPClient создает HTTP клиент для REST запросов драйверов
+func NewHTTPClient() *http.Client {
+	return &http.Client{Timeout: DefaultHTTPTimeout}
+}
+
+// GetJSON выполняет GET запрос и декодирует JSON ответ в out
+// Запрос логируется в out_request с тем же набором полей, что и WS запросы
+// limiter ограничивает частоту REST запросов биржи (nil = без ограничения)
+func GetJSON(ctx context.Context, client *http.Client, log *slog.Logger, limiter *ratelimit.Limiter, rawURL string, out any) error {
+	return DoJSON(ctx, client, log, limiter, http.MethodGet, rawURL, nil, out)
+}
+
+// DoJSON выполняет запрос с JSON телом (body может быть nil) и декодирует ответ в out
+// Перед отправкой запрос ждет токен limiter, глубина очереди и время ожидания попадают в out_request
+func DoJSON(ctx context.Context, client *http.Client, log *slog.Logger, limiter *ratelimit.Limiter, method, rawURL string, body io.Reader, out any) error {
+	queueDepth := limiter.QueueDepth()
+	queueWait, err := limiter.Wait(ctx)
+	if err != nil {
+		logRESTRequest(log, method, rawURL, 0, 0, queueDepth, queueWait, err)
+		return fmt.Errorf("%s %s: rate limit wait: %w", method, rawURL, err)
+	}
+
+	start := time.Now()
+	status := 0
+
+	err = func() error {
+		req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		status = resp.StatusCode
+
+		if resp.StatusCode != http.StatusOK {
+			snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+			return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+		}
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+		return nil
+	}()
+
+	logRESTRequest(log, method, rawURL, status, time.Since(start), queueDepth, queueWait, err)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, rawURL, err)
+	}
+	return nil
+}
+
+func logRESTRequest(log *slog.Logger, method, rawURL string, status int, latency time.Duration, queueDepth int, queueWait time.Duration, err error) {
+	if log == nil {
+		return
+	}
+
+	path := rawURL
+	if parsed, parseErr := url.Parse(rawURL); parseErr == nil {
+		path = parsed.Path
+	}
+
+	fields := []any{
+		"method", method,
+		"path", path,
+		"url", rawURL,
+		"status", status,
+		"latency_ms", float64(latency.Microseconds()) / 1000.0,
+		"queue_depth", queueDepth,
+		"queue_wait_ms", float64(queueWait.Microseconds()) / 1000.0,
+		"request_id", "",
+	}
+	if err != nil {
+		fields = append(fields, "error", err)
+		log.Warn("REST request", fields...)
+		return
+	}
+	log.Info("REST request", fields...)
+}
+
+// ParseLevels разбирает уровни формата [["price","amount", ...], ...]
+// Лишние элементы (количество ордеров, ликвидации у OKX) игнорируются
+func ParseLevels(raw [][]string) ([]messaging.Level, error) {
+	levels := make([]messaging.Level, 0, len(raw))
+	for _, item := range raw {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid level %v", item)
+		}
+		price, err := ParseDecimal(item[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid level price %q: %w", item[0], err)
+		}
+		amount, err := ParseDecimal(item[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid level amount %q: %w", item[1], err)
+		}
+		levels = append(levels, messaging.Level{Price: price, Amount: amount})
+	}
+	return levels, nil
+}
+
+// ParseNumberLevels разбирает уровни, где цена и объем могут быть как строками,
+// так и числами JSON (HTX, KuCoin futures, MEXC futures): [[52690.69, 0.36], ...]
+func ParseNumberLevels(raw [][]json.Number) ([]messaging.Level, error) {
+	levels := make([]messaging.Level, 0, len(raw))
+	for _, item := range raw {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid level %v", item)
+		}
+		price, err := ParseNumber(item[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid level price %q: %w", item[0], err)
+		}
+		amount, err := ParseNumber(item[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid level amount %q: %w", item[1], err)
+		}
+		levels = append(levels, messaging.Level{Price: price, Amount: amount})
+	}
+	return levels, nil
+}
+
+// ParseBBO разбирает лучшие цены потока bookTicker: цена и объем bid, цена и объем ask
+// Пустые строки (пустая сторона книги) дают нулевые значения
+func ParseBBO(bidPrice, bidAmount, askPrice, askAmount string) (*messaging.BBOData, error) {
+	values := [4]string{bidPrice, bidAmount, askPrice, askAmount}
+	var parsed [4]decimal.Decimal
+	for i, value := range values {
+		number, err := ParseDecimal(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bbo value %q: %w", value, err)
+		}
+		parsed[i] = number
+	}
+	return &messaging.BBOData{
+		BidPrice:  parsed[0],
+		BidAmount: parsed[1],
+		AskPrice:  parsed[2],
+		AskAmount: parsed[3],
+	}, nil
+}
+
+// ParseDecimal разбирает число из строки биржи без потери точности, пустая строка = 0
+func ParseDecimal(value string) (decimal.Decimal, error) {
+	if value == "" {
+		return decimal.Zero, nil
+	}
+	return decimal.NewFromString(value)
+}
+
+// ParseNumber разбирает число JSON (строку или число) без потери точности, пустое = 0
+func ParseNumber(value json.Number) (decimal.Decimal, error) {
+	return ParseDecimal(value.String())
+}
+
+// knownQuotes - котируемые активы для разбора слитных символов (BTCUSDT)
+// Порядок важен: более длинные суффиксы проверяются раньше
+var knownQuotes = []string{
+	"FDUSD", "USDT", "USDC", "BUSD", "TUSD", "USDE",
+	"EUR", "TRY", "BRL", "DAI", "BTC", "ETH", "BNB", "USD",
+}
+
+// SplitSymbol разбирает слитный символ биржи (BTCUSDT) в пару "BTC/USDT"
+// Возвращает false если котируемый актив не распознан
+func SplitSymbol(symbol string) (string, bool) {
+	upper := strings.ToUpper(symbol)
+	for _, quote := range knownQuotes {
+		if len(upper) > len(quote) && strings.HasSuffix(upper, quote) {
+			return upper[:len(upper)-len(quote)] + "/" + quote, true
+		}
+	}
+	return "", false
+}
+
+// SplitPair разбирает пару "BTC/USDT" на базовый и котируемый активы
+func SplitPair(pair string) (string, string, error) {
+	base, quote, ok := strings.Cut(pair, "/")
+	if !ok || base == "" || quote == "" {
+		return "", "", fmt.Errorf("invalid pair %q, expected BASE/QUOTE", pair)
+	}
+	return strings.ToUpper(base), strings.ToUpper(quote), nil
+}
+
+// Chunk делит список на части не длиннее size
+func Chunk(items []string, size int) [][]string {
+	if size <= 0 || len(items) <= size {
+		if len(items) == 0 {
+			return nil
+		}
+		return [][]string{items}
+	}
+
+	chunks := make([][]string, 0, (len(items)+size-1)/size)
+	for start := 0; start < len(items); start += size {
+		end := start + size
+		if end > len(items) {
+			end = len(items)
+		}
+		chunks = append(chunks, items[start:end])
+	}
+	return chunks
+}
+
+// MillisToMicros переводит миллисекунды биржи в микросекунды messaging.Message
+func MillisToMicros(ms int64) int64 {
+	return ms * 1000
+}
+
+// NowMicros возвращает локальное время в Unix микросекундах
+func NowMicros() int64 {
+	return time.Now().UTC().UnixMicro()
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+// Decompress распаковывает фрейм биржи, определяя формат по заголовку:
+// gzip (1f 8b), zlib (78 xx) или raw deflate. Несжатый JSON возвращается как есть
+func Decompress(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+
+	switch {
+	case data[0] == '{' || data[0] == '[':
+		return data, nil
+	case len(data) > 1 && data[0] == 0x1f && data[1] == 0x8b:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("gzip frame: %w", err)
+		}
+		defer reader.Close()
+		return readAll(reader, "gzip")
+	case len(data) > 1 && data[0] == 0x78:
+		reader, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("zlib frame: %w", err)
+		}
+		defer reader.Close()
+		return readAll(reader, "zlib")
+	default:
+		reader := flate.NewReader(bytes.NewReader(data))
+		defer reader.Close()
+		return readAll(reader, "deflate")
+	}
+}
+
+func readAll(reader io.Reader, format string) ([]byte, error) {
+	out, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s frame: %w", format, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"strings"
+	"sync"
+)
+
+// SymbolFormat - формат символа биржи на одном рынке
+// Внутренняя пара всегда "BASE/QUOTE" в верхнем регистре (из COIN.SYMBOL)
+type SymbolFormat struct {
+	// Separator - разделитель base и quote: "" (BTCUSDT), "-" (BTC-USDT), "_" (BTC_USDT)
+	Separator string
+	// Suffix - окончание символа после quote: "-SWAP" (OKX), "M" (KuCoin futures)
+	Suffix string
+	// Lower - биржа принимает символ только в нижнем регистре (HTX spot btcusdt)
+	Lower bool
+	// Aliases - внутреннее имя актива -> имя актива на бирже (BTC -> XBT)
+	Aliases map[string]string
+}
+
+// Symbols переводит пары "BTC/USDT" в символы биржи и обратно по форматам рынков
+// Символы, построенные через Symbol или переданные в Remember, разбираются обратно
+// по запомненному соответствию, остальные - по формату рынка
+// Безопасен для конкурентного использования
+type Symbols struct {
+	formats map[string]SymbolFormat // key = market type
+
+	mu       sync.RWMutex
+	bySymbol map[string]string // key = "market:SYMBOL"
+}
+
+// NewSymbols создает переводчик символов с форматами по типу рынка
+// Для рынков без формата используется слитный символ (BTCUSDT)
+func NewSymbols(formats map[string]SymbolFormat) *Symbols {
+	return &Symbols{
+		formats:  formats,
+		bySymbol: make(map[string]string),
+	}
+}
+
+// Symbol переводит "BTC/USDT" в символ биржи и запоминает обратное соответствие
+func (s *Symbols) Symbol(marketType, pair string) (string, error) {
+	base, quote, err := SplitPair(pair)
+	if err != nil {
+		return "", err
+	}
+	format := s.formats[marketType]
+
+	symbol := alias(format.Aliases, base) + format.Separator + alias(format.Aliases, quote) + format.Suffix
+	if format.Lower {
+		symbol = strings.ToLower(symbol)
+	}
+	s.Remember(marketType, symbol, base+"/"+quote)
+	return symbol, nil
+}
+
+// Remember сохраняет соответствие символ -> пара (символ сравнивается без учета регистра)
+// Нужен для символов, которые нельзя однозначно разобрать по формату (список инструментов биржи)
+func (s *Symbols) Remember(marketType, symbol, pair string) {
+	s.mu.Lock()
+	s.bySymbol[marketType+":"+strings.ToUpper(symbol)] = pair
+	s.mu.Unlock()
+}
+
+// Pair переводит символ биржи обратно в "BTC/USDT"
+// Незнакомый символ разбирается по формату рынка, нераспознанный возвращается как есть
+// Символ другого рынка той же биржи (BTC-USDT-SWAP на споте OKX, BTC-USDT на фьючерсах)
+// по формату не разбирается
+func (s *Symbols) Pair(marketType, symbol string) string {
+	upper := strings.ToUpper(symbol)
+
+	s.mu.RLock()
+	pair, ok := s.bySymbol[marketType+":"+upper]
+	s.mu.RUnlock()
+	if ok {
+		return pair
+	}
+
+	format := s.formats[marketType]
+	suffix := strings.ToUpper(format.Suffix)
+	if !strings.HasSuffix(upper, suffix) {
+		return upper
+	}
+	trimmed := strings.TrimSuffix(upper, suffix)
+
+	var base, quote string
+	if format.Separator != "" {
+		base, quote, ok = strings.Cut(trimmed, format.Separator)
+	} else if pair, ok = SplitSymbol(trimmed); ok {
+		base, quote, _ = strings.Cut(pair, "/")
+	}
+	if !ok || !isAsset(base) || !isAsset(quote) {
+		return upper
+	}
+	return unalias(format.Aliases, base) + "/" + unalias(format.Aliases, quote)
+}
+
+// isAsset сообщает похоже ли имя на актив: непустое, без разделителей символов
+func isAsset(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "-_/:")
+}
+
+// alias возвращает имя актива на бирже
+func alias(aliases map[string]string, asset string) string {
+	if native, ok := aliases[asset]; ok {
+		return native
+	}
+	return asset
+}
+
+// unalias возвращает внутреннее имя актива биржи
+func unalias(aliases map[string]string, native string) string {
+	for asset, name := range aliases {
+		if name == native {
+			return asset
+		}
+	}
+	return native
+}
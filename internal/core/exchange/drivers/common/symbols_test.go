@@ -0,0 +1,122 @@
+package common
+
+import (
+	"testing"
+
+	"trader/internal/core/exchange"
+)
+
+// venueFormats - форматы рынков бирж так же, как их задают драйверы
+var venueFormats = map[string]map[string]SymbolFormat{
+	exchange.Binance: {exchange.MarketSpot: {}, exchange.MarketFutures: {}},
+	exchange.HTX: {
+		exchange.MarketSpot:    {Lower: true},
+		exchange.MarketFutures: {Separator: "-"},
+	},
+	exchange.Kucoin: {
+		exchange.MarketSpot:    {Separator: "-"},
+		exchange.MarketFutures: {Suffix: "M", Aliases: map[string]string{"BTC": "XBT"}},
+	},
+	exchange.MEXC: {
+		exchange.MarketSpot:    {},
+		exchange.MarketFutures: {Separator: "_"},
+	},
+	exchange.OKX: {
+		exchange.MarketSpot:    {Separator: "-"},
+		exchange.MarketFutures: {Separator: "-", Suffix: "-SWAP"},
+	},
+}
+
+func TestSymbolsRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		exchangeID string
+		marketType string
+		pair       string
+		symbol     string
+	}{
+		{exchange.Binance, exchange.MarketSpot, "BTC/USDT", "BTCUSDT"},
+		{exchange.Binance, exchange.MarketFutures, "ETH/USDC", "ETHUSDC"},
+		{exchange.HTX, exchange.MarketSpot, "BTC/USDT", "btcusdt"},
+		{exchange.HTX, exchange.MarketFutures, "BTC/USDT", "BTC-USDT"},
+		{exchange.Kucoin, exchange.MarketSpot, "BTC/USDT", "BTC-USDT"},
+		{exchange.Kucoin, exchange.MarketFutures, "BTC/USDT", "XBTUSDTM"},
+		{exchange.Kucoin, exchange.MarketFutures, "ETH/USDT", "ETHUSDTM"},
+		{exchange.MEXC, exchange.MarketFutures, "SOL/USDT", "SOL_USDT"},
+		{exchange.OKX, exchange.MarketSpot, "BTC/USDT", "BTC-USDT"},
+		{exchange.OKX, exchange.MarketFutures, "BTC/USDT", "BTC-USDT-SWAP"},
+	} {
+		t.Run(tc.exchangeID+" "+tc.marketType+" "+tc.pair, func(t *testing.T) {
+			symbol, err := NewSymbols(venueFormats[tc.exchangeID]).Symbol(tc.marketType, tc.pair)
+			if err != nil || symbol != tc.symbol {
+				t.Fatalf("Symbol(%s) = %q, %v, want %q", tc.pair, symbol, err, tc.symbol)
+			}
+			// Разбор по формату, без запомненного Symbol соответствия
+			if pair := NewSymbols(venueFormats[tc.exchangeID]).Pair(tc.marketType, tc.symbol); pair != tc.pair {
+				t.Errorf("Pair(%s) = %q, want %q", tc.symbol, pair, tc.pair)
+			}
+		})
+	}
+}
+
+func TestSymbolsUnknownAndAmbiguous(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		exchangeID string
+		marketType string
+		symbol     string
+		want       string
+	}{
+		{"no known quote", exchange.Binance, exchange.MarketSpot, "foo", "FOO"},
+		{"quote only", exchange.Binance, exchange.MarketSpot, "USDT", "USDT"},
+		{"separator in compact format", exchange.Binance, exchange.MarketSpot, "BTC-USDT", "BTC-USDT"},
+		{"missing separator", exchange.OKX, exchange.MarketSpot, "BTCUSDT", "BTCUSDT"},
+		{"empty base", exchange.MEXC, exchange.MarketFutures, "_USDT", "_USDT"},
+		// Символы другого рынка той же биржи не разбираются в пару этого рынка
+		{"okx swap on spot", exchange.OKX, exchange.MarketSpot, "BTC-USDT-SWAP", "BTC-USDT-SWAP"},
+		{"okx spot on futures", exchange.OKX, exchange.MarketFutures, "BTC-USDT", "BTC-USDT"},
+		{"kucoin futures on spot", exchange.Kucoin, exchange.MarketSpot, "XBTUSDTM", "XBTUSDTM"},
+		{"kucoin spot on futures", exchange.Kucoin, exchange.MarketFutures, "BTC-USDT", "BTC-USDT"},
+		{"mexc futures on spot", exchange.MEXC, exchange.MarketSpot, "BTC_USDT", "BTC_USDT"},
+		// Алиас действует только на рынке, где он задан
+		{"kucoin alias on spot", exchange.Kucoin, exchange.MarketSpot, "XBT-USDT", "XBT/USDT"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NewSymbols(venueFormats[tc.exchangeID]).Pair(tc.marketType, tc.symbol); got != tc.want {
+				t.Errorf("Pair(%s, %s) = %q, want %q", tc.marketType, tc.symbol, got, tc.want)
+			}
+		})
+	}
+
+	symbols := NewSymbols(venueFormats[exchange.Binance])
+	for _, pair := range []string{"BTCUSDT", "BTC/", "/USDT", ""} {
+		if symbol, err := symbols.Symbol(exchange.MarketSpot, pair); err == nil {
+			t.Errorf("Symbol(%q) = %q, want error", pair, symbol)
+		}
+	}
+	// Рынок без формата - слитный символ
+	if symbol, err := symbols.Symbol("options", "btc/usdt"); err != nil || symbol != "BTCUSDT" {
+		t.Errorf("Symbol(options, btc/usdt) = %q, %v, want BTCUSDT", symbol, err)
+	}
+}
+
+func TestSymbolsRememberPerMarket(t *testing.T) {
+	symbols := NewSymbols(venueFormats[exchange.Binance])
+
+	// Один и тот же символ на рынках биржи - разные инструменты
+	symbols.Remember(exchange.MarketFutures, "1000PEPEUSDT", "PEPE/USDT")
+	if got := symbols.Pair(exchange.MarketFutures, "1000pepeusdt"); got != "PEPE/USDT" {
+		t.Errorf("futures Pair = %q, want PEPE/USDT", got)
+	}
+	if got := symbols.Pair(exchange.MarketSpot, "1000PEPEUSDT"); got != "1000PEPE/USDT" {
+		t.Errorf("spot Pair = %q, want 1000PEPE/USDT", got)
+	}
+
+	// Запомненное соответствие важнее разбора по формату
+	symbols.Remember(exchange.MarketSpot, "FOO", "FOO/BAR")
+	if got := symbols.Pair(exchange.MarketSpot, "foo"); got != "FOO/BAR" {
+		t.Errorf("remembered Pair = %q, want FOO/BAR", got)
+	}
+	if got := symbols.Pair(exchange.MarketFutures, "FOO"); got != "FOO" {
+		t.Errorf("futures Pair of spot symbol = %q, want FOO", got)
+	}
+}
@@ -0,0 +1,16 @@
+// Package drivers подключает все драйверы бирж
+// Каждый драйвер регистрирует себя в реестре exchange из init(),
+// поэтому достаточно импортировать этот пакет ради побочного эффекта:
+//
+//	import _ "trader/internal/core/exchange/drivers"
+package drivers
+
+import (
+	_ "trader/internal/core/exchange/drivers/binance"
+	_ "trader/internal/core/exchange/drivers/bybit"
+	_ "trader/internal/core/exchange/drivers/coinex"
+	_ "trader/internal/core/exchange/drivers/htx"
+	_ "trader/internal/core/exchange/drivers/kucoin"
+	_ "trader/internal/core/exchange/drivers/mexc"
+	_ "trader/internal/core/exchange/drivers/okx"
+)
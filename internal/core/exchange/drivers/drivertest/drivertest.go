@@ -0,0 +1,308 @@
+// Package drivertest - проверка драйверов бирж на записанных фреймах из testdata
+//
+// Фреймы лежат в testdata пакета драйвера в том виде, в каком их присылает биржа
+// (в том числе сжатые или protobuf). Тест драйвера описывает ожидаемые сообщения
+// таблицей Case и прогоняет ее через Run. Загрузка инструментов по REST проверяется
+// так же: таблица InstrumentCase с ответами стенда прогоняется через RunInstruments.
+package drivertest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+)
+
+// Level - ожидаемый уровень книги: цена и объем в виде строк
+type Level [2]string
+
+// Trade - ожидаемая сделка
+type Trade struct {
+	Price  string
+	Amount string
+	Side   string
+	ID     string
+}
+
+// Message - ожидаемое сообщение; нулевые Timestamp и SeqNum не проверяются
+type Message struct {
+	Type      string
+	Pair      string
+	Timestamp int64
+	SeqNum    int64
+
+	// Для TypeOrderBook
+	Snapshot bool
+	Depth    int
+	Bids     []Level
+	Asks     []Level
+
+	// Для TypeTrade
+	Trade *Trade
+}
+
+// Case - один фрейм из testdata и сообщения, которые должен вернуть ParseMessage
+type Case struct {
+	Name       string
+	File       string
+	MarketType string
+	Want       []Message
+	// Err - ожидаемая ошибка разбора (errors.Is), nil - разбор без ошибки
+	Err error
+}
+
+// Run прогоняет фреймы таблицы через один экземпляр драйвера по порядку:
+// драйверы с состоянием (checksum книги) видят фреймы в той же последовательности
+func Run(t *testing.T, driver exchange.ExchangeDriver, cases []Case) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			got, err := driver.ParseMessage(tc.MarketType, Frame(t, driver, tc.File))
+			if tc.Err != nil {
+				if !errors.Is(err, tc.Err) {
+					t.Fatalf("ParseMessage error = %v, want %v", err, tc.Err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMessage: %v", err)
+			}
+			Check(t, driver.GetExchangeID(), tc.MarketType, got, tc.Want)
+		})
+	}
+}
+
+// Parse разбирает один фрейм из testdata и требует успешного разбора
+func Parse(t *testing.T, driver exchange.ExchangeDriver, marketType, file string) []*messaging.Message {
+	t.Helper()
+	messages, err := driver.ParseMessage(marketType, Frame(t, driver, file))
+	if err != nil {
+		t.Fatalf("ParseMessage %s: %v", file, err)
+	}
+	return messages
+}
+
+// Frame читает фрейм из testdata и распаковывает его, если драйвер объявил FrameDecoder
+func Frame(t *testing.T, driver exchange.ExchangeDriver, file string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	if decoder, ok := driver.(exchange.FrameDecoder); ok {
+		if data, err = decoder.DecodeFrame(data); err != nil {
+			t.Fatalf("DecodeFrame %s: %v", file, err)
+		}
+	}
+	return data
+}
+
+// Serve поднимает REST стенд, который на любой запрос отвечает файлом из testdata
+// Возвращает адрес стенда и канал с путями полученных запросов
+func Serve(t *testing.T, file string) (string, <-chan string) {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	paths := make(chan string, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case paths <- r.URL.RequestURI():
+		default:
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, paths
+}
+
+// Check сравнивает разобранные сообщения с ожидаемыми
+func Check(t *testing.T, exchangeID, marketType string, got []*messaging.Message, want []Message) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		msg := got[i]
+		if msg.ExchangeID != exchangeID || msg.MarketType != marketType {
+			t.Errorf("message %d: source = %s/%s, want %s/%s", i, msg.ExchangeID, msg.MarketType, exchangeID, marketType)
+		}
+		if msg.Type != w.Type || msg.Pair != w.Pair {
+			t.Errorf("message %d: %s %s, want %s %s", i, msg.Type, msg.Pair, w.Type, w.Pair)
+		}
+		if w.Timestamp != 0 && msg.Timestamp != w.Timestamp {
+			t.Errorf("message %d: Timestamp = %d, want %d", i, msg.Timestamp, w.Timestamp)
+		}
+		if w.SeqNum != 0 && msg.SeqNum != w.SeqNum {
+			t.Errorf("message %d: SeqNum = %d, want %d", i, msg.SeqNum, w.SeqNum)
+		}
+
+		switch w.Type {
+		case messaging.TypeOrderBook:
+			book := msg.OrderBook
+			if book == nil {
+				t.Errorf("message %d: OrderBook is nil", i)
+				continue
+			}
+			if book.Snapshot != w.Snapshot || book.Depth != w.Depth {
+				t.Errorf("message %d: snapshot=%v depth=%d, want snapshot=%v depth=%d",
+					i, book.Snapshot, book.Depth, w.Snapshot, w.Depth)
+			}
+			CheckLevels(t, "bids", book.Bids, w.Bids)
+			CheckLevels(t, "asks", book.Asks, w.Asks)
+		case messaging.TypeTrade:
+			trade := msg.Trade
+			if trade == nil || w.Trade == nil {
+				t.Errorf("message %d: Trade = %+v, want %+v", i, trade, w.Trade)
+				continue
+			}
+			if !equal(trade.Price, w.Trade.Price) || !equal(trade.Amount, w.Trade.Amount) ||
+				trade.Side != w.Trade.Side || trade.TradeID != w.Trade.ID {
+				t.Errorf("message %d: trade %s %s %s id=%q, want %s %s %s id=%q", i,
+					trade.Price, trade.Amount, trade.Side, trade.TradeID,
+					w.Trade.Price, w.Trade.Amount, w.Trade.Side, w.Trade.ID)
+			}
+		}
+	}
+}
+
+// CheckLevels сравнивает уровни книги по значению Decimal (1.50 == 1.5)
+func CheckLevels(t *testing.T, side string, got []messaging.Level, want []Level) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: got %d levels %v, want %v", side, len(got), got, want)
+		return
+	}
+	for i, w := range want {
+		if !equal(got[i].Price, w[0]) || !equal(got[i].Amount, w[1]) {
+			t.Errorf("%s[%d] = %s@%s, want %s@%s", side, i, got[i].Amount, got[i].Price, w[1], w[0])
+		}
+	}
+}
+
+func equal(got decimal.Decimal, want string) bool {
+	return got.Equal(decimal.RequireFromString(want))
+}
+
+// Align - ожидаемый результат AlignDelta для дельты из testdata
+type Align struct {
+	Name          string
+	LastSeqNum    int64
+	AfterSnapshot bool
+	Want          exchange.SeqAlignment
+}
+
+// RunAlign сверяет дельту delta со всеми состояниями книги таблицы
+func RunAlign(t *testing.T, aligner exchange.SequenceAligner, marketType string, delta *messaging.Message, cases []Align) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			if got := aligner.AlignDelta(marketType, tc.LastSeqNum, tc.AfterSnapshot, delta); got != tc.Want {
+				t.Errorf("AlignDelta(last=%d, afterSnapshot=%v) = %d, want %d", tc.LastSeqNum, tc.AfterSnapshot, got, tc.Want)
+			}
+		})
+	}
+}
+
+// Instrument - ожидаемые параметры инструмента; пустая строка - нулевое значение
+type Instrument struct {
+	Pair         string
+	Symbol       string
+	TickSize     string
+	StepSize     string
+	MinQuantity  string
+	MinNotional  string
+	ContractSize string
+}
+
+// InstrumentDriver - драйвер, загружающий инструменты и переводящий их символы
+type InstrumentDriver interface {
+	exchange.ExchangeDriver
+	exchange.InstrumentProvider
+	exchange.SymbolMapper
+}
+
+// InstrumentCase - ответ REST стенда из testdata и инструменты, которые должен вернуть FetchInstruments
+type InstrumentCase struct {
+	MarketType string
+	File       string
+	// Path - ожидаемый путь запроса вместе с query
+	Path string
+	Want []Instrument
+}
+
+// RunInstruments загружает инструменты рынков таблицы со стенда Serve
+// Для каждого случая драйвер создается заново: endpoints возвращает адреса драйвера,
+// в которых REST рынков указывает на стенд
+// Символы загруженных инструментов должны переводиться обратно в пару (SymbolMapper)
+func RunInstruments[E any, D InstrumentDriver](t *testing.T, driver func(E) D, endpoints func(restURL string) E, cases []InstrumentCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.MarketType, func(t *testing.T) {
+			url, paths := Serve(t, tc.File)
+			d := driver(endpoints(url))
+
+			instruments, err := d.FetchInstruments(context.Background(), tc.MarketType)
+			if err != nil {
+				t.Fatalf("FetchInstruments: %v", err)
+			}
+			if path := <-paths; path != tc.Path {
+				t.Errorf("request = %s, want %s", path, tc.Path)
+			}
+			CheckInstruments(t, d.GetExchangeID(), tc.MarketType, instruments, tc.Want)
+
+			for _, instrument := range instruments {
+				if pair := d.Pair(tc.MarketType, instrument.Symbol); pair != instrument.Pair {
+					t.Errorf("Pair(%s) = %q, want %s", instrument.Symbol, pair, instrument.Pair)
+				}
+			}
+		})
+	}
+}
+
+// CheckInstruments сравнивает загруженные инструменты с ожидаемыми по значению Decimal
+func CheckInstruments(t *testing.T, exchangeID, marketType string, got []exchange.Instrument, want []Instrument) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d instruments %+v, want %d", len(got), got, len(want))
+	}
+	for i, w := range want {
+		instrument := got[i]
+		if instrument.ExchangeID != exchangeID || instrument.MarketType != marketType {
+			t.Errorf("instrument %d: source = %s/%s, want %s/%s",
+				i, instrument.ExchangeID, instrument.MarketType, exchangeID, marketType)
+		}
+		if instrument.Pair != w.Pair || instrument.Symbol != w.Symbol {
+			t.Errorf("instrument %d: %s %s, want %s %s", i, instrument.Pair, instrument.Symbol, w.Pair, w.Symbol)
+		}
+		for _, field := range []struct {
+			name string
+			got  decimal.Decimal
+			want string
+		}{
+			{"TickSize", instrument.TickSize, w.TickSize},
+			{"StepSize", instrument.StepSize, w.StepSize},
+			{"MinQuantity", instrument.MinQuantity, w.MinQuantity},
+			{"MinNotional", instrument.MinNotional, w.MinNotional},
+			{"ContractSize", instrument.ContractSize, w.ContractSize},
+		} {
+			want := field.want
+			if want == "" {
+				want = "0"
+			}
+			if !equal(field.got, want) {
+				t.Errorf("instrument %d %s: %s = %s, want %s", i, instrument.Symbol, field.name, field.got, want)
+			}
+		}
+	}
+}
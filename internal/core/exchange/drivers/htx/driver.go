@@ -0,0 +1,560 @@
+// Package htx реализует market-data драйвер HTX (бывший Huobi): spot и USDT linear swap
+//
+// Все фреймы сервера сжаты gzip и приходят бинарными сообщениями, они распаковываются в DecodeFrame.
+// Сервер периодически шлет {"ping": N} и закрывает соединение, если не получает {"pong": N} -
+// ответ строится в ReplyTo.
+// Книга спота до 20 уровней идет каналом mbp.refresh, глубже и на swap - depth.step0
+// (снимок 150 уровней раз в секунду). Каждое сообщение книги является снимком.
+package htx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/exchange/drivers/common"
+	"trader/internal/core/messaging"
+	"trader/internal/logger"
+)
+
+const (
+	// SpotWSEndpoint - публичный WS спота
+	SpotWSEndpoint = "wss://api.huobi.pro/ws"
+	// LinearSwapWSEndpoint - публичный WS USDT linear swap
+	LinearSwapWSEndpoint = "wss://api.hbdm.com/linear-swap-ws"
+	// SpotRESTEndpoint - базовый URL REST API спота
+	SpotRESTEndpoint = "https://api.huobi.pro"
+	// LinearSwapRESTEndpoint - базовый URL REST API linear swap
+	LinearSwapRESTEndpoint = "https://api.hbdm.com"
+
+	// step0Depth - сколько уровней присылает канал depth.step0
+	step0Depth = 150
+)
+
+// Endpoints - адреса WS и REST API, переопределяются для тестового стенда
+type Endpoints struct {
+	SpotWS         string
+	LinearSwapWS   string
+	SpotREST       string
+	LinearSwapREST string
+}
+
+// DefaultEndpoints возвращает боевые адреса HTX
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		SpotWS:         SpotWSEndpoint,
+		LinearSwapWS:   LinearSwapWSEndpoint,
+		SpotREST:       SpotRESTEndpoint,
+		LinearSwapREST: LinearSwapRESTEndpoint,
+	}
+}
+
+func init() {
+	exchange.Register(exchange.HTX, func() exchange.ExchangeDriver {
+		return New(DefaultEndpoints())
+	})
+}
+
+// Driver - драйвер HTX
+type Driver struct {
+	endpoints Endpoints
+	client    *http.Client
+	restLog   *slog.Logger
+	requestID atomic.Int64
+	symbols   *common.Symbols
+}
+
+// New создает драйвер с указанными адресами
+func New(endpoints Endpoints) *Driver {
+	return &Driver{
+		endpoints: endpoints,
+		client:    common.NewHTTPClient(),
+		restLog:   logger.GetOutRequest("htx"),
+		symbols:   common.NewSymbols(symbolFormats),
+	}
+}
+
+// GetExchangeID возвращает ID биржи
+func (d *Driver) GetExchangeID() string {
+	return exchange.HTX
+}
+
+// GetName возвращает название биржи
+func (d *Driver) GetName() string {
+	return "HTX"
+}
+
+// GetWSEndpoint возвращает публичный WS для рынка (futures = USDT linear swap)
+func (d *Driver) GetWSEndpoint(marketType string) (string, error) {
+	switch marketType {
+	case exchange.MarketSpot:
+		return d.endpoints.SpotWS, nil
+	case exchange.MarketFutures:
+		return d.endpoints.LinearSwapWS, nil
+	default:
+		return "", fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+}
+
+// CreateSubscribeMessage строит sub фреймы: HTX принимает один канал на запрос
+func (d *Driver) CreateSubscribeMessage(marketType string, pairs []string, depth int) ([][]byte, error) {
+	return d.buildFrames("sub", marketType, pairs, depth)
+}
+
+// CreateUnsubscribeMessage строит unsub фреймы
+func (d *Driver) CreateUnsubscribeMessage(marketType string, pairs []string, depth int) ([][]byte, error) {
+	return d.buildFrames("unsub", marketType, pairs, depth)
+}
+
+func (d *Driver) buildFrames(op, marketType string, pairs []string, depth int) ([][]byte, error) {
+	if _, err := d.GetWSEndpoint(marketType); err != nil {
+		return nil, err
+	}
+
+	frames := make([][]byte, 0, len(pairs)*2)
+	for _, pair := range pairs {
+		symbol, err := d.symbols.Symbol(marketType, pair)
+		if err != nil {
+			return nil, err
+		}
+		for _, channel := range []string{bookChannel(marketType, symbol, depth), "market." + symbol + ".trade.detail"} {
+			frame, err := json.Marshal(map[string]string{
+				op:   channel,
+				"id": strconv.FormatInt(d.requestID.Add(1), 10),
+			})
+			if err != nil {
+				return nil, err
+			}
+			frames = append(frames, frame)
+		}
+	}
+	return frames, nil
+}
+
+// bookChannel - mbp.refresh.{5,10,20} для неглубокой книги спота, иначе depth.step0
+func bookChannel(marketType, symbol string, depth int) string {
+	if marketType == exchange.MarketSpot {
+		switch {
+		case depth > 0 && depth <= 5:
+			return "market." + symbol + ".mbp.refresh.5"
+		case depth > 5 && depth <= 10:
+			return "market." + symbol + ".mbp.refresh.10"
+		case depth > 10 && depth <= 20:
+			return "market." + symbol + ".mbp.refresh.20"
+		}
+	}
+	return "market." + symbol + ".depth.step0"
+}
+
+// symbolFormats - btcusdt на споте, BTC-USDT на swap
+var symbolFormats = map[string]common.SymbolFormat{
+	exchange.MarketSpot:    {Lower: true},
+	exchange.MarketFutures: {Separator: "-"},
+}
+
+// Symbol переводит "BTC/USDT" в "btcusdt" (spot) или "BTC-USDT" (swap)
+func (d *Driver) Symbol(marketType, pair string) (string, error) {
+	return d.symbols.Symbol(marketType, pair)
+}
+
+// Pair переводит символ HTX обратно в "BTC/USDT"
+func (d *Driver) Pair(marketType, symbol string) string {
+	return d.symbols.Pair(marketType, symbol)
+}
+
+// ============================================================================
+// Служебные фреймы
+// ============================================================================
+
+// DecodeFrame распаковывает gzip фрейм HTX
+func (d *Driver) DecodeFrame(data []byte) ([]byte, error) {
+	return common.Decompress(data)
+}
+
+type pingFrame struct {
+	Ping *int64 `json:"ping"`
+}
+
+// ReplyTo отвечает {"pong": N} на серверный {"ping": N}
+func (d *Driver) ReplyTo(marketType string, data []byte) []byte {
+	ping, ok := parsePing(data)
+	if !ok {
+		return nil
+	}
+	return []byte(`{"pong":` + strconv.FormatInt(ping, 10) + `}`)
+}
+
+// ServerPingTime возвращает N серверного {"ping": N} - время сервера HTX в миллисекундах
+func (d *Driver) ServerPingTime(marketType string, data []byte) (int64, bool) {
+	ping, ok := parsePing(data)
+	if !ok {
+		return 0, false
+	}
+	return common.MillisToMicros(ping), true
+}
+
+// parsePing возвращает N из {"ping": N}
+func parsePing(data []byte) (int64, bool) {
+	if !strings.HasPrefix(string(data), `{"ping"`) {
+		return 0, false
+	}
+	var ping pingFrame
+	if err := json.Unmarshal(data, &ping); err != nil || ping.Ping == nil {
+		return 0, false
+	}
+	return *ping.Ping, true
+}
+
+// ============================================================================
+// Разбор входящих фреймов
+// ============================================================================
+
+type pushFrame struct {
+	Ch   string          `json:"ch"`
+	TS   int64           `json:"ts"`
+	Tick json.RawMessage `json:"tick"`
+
+	// Ответы на sub/unsub: {"id":"1","status":"ok","subbed":"..."} / {"status":"error","err-code":"...","err-msg":"..."}
+	Status  string `json:"status"`
+	ErrCode string `json:"err-code"`
+	ErrMsg  string `json:"err-msg"`
+}
+
+type bookTick struct {
+	Bids    [][]json.Number `json:"bids"`
+	Asks    [][]json.Number `json:"asks"`
+	SeqNum  int64           `json:"seqNum"`
+	Version int64           `json:"version"`
+	TS      int64           `json:"ts"`
+}
+
+type tradeTick struct {
+	Data []tradeItem `json:"data"`
+}
+
+// tradeItem - у swap "amount" в контрактах, объем в монетах передается в "quantity"
+// ID сделки спота не помещается в int64, поэтому id хранится как json.Number
+type tradeItem struct {
+	ID        json.Number `json:"id"`
+	TradeID   json.Number `json:"tradeId"`
+	TS        int64       `json:"ts"`
+	Amount    json.Number `json:"amount"`
+	Quantity  json.Number `json:"quantity"`
+	Price     json.Number `json:"price"`
+	Direction string      `json:"direction"`
+}
+
+// ParseMessage разбирает распакованный фрейм HTX
+func (d *Driver) ParseMessage(marketType string, data []byte) ([]*messaging.Message, error) {
+	var frame pushFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return nil, fmt.Errorf("decode htx frame: %w", err)
+	}
+
+	if frame.Ch == "" {
+		if frame.Status == "error" {
+			return nil, fmt.Errorf("htx error %s: %s", frame.ErrCode, frame.ErrMsg)
+		}
+		// ack подписки и ping (ответ на ping уже отправлен через ReplyTo)
+		return nil, nil
+	}
+
+	// ch = market.{symbol}.{channel...}
+	parts := strings.SplitN(frame.Ch, ".", 3)
+	if len(parts) != 3 || parts[0] != "market" {
+		return nil, fmt.Errorf("unsupported htx channel %q", frame.Ch)
+	}
+	pair := d.symbols.Pair(marketType, parts[1])
+
+	switch {
+	case parts[2] == "trade.detail":
+		return parseTrades(marketType, pair, frame.Tick)
+	case parts[2] == "depth.step0", strings.HasPrefix(parts[2], "mbp.refresh."):
+		msg, err := parseBook(marketType, pair, parts[2], &frame)
+		if err != nil {
+			return nil, err
+		}
+		return []*messaging.Message{msg}, nil
+	default:
+		return nil, fmt.Errorf("unsupported htx channel %q", frame.Ch)
+	}
+}
+
+func parseBook(marketType, pair, channel string, frame *pushFrame) (*messaging.Message, error) {
+	var tick bookTick
+	if err := json.Unmarshal(frame.Tick, &tick); err != nil {
+		return nil, fmt.Errorf("decode htx depth: %w", err)
+	}
+
+	bids, err := common.ParseNumberLevels(tick.Bids)
+	if err != nil {
+		return nil, err
+	}
+	asks, err := common.ParseNumberLevels(tick.Asks)
+	if err != nil {
+		return nil, err
+	}
+
+	depth := step0Depth
+	if levels, ok := strings.CutPrefix(channel, "mbp.refresh."); ok {
+		if depth, err = strconv.Atoi(levels); err != nil {
+			return nil, fmt.Errorf("invalid htx depth channel %q", channel)
+		}
+	}
+
+	seqNum := tick.SeqNum
+	if seqNum == 0 {
+		seqNum = tick.Version
+	}
+	ts := tick.TS
+	if ts == 0 {
+		ts = frame.TS
+	}
+
+	return &messaging.Message{
+		Timestamp:  common.MillisToMicros(ts),
+		ExchangeID: exchange.HTX,
+		MarketType: marketType,
+		Type:       messaging.TypeOrderBook,
+		Pair:       pair,
+		SeqNum:     seqNum,
+		OrderBook: &messaging.OrderBookData{
+			Bids:        bids,
+			Asks:        asks,
+			Depth:       depth,
+			Snapshot:    true,
+			FirstSeqNum: seqNum,
+		},
+	}, nil
+}
+
+func parseTrades(marketType, pair string, raw json.RawMessage) ([]*messaging.Message, error) {
+	var tick tradeTick
+	if err := json.Unmarshal(raw, &tick); err != nil {
+		return nil, fmt.Errorf("decode htx trade.detail: %w", err)
+	}
+
+	messages := make([]*messaging.Message, 0, len(tick.Data))
+	for _, item := range tick.Data {
+		price, err := common.ParseNumber(item.Price)
+		if err != nil {
+			return nil, fmt.Errorf("invalid htx trade price %q: %w", item.Price, err)
+		}
+		size := item.Amount
+		if item.Quantity != "" {
+			size = item.Quantity
+		}
+		amount, err := common.ParseNumber(size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid htx trade amount %q: %w", size, err)
+		}
+
+		tradeID := item.TradeID
+		if tradeID == "" {
+			tradeID = item.ID
+		}
+		seq, _ := tradeID.Int64()
+
+		messages = append(messages, &messaging.Message{
+			Timestamp:  common.MillisToMicros(item.TS),
+			ExchangeID: exchange.HTX,
+			MarketType: marketType,
+			Type:       messaging.TypeTrade,
+			Pair:       pair,
+			SeqNum:     seq,
+			Trade: &messaging.TradeData{
+				Price:   price,
+				Amount:  amount,
+				Side:    item.Direction,
+				TradeID: tradeID.String(),
+			},
+		})
+	}
+	return messages, nil
+}
+
+// timestampResponse - спот отдает время в data, linear swap - в ts
+type timestampResponse struct {
+	Status  string `json:"status"`
+	Data    int64  `json:"data"`
+	TS      int64  `json:"ts"`
+	ErrMsg  string `json:"err-msg"`
+	ErrCode string `json:"err-code"`
+}
+
+// ServerTime запрашивает /v1/common/timestamp (spot) или /api/v1/timestamp (linear swap)
+func (d *Driver) ServerTime(ctx context.Context, marketType string) (int64, error) {
+	var endpoint string
+	switch marketType {
+	case exchange.MarketSpot:
+		endpoint = d.endpoints.SpotREST + "/v1/common/timestamp"
+	case exchange.MarketFutures:
+		endpoint = d.endpoints.LinearSwapREST + "/api/v1/timestamp"
+	default:
+		return 0, fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+
+	var resp timestampResponse
+	if err := common.GetJSON(ctx, d.client, d.restLog, nil, endpoint, &resp); err != nil {
+		return 0, err
+	}
+	if resp.Status != "ok" {
+		return 0, fmt.Errorf("htx timestamp %s: %s", resp.ErrCode, resp.ErrMsg)
+	}
+	ms := resp.Data
+	if marketType == exchange.MarketFutures {
+		ms = resp.TS
+	}
+	return common.MillisToMicros(ms), nil
+}
+
+// ============================================================================
+// Инструменты
+// ============================================================================
+
+// spotSymbolsResponse - /v1/common/symbols, шаги цены и объема заданы точностью
+type spotSymbolsResponse struct {
+	Status  string `json:"status"`
+	ErrCode string `json:"err-code"`
+	ErrMsg  string `json:"err-msg"`
+	Data    []struct {
+		Symbol          string      `json:"symbol"`
+		BaseCurrency    string      `json:"base-currency"`
+		QuoteCurrency   string      `json:"quote-currency"`
+		State           string      `json:"state"`
+		PricePrecision  int32       `json:"price-precision"`
+		AmountPrecision int32       `json:"amount-precision"`
+		MinOrderAmt     json.Number `json:"min-order-amt"`
+		MinOrderValue   json.Number `json:"min-order-value"`
+	} `json:"data"`
+}
+
+// contractInfoResponse - /linear-swap-api/v1/swap_contract_info
+type contractInfoResponse struct {
+	Status  string `json:"status"`
+	ErrCode int    `json:"err_code"`
+	ErrMsg  string `json:"err_msg"`
+	Data    []struct {
+		Symbol         string      `json:"symbol"`
+		ContractCode   string      `json:"contract_code"`
+		ContractSize   json.Number `json:"contract_size"`
+		PriceTick      json.Number `json:"price_tick"`
+		ContractStatus int         `json:"contract_status"`
+		TradePartition string      `json:"trade_partition"`
+	} `json:"data"`
+}
+
+// contractListed - contract_status торгуемого контракта
+const contractListed = 1
+
+// FetchInstruments загружает /v1/common/symbols (spot) или
+// /linear-swap-api/v1/swap_contract_info (linear swap, только бессрочные)
+// Возвращаются только торгуемые инструменты. Объем swap задается в контрактах:
+// шаг и минимум - один контракт, ContractSize - монет в контракте
+func (d *Driver) FetchInstruments(ctx context.Context, marketType string) ([]exchange.Instrument, error) {
+	switch marketType {
+	case exchange.MarketSpot:
+		return d.fetchSpotSymbols(ctx)
+	case exchange.MarketFutures:
+		return d.fetchContracts(ctx)
+	default:
+		return nil, fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+}
+
+// fetchSpotSymbols загружает символы спота в статусе online
+func (d *Driver) fetchSpotSymbols(ctx context.Context) ([]exchange.Instrument, error) {
+	var resp spotSymbolsResponse
+	if err := common.GetJSON(ctx, d.client, d.restLog, nil, d.endpoints.SpotREST+"/v1/common/symbols", &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "ok" {
+		return nil, fmt.Errorf("htx symbols %s: %s", resp.ErrCode, resp.ErrMsg)
+	}
+
+	instruments := make([]exchange.Instrument, 0, len(resp.Data))
+	for _, item := range resp.Data {
+		if item.State != "online" {
+			continue
+		}
+
+		instrument := exchange.Instrument{
+			ExchangeID:   exchange.HTX,
+			MarketType:   exchange.MarketSpot,
+			Pair:         strings.ToUpper(item.BaseCurrency) + "/" + strings.ToUpper(item.QuoteCurrency),
+			Symbol:       item.Symbol,
+			TickSize:     decimal.New(1, -item.PricePrecision),
+			StepSize:     decimal.New(1, -item.AmountPrecision),
+			ContractSize: decimal.NewFromInt(1),
+		}
+		var err error
+		if instrument.MinQuantity, err = common.ParseNumber(item.MinOrderAmt); err != nil {
+			return nil, fmt.Errorf("invalid htx %s min-order-amt: %w", item.Symbol, err)
+		}
+		if instrument.MinNotional, err = common.ParseNumber(item.MinOrderValue); err != nil {
+			return nil, fmt.Errorf("invalid htx %s min-order-value: %w", item.Symbol, err)
+		}
+
+		d.symbols.Remember(exchange.MarketSpot, item.Symbol, instrument.Pair)
+		instruments = append(instruments, instrument)
+	}
+	return instruments, nil
+}
+
+// fetchContracts загружает торгуемые бессрочные линейные контракты
+func (d *Driver) fetchContracts(ctx context.Context) ([]exchange.Instrument, error) {
+	var resp contractInfoResponse
+	endpoint := d.endpoints.LinearSwapREST + "/linear-swap-api/v1/swap_contract_info?business_type=swap"
+	if err := common.GetJSON(ctx, d.client, d.restLog, nil, endpoint, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "ok" {
+		return nil, fmt.Errorf("htx swap_contract_info %d: %s", resp.ErrCode, resp.ErrMsg)
+	}
+
+	instruments := make([]exchange.Instrument, 0, len(resp.Data))
+	for _, item := range resp.Data {
+		if item.ContractStatus != contractListed {
+			continue
+		}
+
+		instrument := exchange.Instrument{
+			ExchangeID:  exchange.HTX,
+			MarketType:  exchange.MarketFutures,
+			Pair:        item.Symbol + "/" + item.TradePartition,
+			Symbol:      item.ContractCode,
+			StepSize:    decimal.NewFromInt(1),
+			MinQuantity: decimal.NewFromInt(1),
+		}
+		var err error
+		if instrument.TickSize, err = common.ParseNumber(item.PriceTick); err != nil {
+			return nil, fmt.Errorf("invalid htx %s price_tick: %w", item.ContractCode, err)
+		}
+		if instrument.ContractSize, err = common.ParseNumber(item.ContractSize); err != nil {
+			return nil, fmt.Errorf("invalid htx %s contract_size: %w", item.ContractCode, err)
+		}
+
+		d.symbols.Remember(exchange.MarketFutures, item.ContractCode, instrument.Pair)
+		instruments = append(instruments, instrument)
+	}
+	return instruments, nil
+}
+
+// Проверка реализации интерфейсов на этапе компиляции
+var (
+	_ exchange.ExchangeDriver     = (*Driver)(nil)
+	_ exchange.FrameDecoder       = (*Driver)(nil)
+	_ exchange.FrameResponder     = (*Driver)(nil)
+	_ exchange.ServerClock        = (*Driver)(nil)
+	_ exchange.ServerPinger       = (*Driver)(nil)
+	_ exchange.InstrumentProvider = (*Driver)(nil)
+	_ exchange.SymbolMapper       = (*Driver)(nil)
+)
@@ -0,0 +1,108 @@
+package htx
+
+import (
+	"testing"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/exchange/drivers/drivertest"
+	"trader/internal/core/messaging"
+)
+
+type (
+	level   = drivertest.Level
+	message = drivertest.Message
+	trade   = drivertest.Trade
+)
+
+func TestParseMessage(t *testing.T) {
+	drivertest.Run(t, New(DefaultEndpoints()), []drivertest.Case{
+		{
+			Name: "subscribe ack", File: "subscribe_ack.json.gz", MarketType: exchange.MarketSpot,
+		},
+		{
+			Name: "spot mbp refresh", File: "spot_mbp_refresh20.json.gz", MarketType: exchange.MarketSpot,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "BTC/USDT", Timestamp: 1731898619520000, SeqNum: 100020142010,
+				Snapshot: true, Depth: 20,
+				Bids: []level{{"67215.0", "1.20441"}, {"67214.9", "0.0451"}, {"67214.1", "0.3"}},
+				Asks: []level{{"67215.1", "0.51234"}, {"67215.2", "0.00015"}, {"67216.0", "0.1"}},
+			}},
+		},
+		{
+			Name: "spot trades", File: "spot_trade_detail.json.gz", MarketType: exchange.MarketSpot,
+			Want: []message{
+				{
+					Type: messaging.TypeTrade, Pair: "BTC/USDT", Timestamp: 1731898619527000, SeqNum: 102523573486,
+					Trade: &trade{Price: "67215.1", Amount: "0.006754", Side: "buy", ID: "102523573486"},
+				},
+				{
+					Type: messaging.TypeTrade, Pair: "BTC/USDT", Timestamp: 1731898619527000, SeqNum: 102523573487,
+					Trade: &trade{Price: "67215", Amount: "0.01", Side: "sell", ID: "102523573487"},
+				},
+			},
+		},
+		{
+			Name: "swap depth", File: "swap_depth_step0.json.gz", MarketType: exchange.MarketFutures,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "BTC/USDT", Timestamp: 1731898619538000, SeqNum: 1731898619,
+				Snapshot: true, Depth: 150,
+				Bids: []level{{"67201.1", "1200"}, {"67201", "35"}},
+				Asks: []level{{"67201.2", "854"}, {"67201.9", "12"}},
+			}},
+		},
+		{
+			// Объем сделки swap - в базовом активе (quantity), а не в контрактах (amount)
+			Name: "swap trade", File: "swap_trade_detail.json.gz", MarketType: exchange.MarketFutures,
+			Want: []message{{
+				Type: messaging.TypeTrade, Pair: "BTC/USDT", Timestamp: 1731898619549000, SeqNum: 2000323456790000,
+				Trade: &trade{Price: "67201.1", Amount: "0.012", Side: "sell", ID: "2000323456790000"},
+			}},
+		},
+	})
+}
+
+func TestReplyToPing(t *testing.T) {
+	driver := New(DefaultEndpoints())
+	ping := drivertest.Frame(t, driver, "ping.json.gz")
+	if reply := driver.ReplyTo(exchange.MarketSpot, ping); string(reply) != `{"pong":1731898619600}` {
+		t.Errorf("ReplyTo(ping) = %s", reply)
+	}
+	if reply := driver.ReplyTo(exchange.MarketSpot, drivertest.Frame(t, driver, "spot_trade_detail.json.gz")); reply != nil {
+		t.Errorf("ReplyTo(trade) = %s, want nil", reply)
+	}
+}
+
+func TestServerPingTime(t *testing.T) {
+	driver := New(DefaultEndpoints())
+	if at, ok := driver.ServerPingTime(exchange.MarketSpot, drivertest.Frame(t, driver, "ping.json.gz")); !ok || at != 1731898619600000 {
+		t.Errorf("ServerPingTime(ping) = %d, %v", at, ok)
+	}
+	if _, ok := driver.ServerPingTime(exchange.MarketSpot, drivertest.Frame(t, driver, "spot_trade_detail.json.gz")); ok {
+		t.Error("ServerPingTime(trade) reported a ping")
+	}
+}
+
+func TestFetchInstruments(t *testing.T) {
+	withREST := func(url string) Endpoints {
+		endpoints := DefaultEndpoints()
+		endpoints.SpotREST = url
+		endpoints.LinearSwapREST = url
+		return endpoints
+	}
+	drivertest.RunInstruments(t, New, withREST, []drivertest.InstrumentCase{
+		{
+			MarketType: exchange.MarketSpot, File: "spot_symbols.json", Path: "/v1/common/symbols",
+			Want: []drivertest.Instrument{{
+				Pair: "BTC/USDT", Symbol: "btcusdt",
+				TickSize: "0.01", StepSize: "0.000001", MinQuantity: "0.0001", MinNotional: "5", ContractSize: "1",
+			}},
+		},
+		{
+			MarketType: exchange.MarketFutures, File: "swap_contract_info.json", Path: "/linear-swap-api/v1/swap_contract_info?business_type=swap",
+			Want: []drivertest.Instrument{{
+				Pair: "ETH/USDT", Symbol: "ETH-USDT",
+				TickSize: "0.01", StepSize: "1", MinQuantity: "1", ContractSize: "0.01",
+			}},
+		},
+	})
+}
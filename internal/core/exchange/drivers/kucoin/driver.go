@@ -0,0 +1,627 @@
+// Package kucoin реализует market-data драйвер KuCoin (spot и USDT-M futures)
+//
+// Публичный WS KuCoin требует временный токен: перед каждым подключением выполняется
+// POST /api/v1/bullet-public, ответ содержит адрес instance server и token.
+// Книга берется из каналов level2Depth5/level2Depth50 - каждое сообщение является снимком,
+// поэтому REST снимок и сверка sequence не нужны.
+// Фьючерсы KuCoin используют XBT вместо BTC и суффикс "M": BTC/USDT -> XBTUSDTM.
+package kucoin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/exchange/drivers/common"
+	"trader/internal/core/messaging"
+	"trader/internal/core/ratelimit"
+	"trader/internal/logger"
+)
+
+const (
+	// SpotWSEndpoint - instance server спота (используется только для проверки рынка,
+	// реальный адрес приходит в ответе bullet-public)
+	SpotWSEndpoint = "wss://ws-api-spot.kucoin.com/"
+	// FuturesWSEndpoint - instance server фьючерсов
+	FuturesWSEndpoint = "wss://ws-api-futures.kucoin.com/"
+	// SpotRESTEndpoint - базовый URL REST API спота
+	SpotRESTEndpoint = "https://api.kucoin.com"
+	// FuturesRESTEndpoint - базовый URL REST API фьючерсов
+	FuturesRESTEndpoint = "https://api-futures.kucoin.com"
+
+	// maxSymbolsPerTopic - KuCoin принимает не более 100 символов в одном topic
+	maxSymbolsPerTopic = 100
+	// maxTopicsPerConnection - KuCoin ограничивает число подписок на соединение,
+	// каждый символ в topic считается отдельной подпиской
+	maxTopicsPerConnection = 300
+
+	// successCode - код успешного ответа REST API KuCoin
+	successCode = "200000"
+)
+
+// rateLimits - KuCoin принимает не более 100 сообщений от клиента за 10 секунд на соединение:
+// burst 10 и 9 в секунду дают максимум 100 за любое окно в 10 секунд
+// REST используется только для bullet-public (weight 10 из 2000 за 30 секунд)
+var rateLimits = exchange.RateLimits{
+	Connection: exchange.RateLimit{PerSecond: 9, Burst: 10},
+	REST:       exchange.RateLimit{PerSecond: 5, Burst: 10},
+}
+
+// Endpoints - адреса WS и REST API, переопределяются для тестового стенда
+type Endpoints struct {
+	SpotWS      string
+	FuturesWS   string
+	SpotREST    string
+	FuturesREST string
+}
+
+// DefaultEndpoints возвращает боевые адреса KuCoin
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		SpotWS:      SpotWSEndpoint,
+		FuturesWS:   FuturesWSEndpoint,
+		SpotREST:    SpotRESTEndpoint,
+		FuturesREST: FuturesRESTEndpoint,
+	}
+}
+
+func init() {
+	exchange.Register(exchange.Kucoin, func() exchange.ExchangeDriver {
+		return New(DefaultEndpoints())
+	})
+}
+
+// Driver - драйвер KuCoin
+type Driver struct {
+	endpoints Endpoints
+	client    *http.Client
+	restLog   *slog.Logger
+	// restLimit - очередь REST запросов по rateLimits.REST
+	restLimit *ratelimit.Limiter
+	symbols   *common.Symbols
+
+	requestID atomic.Int64
+	connectID atomic.Int64
+}
+
+// New создает драйвер с указанными адресами
+func New(endpoints Endpoints) *Driver {
+	return &Driver{
+		endpoints: endpoints,
+		client:    common.NewHTTPClient(),
+		restLog:   logger.GetOutRequest("kucoin"),
+		restLimit: ratelimit.New(rateLimits.REST.PerSecond, rateLimits.REST.Burst),
+		symbols:   common.NewSymbols(symbolFormats),
+	}
+}
+
+// GetExchangeID возвращает ID биржи
+func (d *Driver) GetExchangeID() string {
+	return exchange.Kucoin
+}
+
+// GetName возвращает название биржи
+func (d *Driver) GetName() string {
+	return "KuCoin"
+}
+
+// GetWSEndpoint возвращает адрес instance server без токена
+// Для подключения пул использует ResolveWSEndpoint
+func (d *Driver) GetWSEndpoint(marketType string) (string, error) {
+	switch marketType {
+	case exchange.MarketSpot:
+		return d.endpoints.SpotWS, nil
+	case exchange.MarketFutures:
+		return d.endpoints.FuturesWS, nil
+	default:
+		return "", fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+}
+
+// restEndpoint возвращает базовый URL REST API рынка
+func (d *Driver) restEndpoint(marketType string) (string, error) {
+	switch marketType {
+	case exchange.MarketSpot:
+		return d.endpoints.SpotREST, nil
+	case exchange.MarketFutures:
+		return d.endpoints.FuturesREST, nil
+	default:
+		return "", fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+}
+
+type bulletResponse struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		Token           string `json:"token"`
+		InstanceServers []struct {
+			Endpoint string `json:"endpoint"`
+			Protocol string `json:"protocol"`
+		} `json:"instanceServers"`
+	} `json:"data"`
+}
+
+// ResolveWSEndpoint получает публичный токен и возвращает адрес вида
+// wss://ws-api-spot.kucoin.com/?token=...&connectId=...
+func (d *Driver) ResolveWSEndpoint(ctx context.Context, marketType string) (string, error) {
+	restBase, err := d.restEndpoint(marketType)
+	if err != nil {
+		return "", err
+	}
+
+	var resp bulletResponse
+	if err := common.DoJSON(ctx, d.client, d.restLog, d.restLimit, http.MethodPost, restBase+"/api/v1/bullet-public", nil, &resp); err != nil {
+		return "", err
+	}
+	if resp.Code != successCode {
+		return "", fmt.Errorf("kucoin bullet-public %s: %s", resp.Code, resp.Msg)
+	}
+	if resp.Data.Token == "" || len(resp.Data.InstanceServers) == 0 {
+		return "", fmt.Errorf("kucoin bullet-public: empty token or instance servers")
+	}
+
+	endpoint := resp.Data.InstanceServers[0].Endpoint
+	for _, server := range resp.Data.InstanceServers {
+		if server.Protocol == "websocket" {
+			endpoint = server.Endpoint
+			break
+		}
+	}
+
+	query := url.Values{}
+	query.Set("token", resp.Data.Token)
+	query.Set("connectId", strconv.FormatInt(d.connectID.Add(1), 10))
+	return endpoint + "?" + query.Encode(), nil
+}
+
+// ConnectionLimits - каждая пара занимает две подписки: level2 и match
+func (d *Driver) ConnectionLimits(marketType string) exchange.ConnectionLimits {
+	return exchange.ConnectionLimits{MaxStreams: maxTopicsPerConnection, StreamsPerPair: 2}
+}
+
+// RateLimits возвращает лимиты KuCoin на управляющие фреймы и REST
+func (d *Driver) RateLimits() exchange.RateLimits {
+	return rateLimits
+}
+
+// CreateSubscribeMessage строит subscribe фреймы для книги и сделок
+func (d *Driver) CreateSubscribeMessage(marketType string, pairs []string, depth int) ([][]byte, error) {
+	return d.buildFrames("subscribe", marketType, pairs, depth)
+}
+
+// CreateUnsubscribeMessage строит unsubscribe фреймы
+func (d *Driver) CreateUnsubscribeMessage(marketType string, pairs []string, depth int) ([][]byte, error) {
+	return d.buildFrames("unsubscribe", marketType, pairs, depth)
+}
+
+type controlFrame struct {
+	ID             string `json:"id"`
+	Type           string `json:"type"`
+	Topic          string `json:"topic"`
+	PrivateChannel bool   `json:"privateChannel"`
+	Response       bool   `json:"response"`
+}
+
+func (d *Driver) buildFrames(op, marketType string, pairs []string, depth int) ([][]byte, error) {
+	if _, err := d.GetWSEndpoint(marketType); err != nil {
+		return nil, err
+	}
+
+	symbols := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		symbol, err := d.symbols.Symbol(marketType, pair)
+		if err != nil {
+			return nil, err
+		}
+		symbols = append(symbols, symbol)
+	}
+
+	bookPrefix, tradePrefix := "/spotMarket/", "/market/match:"
+	if marketType == exchange.MarketFutures {
+		bookPrefix, tradePrefix = "/contractMarket/", "/contractMarket/execution:"
+	}
+	bookPrefix += bookChannel(depth) + ":"
+
+	var frames [][]byte
+	for _, chunk := range common.Chunk(symbols, maxSymbolsPerTopic) {
+		joined := strings.Join(chunk, ",")
+		for _, topic := range []string{bookPrefix + joined, tradePrefix + joined} {
+			frame, err := json.Marshal(controlFrame{
+				ID:       strconv.FormatInt(d.requestID.Add(1), 10),
+				Type:     op,
+				Topic:    topic,
+				Response: true,
+			})
+			if err != nil {
+				return nil, err
+			}
+			frames = append(frames, frame)
+		}
+	}
+	return frames, nil
+}
+
+// bookChannel - level2Depth5 для глубины до 5 уровней, иначе level2Depth50
+// Полная книга (depth = 0) ограничена 50 уровнями: инкрементальный level2
+// требует REST снимок, который у KuCoin доступен только с API ключом
+func bookChannel(depth int) string {
+	if depth > 0 && depth <= 5 {
+		return "level2Depth5"
+	}
+	return "level2Depth50"
+}
+
+// symbolFormats - "BTC-USDT" на споте; фьючерсы слитные с суффиксом "M" и XBT вместо BTC
+var symbolFormats = map[string]common.SymbolFormat{
+	exchange.MarketSpot:    {Separator: "-"},
+	exchange.MarketFutures: {Suffix: "M", Aliases: map[string]string{"BTC": "XBT"}},
+}
+
+// Symbol переводит "BTC/USDT" в "BTC-USDT" (spot) или "XBTUSDTM" (futures)
+func (d *Driver) Symbol(marketType, pair string) (string, error) {
+	return d.symbols.Symbol(marketType, pair)
+}
+
+// Pair переводит символ KuCoin обратно в "BTC/USDT"
+func (d *Driver) Pair(marketType, symbol string) string {
+	return d.symbols.Pair(marketType, symbol)
+}
+
+// ============================================================================
+// Разбор входящих фреймов
+// ============================================================================
+
+type pushFrame struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Topic   string          `json:"topic"`
+	Subject string          `json:"subject"`
+	Data    json.RawMessage `json:"data"`
+	Code    json.Number     `json:"code"`
+}
+
+// bookPayload - спот присылает уровни строками, фьючерсы числами
+type bookPayload struct {
+	Bids      [][]json.Number `json:"bids"`
+	Asks      [][]json.Number `json:"asks"`
+	Timestamp int64           `json:"timestamp"`
+	Sequence  int64           `json:"sequence"`
+}
+
+// tradePayload покрывает /market/match (time в наносекундах строкой)
+// и /contractMarket/execution (ts в наносекундах числом)
+type tradePayload struct {
+	Symbol   string      `json:"symbol"`
+	Sequence json.Number `json:"sequence"`
+	Side     string      `json:"side"`
+	Price    json.Number `json:"price"`
+	Size     json.Number `json:"size"`
+	TradeID  string      `json:"tradeId"`
+	Time     json.Number `json:"time"`
+	TS       int64       `json:"ts"`
+}
+
+// ParseMessage разбирает фрейм KuCoin
+func (d *Driver) ParseMessage(marketType string, data []byte) ([]*messaging.Message, error) {
+	var frame pushFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return nil, fmt.Errorf("decode kucoin frame: %w", err)
+	}
+
+	switch frame.Type {
+	case "welcome", "ack", "pong":
+		return nil, nil
+	case "error":
+		return nil, fmt.Errorf("kucoin error %s: %s", frame.Code, strings.Trim(string(frame.Data), `"`))
+	case "message":
+	default:
+		return nil, fmt.Errorf("unsupported kucoin frame type %q", frame.Type)
+	}
+
+	channel, symbol, ok := strings.Cut(frame.Topic, ":")
+	if !ok {
+		return nil, fmt.Errorf("unsupported kucoin topic %q", frame.Topic)
+	}
+	pair := d.symbols.Pair(marketType, symbol)
+
+	switch {
+	case strings.HasSuffix(channel, "/level2Depth5"), strings.HasSuffix(channel, "/level2Depth50"):
+		msg, err := parseBook(marketType, pair, channel, frame.Data)
+		if err != nil {
+			return nil, err
+		}
+		return []*messaging.Message{msg}, nil
+	case channel == "/market/match", channel == "/contractMarket/execution":
+		msg, err := parseTrade(marketType, pair, frame.Data)
+		if err != nil {
+			return nil, err
+		}
+		return []*messaging.Message{msg}, nil
+	default:
+		return nil, fmt.Errorf("unsupported kucoin topic %q", frame.Topic)
+	}
+}
+
+func parseBook(marketType, pair, channel string, raw json.RawMessage) (*messaging.Message, error) {
+	var payload bookPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("decode kucoin level2: %w", err)
+	}
+
+	bids, err := common.ParseNumberLevels(payload.Bids)
+	if err != nil {
+		return nil, err
+	}
+	asks, err := common.ParseNumberLevels(payload.Asks)
+	if err != nil {
+		return nil, err
+	}
+
+	depth := 50
+	if strings.HasSuffix(channel, "Depth5") {
+		depth = 5
+	}
+
+	timestamp := common.MillisToMicros(payload.Timestamp)
+	if timestamp == 0 {
+		timestamp = common.NowMicros()
+	}
+
+	return &messaging.Message{
+		Timestamp:  timestamp,
+		ExchangeID: exchange.Kucoin,
+		MarketType: marketType,
+		Type:       messaging.TypeOrderBook,
+		Pair:       pair,
+		SeqNum:     payload.Sequence,
+		OrderBook: &messaging.OrderBookData{
+			Bids:        bids,
+			Asks:        asks,
+			Depth:       depth,
+			Snapshot:    true,
+			FirstSeqNum: payload.Sequence,
+		},
+	}, nil
+}
+
+func parseTrade(marketType, pair string, raw json.RawMessage) (*messaging.Message, error) {
+	var payload tradePayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("decode kucoin match: %w", err)
+	}
+
+	price, err := common.ParseNumber(payload.Price)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kucoin trade price %q: %w", payload.Price, err)
+	}
+	amount, err := common.ParseNumber(payload.Size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kucoin trade size %q: %w", payload.Size, err)
+	}
+
+	nanos := payload.TS
+	if payload.Time != "" {
+		if nanos, err = payload.Time.Int64(); err != nil {
+			return nil, fmt.Errorf("invalid kucoin trade time %q: %w", payload.Time, err)
+		}
+	}
+	seq, _ := payload.Sequence.Int64()
+
+	return &messaging.Message{
+		Timestamp:  nanos / 1000,
+		ExchangeID: exchange.Kucoin,
+		MarketType: marketType,
+		Type:       messaging.TypeTrade,
+		Pair:       pair,
+		SeqNum:     seq,
+		Trade: &messaging.TradeData{
+			Price:   price,
+			Amount:  amount,
+			Side:    payload.Side,
+			TradeID: payload.TradeID,
+		},
+	}, nil
+}
+
+// pingInterval - pingInterval из ответа bullet-public (18 секунд)
+const pingInterval = 18 * time.Second
+
+// PingInterval возвращает период {"type":"ping"}
+func (d *Driver) PingInterval(marketType string) time.Duration {
+	return pingInterval
+}
+
+// PingFrame строит {"id":"N","type":"ping"}
+func (d *Driver) PingFrame(marketType string) []byte {
+	frame, _ := json.Marshal(pingFrame{
+		ID:   strconv.FormatInt(d.requestID.Add(1), 10),
+		Type: "ping",
+	})
+	return frame
+}
+
+type pingFrame struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// IsPong - ответ {"id":"N","type":"pong"}
+func (d *Driver) IsPong(marketType string, data []byte) bool {
+	if !bytes.Contains(data, []byte(`"pong"`)) {
+		return false
+	}
+	var frame pingFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return false
+	}
+	return frame.Type == "pong"
+}
+
+type timestampResponse struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data int64  `json:"data"`
+}
+
+// ServerTime запрашивает /api/v1/timestamp (одинаковый путь у спота и фьючерсов)
+func (d *Driver) ServerTime(ctx context.Context, marketType string) (int64, error) {
+	restBase, err := d.restEndpoint(marketType)
+	if err != nil {
+		return 0, err
+	}
+
+	var resp timestampResponse
+	if err := common.GetJSON(ctx, d.client, d.restLog, d.restLimit, restBase+"/api/v1/timestamp", &resp); err != nil {
+		return 0, err
+	}
+	if resp.Code != successCode {
+		return 0, fmt.Errorf("kucoin timestamp %s: %s", resp.Code, resp.Msg)
+	}
+	return common.MillisToMicros(resp.Data), nil
+}
+
+// ============================================================================
+// Инструменты
+// ============================================================================
+
+type spotSymbolsResponse struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data []struct {
+		Symbol         string `json:"symbol"`
+		BaseCurrency   string `json:"baseCurrency"`
+		QuoteCurrency  string `json:"quoteCurrency"`
+		BaseMinSize    string `json:"baseMinSize"`
+		BaseIncrement  string `json:"baseIncrement"`
+		PriceIncrement string `json:"priceIncrement"`
+		MinFunds       string `json:"minFunds"`
+		EnableTrading  bool   `json:"enableTrading"`
+	} `json:"data"`
+}
+
+// futuresContractsResponse - числовые поля фьючерсов приходят числами JSON
+type futuresContractsResponse struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data []struct {
+		Symbol     string      `json:"symbol"`
+		Status     string      `json:"status"`
+		IsInverse  bool        `json:"isInverse"`
+		TickSize   json.Number `json:"tickSize"`
+		LotSize    json.Number `json:"lotSize"`
+		Multiplier json.Number `json:"multiplier"`
+	} `json:"data"`
+}
+
+// FetchInstruments загружает /api/v2/symbols (спот) или /api/v1/contracts/active (фьючерсы)
+// Объем ордера фьючерсов задается в лотах размером multiplier базового актива
+func (d *Driver) FetchInstruments(ctx context.Context, marketType string) ([]exchange.Instrument, error) {
+	restBase, err := d.restEndpoint(marketType)
+	if err != nil {
+		return nil, err
+	}
+	if marketType == exchange.MarketFutures {
+		return d.fetchContracts(ctx, restBase)
+	}
+
+	var resp spotSymbolsResponse
+	if err := common.GetJSON(ctx, d.client, d.restLog, d.restLimit, restBase+"/api/v2/symbols", &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != successCode {
+		return nil, fmt.Errorf("kucoin symbols %s: %s", resp.Code, resp.Msg)
+	}
+
+	instruments := make([]exchange.Instrument, 0, len(resp.Data))
+	for _, item := range resp.Data {
+		if !item.EnableTrading {
+			continue
+		}
+
+		instrument := exchange.Instrument{
+			ExchangeID:   exchange.Kucoin,
+			MarketType:   marketType,
+			Pair:         item.BaseCurrency + "/" + item.QuoteCurrency,
+			Symbol:       item.Symbol,
+			ContractSize: decimal.NewFromInt(1),
+		}
+		if instrument.TickSize, err = common.ParseDecimal(item.PriceIncrement); err != nil {
+			return nil, fmt.Errorf("invalid kucoin %s priceIncrement: %w", item.Symbol, err)
+		}
+		if instrument.StepSize, err = common.ParseDecimal(item.BaseIncrement); err != nil {
+			return nil, fmt.Errorf("invalid kucoin %s baseIncrement: %w", item.Symbol, err)
+		}
+		if instrument.MinQuantity, err = common.ParseDecimal(item.BaseMinSize); err != nil {
+			return nil, fmt.Errorf("invalid kucoin %s baseMinSize: %w", item.Symbol, err)
+		}
+		if instrument.MinNotional, err = common.ParseDecimal(item.MinFunds); err != nil {
+			return nil, fmt.Errorf("invalid kucoin %s minFunds: %w", item.Symbol, err)
+		}
+
+		instruments = append(instruments, instrument)
+	}
+	return instruments, nil
+}
+
+// fetchContracts загружает активные линейные контракты фьючерсов
+func (d *Driver) fetchContracts(ctx context.Context, restBase string) ([]exchange.Instrument, error) {
+	var resp futuresContractsResponse
+	if err := common.GetJSON(ctx, d.client, d.restLog, d.restLimit, restBase+"/api/v1/contracts/active", &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != successCode {
+		return nil, fmt.Errorf("kucoin contracts %s: %s", resp.Code, resp.Msg)
+	}
+
+	instruments := make([]exchange.Instrument, 0, len(resp.Data))
+	for _, item := range resp.Data {
+		if item.Status != "Open" || item.IsInverse {
+			continue
+		}
+
+		instrument := exchange.Instrument{
+			ExchangeID: exchange.Kucoin,
+			MarketType: exchange.MarketFutures,
+			Pair:       d.symbols.Pair(exchange.MarketFutures, item.Symbol),
+			Symbol:     item.Symbol,
+		}
+		var err error
+		if instrument.TickSize, err = common.ParseNumber(item.TickSize); err != nil {
+			return nil, fmt.Errorf("invalid kucoin %s tickSize: %w", item.Symbol, err)
+		}
+		if instrument.StepSize, err = common.ParseNumber(item.LotSize); err != nil {
+			return nil, fmt.Errorf("invalid kucoin %s lotSize: %w", item.Symbol, err)
+		}
+		if instrument.ContractSize, err = common.ParseNumber(item.Multiplier); err != nil {
+			return nil, fmt.Errorf("invalid kucoin %s multiplier: %w", item.Symbol, err)
+		}
+		instrument.MinQuantity = instrument.StepSize
+
+		instruments = append(instruments, instrument)
+	}
+	return instruments, nil
+}
+
+// Проверка реализации интерфейсов на этапе компиляции
+var (
+	_ exchange.ExchangeDriver     = (*Driver)(nil)
+	_ exchange.EndpointResolver   = (*Driver)(nil)
+	_ exchange.ConnectionLimiter  = (*Driver)(nil)
+	_ exchange.RateLimited        = (*Driver)(nil)
+	_ exchange.Pinger             = (*Driver)(nil)
+	_ exchange.ServerClock        = (*Driver)(nil)
+	_ exchange.InstrumentProvider = (*Driver)(nil)
+	_ exchange.SymbolMapper       = (*Driver)(nil)
+)
@@ -0,0 +1,93 @@
+package kucoin
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/exchange/drivers/drivertest"
+	"trader/internal/core/messaging"
+)
+
+type (
+	level   = drivertest.Level
+	message = drivertest.Message
+	trade   = drivertest.Trade
+)
+
+func TestParseMessage(t *testing.T) {
+	drivertest.Run(t, New(DefaultEndpoints()), []drivertest.Case{
+		{
+			Name: "welcome", File: "welcome.json", MarketType: exchange.MarketSpot,
+		},
+		{
+			Name: "spot level2 depth5", File: "spot_level2depth5.json", MarketType: exchange.MarketSpot,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "BTC/USDT", Timestamp: 1731898619520000,
+				Snapshot: true, Depth: 5,
+				Bids: []level{{"67215", "1.20441"}, {"67214.9", "0.0451"}, {"67214.1", "0.3"}, {"67213.8", "0.002"}, {"67213", "0.71"}},
+				Asks: []level{{"67215.1", "0.51234"}, {"67215.2", "0.00015"}, {"67216", "0.1"}, {"67216.5", "0.02"}, {"67217", "1.3"}},
+			}},
+		},
+		{
+			Name: "spot match", File: "spot_match.json", MarketType: exchange.MarketSpot,
+			Want: []message{{
+				Type: messaging.TypeTrade, Pair: "BTC/USDT", Timestamp: 1731898619520456, SeqNum: 11067996711960577,
+				Trade: &trade{Price: "67215.1", Amount: "0.00012", Side: "buy", ID: "11067996711960577"},
+			}},
+		},
+		{
+			// Фьючерсный символ XBTUSDTM: XBT - имя BTC на KuCoin Futures
+			Name: "futures level2 depth50", File: "futures_level2depth50.json", MarketType: exchange.MarketFutures,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "BTC/USDT", Timestamp: 1731898619521000, SeqNum: 1709320123456,
+				Snapshot: true, Depth: 50,
+				Bids: []level{{"67201.1", "1200"}, {"67201", "35"}, {"67200.5", "410"}},
+				Asks: []level{{"67201.2", "854"}, {"67201.9", "12"}, {"67202.5", "300"}},
+			}},
+		},
+		{
+			Name: "futures execution", File: "futures_execution.json", MarketType: exchange.MarketFutures,
+			Want: []message{{
+				Type: messaging.TypeTrade, Pair: "BTC/USDT", Timestamp: 1731898619520000, SeqNum: 1696941513,
+				Trade: &trade{Price: "67201.1", Amount: "12", Side: "sell", ID: "1696941513"},
+			}},
+		},
+	})
+}
+
+func TestResolveWSEndpoint(t *testing.T) {
+	rest, paths := drivertest.Serve(t, "bullet_public.json")
+	endpoints := DefaultEndpoints()
+	endpoints.SpotREST = rest
+	driver := New(endpoints)
+
+	first, err := driver.ResolveWSEndpoint(context.Background(), exchange.MarketSpot)
+	if err != nil {
+		t.Fatalf("ResolveWSEndpoint: %v", err)
+	}
+	if path := <-paths; path != "/api/v1/bullet-public" {
+		t.Errorf("request = %s", path)
+	}
+	if !strings.HasPrefix(first, "wss://ws-api-spot.kucoin.com/?") {
+		t.Fatalf("endpoint = %s", first)
+	}
+	query, err := url.ParseQuery(first[strings.Index(first, "?")+1:])
+	if err != nil {
+		t.Fatalf("endpoint query: %v", err)
+	}
+	if !strings.HasPrefix(query.Get("token"), "2neAiuYvAU61ZDXANAGAsiL4") || query.Get("connectId") == "" {
+		t.Errorf("endpoint query = %v", query)
+	}
+
+	// Каждое подключение получает свой connectId
+	second, err := driver.ResolveWSEndpoint(context.Background(), exchange.MarketSpot)
+	if err != nil {
+		t.Fatalf("ResolveWSEndpoint: %v", err)
+	}
+	if second == first {
+		t.Errorf("connectId reused: %s", second)
+	}
+}
@@ -0,0 +1,706 @@
+// Package mexc реализует market-data драйвер MEXC (spot и USDT perpetual futures)
+//
+// Спот (API v3) присылает данные бинарными protobuf фреймами PushDataV3ApiWrapper,
+// ответы на подписку и PING приходят текстовым JSON. Фьючерсы (contract API) - только JSON.
+// Используются каналы ограниченной глубины (5/10/20 уровней), каждое сообщение является снимком.
+package mexc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/exchange/drivers/common"
+	"trader/internal/core/messaging"
+	"trader/internal/logger"
+)
+
+const (
+	// SpotWSEndpoint - публичный WS спота (protobuf)
+	SpotWSEndpoint = "wss://wbs-api.mexc.com/ws"
+	// FuturesWSEndpoint - публичный WS фьючерсов
+	FuturesWSEndpoint = "wss://contract.mexc.com/edge"
+	// SpotRESTEndpoint - базовый URL REST API спота
+	SpotRESTEndpoint = "https://api.mexc.com"
+	// FuturesRESTEndpoint - базовый URL REST API фьючерсов (contract API)
+	FuturesRESTEndpoint = "https://contract.mexc.com"
+
+	// maxStreamsPerFrame - ограничение на число params в одном SUBSCRIPTION
+	maxStreamsPerFrame = 30
+	// maxSpotStreamsPerConnection - спот MEXC допускает не более 30 подписок на соединение
+	maxSpotStreamsPerConnection = 30
+
+	spotDepthChannel = "spot@public.limit.depth.v3.api.pb@"
+	spotDealsChannel = "spot@public.aggre.deals.v3.api.pb@100ms@"
+)
+
+// depthLimits - глубины каналов limit.depth (spot) и depth.full (futures)
+var depthLimits = []int{5, 10, 20}
+
+// Endpoints - адреса WS и REST API, переопределяются для тестового стенда
+type Endpoints struct {
+	SpotWS      string
+	FuturesWS   string
+	SpotREST    string
+	FuturesREST string
+}
+
+// DefaultEndpoints возвращает боевые адреса MEXC
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		SpotWS:      SpotWSEndpoint,
+		FuturesWS:   FuturesWSEndpoint,
+		SpotREST:    SpotRESTEndpoint,
+		FuturesREST: FuturesRESTEndpoint,
+	}
+}
+
+func init() {
+	exchange.Register(exchange.MEXC, func() exchange.ExchangeDriver {
+		return New(DefaultEndpoints())
+	})
+}
+
+// Driver - драйвер MEXC
+type Driver struct {
+	endpoints Endpoints
+	client    *http.Client
+	restLog   *slog.Logger
+	symbols   *common.Symbols
+}
+
+// New создает драйвер с указанными адресами
+func New(endpoints Endpoints) *Driver {
+	return &Driver{
+		endpoints: endpoints,
+		client:    common.NewHTTPClient(),
+		restLog:   logger.GetOutRequest("mexc"),
+		symbols:   common.NewSymbols(symbolFormats),
+	}
+}
+
+// GetExchangeID возвращает ID биржи
+func (d *Driver) GetExchangeID() string {
+	return exchange.MEXC
+}
+
+// GetName возвращает название биржи
+func (d *Driver) GetName() string {
+	return "MEXC"
+}
+
+// GetWSEndpoint возвращает публичный WS для рынка
+func (d *Driver) GetWSEndpoint(marketType string) (string, error) {
+	switch marketType {
+	case exchange.MarketSpot:
+		return d.endpoints.SpotWS, nil
+	case exchange.MarketFutures:
+		return d.endpoints.FuturesWS, nil
+	default:
+		return "", fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+}
+
+// ConnectionLimits - лимит есть только у спота, фьючерсный endpoint его не объявляет
+func (d *Driver) ConnectionLimits(marketType string) exchange.ConnectionLimits {
+	if marketType == exchange.MarketFutures {
+		return exchange.ConnectionLimits{}
+	}
+	return exchange.ConnectionLimits{MaxStreams: maxSpotStreamsPerConnection, StreamsPerPair: 2}
+}
+
+// CreateSubscribeMessage строит фреймы подписки на книгу и сделки
+func (d *Driver) CreateSubscribeMessage(marketType string, pairs []string, depth int) ([][]byte, error) {
+	if marketType == exchange.MarketFutures {
+		return d.buildFuturesFrames("sub", pairs, depth)
+	}
+	return d.buildSpotFrames("SUBSCRIPTION", marketType, pairs, depth)
+}
+
+// CreateUnsubscribeMessage строит фреймы отписки
+func (d *Driver) CreateUnsubscribeMessage(marketType string, pairs []string, depth int) ([][]byte, error) {
+	if marketType == exchange.MarketFutures {
+		return d.buildFuturesFrames("unsub", pairs, depth)
+	}
+	return d.buildSpotFrames("UNSUBSCRIPTION", marketType, pairs, depth)
+}
+
+type spotControlFrame struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+}
+
+func (d *Driver) buildSpotFrames(method, marketType string, pairs []string, depth int) ([][]byte, error) {
+	if _, err := d.GetWSEndpoint(marketType); err != nil {
+		return nil, err
+	}
+
+	limit := strconv.Itoa(depthLimit(depth))
+	streams := make([]string, 0, len(pairs)*2)
+	for _, pair := range pairs {
+		symbol, err := d.symbols.Symbol(marketType, pair)
+		if err != nil {
+			return nil, err
+		}
+		streams = append(streams, spotDepthChannel+symbol+"@"+limit, spotDealsChannel+symbol)
+	}
+
+	var frames [][]byte
+	for _, chunk := range common.Chunk(streams, maxStreamsPerFrame) {
+		frame, err := json.Marshal(spotControlFrame{Method: method, Params: chunk})
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+type futuresParam struct {
+	Symbol string `json:"symbol"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+type futuresControlFrame struct {
+	Method string       `json:"method"`
+	Param  futuresParam `json:"param"`
+}
+
+// buildFuturesFrames - contract API принимает одну подписку на запрос:
+// sub.depth.full {symbol, limit} и sub.deal {symbol}
+func (d *Driver) buildFuturesFrames(op string, pairs []string, depth int) ([][]byte, error) {
+	limit := depthLimit(depth)
+	frames := make([][]byte, 0, len(pairs)*2)
+	for _, pair := range pairs {
+		symbol, err := d.symbols.Symbol(exchange.MarketFutures, pair)
+		if err != nil {
+			return nil, err
+		}
+
+		requests := []futuresControlFrame{
+			{Method: op + ".depth.full", Param: futuresParam{Symbol: symbol, Limit: limit}},
+			{Method: op + ".deal", Param: futuresParam{Symbol: symbol}},
+		}
+		for _, request := range requests {
+			frame, err := json.Marshal(request)
+			if err != nil {
+				return nil, err
+			}
+			frames = append(frames, frame)
+		}
+	}
+	return frames, nil
+}
+
+// depthLimit подбирает минимальную доступную глубину не меньше запрошенной
+// Полная книга (depth = 0) ограничена 20 уровнями - максимумом каналов со снимками
+func depthLimit(depth int) int {
+	if depth > 0 {
+		for _, limit := range depthLimits {
+			if limit >= depth {
+				return limit
+			}
+		}
+	}
+	return depthLimits[len(depthLimits)-1]
+}
+
+// symbolFormats - BTCUSDT на споте, BTC_USDT на фьючерсах
+var symbolFormats = map[string]common.SymbolFormat{
+	exchange.MarketSpot:    {},
+	exchange.MarketFutures: {Separator: "_"},
+}
+
+// Symbol переводит "BTC/USDT" в "BTCUSDT" (spot) или "BTC_USDT" (futures)
+func (d *Driver) Symbol(marketType, pair string) (string, error) {
+	return d.symbols.Symbol(marketType, pair)
+}
+
+// Pair переводит символ MEXC обратно в "BTC/USDT"
+func (d *Driver) Pair(marketType, symbol string) string {
+	return d.symbols.Pair(marketType, symbol)
+}
+
+// ============================================================================
+// Разбор входящих фреймов
+// ============================================================================
+
+// ParseMessage разбирает protobuf фрейм спота или JSON фрейм
+func (d *Driver) ParseMessage(marketType string, data []byte) ([]*messaging.Message, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if data[0] == '{' {
+		if marketType == exchange.MarketFutures {
+			return d.parseFutures(data)
+		}
+		return nil, parseSpotControl(data)
+	}
+	return d.parseSpotPush(marketType, data)
+}
+
+type spotControlReply struct {
+	ID   int64  `json:"id"`
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// parseSpotControl проверяет JSON ответ спота: {"id":0,"code":0,"msg":"spot@public..."} или PONG
+func parseSpotControl(data []byte) error {
+	var reply spotControlReply
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return fmt.Errorf("decode mexc reply: %w", err)
+	}
+	if reply.Code != 0 {
+		return fmt.Errorf("mexc error %d: %s", reply.Code, reply.Msg)
+	}
+	return nil
+}
+
+func (d *Driver) parseSpotPush(marketType string, data []byte) ([]*messaging.Message, error) {
+	wrapper, err := decodeWrapper(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode mexc push: %w", err)
+	}
+
+	pair := d.symbols.Pair(marketType, wrapper.symbol)
+	timestamp := wrapper.sendTime
+	if timestamp == 0 {
+		timestamp = wrapper.createTime
+	}
+
+	switch {
+	case wrapper.limitDepths != nil:
+		depths, err := decodeLimitDepths(wrapper.limitDepths)
+		if err != nil {
+			return nil, fmt.Errorf("decode mexc limit depth: %w", err)
+		}
+		msg, err := spotBookMessage(marketType, pair, wrapper, depths, timestamp)
+		if err != nil {
+			return nil, err
+		}
+		return []*messaging.Message{msg}, nil
+	case wrapper.aggreDeals != nil:
+		deals, err := decodeAggreDeals(wrapper.aggreDeals)
+		if err != nil {
+			return nil, fmt.Errorf("decode mexc aggre deals: %w", err)
+		}
+		return spotTradeMessages(marketType, pair, deals)
+	default:
+		return nil, fmt.Errorf("unsupported mexc channel %q", wrapper.channel)
+	}
+}
+
+func spotBookMessage(marketType, pair string, wrapper *pushWrapper, depths *limitDepths, timestamp int64) (*messaging.Message, error) {
+	bids, err := common.ParseLevels(depths.bids)
+	if err != nil {
+		return nil, err
+	}
+	asks, err := common.ParseLevels(depths.asks)
+	if err != nil {
+		return nil, err
+	}
+
+	// channel = spot@public.limit.depth.v3.api.pb@BTCUSDT@20
+	depth := 0
+	if idx := strings.LastIndex(wrapper.channel, "@"); idx >= 0 {
+		depth, _ = strconv.Atoi(wrapper.channel[idx+1:])
+	}
+	seqNum, _ := strconv.ParseInt(depths.version, 10, 64)
+
+	return &messaging.Message{
+		Timestamp:  common.MillisToMicros(timestamp),
+		ExchangeID: exchange.MEXC,
+		MarketType: marketType,
+		Type:       messaging.TypeOrderBook,
+		Pair:       pair,
+		SeqNum:     seqNum,
+		OrderBook: &messaging.OrderBookData{
+			Bids:        bids,
+			Asks:        asks,
+			Depth:       depth,
+			Snapshot:    true,
+			FirstSeqNum: seqNum,
+		},
+	}, nil
+}
+
+func spotTradeMessages(marketType, pair string, deals []aggreDeal) ([]*messaging.Message, error) {
+	messages := make([]*messaging.Message, 0, len(deals))
+	for _, deal := range deals {
+		price, err := common.ParseDecimal(deal.price)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mexc deal price %q: %w", deal.price, err)
+		}
+		amount, err := common.ParseDecimal(deal.quantity)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mexc deal quantity %q: %w", deal.quantity, err)
+		}
+
+		messages = append(messages, &messaging.Message{
+			Timestamp:  common.MillisToMicros(deal.time),
+			ExchangeID: exchange.MEXC,
+			MarketType: marketType,
+			Type:       messaging.TypeTrade,
+			Pair:       pair,
+			Trade: &messaging.TradeData{
+				Price:  price,
+				Amount: amount,
+				Side:   tradeSide(deal.tradeType),
+			},
+		})
+	}
+	return messages, nil
+}
+
+// tradeSide переводит тип сделки MEXC (1 - покупка, 2 - продажа) в "buy"/"sell"
+func tradeSide(tradeType int64) string {
+	if tradeType == 1 {
+		return "buy"
+	}
+	return "sell"
+}
+
+// ============================================================================
+// Фьючерсы (JSON)
+// ============================================================================
+
+type futuresPush struct {
+	Channel string          `json:"channel"`
+	Symbol  string          `json:"symbol"`
+	TS      int64           `json:"ts"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// futuresDepth - уровни [price, volume, orderCount], volume в контрактах
+type futuresDepth struct {
+	Asks    [][]json.Number `json:"asks"`
+	Bids    [][]json.Number `json:"bids"`
+	Version int64           `json:"version"`
+}
+
+// futuresDeal - "T" (1 buy, 2 sell) и "t" (время) объявлены отдельными полями
+// из-за сравнения ключей без учета регистра в encoding/json
+type futuresDeal struct {
+	Price  json.Number `json:"p"`
+	Volume json.Number `json:"v"`
+	Side   int64       `json:"T"`
+	Time   int64       `json:"t"`
+}
+
+func (d *Driver) parseFutures(data []byte) ([]*messaging.Message, error) {
+	var push futuresPush
+	if err := json.Unmarshal(data, &push); err != nil {
+		return nil, fmt.Errorf("decode mexc futures frame: %w", err)
+	}
+
+	pair := d.symbols.Pair(exchange.MarketFutures, push.Symbol)
+	switch {
+	case push.Channel == "push.depth.full":
+		msg, err := futuresBookMessage(pair, &push)
+		if err != nil {
+			return nil, err
+		}
+		return []*messaging.Message{msg}, nil
+	case push.Channel == "push.deal":
+		return futuresTradeMessages(pair, push.Data)
+	case push.Channel == "rs.error":
+		return nil, fmt.Errorf("mexc futures error: %s", strings.Trim(string(push.Data), `"`))
+	case push.Channel == "pong", strings.HasPrefix(push.Channel, "rs."):
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported mexc futures channel %q", push.Channel)
+	}
+}
+
+func futuresBookMessage(pair string, push *futuresPush) (*messaging.Message, error) {
+	var payload futuresDepth
+	if err := json.Unmarshal(push.Data, &payload); err != nil {
+		return nil, fmt.Errorf("decode mexc futures depth: %w", err)
+	}
+
+	bids, err := common.ParseNumberLevels(payload.Bids)
+	if err != nil {
+		return nil, err
+	}
+	asks, err := common.ParseNumberLevels(payload.Asks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &messaging.Message{
+		Timestamp:  common.MillisToMicros(push.TS),
+		ExchangeID: exchange.MEXC,
+		MarketType: exchange.MarketFutures,
+		Type:       messaging.TypeOrderBook,
+		Pair:       pair,
+		SeqNum:     payload.Version,
+		OrderBook: &messaging.OrderBookData{
+			Bids:        bids,
+			Asks:        asks,
+			Snapshot:    true,
+			FirstSeqNum: payload.Version,
+		},
+	}, nil
+}
+
+// futuresTradeMessages - push.deal присылает одну сделку объектом или несколько массивом
+func futuresTradeMessages(pair string, raw json.RawMessage) ([]*messaging.Message, error) {
+	var deals []futuresDeal
+	if len(raw) > 0 && raw[0] == '[' {
+		if err := json.Unmarshal(raw, &deals); err != nil {
+			return nil, fmt.Errorf("decode mexc futures deals: %w", err)
+		}
+	} else {
+		var deal futuresDeal
+		if err := json.Unmarshal(raw, &deal); err != nil {
+			return nil, fmt.Errorf("decode mexc futures deal: %w", err)
+		}
+		deals = append(deals, deal)
+	}
+
+	messages := make([]*messaging.Message, 0, len(deals))
+	for _, deal := range deals {
+		price, err := common.ParseNumber(deal.Price)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mexc futures deal price %q: %w", deal.Price, err)
+		}
+		amount, err := common.ParseNumber(deal.Volume)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mexc futures deal volume %q: %w", deal.Volume, err)
+		}
+
+		messages = append(messages, &messaging.Message{
+			Timestamp:  common.MillisToMicros(deal.Time),
+			ExchangeID: exchange.MEXC,
+			MarketType: exchange.MarketFutures,
+			Type:       messaging.TypeTrade,
+			Pair:       pair,
+			Trade: &messaging.TradeData{
+				Price:  price,
+				Amount: amount,
+				Side:   tradeSide(deal.Side),
+			},
+		})
+	}
+	return messages, nil
+}
+
+const (
+	// spotPingInterval - спот закрывает соединение без ping через 60 секунд
+	spotPingInterval = 20 * time.Second
+	// futuresPingInterval - contract API рекомендует ping каждые 10-20 секунд
+	futuresPingInterval = 15 * time.Second
+)
+
+// PingInterval возвращает период ping для рынка
+func (d *Driver) PingInterval(marketType string) time.Duration {
+	if marketType == exchange.MarketFutures {
+		return futuresPingInterval
+	}
+	return spotPingInterval
+}
+
+// PingFrame - спот ожидает {"method":"PING"}, фьючерсы {"method":"ping"}
+func (d *Driver) PingFrame(marketType string) []byte {
+	if marketType == exchange.MarketFutures {
+		return []byte(`{"method":"ping"}`)
+	}
+	return []byte(`{"method":"PING"}`)
+}
+
+// IsPong - спот отвечает {"id":0,"code":0,"msg":"PONG"}, фьючерсы {"channel":"pong","data":ts}
+func (d *Driver) IsPong(marketType string, data []byte) bool {
+	if len(data) == 0 || data[0] != '{' {
+		return false
+	}
+	if marketType == exchange.MarketFutures {
+		var push futuresPush
+		return json.Unmarshal(data, &push) == nil && push.Channel == "pong"
+	}
+	var reply spotControlReply
+	return json.Unmarshal(data, &reply) == nil && reply.Msg == "PONG"
+}
+
+type spotTimeResponse struct {
+	ServerTime int64 `json:"serverTime"`
+}
+
+type futuresPingResponse struct {
+	Success bool  `json:"success"`
+	Code    int   `json:"code"`
+	Data    int64 `json:"data"`
+}
+
+// ServerTime запрашивает /api/v3/time (spot) или /api/v1/contract/ping (futures)
+func (d *Driver) ServerTime(ctx context.Context, marketType string) (int64, error) {
+	switch marketType {
+	case exchange.MarketSpot:
+		var resp spotTimeResponse
+		if err := common.GetJSON(ctx, d.client, d.restLog, nil, d.endpoints.SpotREST+"/api/v3/time", &resp); err != nil {
+			return 0, err
+		}
+		return common.MillisToMicros(resp.ServerTime), nil
+	case exchange.MarketFutures:
+		var resp futuresPingResponse
+		if err := common.GetJSON(ctx, d.client, d.restLog, nil, d.endpoints.FuturesREST+"/api/v1/contract/ping", &resp); err != nil {
+			return 0, err
+		}
+		if !resp.Success {
+			return 0, fmt.Errorf("mexc contract ping code %d", resp.Code)
+		}
+		return common.MillisToMicros(resp.Data), nil
+	default:
+		return 0, fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+}
+
+// ============================================================================
+// Инструменты
+// ============================================================================
+
+// exchangeInfoResponse - /api/v3/exchangeInfo спота
+// Шаг цены задан точностью quotePrecision, шаг объема - baseSizePrecision,
+// минимальная стоимость ордера - quoteAmountPrecision
+type exchangeInfoResponse struct {
+	Symbols []struct {
+		Symbol               string `json:"symbol"`
+		Status               string `json:"status"`
+		BaseAsset            string `json:"baseAsset"`
+		QuoteAsset           string `json:"quoteAsset"`
+		QuotePrecision       int32  `json:"quotePrecision"`
+		BaseSizePrecision    string `json:"baseSizePrecision"`
+		QuoteAmountPrecision string `json:"quoteAmountPrecision"`
+		IsSpotTradingAllowed bool   `json:"isSpotTradingAllowed"`
+	} `json:"symbols"`
+}
+
+// contractDetailResponse - /api/v1/contract/detail фьючерсов
+type contractDetailResponse struct {
+	Success bool `json:"success"`
+	Code    int  `json:"code"`
+	Data    []struct {
+		Symbol       string      `json:"symbol"`
+		BaseCoin     string      `json:"baseCoin"`
+		QuoteCoin    string      `json:"quoteCoin"`
+		ContractSize json.Number `json:"contractSize"`
+		PriceUnit    json.Number `json:"priceUnit"`
+		VolUnit      json.Number `json:"volUnit"`
+		MinVol       json.Number `json:"minVol"`
+		State        int         `json:"state"`
+	} `json:"data"`
+}
+
+// spotSymbolEnabled - статус торгуемого символа спота ("1", в старых ответах "ENABLED")
+func spotSymbolEnabled(status string) bool {
+	return status == "1" || status == "ENABLED"
+}
+
+// FetchInstruments загружает /api/v3/exchangeInfo (spot) или /api/v1/contract/detail (futures)
+// Возвращаются только торгуемые инструменты (контракты в state 0).
+// Объем фьючерсов задается в контрактах, ContractSize - монет в контракте
+func (d *Driver) FetchInstruments(ctx context.Context, marketType string) ([]exchange.Instrument, error) {
+	switch marketType {
+	case exchange.MarketSpot:
+		return d.fetchSpotSymbols(ctx)
+	case exchange.MarketFutures:
+		return d.fetchContracts(ctx)
+	default:
+		return nil, fmt.Errorf("%w: %s", exchange.ErrUnsupportedMarket, marketType)
+	}
+}
+
+// fetchSpotSymbols загружает символы спота, открытые для торговли
+func (d *Driver) fetchSpotSymbols(ctx context.Context) ([]exchange.Instrument, error) {
+	var resp exchangeInfoResponse
+	if err := common.GetJSON(ctx, d.client, d.restLog, nil, d.endpoints.SpotREST+"/api/v3/exchangeInfo", &resp); err != nil {
+		return nil, err
+	}
+
+	instruments := make([]exchange.Instrument, 0, len(resp.Symbols))
+	for _, item := range resp.Symbols {
+		if !spotSymbolEnabled(item.Status) || !item.IsSpotTradingAllowed {
+			continue
+		}
+
+		instrument := exchange.Instrument{
+			ExchangeID:   exchange.MEXC,
+			MarketType:   exchange.MarketSpot,
+			Pair:         item.BaseAsset + "/" + item.QuoteAsset,
+			Symbol:       item.Symbol,
+			TickSize:     decimal.New(1, -item.QuotePrecision),
+			ContractSize: decimal.NewFromInt(1),
+		}
+		var err error
+		if instrument.StepSize, err = common.ParseDecimal(item.BaseSizePrecision); err != nil {
+			return nil, fmt.Errorf("invalid mexc %s baseSizePrecision: %w", item.Symbol, err)
+		}
+		if instrument.MinNotional, err = common.ParseDecimal(item.QuoteAmountPrecision); err != nil {
+			return nil, fmt.Errorf("invalid mexc %s quoteAmountPrecision: %w", item.Symbol, err)
+		}
+
+		d.symbols.Remember(exchange.MarketSpot, item.Symbol, instrument.Pair)
+		instruments = append(instruments, instrument)
+	}
+	return instruments, nil
+}
+
+// fetchContracts загружает торгуемые контракты фьючерсов
+func (d *Driver) fetchContracts(ctx context.Context) ([]exchange.Instrument, error) {
+	var resp contractDetailResponse
+	if err := common.GetJSON(ctx, d.client, d.restLog, nil, d.endpoints.FuturesREST+"/api/v1/contract/detail", &resp); err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("mexc contract detail code %d", resp.Code)
+	}
+
+	instruments := make([]exchange.Instrument, 0, len(resp.Data))
+	for _, item := range resp.Data {
+		if item.State != 0 {
+			continue
+		}
+
+		instrument := exchange.Instrument{
+			ExchangeID: exchange.MEXC,
+			MarketType: exchange.MarketFutures,
+			Pair:       item.BaseCoin + "/" + item.QuoteCoin,
+			Symbol:     item.Symbol,
+		}
+		var err error
+		if instrument.TickSize, err = common.ParseNumber(item.PriceUnit); err != nil {
+			return nil, fmt.Errorf("invalid mexc %s priceUnit: %w", item.Symbol, err)
+		}
+		if instrument.StepSize, err = common.ParseNumber(item.VolUnit); err != nil {
+			return nil, fmt.Errorf("invalid mexc %s volUnit: %w", item.Symbol, err)
+		}
+		if instrument.MinQuantity, err = common.ParseNumber(item.MinVol); err != nil {
+			return nil, fmt.Errorf("invalid mexc %s minVol: %w", item.Symbol, err)
+		}
+		if instrument.ContractSize, err = common.ParseNumber(item.ContractSize); err != nil {
+			return nil, fmt.Errorf("invalid mexc %s contractSize: %w", item.Symbol, err)
+		}
+
+		d.symbols.Remember(exchange.MarketFutures, item.Symbol, instrument.Pair)
+		instruments = append(instruments, instrument)
+	}
+	return instruments, nil
+}
+
+// Проверка реализации интерфейсов на этапе компиляции
+var (
+	_ exchange.ExchangeDriver     = (*Driver)(nil)
+	_ exchange.ConnectionLimiter  = (*Driver)(nil)
+	_ exchange.Pinger             = (*Driver)(nil)
+	_ exchange.ServerClock        = (*Driver)(nil)
+	_ exchange.SymbolMapper       = (*Driver)(nil)
+	_ exchange.InstrumentProvider = (*Driver)(nil)
+)
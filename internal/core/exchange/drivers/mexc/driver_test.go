@@ -0,0 +1,90 @@
+package mexc
+
+import (
+	"testing"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/exchange/drivers/drivertest"
+	"trader/internal/core/messaging"
+)
+
+type (
+	level   = drivertest.Level
+	message = drivertest.Message
+	trade   = drivertest.Trade
+)
+
+func TestParseMessage(t *testing.T) {
+	drivertest.Run(t, New(DefaultEndpoints()), []drivertest.Case{
+		{
+			Name: "spot subscribe reply", File: "spot_subscribe_reply.json", MarketType: exchange.MarketSpot,
+		},
+		{
+			// Spot push приходит protobuf (PushDataV3ApiWrapper)
+			Name: "spot limit depth", File: "spot_limit_depth20.pb", MarketType: exchange.MarketSpot,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "BTC/USDT", Timestamp: 1731898619520000, SeqNum: 36913565463,
+				Snapshot: true, Depth: 20,
+				Bids: []level{{"67215", "1.20441"}, {"67214.9", "0.0451"}},
+				Asks: []level{{"67215.1", "0.51234"}, {"67215.2", "0.00015"}},
+			}},
+		},
+		{
+			Name: "spot aggregated deals", File: "spot_aggre_deals.pb", MarketType: exchange.MarketSpot,
+			Want: []message{
+				{
+					Type: messaging.TypeTrade, Pair: "BTC/USDT", Timestamp: 1731898619527000,
+					Trade: &trade{Price: "67215.1", Amount: "0.0012", Side: "buy"},
+				},
+				{
+					Type: messaging.TypeTrade, Pair: "BTC/USDT", Timestamp: 1731898619528000,
+					Trade: &trade{Price: "67215", Amount: "0.25", Side: "sell"},
+				},
+			},
+		},
+		{
+			Name: "futures subscribe reply", File: "futures_sub_reply.json", MarketType: exchange.MarketFutures,
+		},
+		{
+			Name: "futures full depth", File: "futures_depth_full.json", MarketType: exchange.MarketFutures,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "BTC/USDT", Timestamp: 1731898619521000, SeqNum: 36913565463,
+				Snapshot: true,
+				Bids:     []level{{"67201.1", "1200"}, {"67201", "35"}},
+				Asks:     []level{{"67201.2", "854"}, {"67201.9", "12"}},
+			}},
+		},
+		{
+			Name: "futures deal", File: "futures_deal.json", MarketType: exchange.MarketFutures,
+			Want: []message{{
+				Type: messaging.TypeTrade, Pair: "BTC/USDT", Timestamp: 1731898619520000,
+				Trade: &trade{Price: "67201.1", Amount: "12", Side: "sell"},
+			}},
+		},
+	})
+}
+
+func TestFetchInstruments(t *testing.T) {
+	withREST := func(url string) Endpoints {
+		endpoints := DefaultEndpoints()
+		endpoints.SpotREST = url
+		endpoints.FuturesREST = url
+		return endpoints
+	}
+	drivertest.RunInstruments(t, New, withREST, []drivertest.InstrumentCase{
+		{
+			MarketType: exchange.MarketSpot, File: "spot_exchange_info.json", Path: "/api/v3/exchangeInfo",
+			Want: []drivertest.Instrument{{
+				Pair: "BTC/USDT", Symbol: "BTCUSDT",
+				TickSize: "0.01", StepSize: "0.000001", MinNotional: "1", ContractSize: "1",
+			}},
+		},
+		{
+			MarketType: exchange.MarketFutures, File: "contract_detail.json", Path: "/api/v1/contract/detail",
+			Want: []drivertest.Instrument{{
+				Pair: "ETH/USDT", Symbol: "ETH_USDT",
+				TickSize: "0.01", StepSize: "1", MinQuantity: "1", ContractSize: "0.01",
+			}},
+		},
+	})
+}
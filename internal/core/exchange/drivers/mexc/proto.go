@@ -0,0 +1,208 @@
+package mexc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Минимальный разбор protobuf wire format для push фреймов спота MEXC
+// Схема (PushDataV3ApiWrapper.proto) небольшая и стабильна, поэтому вместо
+// кодогенерации поля читаются напрямую по номерам
+
+// errTruncated - фрейм обрывается посреди поля
+var errTruncated = errors.New("truncated protobuf message")
+
+// Типы wire format, которые встречаются в схемах MEXC
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protoField - одно поле сообщения: для wireBytes заполнено bytes, для остальных - varint
+type protoField struct {
+	num    int
+	wire   int
+	varint uint64
+	bytes  []byte
+}
+
+// walkProto вызывает fn для каждого поля сообщения в порядке следования
+func walkProto(data []byte, fn func(field protoField) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+
+		field := protoField{num: int(key >> 3), wire: int(key & 7)}
+		switch field.wire {
+		case wireVarint:
+			value, n := binary.Uvarint(data)
+			if n <= 0 {
+				return errTruncated
+			}
+			field.varint = value
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errTruncated
+			}
+			field.varint = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return errTruncated
+			}
+			field.bytes = data[n : n+int(size)]
+			data = data[n+int(size):]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errTruncated
+			}
+			field.varint = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d in field %d", field.wire, field.num)
+		}
+
+		if err := fn(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Номера полей PushDataV3ApiWrapper
+const (
+	wrapperChannel           = 1
+	wrapperSymbol            = 3
+	wrapperCreateTime        = 5
+	wrapperSendTime          = 6
+	wrapperPublicLimitDepths = 303
+	wrapperPublicAggreDeals  = 314
+)
+
+// pushWrapper - PushDataV3ApiWrapper (только используемые поля)
+type pushWrapper struct {
+	channel     string
+	symbol      string
+	createTime  int64
+	sendTime    int64
+	limitDepths []byte
+	aggreDeals  []byte
+}
+
+func decodeWrapper(data []byte) (*pushWrapper, error) {
+	wrapper := &pushWrapper{}
+	err := walkProto(data, func(field protoField) error {
+		switch field.num {
+		case wrapperChannel:
+			wrapper.channel = string(field.bytes)
+		case wrapperSymbol:
+			wrapper.symbol = string(field.bytes)
+		case wrapperCreateTime:
+			wrapper.createTime = int64(field.varint)
+		case wrapperSendTime:
+			wrapper.sendTime = int64(field.varint)
+		case wrapperPublicLimitDepths:
+			wrapper.limitDepths = field.bytes
+		case wrapperPublicAggreDeals:
+			wrapper.aggreDeals = field.bytes
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wrapper, nil
+}
+
+// limitDepths - PublicLimitDepthsV3Api: asks = 1, bids = 2, eventType = 3, version = 4
+// Уровень PublicLimitDepthV3ApiItem: price = 1, quantity = 2 (строки)
+type limitDepths struct {
+	asks    [][]string
+	bids    [][]string
+	version string
+}
+
+func decodeLimitDepths(data []byte) (*limitDepths, error) {
+	depths := &limitDepths{}
+	err := walkProto(data, func(field protoField) error {
+		switch field.num {
+		case 1, 2:
+			level, err := decodeDepthItem(field.bytes)
+			if err != nil {
+				return err
+			}
+			if field.num == 1 {
+				depths.asks = append(depths.asks, level)
+			} else {
+				depths.bids = append(depths.bids, level)
+			}
+		case 4:
+			depths.version = string(field.bytes)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return depths, nil
+}
+
+func decodeDepthItem(data []byte) ([]string, error) {
+	level := make([]string, 2)
+	err := walkProto(data, func(field protoField) error {
+		if field.num == 1 || field.num == 2 {
+			level[field.num-1] = string(field.bytes)
+		}
+		return nil
+	})
+	return level, err
+}
+
+// aggreDeal - PublicAggreDealsV3ApiItem: price = 1, quantity = 2, tradeType = 3 (1 buy, 2 sell), time = 4
+type aggreDeal struct {
+	price     string
+	quantity  string
+	tradeType int64
+	time      int64
+}
+
+// decodeAggreDeals разбирает PublicAggreDealsV3Api: deals = 1, eventType = 2
+func decodeAggreDeals(data []byte) ([]aggreDeal, error) {
+	var deals []aggreDeal
+	err := walkProto(data, func(field protoField) error {
+		if field.num != 1 {
+			return nil
+		}
+		var deal aggreDeal
+		err := walkProto(field.bytes, func(item protoField) error {
+			switch item.num {
+			case 1:
+				deal.price = string(item.bytes)
+			case 2:
+				deal.quantity = string(item.bytes)
+			case 3:
+				deal.tradeType = int64(item.varint)
+			case 4:
+				deal.time = int64(item.varint)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		deals = append(deals, deal)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deals, nil
+}
@@ -0,0 +1,6 @@
+
+/spot@public.aggre.deals.v3.api.pb@100ms@BTCUSDTBTCUSDT02]
+
+67215.10.0012 2
+
+672150.25 2'spot@public.aggre.deals.v3.api.pb@100ms
\ No newline at end of file
@@ -0,0 +1,8 @@
+
+,spot@public.limit.depth.v3.api.pb@BTCUSDT@20BTCUSDT" c0b05ecc7b9e4b4da5b2ab8a0e0f0d1702}
+
+67215.10.51234
+
+67215.20.00015
+672151.20441
+67214.90.0451!spot@public.limit.depth.v3.api.pb"36913565463
\ No newline at end of file
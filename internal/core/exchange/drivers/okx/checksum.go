@@ -0,0 +1,139 @@
+package okx
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// ErrChecksumMismatch - CRC32 книги после применения обновления не совпал с присланным OKX
+// Обновление отбрасывается, следующее не сойдется по prevSeqId и книга будет пересобрана
+var ErrChecksumMismatch = errors.New("okx order book checksum mismatch")
+
+// checksumLevels - сколько уровней с каждой стороны входит в checksum OKX
+const checksumLevels = 25
+
+// strLevel - уровень книги: порядок и совпадение уровней - по decimal цене,
+// checksum считается по исходным строкам биржи
+type strLevel struct {
+	price   decimal.Decimal
+	priceS  string
+	amountS string
+}
+
+// checksumBook - локальная копия книги канала books для проверки checksum
+type checksumBook struct {
+	bids []strLevel // по убыванию цены
+	asks []strLevel // по возрастанию цены
+	// valid = false после расхождения: проверка выключена до нового snapshot
+	valid bool
+}
+
+// checksumTracker хранит книги для проверки checksum по instId
+type checksumTracker struct {
+	mu    sync.Mutex
+	books map[string]*checksumBook
+}
+
+func newChecksumTracker() *checksumTracker {
+	return &checksumTracker{books: make(map[string]*checksumBook)}
+}
+
+// apply применяет snapshot/update к книге instId и сверяет checksum
+// Ошибка ErrChecksumMismatch возвращается только на первом расхождении,
+// дальнейшие обновления пропускаются без проверки до следующего snapshot
+func (t *checksumTracker) apply(instID string, snapshot bool, bids, asks [][]string, expected int32) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	book, ok := t.books[instID]
+	if snapshot || !ok {
+		book = &checksumBook{valid: snapshot}
+		t.books[instID] = book
+	}
+	if !book.valid {
+		return nil
+	}
+
+	var err error
+	if book.bids, err = mergeLevels(book.bids, bids, true); err != nil {
+		return err
+	}
+	if book.asks, err = mergeLevels(book.asks, asks, false); err != nil {
+		return err
+	}
+
+	if actual := book.checksum(); actual != expected {
+		book.valid = false
+		return fmt.Errorf("%w: %s expected %d got %d", ErrChecksumMismatch, instID, expected, actual)
+	}
+	return nil
+}
+
+// reset забывает книгу instId (например после отписки)
+func (t *checksumTracker) reset(instID string) {
+	t.mu.Lock()
+	delete(t.books, instID)
+	t.mu.Unlock()
+}
+
+// mergeLevels применяет изменения к отсортированной стороне книги
+// Размер "0" удаляет уровень
+func mergeLevels(side []strLevel, changes [][]string, descending bool) ([]strLevel, error) {
+	for _, change := range changes {
+		if len(change) < 2 {
+			return nil, fmt.Errorf("invalid okx level %v", change)
+		}
+		price, err := decimal.NewFromString(change[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid okx level price %q: %w", change[0], err)
+		}
+		amount, err := decimal.NewFromString(change[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid okx level size %q: %w", change[1], err)
+		}
+
+		idx := sort.Search(len(side), func(i int) bool {
+			if descending {
+				return side[i].price.Cmp(price) <= 0
+			}
+			return side[i].price.Cmp(price) >= 0
+		})
+		exists := idx < len(side) && side[idx].price.Equal(price)
+
+		switch {
+		case amount.IsZero() && exists:
+			side = append(side[:idx], side[idx+1:]...)
+		case amount.IsZero():
+			// удаление несуществующего уровня - ничего не делаем
+		case exists:
+			side[idx].priceS = change[0]
+			side[idx].amountS = change[1]
+		default:
+			side = append(side, strLevel{})
+			copy(side[idx+1:], side[idx:])
+			side[idx] = strLevel{price: price, priceS: change[0], amountS: change[1]}
+		}
+	}
+	return side, nil
+}
+
+// checksum строит строку bid1:size1:ask1:size1:bid2:... из 25 лучших уровней
+// (если одна сторона короче - продолжается только другая) и возвращает CRC32 как int32
+func (b *checksumBook) checksum() int32 {
+	parts := make([]string, 0, checksumLevels*4)
+	for i := 0; i < checksumLevels; i++ {
+		if i < len(b.bids) {
+			parts = append(parts, b.bids[i].priceS, b.bids[i].amountS)
+		}
+		if i < len(b.asks) {
+			parts = append(parts, b.asks[i].priceS, b.asks[i].amountS)
+		}
+	}
+	return int32(crc32.ChecksumIEEE([]byte(strings.Join(parts, ":"))))
+}
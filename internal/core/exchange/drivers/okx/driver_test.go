@@ -0,0 +1,135 @@
+package okx
+
+import (
+	"hash/crc32"
+	"testing"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/exchange/drivers/drivertest"
+	"trader/internal/core/messaging"
+)
+
+type (
+	level   = drivertest.Level
+	message = drivertest.Message
+	trade   = drivertest.Trade
+)
+
+var (
+	snapshotBook = message{
+		Type: messaging.TypeOrderBook, Pair: "BTC/USDT", Timestamp: 1718102400500000, SeqNum: 12031850431,
+		Snapshot: true,
+		Bids:     []level{{"67250.1", "0.52"}, {"67250", "1.1"}, {"67249.8", "0.003"}},
+		Asks:     []level{{"67250.2", "0.81"}, {"67250.5", "2"}},
+	}
+	updateBook = message{
+		Type: messaging.TypeOrderBook, Pair: "BTC/USDT", Timestamp: 1718102400600000, SeqNum: 12031850440,
+		Bids: []level{{"67250", "0.9"}},
+		Asks: []level{{"67250.2", "0"}, {"67250.3", "0.4"}},
+	}
+)
+
+func TestParseMessage(t *testing.T) {
+	drivertest.Run(t, New(DefaultEndpoints()), []drivertest.Case{
+		{
+			Name: "subscribe event", File: "subscribe_event.json", MarketType: exchange.MarketSpot,
+		},
+		{
+			Name: "spot book snapshot", File: "spot_books_snapshot.json", MarketType: exchange.MarketSpot,
+			Want: []message{snapshotBook},
+		},
+		{
+			Name: "spot book update", File: "spot_books_update.json", MarketType: exchange.MarketSpot,
+			Want: []message{updateBook},
+		},
+		{
+			Name: "spot trade", File: "spot_trades.json", MarketType: exchange.MarketSpot,
+			Want: []message{{
+				Type: messaging.TypeTrade, Pair: "BTC/USDT", Timestamp: 1718102400655000, SeqNum: 542093711,
+				Trade: &trade{Price: "67250.2", Amount: "0.01203", Side: "buy", ID: "542093711"},
+			}},
+		},
+		{
+			Name: "swap books5", File: "swap_books5.json", MarketType: exchange.MarketFutures,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "ETH/USDT", Timestamp: 1718102400710000, SeqNum: 3401928374,
+				Snapshot: true, Depth: 5,
+				Bids: []level{{"3512.44", "88"}, {"3512.43", "14"}},
+				Asks: []level{{"3512.45", "120"}, {"3512.46", "35"}},
+			}},
+		},
+	})
+}
+
+func TestChecksum(t *testing.T) {
+	drivertest.Run(t, New(DefaultEndpoints()), []drivertest.Case{
+		{
+			Name: "snapshot", File: "spot_books_snapshot.json", MarketType: exchange.MarketSpot,
+			Want: []message{snapshotBook},
+		},
+		{
+			Name: "update matches checksum", File: "spot_books_update.json", MarketType: exchange.MarketSpot,
+			Want: []message{updateBook},
+		},
+		{
+			Name: "update with wrong checksum", File: "spot_books_update_bad_checksum.json", MarketType: exchange.MarketSpot,
+			Err: ErrChecksumMismatch,
+		},
+		{
+			// После расхождения проверка выключена до нового снимка, дальше книгу сверяет prevSeqId
+			Name: "check disabled until snapshot", File: "spot_books_update_bad_checksum.json", MarketType: exchange.MarketSpot,
+			Want: []message{{
+				Type: messaging.TypeOrderBook, Pair: "BTC/USDT", SeqNum: 12031850450,
+				Bids: []level{{"67249.8", "0"}},
+				Asks: []level{},
+			}},
+		},
+		{
+			Name: "snapshot restores check", File: "spot_books_snapshot.json", MarketType: exchange.MarketSpot,
+			Want: []message{snapshotBook},
+		},
+		{
+			Name: "update after new snapshot", File: "spot_books_update.json", MarketType: exchange.MarketSpot,
+			Want: []message{updateBook},
+		},
+	})
+}
+
+func TestAlignDelta(t *testing.T) {
+	driver := New(DefaultEndpoints())
+	drivertest.Parse(t, driver, exchange.MarketSpot, "spot_books_snapshot.json")
+	// seqId = 12031850440, prevSeqId = 12031850431
+	delta := drivertest.Parse(t, driver, exchange.MarketSpot, "spot_books_update.json")[0]
+
+	drivertest.RunAlign(t, driver, exchange.MarketSpot, delta, []drivertest.Align{
+		{Name: "prevSeqId matches snapshot", LastSeqNum: 12031850431, AfterSnapshot: true, Want: exchange.SeqApply},
+		{Name: "prevSeqId matches previous", LastSeqNum: 12031850431, Want: exchange.SeqApply},
+		{Name: "duplicate", LastSeqNum: 12031850440, Want: exchange.SeqStale},
+		{Name: "missed update", LastSeqNum: 12031850420, Want: exchange.SeqGap},
+		{Name: "between prev and seq", LastSeqNum: 12031850435, Want: exchange.SeqGap},
+	})
+}
+
+func TestChecksumTracksDecimalPrices(t *testing.T) {
+	// Цены, неразличимые во float64, - разные уровни; "67250" и "67250.0" - один уровень
+	bids, err := mergeLevels(nil, [][]string{
+		{"0.10000000000000001", "5"}, {"0.1", "3"}, {"67250", "1.1"},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bids) != 3 || bids[0].priceS != "67250" || bids[1].priceS != "0.10000000000000001" || bids[2].priceS != "0.1" {
+		t.Fatalf("bids = %+v", bids)
+	}
+
+	bids, _ = mergeLevels(bids, [][]string{{"67250.0", "0.9"}, {"0.1", "0"}}, true)
+	if len(bids) != 2 || bids[0].priceS != "67250.0" || bids[0].amountS != "0.9" || bids[1].priceS != "0.10000000000000001" {
+		t.Fatalf("bids after update = %+v", bids)
+	}
+
+	// Checksum считается по строкам биржи
+	book := &checksumBook{bids: bids, asks: []strLevel{{priceS: "67250.2", amountS: "0.81"}}}
+	if want := int32(crc32.ChecksumIEEE([]byte("67250.0:0.9:67250.2:0.81:0.10000000000000001:5"))); book.checksum() != want {
+		t.Errorf("checksum = %d, want %d", book.checksum(), want)
+	}
+}
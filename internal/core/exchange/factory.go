@@ -0,0 +1,67 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownExchange - для биржи не зарегистрирован драйвер
+var ErrUnknownExchange = errors.New("no driver registered for exchange")
+
+// DriverFactory создает экземпляр драйвера биржи
+type DriverFactory func() ExchangeDriver
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]DriverFactory) // key = ExchangeID
+)
+
+// Register регистрирует фабрику драйвера для биржи
+// Вызывается из init() пакета драйвера, повторная регистрация - ошибка программиста (panic)
+func Register(exchangeID string, factory DriverFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("exchange: Register factory is nil for " + exchangeID)
+	}
+	if _, exists := registry[exchangeID]; exists {
+		panic("exchange: Register called twice for " + exchangeID)
+	}
+	registry[exchangeID] = factory
+}
+
+// NewDriver создает драйвер для биржи по ее ID
+func NewDriver(exchangeID string) (ExchangeDriver, error) {
+	registryMu.RLock()
+	factory, ok := registry[exchangeID]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownExchange, exchangeID)
+	}
+	return factory(), nil
+}
+
+// IsRegistered сообщает есть ли драйвер для биржи
+func IsRegistered(exchangeID string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := registry[exchangeID]
+	return ok
+}
+
+// RegisteredExchanges возвращает отсортированный список бирж с драйверами
+func RegisteredExchanges() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	ids := make([]string, 0, len(registry))
+	for id := range registry {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,94 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// ErrOrderRejected - ордер не проходит ограничения инструмента и будет отклонен биржей
+var ErrOrderRejected = errors.New("order violates instrument limits")
+
+// ============================================================================
+// Instrument - параметры торгуемого инструмента на бирже
+// ============================================================================
+
+// Instrument описывает ограничения биржи на ордера по паре
+// Загружается драйвером (InstrumentProvider) из REST exchangeInfo-подобных методов
+// Нулевое значение ограничения означает, что биржа его не объявляет
+type Instrument struct {
+	// ExchangeID, MarketType, Pair - идентификация, Pair в формате "BTC/USDT"
+	ExchangeID string
+	MarketType string
+	Pair       string
+
+	// Symbol - символ инструмента на бирже (BTCUSDT, BTC-USDT-SWAP, XBTUSDTM)
+	Symbol string
+
+	// TickSize - шаг цены: цена ордера должна быть кратна TickSize
+	TickSize decimal.Decimal
+
+	// StepSize - шаг объема ордера. Объем ордера измеряется в базовом активе,
+	// а для фьючерсов с ContractSize != 1 - в контрактах
+	StepSize decimal.Decimal
+
+	// MinQuantity - минимальный объем ордера в тех же единицах, что StepSize
+	MinQuantity decimal.Decimal
+
+	// MinNotional - минимальная стоимость ордера в котируемом активе
+	MinNotional decimal.Decimal
+
+	// ContractSize - количество базового актива в одном контракте
+	// 1 для спота и фьючерсов, где объем задается в базовом активе
+	ContractSize decimal.Decimal
+}
+
+// RoundPrice приводит цену ордера к TickSize (покупка вниз, продажа вверх)
+func (i Instrument) RoundPrice(price decimal.Decimal, side string) decimal.Decimal {
+	return RoundPrice(price, i.TickSize, side)
+}
+
+// RoundQuantity округляет объем ордера вниз до StepSize
+func (i Instrument) RoundQuantity(quantity decimal.Decimal) decimal.Decimal {
+	return RoundQuantity(quantity, i.StepSize)
+}
+
+// QuantityFor переводит объем в базовом активе в объем ордера (контракты для фьючерсов)
+// с округлением вниз до StepSize
+func (i Instrument) QuantityFor(baseAmount decimal.Decimal) decimal.Decimal {
+	if i.ContractSize.IsPositive() {
+		baseAmount = baseAmount.Div(i.ContractSize)
+	}
+	return i.RoundQuantity(baseAmount)
+}
+
+// Notional возвращает стоимость ордера в котируемом активе
+func (i Instrument) Notional(price, quantity decimal.Decimal) decimal.Decimal {
+	notional := price.Mul(quantity)
+	if i.ContractSize.IsPositive() {
+		notional = notional.Mul(i.ContractSize)
+	}
+	return notional
+}
+
+// ValidateOrder проверяет цену и объем ордера до отправки на биржу:
+// кратность шагам, минимальный объем и минимальная стоимость
+func (i Instrument) ValidateOrder(price, quantity decimal.Decimal) error {
+	if !quantity.IsPositive() {
+		return fmt.Errorf("%w: %s %s quantity %s must be positive", ErrOrderRejected, i.ExchangeID, i.Pair, quantity)
+	}
+	if i.TickSize.IsPositive() && !price.Mod(i.TickSize).IsZero() {
+		return fmt.Errorf("%w: %s %s price %s is not a multiple of tick %s", ErrOrderRejected, i.ExchangeID, i.Pair, price, i.TickSize)
+	}
+	if i.StepSize.IsPositive() && !quantity.Mod(i.StepSize).IsZero() {
+		return fmt.Errorf("%w: %s %s quantity %s is not a multiple of step %s", ErrOrderRejected, i.ExchangeID, i.Pair, quantity, i.StepSize)
+	}
+	if quantity.LessThan(i.MinQuantity) {
+		return fmt.Errorf("%w: %s %s quantity %s below minimum %s", ErrOrderRejected, i.ExchangeID, i.Pair, quantity, i.MinQuantity)
+	}
+	if price.IsPositive() && i.Notional(price, quantity).LessThan(i.MinNotional) {
+		return fmt.Errorf("%w: %s %s notional %s below minimum %s", ErrOrderRejected, i.ExchangeID, i.Pair, i.Notional(price, quantity), i.MinNotional)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package exchange
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// ============================================================================
+// Округление цены и объема ордера к шагам биржи
+// ============================================================================
+
+// Биржа отклоняет ордер, если цена не кратна шагу цены (tick size) или объем
+// не кратен шагу лота (step size): "invalid price/quantity precision".
+// Округление делается в decimal: float64 не представляет точно даже 0.1,
+// и 0.3 / 0.1 после округления вниз дает 2 шага вместо 3.
+
+// Направление ордера
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+)
+
+// RoundPrice приводит цену ордера к шагу tick в сторону, безопасную для ордера:
+// покупка округляется вниз (не переплатить), продажа - вверх (не продешевить)
+// tick <= 0 - цена возвращается без изменений
+func RoundPrice(price, tick decimal.Decimal, side string) decimal.Decimal {
+	if !tick.IsPositive() {
+		return price
+	}
+	steps := price.Div(tick)
+	if side == SideSell {
+		steps = steps.Ceil()
+	} else {
+		steps = steps.Floor()
+	}
+	return steps.Mul(tick)
+}
+
+// RoundQuantity округляет объем вниз до шага лота step:
+// ордер никогда не превышает доступный объем или лимит позиции
+// step <= 0 - объем возвращается без изменений
+func RoundQuantity(amount, step decimal.Decimal) decimal.Decimal {
+	if !step.IsPositive() {
+		return amount
+	}
+	return amount.Div(step).Floor().Mul(step)
+}
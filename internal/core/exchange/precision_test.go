@@ -0,0 +1,116 @@
+package exchange
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func dec(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func TestRoundPrice(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		price string
+		tick  string
+		side  string
+		want  string
+	}{
+		{"multiple of tick buy", "100.25", "0.05", SideBuy, "100.25"},
+		{"multiple of tick sell", "100.25", "0.05", SideSell, "100.25"},
+		{"just above tick buy", "100.2500001", "0.05", SideBuy, "100.25"},
+		{"just above tick sell", "100.2500001", "0.05", SideSell, "100.3"},
+		{"just below tick buy", "100.2499999", "0.05", SideBuy, "100.2"},
+		{"just below tick sell", "100.2499999", "0.05", SideSell, "100.25"},
+		// float64: 0.3 / 0.1 = 2.9999999999999996, округление вниз дало бы 0.2
+		{"decimal tick 0.1", "0.3", "0.1", SideBuy, "0.3"},
+		{"tiny tick", "0.000012345", "0.00000001", SideBuy, "0.00001234"},
+		{"tiny tick sell", "0.000012345", "0.00000001", SideSell, "0.00001235"},
+		{"integer tick", "27013", "10", SideBuy, "27010"},
+		{"below one tick buy", "0.04", "0.05", SideBuy, "0"},
+		{"below one tick sell", "0.04", "0.05", SideSell, "0.05"},
+		{"zero tick", "100.123", "0", SideBuy, "100.123"},
+		{"negative tick", "100.123", "-0.1", SideSell, "100.123"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := RoundPrice(dec(tc.price), dec(tc.tick), tc.side)
+			if !got.Equal(dec(tc.want)) {
+				t.Errorf("RoundPrice(%s, %s, %s) = %s, want %s", tc.price, tc.tick, tc.side, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRoundQuantity(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		amount string
+		step   string
+		want   string
+	}{
+		{"multiple of step", "1.5", "0.001", "1.5"},
+		{"just above step", "1.5000001", "0.001", "1.5"},
+		{"just below step", "1.4999999", "0.001", "1.499"},
+		{"decimal step 0.1", "0.3", "0.1", "0.3"},
+		{"integer step", "17", "5", "15"},
+		{"below one step", "0.0009", "0.001", "0"},
+		{"zero step", "0.123456", "0", "0.123456"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := RoundQuantity(dec(tc.amount), dec(tc.step))
+			if !got.Equal(dec(tc.want)) {
+				t.Errorf("RoundQuantity(%s, %s) = %s, want %s", tc.amount, tc.step, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInstrumentValidateOrder(t *testing.T) {
+	spot := Instrument{
+		ExchangeID: Binance, Pair: "BTC/USDT",
+		TickSize: dec("0.01"), StepSize: dec("0.00001"),
+		MinQuantity: dec("0.0001"), MinNotional: dec("5"),
+	}
+	contracts := Instrument{
+		ExchangeID: OKX, Pair: "BTC/USDT",
+		TickSize: dec("0.1"), StepSize: dec("1"), MinQuantity: dec("1"),
+		ContractSize: dec("0.01"),
+	}
+
+	for _, tc := range []struct {
+		name       string
+		instrument Instrument
+		price      string
+		quantity   string
+		reject     bool
+	}{
+		{"valid", spot, "27000.01", "0.001", false},
+		{"price off tick", spot, "27000.015", "0.001", true},
+		{"quantity off step", spot, "27000", "0.000015", true},
+		{"below min quantity", spot, "27000", "0.00009", true},
+		{"at min quantity", spot, "60000", "0.0001", false},
+		{"below min notional", spot, "27000", "0.00018", true},
+		{"zero quantity", spot, "27000", "0", true},
+		{"rounded below min quantity", spot, "27000", spot.RoundQuantity(dec("0.000099")).String(), true},
+		{"contracts", contracts, "27000.1", "3", false},
+		{"fractional contract", contracts, "27000.1", "0.5", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.instrument.ValidateOrder(dec(tc.price), dec(tc.quantity))
+			if tc.reject != errors.Is(err, ErrOrderRejected) {
+				t.Errorf("ValidateOrder(%s, %s) = %v, reject %v", tc.price, tc.quantity, err, tc.reject)
+			}
+		})
+	}
+
+	// 3 контракта по 0.01 BTC: стоимость считается в базовом активе
+	if notional := contracts.Notional(dec("27000"), dec("3")); !notional.Equal(dec("810")) {
+		t.Errorf("contracts notional = %s, want 810", notional)
+	}
+	if quantity := contracts.QuantityFor(dec("0.037")); !quantity.Equal(dec("3")) {
+		t.Errorf("QuantityFor(0.037 BTC) = %s contracts, want 3", quantity)
+	}
+}
@@ -1,6 +1,8 @@
 // Package exchange содержит типы и интерфейсы для работы с биржами
 package exchange
 
+import "github.com/shopspring/decimal"
+
 // ============================================================================
 // Exchange Identifiers
 // ============================================================================
@@ -38,13 +40,14 @@ const (
 type Level struct {
 	// Price - цена за одну единицу актива
 	// Для BTC/USDT это будет цена в USDT
-	// Тип float64 позволяет работать с дробными ценами (например, 45123.56)
-	Price float64
+	// Десятичное число с фиксированной точкой: цена хранится ровно так,
+	// как ее прислала биржа (45123.56), без ошибок округления float64
+	Price decimal.Decimal
 
 	// Amount - объем активов на данной цене
 	// Для BTC/USDT это будет количество BTC доступно по цене Price
 	// Если Amount = 0, это означает что данный уровень удален
-	Amount float64
+	Amount decimal.Decimal
 }
 
 // ============================================================================
@@ -224,6 +227,17 @@ type TradingTask struct {
 
 	// === Параметры торговли (из таблицы TRADE) ===
 
+	// MaxAmountTrade - предельная стоимость одного ордера в котируемом активе (TRADE.MAX_AMOUNT_TRADE)
+	// Ноль = без ограничения
+	MaxAmountTrade decimal.Decimal
+
+	// MaxPositionSize - предельный размер позиции в котируемом активе (TRADE.MAX_POSITION_SIZE)
+	MaxPositionSize decimal.Decimal
+
+	// SlippagePercent - допустимое проскальзывание рыночного ордера от mid в процентах
+	// (TRADE.SLIPPAGE_PERCENT), ноль = без ограничения
+	SlippagePercent decimal.Decimal
+
 	// StrategyParams - параметры стратегии в виде JSON строки
 	// Содержит конфигурацию для конкретной стратегии
 	// Примеры для grid стратегии:
@@ -236,6 +250,10 @@ type TradingTask struct {
 	// Парсится при загрузке в зависимости от StrategyID
 	StrategyParams string
 
+	// BBOOnly - стратегии нужны только лучшие bid/ask (TRADE.BBO_ONLY)
+	// Пара подписывается с глубиной DepthBBO, если ее книга не нужна другим задачам
+	BBOOnly bool
+
 	// ExchangeAccountID - ID API ключа для этой биржи
 	// Определяет какой аккаунт на бирже использовать для ордеров
 	// Пользователь может иметь несколько API ключей на одной бирже
@@ -0,0 +1,200 @@
+// Package instrument кеширует параметры инструментов бирж (шаг цены, шаг объема,
+// минимальный ордер, размер контракта)
+//
+// Инструменты рынка загружаются целиком одним REST запросом драйвера
+// (exchange.InstrumentProvider) при первом обращении и обновляются по истечении TTL.
+// Если обновление не удалось, используются ранее загруженные данные,
+// а следующая попытка делается не раньше чем через RetryDelay.
+package instrument
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"trader/internal/core/exchange"
+	"trader/internal/logger"
+)
+
+// DefaultTTL - как долго загруженные инструменты считаются актуальными
+// Шаги цены и лотов меняются редко, но биржи объявляют такие изменения заранее
+const DefaultTTL = 1 * time.Hour
+
+// RetryDelay - пауза перед повторной загрузкой после неудачной:
+// пока REST биржи недоступен, Get не должен обращаться к нему при каждом вызове
+const RetryDelay = 1 * time.Minute
+
+var (
+	// ErrNotSupported - драйвер биржи не умеет загружать инструменты
+	ErrNotSupported = errors.New("exchange does not provide instruments")
+	// ErrNotFound - биржа не торгует пару на рынке
+	ErrNotFound = errors.New("instrument not found")
+)
+
+// Source - источник драйверов бирж, реализуется ws.Pool
+type Source interface {
+	Driver(exchangeID string) (exchange.ExchangeDriver, error)
+}
+
+// market - инструменты одного (exchange, market)
+type market struct {
+	// loadMu сериализует загрузку: параллельные Get ждут один REST запрос
+	loadMu sync.Mutex
+
+	instruments map[string]exchange.Instrument // key = pair
+	loadedAt    time.Time
+
+	// retryAt - раньше этого времени загрузка не повторяется, loadErr - ошибка последней загрузки
+	retryAt time.Time
+	loadErr error
+}
+
+// Catalog - кеш инструментов по (exchange, market, pair)
+// Безопасен для конкурентного использования
+type Catalog struct {
+	source Source
+	ttl    time.Duration
+	log    *slog.Logger
+
+	mu      sync.RWMutex
+	markets map[string]*market // key = "exchange:market"
+}
+
+// NewCatalog создает пустой каталог, ttl <= 0 - DefaultTTL
+func NewCatalog(source Source, ttl time.Duration) *Catalog {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Catalog{
+		source:  source,
+		ttl:     ttl,
+		log:     logger.Get("instrument"),
+		markets: make(map[string]*market),
+	}
+}
+
+// Get возвращает инструмент пары, при необходимости загружая инструменты рынка
+func (c *Catalog) Get(ctx context.Context, exchangeID, marketType, pair string) (exchange.Instrument, error) {
+	m := c.market(exchangeID, marketType)
+
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+
+	if time.Since(m.loadedAt) >= c.ttl {
+		if err := c.reload(ctx, m, exchangeID, marketType); err != nil {
+			if m.loadedAt.IsZero() {
+				return exchange.Instrument{}, err
+			}
+			// Устаревшие шаги лучше, чем отказ в торговле из-за недоступного REST
+			c.log.Warn("instrument refresh failed, using cached",
+				"exchange_id", exchangeID,
+				"market_type", marketType,
+				"loaded_at", m.loadedAt,
+				"retry_at", m.retryAt,
+				"error", err,
+			)
+		}
+	}
+
+	instrument, ok := m.instruments[pair]
+	if !ok {
+		return exchange.Instrument{}, fmt.Errorf("%w: %s %s %s", ErrNotFound, exchangeID, marketType, pair)
+	}
+	return instrument, nil
+}
+
+// Refresh принудительно перезагружает инструменты рынка
+func (c *Catalog) Refresh(ctx context.Context, exchangeID, marketType string) error {
+	m := c.market(exchangeID, marketType)
+
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+	return c.load(ctx, m, exchangeID, marketType)
+}
+
+// Instruments возвращает загруженные инструменты рынка, отсортированные по паре
+// Не обращается к бирже: пусто, если рынок еще не загружался
+func (c *Catalog) Instruments(exchangeID, marketType string) []exchange.Instrument {
+	m := c.market(exchangeID, marketType)
+
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+
+	instruments := make([]exchange.Instrument, 0, len(m.instruments))
+	for _, instrument := range m.instruments {
+		instruments = append(instruments, instrument)
+	}
+	sort.Slice(instruments, func(i, j int) bool { return instruments[i].Pair < instruments[j].Pair })
+	return instruments
+}
+
+// market возвращает (создает) запись рынка
+func (c *Catalog) market(exchangeID, marketType string) *market {
+	key := exchangeID + ":" + marketType
+
+	c.mu.RLock()
+	m, ok := c.markets[key]
+	c.mu.RUnlock()
+	if ok {
+		return m
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if m, ok = c.markets[key]; !ok {
+		m = &market{}
+		c.markets[key] = m
+	}
+	return m
+}
+
+// reload загружает инструменты рынка, если после неудачной загрузки прошло RetryDelay,
+// иначе возвращает ошибку той загрузки. Вызывается под m.loadMu
+func (c *Catalog) reload(ctx context.Context, m *market, exchangeID, marketType string) error {
+	if time.Now().Before(m.retryAt) {
+		return m.loadErr
+	}
+	err := c.load(ctx, m, exchangeID, marketType)
+	if err != nil {
+		m.retryAt = time.Now().Add(RetryDelay)
+	}
+	m.loadErr = err
+	return err
+}
+
+// load загружает инструменты рынка через драйвер, вызывается под m.loadMu
+func (c *Catalog) load(ctx context.Context, m *market, exchangeID, marketType string) error {
+	driver, err := c.source.Driver(exchangeID)
+	if err != nil {
+		return err
+	}
+	provider, ok := driver.(exchange.InstrumentProvider)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotSupported, exchangeID)
+	}
+
+	start := time.Now()
+	list, err := provider.FetchInstruments(ctx, marketType)
+	if err != nil {
+		return fmt.Errorf("load %s %s instruments: %w", exchangeID, marketType, err)
+	}
+
+	instruments := make(map[string]exchange.Instrument, len(list))
+	for _, instrument := range list {
+		instruments[instrument.Pair] = instrument
+	}
+	m.instruments = instruments
+	m.loadedAt = time.Now()
+
+	c.log.Info("instruments loaded",
+		"exchange_id", exchangeID,
+		"market_type", marketType,
+		"instruments", len(instruments),
+		"latency_ms", float64(time.Since(start).Microseconds())/1000.0,
+	)
+	return nil
+}
@@ -0,0 +1,90 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+)
+
+// instrumentDriver - биржа с REST инструментами, err включает отказ REST
+type instrumentDriver struct {
+	calls int
+	err   error
+}
+
+func (d *instrumentDriver) GetExchangeID() string                { return "test" }
+func (d *instrumentDriver) GetName() string                      { return "Test" }
+func (d *instrumentDriver) GetWSEndpoint(string) (string, error) { return "ws://test", nil }
+func (d *instrumentDriver) CreateSubscribeMessage(string, []string, int) ([][]byte, error) {
+	return nil, nil
+}
+func (d *instrumentDriver) CreateUnsubscribeMessage(string, []string, int) ([][]byte, error) {
+	return nil, nil
+}
+func (d *instrumentDriver) ParseMessage(string, []byte) ([]*messaging.Message, error) {
+	return nil, nil
+}
+
+func (d *instrumentDriver) FetchInstruments(_ context.Context, marketType string) ([]exchange.Instrument, error) {
+	d.calls++
+	if d.err != nil {
+		return nil, d.err
+	}
+	return []exchange.Instrument{{
+		ExchangeID: "test", MarketType: marketType, Pair: "BTC/USDT", Symbol: "BTCUSDT",
+		TickSize: decimal.RequireFromString("0.01"),
+	}}, nil
+}
+
+type driverSource struct{ driver exchange.ExchangeDriver }
+
+func (s driverSource) Driver(string) (exchange.ExchangeDriver, error) { return s.driver, nil }
+
+func TestGetBacksOffAfterFailedRefresh(t *testing.T) {
+	driver := &instrumentDriver{}
+	// TTL истекает сразу: каждый Get пытается обновить инструменты
+	catalog := NewCatalog(driverSource{driver}, time.Nanosecond)
+	ctx := context.Background()
+
+	if _, err := catalog.Get(ctx, "test", exchange.MarketSpot, "BTC/USDT"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	driver.err = errors.New("503 service unavailable")
+	for i := 0; i < 3; i++ {
+		instrument, err := catalog.Get(ctx, "test", exchange.MarketSpot, "BTC/USDT")
+		if err != nil || instrument.Symbol != "BTCUSDT" {
+			t.Fatalf("Get with failed refresh = %+v, %v; want cached instrument", instrument, err)
+		}
+	}
+	if driver.calls != 2 {
+		t.Errorf("FetchInstruments calls = %d, want 2: refresh must wait RetryDelay after a failure", driver.calls)
+	}
+
+	// Refresh - принудительная загрузка без паузы
+	driver.err = nil
+	if err := catalog.Refresh(ctx, "test", exchange.MarketSpot); err != nil || driver.calls != 3 {
+		t.Errorf("Refresh = %v, calls = %d", err, driver.calls)
+	}
+}
+
+func TestGetWithoutCacheReturnsLastError(t *testing.T) {
+	failure := errors.New("503 service unavailable")
+	driver := &instrumentDriver{err: failure}
+	catalog := NewCatalog(driverSource{driver}, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if _, err := catalog.Get(context.Background(), "test", exchange.MarketFutures, "BTC/USDT"); !errors.Is(err, failure) {
+			t.Fatalf("Get = %v, want %v", err, failure)
+		}
+	}
+	if driver.calls != 1 {
+		t.Errorf("FetchInstruments calls = %d, want 1", driver.calls)
+	}
+}
@@ -1,6 +1,11 @@
 // Package messaging содержит единый формат сообщений от всех бирж
+//
+// Цены и объемы - decimal.Decimal: значения биржи хранятся без потерь точности
+// от разбора до записи в БД (DECIMAL(30,12))
 package messaging
 
+import "github.com/shopspring/decimal"
+
 // ============================================================================
 // Message Types - константы для типов сообщений от бирж
 // ============================================================================
@@ -12,6 +17,16 @@ const (
 	TypeTrade     = "trade"     // Новая сделка (реальная торговля на бирже)
 	TypePosition  = "position"  // Обновление позиции (для трейдера)
 	TypeOrder     = "order"     // Обновление статуса ордера (мой ордер исполнен и т.д.)
+
+	// TypeBBO - изменились лучшие bid/ask пары (вершина книги)
+	// Приходит из потока лучших цен биржи (bookTicker) или выводится из книги,
+	// публикуется только когда вершина книги действительно изменилась
+	TypeBBO = "bbo"
+
+	// TypeBookInvalidated - сигнал WS пула: соединение оборвалось, книга пары устарела
+	// Получатель должен сбросить состояние книги и дождаться нового снимка
+	// Заполнены только Timestamp, ExchangeID, MarketType и Pair
+	TypeBookInvalidated = "book_invalidated"
 )
 
 // ============================================================================
@@ -22,9 +37,9 @@ const (
 // Используется внутри OrderBookData для представления bid/ask
 type Level struct {
 	// Price - цена за одну единицу актива
-	Price float64
+	Price decimal.Decimal
 	// Amount - объем на этой цене (0 = уровень удален)
-	Amount float64
+	Amount decimal.Decimal
 }
 
 // ============================================================================
@@ -43,8 +58,18 @@ type Message struct {
 	// Стандартный формат для HFT: достаточная точность для всех бирж
 	// Конверсия: ms × 1000 = μs, ns ÷ 1000 = μs
 	// Всегда в UTC, не зависит от часового пояса
+	// Это время по часам биржи: для сравнения бирж между собой используйте LocalTimestamp
 	Timestamp int64
 
+	// ReceivedAt - локальное время получения фрейма в Unix микросекундах (UTC)
+	// Заполняется WS пулом, 0 для сообщений не из WS (например REST снимков)
+	ReceivedAt int64
+
+	// ClockOffset - оценка расхождения часов биржи с локальными в микросекундах
+	// (время биржи минус локальное время), 0 пока оценки нет
+	// ReceivedAt - LocalTimestamp() = задержка доставки от биржи до нас
+	ClockOffset int64
+
 	// ExchangeID - ID биржи которая отправила это сообщение
 	// Значения: "binance", "bybit", "okx", "kucoin", "coinex", "htx", "mexc", "dex"
 	// Используется для идентификации источника данных
@@ -54,7 +79,7 @@ type Message struct {
 	// Определяет какой рынок связан с этим сообщением
 	MarketType string
 
-	// Type - тип сообщения (orderbook, trade, position, order)
+	// Type - тип сообщения (orderbook, trade, bbo, position, order)
 	// Определяет какое из полей ниже заполнено
 	// Остальные поля будут nil
 	Type string
@@ -91,6 +116,11 @@ type Message struct {
 	// Содержит информацию об исполнении моего ордера
 	// Заполнено ТОЛЬКО если Type == TypeOrder
 	Order *OrderData
+
+	// BBO - данные для типа "bbo"
+	// Содержит лучшие bid/ask без остальной книги
+	// Заполнено ТОЛЬКО если Type == TypeBBO
+	BBO *BBOData
 }
 
 // ============================================================================
@@ -125,6 +155,40 @@ type OrderBookData struct {
 	// - 0 = полная книга (самая полная информация, но медленнее)
 	// Примечание: количество уровней может быть < Depth если меньше уровней в реальности
 	Depth int
+
+	// Snapshot - true если это полный снимок книги (заменяет текущее состояние)
+	// false - дельта: уровни с Amount = 0 удаляются, остальные перезаписываются
+	Snapshot bool
+
+	// FirstSeqNum - первый update id, вошедший в дельту (Binance "U")
+	// Для бирж без диапазона update id равен Message.SeqNum
+	FirstSeqNum int64
+
+	// PrevSeqNum - SeqNum предыдущей дельты по версии биржи (Binance futures "pu")
+	// 0 если биржа не передает предыдущий номер
+	PrevSeqNum int64
+}
+
+// ============================================================================
+// BBOData - данные для сообщений типа "bbo"
+// ============================================================================
+
+// BBOData содержит лучшие цены книги (best bid/offer)
+// Нулевые цена и объем стороны - сторона книги пуста
+type BBOData struct {
+	// BidPrice и BidAmount - лучшая цена покупателя и объем на ней (Bids[0])
+	BidPrice  decimal.Decimal
+	BidAmount decimal.Decimal
+
+	// AskPrice и AskAmount - лучшая цена продавца и объем на ней (Asks[0])
+	AskPrice  decimal.Decimal
+	AskAmount decimal.Decimal
+}
+
+// Equal сообщает совпадают ли цены и объемы лучших уровней
+func (b BBOData) Equal(other BBOData) bool {
+	return b.BidPrice.Equal(other.BidPrice) && b.BidAmount.Equal(other.BidAmount) &&
+		b.AskPrice.Equal(other.AskPrice) && b.AskAmount.Equal(other.AskAmount)
 }
 
 // ============================================================================
@@ -137,17 +201,20 @@ type OrderBookData struct {
 type TradeData struct {
 	// Price - цена сделки
 	// По какой цене произошла сделка
-	Price float64
+	Price decimal.Decimal
 
 	// Amount - объем сделки
 	// Количество актива которое торговалось
-	Amount float64
+	Amount decimal.Decimal
 
 	// Side - направление сделки с точки зрения инициатора
 	// "buy" = покупатель инициировал сделку (взял ask)
 	// "sell" = продавец инициировал сделку (взял bid)
 	// Используется для анализа направления тренда (aggressor side)
 	Side string
+
+	// TradeID - ID сделки на бирже (для дедупликации)
+	TradeID string
 }
 
 // ============================================================================
@@ -165,20 +232,20 @@ type PositionData struct {
 
 	// Amount - объем позиции
 	// Количество активов в позиции
-	Amount float64
+	Amount decimal.Decimal
 
 	// EntryPrice - цена входа в позицию
 	// По какой цене открыли позицию
-	EntryPrice float64
+	EntryPrice decimal.Decimal
 
 	// CurrentPrice - текущая цена актива
 	// Используется для вычисления P&L
-	CurrentPrice float64
+	CurrentPrice decimal.Decimal
 
 	// PnL - прибыль/убыток по позиции
 	// Вычисляется как (CurrentPrice - EntryPrice) * Amount для long
 	// или как (EntryPrice - CurrentPrice) * Amount для short
-	PnL float64
+	PnL decimal.Decimal
 }
 
 // ============================================================================
@@ -202,15 +269,15 @@ type OrderData struct {
 	// Price - цена заказа
 	// Лимит цена для лимит-ордера
 	// 0 для маркет-ордера
-	Price float64
+	Price decimal.Decimal
 
 	// Amount - объем заказа (всего)
 	// Сколько активов хотели купить/продать
-	Amount float64
+	Amount decimal.Decimal
 
 	// Filled - объем уже исполненный
 	// Сколько уже исполнено
-	Filled float64
+	Filled decimal.Decimal
 
 	// Status - статус ордера
 	// "open" = открыт (ждет исполнения)
@@ -222,13 +289,19 @@ type OrderData struct {
 
 	// Commission - комиссия по ордеру
 	// Сколько взяла биржа за исполнение
-	Commission float64
+	Commission decimal.Decimal
 }
 
 // ============================================================================
 // Вспомогательные функции
 // ============================================================================
 
+// LocalTimestamp возвращает время события по локальным часам в Unix микросекундах
+// Время разных бирж сравнимо только в этой шкале
+func (m *Message) LocalTimestamp() int64 {
+	return m.Timestamp - m.ClockOffset
+}
+
 // GetMessageKey возвращает уникальный ключ для сообщения
 // Используется для дедупликации и логирования
 func GetMessageKey(msg *Message) string {
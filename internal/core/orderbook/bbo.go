@@ -0,0 +1,78 @@
+package orderbook
+
+import (
+	"fmt"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+)
+
+// updateBBO публикует лучшие цены из потока биржи (bookTicker), если они изменились
+// Пары с подпиской exchange.DepthBBO не имеют книги: состояние хранит только BBO
+func (m *Manager) updateBBO(msg *messaging.Message) error {
+	if msg.BBO == nil || msg.ExchangeID == "" || msg.Pair == "" {
+		return fmt.Errorf("%w: %s %s %s", ErrInvalidMessage, msg.ExchangeID, msg.MarketType, msg.Pair)
+	}
+	key := exchange.GetOrderBookKey(msg.ExchangeID, msg.Pair, msg.MarketType)
+
+	m.mu.Lock()
+	update := m.bboUpdate(m.state(key, msg), *msg.BBO, msg)
+	m.mu.Unlock()
+
+	m.router.Publish(update)
+	return nil
+}
+
+// bboUpdate запоминает лучшие цены пары и собирает TypeBBO для подписчиков
+// nil если цены и объемы не изменились или на BBO пары никто не подписан
+// Вызывается под m.mu
+func (m *Manager) bboUpdate(state *bookState, bbo messaging.BBOData, source *messaging.Message) *messaging.Message {
+	if state.hasBBO && state.bbo.Equal(bbo) {
+		return nil
+	}
+	state.bbo = bbo
+	state.hasBBO = true
+
+	book := &state.book
+	if !m.router.HasSubscribers(book.ExchangeID, book.MarketType, book.Pair, messaging.TypeBBO) {
+		return nil
+	}
+	return &messaging.Message{
+		Timestamp:   source.Timestamp,
+		ReceivedAt:  source.ReceivedAt,
+		ClockOffset: source.ClockOffset,
+		ExchangeID:  book.ExchangeID,
+		MarketType:  book.MarketType,
+		Type:        messaging.TypeBBO,
+		Pair:        book.Pair,
+		SeqNum:      source.SeqNum,
+		BBO:         &bbo,
+	}
+}
+
+// GetBBO возвращает последние лучшие цены пары (из книги или потока лучших цен)
+// false если цен еще нет или книга сброшена и ждет снимка
+func (m *Manager) GetBBO(exchangeID, pair, marketType string) (messaging.BBOData, bool) {
+	key := exchange.GetOrderBookKey(exchangeID, pair, marketType)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, ok := m.books[key]
+	if !ok || !state.hasBBO {
+		return messaging.BBOData{}, false
+	}
+	return state.bbo, true
+}
+
+// topOfBook возвращает лучшие уровни книги, пустая сторона - нулевые цена и объем
+func topOfBook(book *exchange.OrderBook) messaging.BBOData {
+	var bbo messaging.BBOData
+	if len(book.Bids) > 0 {
+		bbo.BidPrice, bbo.BidAmount = book.Bids[0].Price, book.Bids[0].Amount
+	}
+	if len(book.Asks) > 0 {
+		bbo.AskPrice, bbo.AskAmount = book.Asks[0].Price, book.Asks[0].Amount
+	}
+	return bbo
+}
@@ -0,0 +1,217 @@
+package orderbook
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/pubsub"
+)
+
+// ============================================================================
+// Сводная книга пары по всем биржам
+// ============================================================================
+
+// Fees - комиссия тейкера по биржам в долях (0.001 = 0.1%), key = exchange ID
+// Биржа без записи считается без комиссии
+type Fees map[string]decimal.Decimal
+
+// bpsScale - базисных пунктов в единице
+var bpsScale = decimal.NewFromInt(10000)
+
+// VenueLevel - уровень сводной книги с биржей-источником
+type VenueLevel struct {
+	ExchangeID string
+	MarketType string
+	Price      decimal.Decimal
+	Amount     decimal.Decimal
+	// EffectivePrice - цена с учетом комиссии тейкера биржи:
+	// продажа в bid получает Price * (1 - fee), покупка из ask платит Price * (1 + fee)
+	EffectivePrice decimal.Decimal
+}
+
+// VenueQuote - лучшие цены одной биржи в сводной книге
+type VenueQuote struct {
+	ExchangeID string
+	MarketType string
+	// Timestamp - время книги биржи (Unix μs), по нему вызывающий отсекает устаревшие
+	Timestamp int64
+	// Fee - примененная комиссия тейкера
+	Fee decimal.Decimal
+	// BestBid и BestAsk - нулевые, если сторона книги пуста
+	BestBid VenueLevel
+	BestAsk VenueLevel
+}
+
+// CrossSpread - лучшая межбиржевая связка: купить по ask одной биржи и продать в bid другой
+type CrossSpread struct {
+	Buy  VenueLevel
+	Sell VenueLevel
+	// Spread - Sell.EffectivePrice - Buy.EffectivePrice, > 0 - связка прибыльна после комиссий
+	Spread decimal.Decimal
+	// SpreadBps - Spread в базисных пунктах от Buy.EffectivePrice
+	SpreadBps decimal.Decimal
+	// Amount - объем, доступный на обоих лучших уровнях
+	Amount decimal.Decimal
+}
+
+// ConsolidatedBook - книги пары со всех бирж, объединенные в одну
+type ConsolidatedBook struct {
+	Pair string
+	// MarketType - рынок книг, pubsub.Wildcard - все рынки
+	MarketType string
+
+	// Bids и Asks - уровни всех бирж, отсортированные по EffectivePrice
+	// (bids по убыванию, asks по возрастанию), при равенстве - по цене и бирже
+	Bids []VenueLevel
+	Asks []VenueLevel
+
+	// Venues - лучшие цены каждой биржи, отсортированные по бирже и рынку
+	Venues []VenueQuote
+
+	// Spread - лучшая связка между разными биржами, nil если у пары меньше двух бирж
+	// с непустыми сторонами
+	Spread *CrossSpread
+}
+
+// ConsolidatedBook собирает сводную книгу пары со всех бирж
+// marketType = pubsub.Wildcard объединяет рынки (спот одной биржи с фьючерсами другой)
+// depth ограничивает число уровней каждой стороны сводной книги, depth <= 0 = без ограничения
+// Участвуют валидные книги и пары, подписанные только на лучшие цены (exchange.DepthBBO)
+// nil если пары нет ни на одной бирже
+func (m *Manager) ConsolidatedBook(pair, marketType string, fees Fees, depth int) *ConsolidatedBook {
+	consolidated := &ConsolidatedBook{Pair: pair, MarketType: marketType}
+
+	m.mu.RLock()
+	for _, state := range m.books {
+		book := &state.book
+		if book.Pair != pair || (marketType != pubsub.Wildcard && book.MarketType != marketType) {
+			continue
+		}
+
+		bids, asks, ok := venueLevels(state)
+		if !ok {
+			continue
+		}
+		fee := fees[book.ExchangeID]
+		quote := VenueQuote{
+			ExchangeID: book.ExchangeID,
+			MarketType: book.MarketType,
+			Timestamp:  book.Timestamp,
+			Fee:        fee,
+		}
+		for i, level := range bids {
+			venue := venueLevel(book, level, decimal.NewFromInt(1).Sub(fee))
+			if i == 0 {
+				quote.BestBid = venue
+			}
+			consolidated.Bids = append(consolidated.Bids, venue)
+		}
+		for i, level := range asks {
+			venue := venueLevel(book, level, decimal.NewFromInt(1).Add(fee))
+			if i == 0 {
+				quote.BestAsk = venue
+			}
+			consolidated.Asks = append(consolidated.Asks, venue)
+		}
+		consolidated.Venues = append(consolidated.Venues, quote)
+	}
+	m.mu.RUnlock()
+
+	if len(consolidated.Venues) == 0 {
+		return nil
+	}
+
+	sortVenueLevels(consolidated.Bids, true)
+	sortVenueLevels(consolidated.Asks, false)
+	if depth > 0 {
+		consolidated.Bids = consolidated.Bids[:min(depth, len(consolidated.Bids))]
+		consolidated.Asks = consolidated.Asks[:min(depth, len(consolidated.Asks))]
+	}
+	sort.Slice(consolidated.Venues, func(i, j int) bool {
+		a, b := consolidated.Venues[i], consolidated.Venues[j]
+		if a.ExchangeID != b.ExchangeID {
+			return a.ExchangeID < b.ExchangeID
+		}
+		return a.MarketType < b.MarketType
+	})
+	consolidated.Spread = bestCrossSpread(consolidated.Venues)
+	return consolidated
+}
+
+// venueLevels возвращает стороны книги пары: уровни валидной книги
+// или лучшие цены потока BBO, если книги у пары нет
+// Вызывается под m.mu
+func venueLevels(state *bookState) ([]exchange.Level, []exchange.Level, bool) {
+	if state.status == statusSynced {
+		return truncate(state.book.Bids, state.book.Depth), truncate(state.book.Asks, state.book.Depth), true
+	}
+	if !state.hasBBO {
+		return nil, nil, false
+	}
+
+	var bids, asks []exchange.Level
+	if state.bbo.BidAmount.IsPositive() {
+		bids = []exchange.Level{{Price: state.bbo.BidPrice, Amount: state.bbo.BidAmount}}
+	}
+	if state.bbo.AskAmount.IsPositive() {
+		asks = []exchange.Level{{Price: state.bbo.AskPrice, Amount: state.bbo.AskAmount}}
+	}
+	return bids, asks, true
+}
+
+func venueLevel(book *exchange.OrderBook, level exchange.Level, feeFactor decimal.Decimal) VenueLevel {
+	return VenueLevel{
+		ExchangeID:     book.ExchangeID,
+		MarketType:     book.MarketType,
+		Price:          level.Price,
+		Amount:         level.Amount,
+		EffectivePrice: level.Price.Mul(feeFactor),
+	}
+}
+
+// sortVenueLevels сортирует сторону сводной книги от лучшей цены с учетом комиссии
+func sortVenueLevels(levels []VenueLevel, bids bool) {
+	sort.SliceStable(levels, func(i, j int) bool {
+		a, b := levels[i], levels[j]
+		if !a.EffectivePrice.Equal(b.EffectivePrice) {
+			return better(a.EffectivePrice, b.EffectivePrice, bids)
+		}
+		if !a.Price.Equal(b.Price) {
+			return better(a.Price, b.Price, bids)
+		}
+		if a.ExchangeID != b.ExchangeID {
+			return a.ExchangeID < b.ExchangeID
+		}
+		return a.MarketType < b.MarketType
+	})
+}
+
+// bestCrossSpread выбирает связку покупки и продажи на разных биржах
+// с наибольшей разницей цен после комиссий
+func bestCrossSpread(venues []VenueQuote) *CrossSpread {
+	var best *CrossSpread
+	for _, buy := range venues {
+		if !buy.BestAsk.Amount.IsPositive() || !buy.BestAsk.EffectivePrice.IsPositive() {
+			continue
+		}
+		for _, sell := range venues {
+			if sell.ExchangeID == buy.ExchangeID || !sell.BestBid.Amount.IsPositive() {
+				continue
+			}
+			spread := sell.BestBid.EffectivePrice.Sub(buy.BestAsk.EffectivePrice)
+			if best != nil && !spread.GreaterThan(best.Spread) {
+				continue
+			}
+			best = &CrossSpread{
+				Buy:       buy.BestAsk,
+				Sell:      sell.BestBid,
+				Spread:    spread,
+				SpreadBps: spread.Div(buy.BestAsk.EffectivePrice).Mul(bpsScale),
+				Amount:    decimal.Min(buy.BestAsk.Amount, sell.BestBid.Amount),
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,272 @@
+package orderbook
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+	"trader/internal/core/pubsub"
+)
+
+func d(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+// levels собирает уровни сообщения из пар "цена", "объем"
+func levels(values ...string) []messaging.Level {
+	result := make([]messaging.Level, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		result = append(result, messaging.Level{Price: d(values[i]), Amount: d(values[i+1])})
+	}
+	return result
+}
+
+// applySnapshots применяет снимки книги BTC/USDT, key = "exchange:market"
+func applySnapshots(t *testing.T, m *Manager, books map[string][2][]messaging.Level) {
+	t.Helper()
+	for venue, sides := range books {
+		exchangeID, marketType, _ := strings.Cut(venue, ":")
+		err := m.UpdateOrderBook(&messaging.Message{
+			ExchangeID: exchangeID,
+			MarketType: marketType,
+			Type:       messaging.TypeOrderBook,
+			Pair:       "BTC/USDT",
+			SeqNum:     1,
+			OrderBook:  &messaging.OrderBookData{Bids: sides[0], Asks: sides[1], Snapshot: true},
+		})
+		if err != nil {
+			t.Fatalf("snapshot %s: %v", venue, err)
+		}
+	}
+}
+
+// ladder сводит сторону сводной книги к строкам "exchange price effective"
+func ladder(side []VenueLevel) []string {
+	result := make([]string, len(side))
+	for i, level := range side {
+		result[i] = fmt.Sprintf("%s %s %s", level.ExchangeID, level.Price, level.EffectivePrice)
+	}
+	return result
+}
+
+func expectLadder(t *testing.T, name string, side []VenueLevel, want ...string) {
+	t.Helper()
+	if got := ladder(side); strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+func TestConsolidatedBookMergesLadderWithFees(t *testing.T) {
+	m := NewManager(newResubscribeSource(0))
+	defer m.Close()
+
+	applySnapshots(t, m, map[string][2][]messaging.Level{
+		"binance:spot": {levels("100", "1", "99", "2"), levels("101", "1", "102", "1")},
+		"okx:spot":     {levels("100.5", "0.5", "98", "1"), levels("101.5", "2")},
+	})
+	// bybit подписана только на лучшие цены: книги нет, участвует BBO
+	if err := m.UpdateOrderBook(&messaging.Message{
+		ExchangeID: "bybit", MarketType: exchange.MarketSpot, Type: messaging.TypeBBO, Pair: "BTC/USDT",
+		BBO: &messaging.BBOData{BidPrice: d("99.5"), BidAmount: d("3"), AskPrice: d("100.8"), AskAmount: d("1")},
+	}); err != nil {
+		t.Fatalf("bbo: %v", err)
+	}
+
+	// okx нет в комиссиях - без комиссии
+	fees := Fees{"binance": d("0.001"), "bybit": d("0.002")}
+	book := m.ConsolidatedBook("BTC/USDT", exchange.MarketSpot, fees, 0)
+	if book == nil {
+		t.Fatal("ConsolidatedBook = nil")
+	}
+
+	expectLadder(t, "bids", book.Bids,
+		"okx 100.5 100.5", "binance 100 99.9", "bybit 99.5 99.301", "binance 99 98.901", "okx 98 98")
+	// Без комиссии лучший ask был бы у binance (101), с комиссией - у bybit
+	expectLadder(t, "asks", book.Asks,
+		"bybit 100.8 101.0016", "binance 101 101.101", "okx 101.5 101.5", "binance 102 102.102")
+
+	var venues []string
+	for _, venue := range book.Venues {
+		venues = append(venues, fmt.Sprintf("%s %s/%s fee %s", venue.ExchangeID, venue.BestBid.Price, venue.BestAsk.Price, venue.Fee))
+	}
+	if got, want := strings.Join(venues, ", "), "binance 100/101 fee 0.001, bybit 99.5/100.8 fee 0.002, okx 100.5/101.5 fee 0"; got != want {
+		t.Errorf("venues = %s, want %s", got, want)
+	}
+
+	// Прибыльной связки нет: лучшая - наименее убыточная, купить на bybit и продать на okx
+	spread := book.Spread
+	if spread == nil {
+		t.Fatal("Spread = nil")
+	}
+	if spread.Buy.ExchangeID != "bybit" || spread.Sell.ExchangeID != "okx" ||
+		!spread.Spread.Equal(d("-0.5016")) || !spread.Amount.Equal(d("0.5")) {
+		t.Errorf("spread = buy %s sell %s %s amount %s, want buy bybit sell okx -0.5016 amount 0.5",
+			spread.Buy.ExchangeID, spread.Sell.ExchangeID, spread.Spread, spread.Amount)
+	}
+	if want := d("-0.5016").Div(d("101.0016")).Mul(d("10000")); !spread.SpreadBps.Equal(want) {
+		t.Errorf("SpreadBps = %s, want %s", spread.SpreadBps, want)
+	}
+
+	// Глубина ограничивает каждую сторону сводной книги, а не книги бирж
+	limited := m.ConsolidatedBook("BTC/USDT", exchange.MarketSpot, fees, 2)
+	expectLadder(t, "limited bids", limited.Bids, "okx 100.5 100.5", "binance 100 99.9")
+	expectLadder(t, "limited asks", limited.Asks, "bybit 100.8 101.0016", "binance 101 101.101")
+	if len(limited.Venues) != 3 {
+		t.Errorf("limited venues = %d, want 3", len(limited.Venues))
+	}
+}
+
+func TestConsolidatedBookCrossedVenues(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		books map[string][2][]messaging.Level
+		fees  Fees
+		// spread - "buy sell spread amount", "" - связки нет
+		spread string
+	}{
+		{
+			name: "crossed without fees",
+			books: map[string][2][]messaging.Level{
+				"binance:spot": {levels("101", "1"), levels("102", "1.5")},
+				"okx:spot":     {levels("103", "2"), levels("104", "1")},
+			},
+			spread: "binance okx 1 1.5",
+		},
+		{
+			name: "fees shrink the spread",
+			books: map[string][2][]messaging.Level{
+				"binance:spot": {levels("101", "1"), levels("102", "1.5")},
+				"okx:spot":     {levels("103", "2"), levels("104", "1")},
+			},
+			fees:   Fees{"binance": d("0.001"), "okx": d("0.001")},
+			spread: "binance okx 0.795 1.5",
+		},
+		{
+			name: "fees close the spread",
+			books: map[string][2][]messaging.Level{
+				"binance:spot": {levels("101", "1"), levels("102", "1.5")},
+				"okx:spot":     {levels("103", "2"), levels("104", "1")},
+			},
+			fees:   Fees{"binance": d("0.01"), "okx": d("0.01")},
+			spread: "binance okx -1.05 1.5",
+		},
+		{
+			name: "best of three venues",
+			books: map[string][2][]messaging.Level{
+				"binance:spot": {levels("100", "1"), levels("101", "1")},
+				"okx:spot":     {levels("102", "0.2"), levels("103", "1")},
+				"bybit:spot":   {levels("101.5", "4"), levels("99.5", "0.3")},
+			},
+			spread: "bybit okx 2.5 0.2",
+		},
+		{
+			// Книга, скрещенная на одной бирже, не дает связку сама с собой
+			name: "single venue crossed book",
+			books: map[string][2][]messaging.Level{
+				"binance:spot": {levels("105", "1"), levels("101", "1")},
+			},
+		},
+		{
+			name: "other venue without asks",
+			books: map[string][2][]messaging.Level{
+				"binance:spot": {levels("100", "1"), nil},
+				"okx:spot":     {levels("99", "1"), levels("101", "1")},
+			},
+			spread: "okx binance -1 1",
+		},
+		{
+			name: "no asks anywhere",
+			books: map[string][2][]messaging.Level{
+				"binance:spot": {levels("100", "1"), nil},
+				"okx:spot":     {levels("99", "1"), nil},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewManager(newResubscribeSource(0))
+			defer m.Close()
+			applySnapshots(t, m, tc.books)
+
+			book := m.ConsolidatedBook("BTC/USDT", exchange.MarketSpot, tc.fees, 0)
+			if book == nil {
+				t.Fatal("ConsolidatedBook = nil")
+			}
+			got := ""
+			if book.Spread != nil {
+				got = fmt.Sprintf("%s %s %s %s", book.Spread.Buy.ExchangeID, book.Spread.Sell.ExchangeID, book.Spread.Spread, book.Spread.Amount)
+			}
+			if got != tc.spread {
+				t.Errorf("spread = %q, want %q", got, tc.spread)
+			}
+		})
+	}
+}
+
+func TestConsolidatedBookMissingVenues(t *testing.T) {
+	m := NewManager(newResubscribeSource(0))
+	defer m.Close()
+
+	if book := m.ConsolidatedBook("BTC/USDT", exchange.MarketSpot, nil, 0); book != nil {
+		t.Fatalf("ConsolidatedBook without books = %+v, want nil", book)
+	}
+
+	applySnapshots(t, m, map[string][2][]messaging.Level{
+		"binance:spot":    {levels("100", "1"), levels("101", "1")},
+		"okx:spot":        {levels("100.5", "1"), levels("101.5", "1")},
+		"okx:futures":     {levels("102", "1"), levels("103", "1")},
+		"bybit:spot":      {levels("99", "1"), levels("100", "1")},
+		"kucoin:spot":     {levels("98", "1"), levels("99", "1")},
+		"binance:futures": {levels("97", "1"), levels("98", "1")},
+	})
+
+	// Сброшенная книга (обрыв соединения) не участвует до нового снимка
+	if err := m.UpdateOrderBook(&messaging.Message{
+		ExchangeID: "bybit", MarketType: exchange.MarketSpot, Type: messaging.TypeBookInvalidated, Pair: "BTC/USDT",
+	}); err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+	// Книга, ждущая снимка после разрыва последовательности, тоже
+	for _, seq := range []int64{2, 5} {
+		if err := m.UpdateOrderBook(&messaging.Message{
+			ExchangeID: "kucoin", MarketType: exchange.MarketSpot, Type: messaging.TypeOrderBook, Pair: "BTC/USDT",
+			SeqNum: seq, OrderBook: &messaging.OrderBookData{Bids: levels("98.5", "1"), PrevSeqNum: seq - 1},
+		}); err != nil {
+			t.Fatalf("delta %d: %v", seq, err)
+		}
+	}
+
+	spot := m.ConsolidatedBook("BTC/USDT", exchange.MarketSpot, nil, 0)
+	if got := venueIDs(spot); got != "binance:spot okx:spot" {
+		t.Fatalf("spot venues = %s, want binance:spot okx:spot", got)
+	}
+	// Обе биржи спота: лучшая связка - купить на binance 101, продать на okx 100.5
+	if spot.Spread == nil || spot.Spread.Buy.ExchangeID != "binance" || !spot.Spread.Spread.Equal(d("-0.5")) {
+		t.Errorf("spot spread = %+v", spot.Spread)
+	}
+
+	// Все рынки: спот и фьючерсы в одной книге, лучшая связка - между фьючерсами бирж
+	all := m.ConsolidatedBook("BTC/USDT", pubsub.Wildcard, nil, 0)
+	if got := venueIDs(all); got != "binance:futures binance:spot okx:futures okx:spot" {
+		t.Fatalf("all venues = %s", got)
+	}
+	if s := all.Spread; s == nil || s.Buy.ExchangeID != "binance" || s.Buy.MarketType != exchange.MarketFutures ||
+		s.Sell.ExchangeID != "okx" || s.Sell.MarketType != exchange.MarketFutures || !s.Spread.Equal(d("4")) {
+		t.Errorf("all markets spread = %+v, want buy binance futures 98, sell okx futures 102", s)
+	}
+
+	if book := m.ConsolidatedBook("ETH/USDT", pubsub.Wildcard, nil, 0); book != nil {
+		t.Errorf("ConsolidatedBook of unknown pair = %+v, want nil", book)
+	}
+}
+
+func venueIDs(book *ConsolidatedBook) string {
+	var ids []string
+	for _, venue := range book.Venues {
+		ids = append(ids, venue.ExchangeID+":"+venue.MarketType)
+	}
+	return strings.Join(ids, " ")
+}
@@ -0,0 +1,217 @@
+package orderbook
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+	"trader/internal/core/pubsub"
+)
+
+// ============================================================================
+// История последних книг и сделок пар в памяти
+// ============================================================================
+
+// historyQueueSize - очередь подписчика истории: каждое изменение книги должно попасть в буфер
+const historyQueueSize = 16384
+
+var historySeq atomic.Uint64
+
+// pairHistory - буферы одной пары
+type pairHistory struct {
+	exchangeID string
+	marketType string
+	pair       string
+
+	books  *Ring[*messaging.Message]
+	trades *Ring[*messaging.Message]
+}
+
+// History хранит в кольцевых буферах последние снимки книг и сделки отслеживаемых пар,
+// чтобы стратегии и admin API читали последние минуты без запроса в ClickHouse
+// Подписывается на Manager только по отслеживаемым парам. Сообщения пишет одна горутина
+// очереди подписчика, чтение блокирует только буфер своей пары
+// Безопасен для конкурентного использования
+type History struct {
+	manager         *Manager
+	defaultCapacity int
+	id              string
+
+	mu    sync.RWMutex
+	pairs map[string]*pairHistory // key = exchange.GetOrderBookKey()
+}
+
+// NewHistory создает историю поверх менеджера книг
+// defaultCapacity - емкость буферов пары, если задача ее не задает (MonitorConfig.RingBufferSize)
+func NewHistory(manager *Manager, defaultCapacity int) *History {
+	return &History{
+		manager:         manager,
+		defaultCapacity: max(defaultCapacity, 1),
+		id:              fmt.Sprintf("orderbook-history-%d", historySeq.Add(1)),
+		pairs:           make(map[string]*pairHistory),
+	}
+}
+
+// Sync приводит отслеживаемые пары к задачам мониторинга
+// Емкость буферов пары - RingBufferSize задачи (для задач разных пользователей - наибольшая)
+func (h *History) Sync(tasks []*exchange.MonitoringTask) error {
+	wanted := make(map[string]*exchange.MonitoringTask, len(tasks))
+	capacity := make(map[string]int, len(tasks))
+	for _, task := range tasks {
+		key := exchange.GetOrderBookKey(task.ExchangeID, task.TradePair, task.MarketType)
+		wanted[key] = task
+		capacity[key] = max(capacity[key], task.RingBufferSize)
+	}
+
+	h.mu.RLock()
+	var stale []*pairHistory
+	for key, ph := range h.pairs {
+		if _, ok := wanted[key]; !ok {
+			stale = append(stale, ph)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, ph := range stale {
+		h.Untrack(ph.exchangeID, ph.pair, ph.marketType)
+	}
+	for key, task := range wanted {
+		if err := h.Track(task.ExchangeID, task.TradePair, task.MarketType, capacity[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Track начинает запись пары в буферы емкостью capacity (<= 0 - емкость по умолчанию)
+// Для уже отслеживаемой пары меняет емкость, сохраняя последние записи
+func (h *History) Track(exchangeID, pair, marketType string, capacity int) error {
+	if capacity <= 0 {
+		capacity = h.defaultCapacity
+	}
+	key := exchange.GetOrderBookKey(exchangeID, pair, marketType)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if ph, ok := h.pairs[key]; ok {
+		// Буферы меняются на месте: OnMessage мог уже получить ph и пишет в те же Ring
+		ph.books.Resize(capacity)
+		ph.trades.Resize(capacity)
+		return nil
+	}
+
+	h.pairs[key] = &pairHistory{
+		exchangeID: exchangeID,
+		marketType: marketType,
+		pair:       pair,
+		books:      NewRing[*messaging.Message](capacity),
+		trades:     NewRing[*messaging.Message](capacity),
+	}
+	if err := h.manager.Subscribe(h, exchangeID, pair, marketType); err != nil {
+		delete(h.pairs, key)
+		return fmt.Errorf("track %s history: %w", key, err)
+	}
+	return nil
+}
+
+// Untrack прекращает запись пары и освобождает ее буферы
+func (h *History) Untrack(exchangeID, pair, marketType string) {
+	key := exchange.GetOrderBookKey(exchangeID, pair, marketType)
+
+	h.mu.Lock()
+	_, ok := h.pairs[key]
+	delete(h.pairs, key)
+	h.mu.Unlock()
+
+	if ok {
+		h.manager.Unsubscribe(h, exchangeID, pair, marketType)
+	}
+}
+
+// Close прекращает запись всех пар
+func (h *History) Close() {
+	h.manager.router.UnsubscribeAll(h)
+
+	h.mu.Lock()
+	h.pairs = make(map[string]*pairHistory)
+	h.mu.Unlock()
+}
+
+// Books возвращает снимки книги пары (TypeOrderBook) за [from, to] от старых к новым
+// Время - время книги по часам биржи
+func (h *History) Books(exchangeID, pair, marketType string, from, to time.Time) []*messaging.Message {
+	if ph := h.pair(exchangeID, pair, marketType); ph != nil {
+		return ph.books.Range(from.UnixMicro(), to.UnixMicro())
+	}
+	return nil
+}
+
+// Trades возвращает сделки пары (TypeTrade) за [from, to] от старых к новым
+func (h *History) Trades(exchangeID, pair, marketType string, from, to time.Time) []*messaging.Message {
+	if ph := h.pair(exchangeID, pair, marketType); ph != nil {
+		return ph.trades.Range(from.UnixMicro(), to.UnixMicro())
+	}
+	return nil
+}
+
+// LastBooks возвращает до n последних снимков книги пары, n <= 0 - все
+func (h *History) LastBooks(exchangeID, pair, marketType string, n int) []*messaging.Message {
+	if ph := h.pair(exchangeID, pair, marketType); ph != nil {
+		return ph.books.Last(n)
+	}
+	return nil
+}
+
+// LastTrades возвращает до n последних сделок пары, n <= 0 - все
+func (h *History) LastTrades(exchangeID, pair, marketType string, n int) []*messaging.Message {
+	if ph := h.pair(exchangeID, pair, marketType); ph != nil {
+		return ph.trades.Last(n)
+	}
+	return nil
+}
+
+// OnMessage - pubsub.Subscriber, записывает снимок книги или сделку в буфер пары
+// Сообщения общие для всех подписчиков и не изменяются после публикации
+func (h *History) OnMessage(msg *messaging.Message) {
+	ph := h.pair(msg.ExchangeID, msg.Pair, msg.MarketType)
+	if ph == nil {
+		return
+	}
+
+	at := msg.Timestamp
+	if at == 0 {
+		at = msg.ReceivedAt
+	}
+	switch msg.Type {
+	case messaging.TypeOrderBook:
+		ph.books.Push(at, msg)
+	case messaging.TypeTrade:
+		ph.trades.Push(at, msg)
+	}
+}
+
+// OnError - pubsub.Subscriber, разрывы книги не влияют на уже записанную историю
+func (h *History) OnError(error) {}
+
+// GetID - pubsub.Subscriber
+func (h *History) GetID() string { return h.id }
+
+// MessageTypes - pubsub.TypeFilter: только снимки книг и сделки
+func (h *History) MessageTypes() []string {
+	return []string{messaging.TypeOrderBook, messaging.TypeTrade}
+}
+
+// QueueOptions - pubsub.QueueConfigurer: большая очередь, история не должна схлопывать книги
+func (h *History) QueueOptions() pubsub.QueueOptions {
+	return pubsub.QueueOptions{Size: historyQueueSize, Overflow: pubsub.DropOldest}
+}
+
+func (h *History) pair(exchangeID, pair, marketType string) *pairHistory {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.pairs[exchange.GetOrderBookKey(exchangeID, pair, marketType)]
+}
@@ -0,0 +1,57 @@
+package orderbook
+
+import (
+	"testing"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+)
+
+func TestRingResizeKeepsLatest(t *testing.T) {
+	ring := NewRing[int](4)
+	for i := 1; i <= 6; i++ {
+		ring.Push(int64(i), i)
+	}
+
+	ring.Resize(2)
+	if got := ring.Last(0); len(got) != 2 || got[0] != 5 || got[1] != 6 {
+		t.Fatalf("after shrink Last = %v, want [5 6]", got)
+	}
+
+	ring.Resize(3)
+	ring.Push(7, 7)
+	ring.Push(8, 8)
+	if got := ring.Last(0); len(got) != 3 || got[0] != 6 || got[2] != 8 {
+		t.Fatalf("after grow Last = %v, want [6 7 8]", got)
+	}
+	if got := ring.Range(7, 8); len(got) != 2 {
+		t.Errorf("Range(7, 8) = %v", got)
+	}
+}
+
+func TestTrackResizesBuffersInPlace(t *testing.T) {
+	m := NewManager(newResubscribeSource(0))
+	defer m.Close()
+	history := NewHistory(m, 4)
+	defer history.Close()
+
+	if err := history.Track("stream", "BTC/USDT", exchange.MarketSpot, 4); err != nil {
+		t.Fatalf("Track: %v", err)
+	}
+	// OnMessage, получивший буферы пары до смены емкости, пишет в них же
+	tracked := history.pair("stream", "BTC/USDT", exchange.MarketSpot)
+	if err := history.Track("stream", "BTC/USDT", exchange.MarketSpot, 8); err != nil {
+		t.Fatalf("Track resize: %v", err)
+	}
+	if history.pair("stream", "BTC/USDT", exchange.MarketSpot) != tracked {
+		t.Fatal("Track replaced pair buffers on resize")
+	}
+
+	tracked.trades.Push(1, &messaging.Message{Type: messaging.TypeTrade, Pair: "BTC/USDT"})
+	if got := history.LastTrades("stream", "BTC/USDT", exchange.MarketSpot, 0); len(got) != 1 {
+		t.Fatalf("LastTrades = %d messages, want 1", len(got))
+	}
+	if got := tracked.books.Cap(); got != 8 {
+		t.Errorf("books capacity = %d, want 8", got)
+	}
+}
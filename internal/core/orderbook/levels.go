@@ -0,0 +1,76 @@
+package orderbook
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+)
+
+// toLevels копирует уровни сообщения в уровни книги, пропуская удаленные (Amount = 0)
+// и сортируя по стороне: bids по убыванию цены, asks по возрастанию
+func toLevels(levels []messaging.Level, bids bool) []exchange.Level {
+	result := make([]exchange.Level, 0, len(levels))
+	for _, level := range levels {
+		if level.Amount.IsZero() {
+			continue
+		}
+		result = append(result, exchange.Level{Price: level.Price, Amount: level.Amount})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return better(result[i].Price, result[j].Price, bids)
+	})
+	return result
+}
+
+// fromLevels копирует уровни книги в уровни сообщения
+func fromLevels(levels []exchange.Level) []messaging.Level {
+	result := make([]messaging.Level, len(levels))
+	for i, level := range levels {
+		result[i] = messaging.Level{Price: level.Price, Amount: level.Amount}
+	}
+	return result
+}
+
+// mergeLevels применяет дельту к отсортированной стороне книги
+// Amount = 0 удаляет уровень, иначе уровень добавляется или заменяется
+func mergeLevels(side []exchange.Level, delta []messaging.Level, bids bool) []exchange.Level {
+	for _, level := range delta {
+		i := sort.Search(len(side), func(i int) bool {
+			return !better(side[i].Price, level.Price, bids)
+		})
+		found := i < len(side) && side[i].Price.Equal(level.Price)
+
+		switch {
+		case level.Amount.IsZero():
+			if found {
+				side = append(side[:i], side[i+1:]...)
+			}
+		case found:
+			side[i].Amount = level.Amount
+		default:
+			side = append(side, exchange.Level{})
+			copy(side[i+1:], side[i:])
+			side[i] = exchange.Level{Price: level.Price, Amount: level.Amount}
+		}
+	}
+	return side
+}
+
+// better сообщает стоит ли цена a выше цены b на своей стороне книги
+func better(a, b decimal.Decimal, bids bool) bool {
+	if bids {
+		return a.GreaterThan(b)
+	}
+	return a.LessThan(b)
+}
+
+// truncate обрезает сторону книги до depth лучших уровней, depth <= 0 = без ограничения
+func truncate(side []exchange.Level, depth int) []exchange.Level {
+	if depth > 0 && len(side) > depth {
+		return side[:depth]
+	}
+	return side
+}
@@ -0,0 +1,635 @@
+// Package orderbook ведет локальные книги ордеров по потоку сообщений WS пула
+//
+// Книга строится из снимка (Snapshot = true) и дальше обновляется дельтами.
+// Порядок дельт проверяется по SeqNum: правила нумерации знает драйвер биржи
+// (exchange.SequenceAligner), для остальных бирж дельта не должна идти назад.
+// При разрыве последовательности книга помечается невалидной и восстанавливается:
+// по REST снимку (exchange.SnapshotProvider), иначе переподпиской пары в WS пуле.
+//
+// Каждое изменение книги публикуется подписчикам (pubsub.Router) как снимок
+// текущего состояния книги, сделки передаются как есть. Изменение лучших bid/ask
+// дополнительно публикуется сообщением TypeBBO, так же как и BBO из потока лучших цен биржи.
+//
+// ConsolidatedBook объединяет книги одной пары со всех бирж для арбитража.
+// History хранит последние снимки книг и сделки пар мониторинга в кольцевых буферах.
+package orderbook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+	"trader/internal/core/pubsub"
+	"trader/internal/logger"
+)
+
+const (
+	// maxPendingDeltas - сколько дельт буферизуется на время загрузки снимка
+	// При переполнении отбрасываются самые старые: их покроет снимок или следующая загрузка
+	maxPendingDeltas = 10000
+	// maxResyncAttempts - попыток загрузки снимка (или переподписки) подряд,
+	// после чего книга ждет следующей дельты
+	maxResyncAttempts = 5
+	// resyncTimeout - таймаут одного запроса снимка
+	resyncTimeout = 10 * time.Second
+	// resyncRetryDelay - базовая пауза между попытками, растет линейно с номером попытки
+	resyncRetryDelay = 1 * time.Second
+)
+
+var (
+	// ErrInvalidMessage - сообщение книги без данных или без идентификации пары
+	ErrInvalidMessage = errors.New("invalid orderbook message")
+	// ErrSequenceGap - пропущены обновления книги, книга невалидна до восстановления
+	ErrSequenceGap = errors.New("orderbook sequence gap")
+	// ErrResyncFailed - книгу не удалось восстановить по REST снимку или переподпиской
+	ErrResyncFailed = errors.New("orderbook resync failed")
+)
+
+// Source - источник потока книг: драйверы бирж и переподписка пар
+// Реализуется ws.Pool
+type Source interface {
+	// Driver возвращает драйвер биржи для сверки последовательности и REST снимков
+	Driver(exchangeID string) (exchange.ExchangeDriver, error)
+	// Resubscribe переподписывает пару, чтобы биржа прислала новый снимок книги
+	Resubscribe(exchangeID, marketType, pair string) error
+}
+
+// syncStatus - состояние синхронизации книги
+type syncStatus int
+
+const (
+	// statusAwaitingSnapshot - снимка нет (старт, обрыв, разрыв последовательности)
+	statusAwaitingSnapshot syncStatus = iota
+	// statusResyncing - идет восстановление: дельты буферизуются до REST снимка
+	// или отбрасываются до нового WS снимка
+	statusResyncing
+	// statusSynced - книга валидна
+	statusSynced
+)
+
+// bookState - книга пары и состояние ее синхронизации
+type bookState struct {
+	// book хранит все уровни снимка и дельт, book.Depth применяется только при чтении:
+	// уровень, вытесненный за глубину, возвращается в книгу, когда освободится место
+	book   exchange.OrderBook
+	status syncStatus
+
+	// lastSeqNum - SeqNum последнего примененного снимка или дельты
+	lastSeqNum int64
+	// afterSnapshot - lastSeqNum взят из снимка, дельт после него еще не применялось
+	afterSnapshot bool
+
+	// pending - дельты, пришедшие во время загрузки REST снимка
+	pending []*messaging.Message
+	// generation увеличивается при каждом сбросе книги,
+	// результат загрузки снимка для старого поколения отбрасывается
+	generation uint64
+	// recoveryFailed - восстановление не удалось, следующая дельта запустит его заново
+	// (для бирж без REST снимка - переподпиской пары)
+	recoveryFailed bool
+
+	// bbo - последние опубликованные лучшие цены, hasBBO = false до первых после сброса
+	bbo    messaging.BBOData
+	hasBBO bool
+}
+
+// Manager ведет книги ордеров по ключу exchange.GetOrderBookKey
+// Безопасен для конкурентного использования
+type Manager struct {
+	mu     sync.RWMutex
+	books  map[string]*bookState // key = exchange.GetOrderBookKey()
+	source Source
+	router *pubsub.Router
+	log    *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewManager создает менеджер книг поверх источника потока
+func NewManager(source Source) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		books:  make(map[string]*bookState),
+		source: source,
+		router: pubsub.NewRouter(),
+		log:    logger.Get("orderbook"),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Close останавливает загрузку снимков и доставку подписчикам
+func (m *Manager) Close() {
+	m.cancel()
+	m.router.Close()
+}
+
+// Subscribe подписывает на обновления пары, любой аргумент может быть pubsub.Wildcard
+// Подписчик получает снимки книги после каждого изменения, TypeBBO при изменении
+// лучших цен, сделки и TypeBookInvalidated при сбросе книги; разрывы последовательности
+// приходят в OnError. Подписчик с pubsub.TypeFilter получает только свои типы сообщений
+func (m *Manager) Subscribe(subscriber pubsub.Subscriber, exchangeID, pair, marketType string) error {
+	return m.router.Subscribe(subscriber, pubsub.Key(exchangeID, marketType, pair))
+}
+
+// Unsubscribe снимает подписку, сделанную Subscribe с теми же аргументами
+func (m *Manager) Unsubscribe(subscriber pubsub.Subscriber, exchangeID, pair, marketType string) {
+	m.router.Unsubscribe(subscriber, pubsub.Key(exchangeID, marketType, pair))
+}
+
+// SubscriberStats возвращает состояние очередей подписчиков
+func (m *Manager) SubscriberStats() []pubsub.SubscriberStats {
+	return m.router.Stats()
+}
+
+// HandleMessage - обработчик для ws.Pool.AddHandler
+// Ошибки применения сообщений пишутся в лог
+func (m *Manager) HandleMessage(msg *messaging.Message) {
+	if err := m.UpdateOrderBook(msg); err != nil {
+		m.log.Warn("orderbook update failed", "error", err)
+	}
+}
+
+// UpdateOrderBook применяет сообщение пула к книге пары
+// TypeOrderBook - снимок или дельта, TypeBookInvalidated - сброс книги до нового снимка,
+// TypeBBO - лучшие цены из потока биржи (публикуются только при изменении),
+// остальные типы сообщений передаются подписчикам без изменений
+func (m *Manager) UpdateOrderBook(msg *messaging.Message) error {
+	if msg == nil {
+		return fmt.Errorf("%w: nil message", ErrInvalidMessage)
+	}
+
+	switch msg.Type {
+	case messaging.TypeBookInvalidated:
+		m.invalidate(msg)
+		m.router.Publish(msg)
+		return nil
+	case messaging.TypeBBO:
+		return m.updateBBO(msg)
+	case messaging.TypeOrderBook:
+	default:
+		m.router.Publish(msg)
+		return nil
+	}
+
+	if msg.OrderBook == nil || msg.ExchangeID == "" || msg.Pair == "" {
+		return fmt.Errorf("%w: %s %s %s", ErrInvalidMessage, msg.ExchangeID, msg.MarketType, msg.Pair)
+	}
+
+	driver, err := m.source.Driver(msg.ExchangeID)
+	if err != nil {
+		return err
+	}
+
+	// Публикация идет после снятия блокировки: подписчик с политикой Block
+	// не должен останавливать чтение книг
+	m.mu.Lock()
+	updates, gapErr := m.apply(msg, driver)
+	m.mu.Unlock()
+
+	if gapErr != nil {
+		m.router.PublishError(msg.ExchangeID, msg.MarketType, msg.Pair, gapErr)
+	}
+	for _, update := range updates {
+		m.router.Publish(update)
+	}
+	return nil
+}
+
+// apply применяет сообщение книги и возвращает сообщения для подписчиков
+// (пусто если книга не изменилась или подписчиков нет) и ошибку разрыва последовательности
+// Вызывается под m.mu
+func (m *Manager) apply(msg *messaging.Message, driver exchange.ExchangeDriver) ([]*messaging.Message, error) {
+	key := exchange.GetOrderBookKey(msg.ExchangeID, msg.Pair, msg.MarketType)
+
+	state := m.state(key, msg)
+
+	if msg.OrderBook.Snapshot {
+		// Снимок из потока заменяет книгу целиком и отменяет идущую загрузку REST снимка
+		if state.status == statusResyncing {
+			state.generation++
+		}
+		state.pending = nil
+		applySnapshot(state, msg)
+		return m.bookUpdates(state, msg), nil
+	}
+
+	switch state.status {
+	case statusAwaitingSnapshot:
+		if provider, ok := driver.(exchange.SnapshotProvider); ok {
+			state.pending = append(state.pending, msg)
+			m.startResync(state, driver, provider)
+		} else if state.recoveryFailed {
+			m.startResubscribe(state)
+		}
+		// Без REST снимка биржа пришлет снимок в потоке после (пере)подписки
+		return nil, nil
+
+	case statusResyncing:
+		if _, ok := driver.(exchange.SnapshotProvider); ok {
+			state.pending = appendPending(state.pending, msg)
+		}
+		return nil, nil
+	}
+
+	switch alignDelta(driver, state, msg) {
+	case exchange.SeqStale:
+		return nil, nil
+	case exchange.SeqGap:
+		lastSeqNum := state.lastSeqNum
+		m.handleGap(state, driver, msg)
+		return nil, fmt.Errorf("%w: %s last %d, got %d", ErrSequenceGap, key, lastSeqNum, msg.SeqNum)
+	}
+
+	applyDelta(state, msg)
+	return m.bookUpdates(state, msg), nil
+}
+
+// state возвращает (создает) состояние книги пары сообщения
+// Вызывается под m.mu
+func (m *Manager) state(key string, msg *messaging.Message) *bookState {
+	state, ok := m.books[key]
+	if !ok {
+		state = &bookState{book: exchange.OrderBook{
+			ExchangeID: msg.ExchangeID,
+			Pair:       msg.Pair,
+			MarketType: msg.MarketType,
+		}}
+		m.books[key] = state
+	}
+	return state
+}
+
+// bookUpdates собирает сообщения подписчикам после изменения книги:
+// снимок текущего состояния книги и TypeBBO, если изменились лучшие цены
+// Вызывается под m.mu
+func (m *Manager) bookUpdates(state *bookState, source *messaging.Message) []*messaging.Message {
+	var updates []*messaging.Message
+	if update := m.bookUpdate(state, source); update != nil {
+		updates = append(updates, update)
+	}
+	if update := m.bboUpdate(state, topOfBook(&state.book), source); update != nil {
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+// bookUpdate собирает снимок текущего состояния книги для подписчиков
+// Время получения и оценка часов берутся из сообщения, изменившего книгу
+// nil если на пару никто не подписан
+// Вызывается под m.mu
+func (m *Manager) bookUpdate(state *bookState, source *messaging.Message) *messaging.Message {
+	book := &state.book
+	if !m.router.HasSubscribers(book.ExchangeID, book.MarketType, book.Pair, messaging.TypeOrderBook) {
+		return nil
+	}
+	return &messaging.Message{
+		Timestamp:   book.Timestamp,
+		ReceivedAt:  source.ReceivedAt,
+		ClockOffset: source.ClockOffset,
+		ExchangeID:  book.ExchangeID,
+		MarketType:  book.MarketType,
+		Type:        messaging.TypeOrderBook,
+		Pair:        book.Pair,
+		SeqNum:      book.SeqNum,
+		OrderBook: &messaging.OrderBookData{
+			Bids:     fromLevels(truncate(book.Bids, book.Depth)),
+			Asks:     fromLevels(truncate(book.Asks, book.Depth)),
+			Depth:    book.Depth,
+			Snapshot: true,
+		},
+	}
+}
+
+// BookFromMessage собирает книгу из снимка, который менеджер публикует подписчикам
+// nil если сообщение не снимок книги (дельта из потока биржи, BBO, сделка)
+func BookFromMessage(msg *messaging.Message) *exchange.OrderBook {
+	if msg == nil || msg.Type != messaging.TypeOrderBook || msg.OrderBook == nil || !msg.OrderBook.Snapshot {
+		return nil
+	}
+	return &exchange.OrderBook{
+		ExchangeID: msg.ExchangeID,
+		Pair:       msg.Pair,
+		MarketType: msg.MarketType,
+		Bids:       toLevels(msg.OrderBook.Bids, true),
+		Asks:       toLevels(msg.OrderBook.Asks, false),
+		Depth:      msg.OrderBook.Depth,
+		Timestamp:  msg.Timestamp,
+		SeqNum:     msg.SeqNum,
+	}
+}
+
+// GetOrderBook возвращает копию книги пары, не глубже Depth подписки
+// nil если книги нет или она сейчас невалидна (ждет снимка)
+func (m *Manager) GetOrderBook(exchangeID, pair, marketType string) *exchange.OrderBook {
+	key := exchange.GetOrderBookKey(exchangeID, pair, marketType)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, ok := m.books[key]
+	if !ok || state.status != statusSynced {
+		return nil
+	}
+
+	book := state.book
+	book.Bids = append([]exchange.Level(nil), truncate(state.book.Bids, book.Depth)...)
+	book.Asks = append([]exchange.Level(nil), truncate(state.book.Asks, book.Depth)...)
+	return &book
+}
+
+// invalidate сбрасывает книгу по сигналу пула (обрыв соединения, переподписка)
+func (m *Manager) invalidate(msg *messaging.Message) {
+	key := exchange.GetOrderBookKey(msg.ExchangeID, msg.Pair, msg.MarketType)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if state, ok := m.books[key]; ok {
+		reset(state)
+	}
+}
+
+// handleGap реагирует на разрыв последовательности
+// С REST снимком дельта буферизуется и запускается загрузка снимка,
+// иначе пара переподписывается и книга ждет снимка из потока
+// Вызывается под m.mu
+func (m *Manager) handleGap(state *bookState, driver exchange.ExchangeDriver, msg *messaging.Message) {
+	book := &state.book
+	logFields := []any{
+		"exchange_id", book.ExchangeID,
+		"market_type", book.MarketType,
+		"pair", book.Pair,
+		"last_seq_num", state.lastSeqNum,
+		"seq_num", msg.SeqNum,
+		"first_seq_num", msg.OrderBook.FirstSeqNum,
+		"prev_seq_num", msg.OrderBook.PrevSeqNum,
+	}
+
+	reset(state)
+
+	if provider, ok := driver.(exchange.SnapshotProvider); ok {
+		m.log.Warn("orderbook sequence gap", append(logFields, "action", "snapshot")...)
+		state.pending = append(state.pending, msg)
+		m.startResync(state, driver, provider)
+		return
+	}
+
+	m.log.Warn("orderbook sequence gap", append(logFields, "action", "resubscribe")...)
+	m.startResubscribe(state)
+}
+
+// startResync переводит книгу в statusResyncing и запускает загрузку REST снимка
+// Вызывается под m.mu
+func (m *Manager) startResync(state *bookState, driver exchange.ExchangeDriver, provider exchange.SnapshotProvider) {
+	state.status = statusResyncing
+	book := state.book
+	go m.resync(driver, provider, book.ExchangeID, book.MarketType, book.Pair, book.Depth, state.generation)
+}
+
+// startResubscribe переводит книгу в statusResyncing и запускает переподписку пары
+// Вызывается под m.mu
+func (m *Manager) startResubscribe(state *bookState) {
+	state.status = statusResyncing
+	book := state.book
+	go m.resubscribe(book.ExchangeID, book.MarketType, book.Pair, state.generation)
+}
+
+// resubscribe переподписывает пару, чтобы биржа без REST снимка прислала снимок в потоке
+// Пул пришлет TypeBookInvalidated и после подписки новый снимок
+// Неудачная переподписка повторяется с той же паузой, что и загрузка снимка
+func (m *Manager) resubscribe(exchangeID, marketType, pair string, generation uint64) {
+	key := exchange.GetOrderBookKey(exchangeID, pair, marketType)
+	logFields := []any{
+		"exchange_id", exchangeID,
+		"market_type", marketType,
+		"pair", pair,
+	}
+
+	for attempt := 1; attempt <= maxResyncAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-time.After(time.Duration(attempt-1) * resyncRetryDelay):
+			}
+
+			m.mu.RLock()
+			state, ok := m.books[key]
+			current := ok && state.generation == generation && state.status == statusResyncing
+			m.mu.RUnlock()
+			if !current {
+				// Книгу сбросили или восстановили из потока, пока ждали повтора
+				return
+			}
+		}
+
+		err := m.source.Resubscribe(exchangeID, marketType, pair)
+		if err == nil {
+			return
+		}
+		if m.ctx.Err() != nil {
+			return
+		}
+		m.log.Warn("orderbook resubscribe failed", append(logFields, "attempt", attempt, "error", err)...)
+	}
+
+	m.log.Error("orderbook resync failed", append(logFields, "attempts", maxResyncAttempts)...)
+	m.abandonResync(exchangeID, marketType, pair)
+	m.router.PublishError(exchangeID, marketType, pair,
+		fmt.Errorf("%w: %s after %d attempts", ErrResyncFailed, key, maxResyncAttempts))
+}
+
+// resync загружает REST снимок и применяет поверх него накопленные дельты
+// Если снимок старше первой накопленной дельты, загрузка повторяется
+func (m *Manager) resync(driver exchange.ExchangeDriver, provider exchange.SnapshotProvider, exchangeID, marketType, pair string, depth int, generation uint64) {
+	key := exchange.GetOrderBookKey(exchangeID, pair, marketType)
+	logFields := []any{
+		"exchange_id", exchangeID,
+		"market_type", marketType,
+		"pair", pair,
+	}
+
+	for attempt := 1; attempt <= maxResyncAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-time.After(time.Duration(attempt-1) * resyncRetryDelay):
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(m.ctx, resyncTimeout)
+		start := time.Now()
+		snapshot, err := provider.FetchOrderBookSnapshot(ctx, marketType, pair, depth)
+		cancel()
+		if m.ctx.Err() != nil {
+			return
+		}
+		if err == nil && (snapshot == nil || snapshot.OrderBook == nil) {
+			err = fmt.Errorf("%w: empty snapshot", ErrInvalidMessage)
+		}
+		if err != nil {
+			m.log.Warn("orderbook snapshot failed", append(logFields, "attempt", attempt, "error", err)...)
+			continue
+		}
+
+		m.mu.Lock()
+		state, ok := m.books[key]
+		if !ok || state.generation != generation || state.status != statusResyncing {
+			// Книгу сбросили или восстановили из потока, пока грузился снимок
+			m.mu.Unlock()
+			return
+		}
+		replayed, synced := m.replay(state, driver, snapshot)
+		seqNum := state.lastSeqNum
+		var updates []*messaging.Message
+		if synced {
+			updates = m.bookUpdates(state, snapshot)
+		}
+		m.mu.Unlock()
+
+		if synced {
+			for _, update := range updates {
+				m.router.Publish(update)
+			}
+			m.log.Info("orderbook resync", append(logFields,
+				"attempt", attempt,
+				"snapshot_seq_num", snapshot.SeqNum,
+				"seq_num", seqNum,
+				"replayed", replayed,
+				"latency_ms", float64(time.Since(start).Microseconds())/1000.0,
+			)...)
+			return
+		}
+		m.log.Warn("orderbook snapshot behind stream", append(logFields,
+			"attempt", attempt,
+			"snapshot_seq_num", snapshot.SeqNum,
+		)...)
+	}
+
+	m.log.Error("orderbook resync failed", append(logFields, "attempts", maxResyncAttempts)...)
+	m.abandonResync(exchangeID, marketType, pair)
+	m.router.PublishError(exchangeID, marketType, pair,
+		fmt.Errorf("%w: %s after %d attempts", ErrResyncFailed, key, maxResyncAttempts))
+}
+
+// replay применяет снимок и накопленные дельты
+// false если между снимком и дельтами остался разрыв: книга снова ждет снимка,
+// дельты начиная с разрыва остаются в буфере
+// Вызывается под m.mu
+func (m *Manager) replay(state *bookState, driver exchange.ExchangeDriver, snapshot *messaging.Message) (int, bool) {
+	pending := state.pending
+	state.pending = nil
+
+	snapshot.OrderBook.Snapshot = true
+	applySnapshot(state, snapshot)
+
+	replayed := 0
+	for i, delta := range pending {
+		switch alignDelta(driver, state, delta) {
+		case exchange.SeqStale:
+			continue
+		case exchange.SeqGap:
+			state.status = statusResyncing
+			state.pending = pending[i:]
+			return replayed, false
+		}
+		applyDelta(state, delta)
+		replayed++
+	}
+	return replayed, true
+}
+
+// abandonResync возвращает книгу в ожидание снимка после неудачного восстановления
+// Следующая дельта запустит восстановление заново: загрузку REST снимка
+// или, для бирж без него, переподписку пары
+func (m *Manager) abandonResync(exchangeID, marketType, pair string) {
+	key := exchange.GetOrderBookKey(exchangeID, pair, marketType)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if state, ok := m.books[key]; ok && state.status == statusResyncing {
+		reset(state)
+		state.recoveryFailed = true
+	}
+}
+
+// alignDelta сверяет дельту с книгой по правилам драйвера
+// Без SequenceAligner дельта с SeqNum не больше последнего считается устаревшей,
+// а PrevSeqNum (если биржа его присылает) должен совпадать с последним SeqNum
+func alignDelta(driver exchange.ExchangeDriver, state *bookState, delta *messaging.Message) exchange.SeqAlignment {
+	if aligner, ok := driver.(exchange.SequenceAligner); ok {
+		return aligner.AlignDelta(delta.MarketType, state.lastSeqNum, state.afterSnapshot, delta)
+	}
+
+	switch {
+	case delta.SeqNum == 0 || state.lastSeqNum == 0:
+		return exchange.SeqApply
+	case delta.SeqNum <= state.lastSeqNum:
+		return exchange.SeqStale
+	case !state.afterSnapshot && delta.OrderBook.PrevSeqNum != 0 && delta.OrderBook.PrevSeqNum != state.lastSeqNum:
+		return exchange.SeqGap
+	default:
+		return exchange.SeqApply
+	}
+}
+
+// applySnapshot заменяет книгу снимком
+func applySnapshot(state *bookState, msg *messaging.Message) {
+	book := &state.book
+	book.Depth = msg.OrderBook.Depth
+	book.Bids = toLevels(msg.OrderBook.Bids, true)
+	book.Asks = toLevels(msg.OrderBook.Asks, false)
+	book.Timestamp = msg.Timestamp
+	book.SeqNum = msg.SeqNum
+
+	state.lastSeqNum = msg.SeqNum
+	state.afterSnapshot = true
+	state.status = statusSynced
+}
+
+// applyDelta применяет дельту к валидной книге
+// Уровни за пределами глубины сохраняются: удаление лучших уровней поднимает их в книгу
+func applyDelta(state *bookState, msg *messaging.Message) {
+	book := &state.book
+	book.Bids = mergeLevels(book.Bids, msg.OrderBook.Bids, true)
+	book.Asks = mergeLevels(book.Asks, msg.OrderBook.Asks, false)
+	if msg.Timestamp != 0 {
+		book.Timestamp = msg.Timestamp
+	}
+	if msg.SeqNum != 0 {
+		book.SeqNum = msg.SeqNum
+		state.lastSeqNum = msg.SeqNum
+	}
+	state.afterSnapshot = false
+}
+
+// reset очищает книгу до нового снимка
+func reset(state *bookState) {
+	state.book.Bids = nil
+	state.book.Asks = nil
+	state.book.SeqNum = 0
+	state.lastSeqNum = 0
+	state.afterSnapshot = false
+	state.pending = nil
+	state.status = statusAwaitingSnapshot
+	state.generation++
+	state.hasBBO = false
+	state.recoveryFailed = false
+}
+
+// appendPending добавляет дельту в буфер, вытесняя самые старые при переполнении
+func appendPending(pending []*messaging.Message, msg *messaging.Message) []*messaging.Message {
+	if len(pending) >= maxPendingDeltas {
+		pending = append(pending[:0], pending[len(pending)-maxPendingDeltas+1:]...)
+	}
+	return append(pending, msg)
+}
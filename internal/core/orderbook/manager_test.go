@@ -0,0 +1,232 @@
+package orderbook
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+)
+
+// streamDriver - биржа без REST снимка: книга восстанавливается только переподпиской
+type streamDriver struct{}
+
+func (streamDriver) GetExchangeID() string                { return "stream" }
+func (streamDriver) GetName() string                      { return "Stream" }
+func (streamDriver) GetWSEndpoint(string) (string, error) { return "ws://stream", nil }
+func (streamDriver) CreateSubscribeMessage(string, []string, int) ([][]byte, error) {
+	return nil, nil
+}
+func (streamDriver) CreateUnsubscribeMessage(string, []string, int) ([][]byte, error) {
+	return nil, nil
+}
+func (streamDriver) ParseMessage(string, []byte) ([]*messaging.Message, error) { return nil, nil }
+
+// resubscribeSource - источник, у которого первые failures переподписок завершаются ошибкой
+type resubscribeSource struct {
+	mu       sync.Mutex
+	failures int
+	calls    chan string
+}
+
+func newResubscribeSource(failures int) *resubscribeSource {
+	return &resubscribeSource{failures: failures, calls: make(chan string, 16)}
+}
+
+func (s *resubscribeSource) Driver(string) (exchange.ExchangeDriver, error) {
+	return streamDriver{}, nil
+}
+
+func (s *resubscribeSource) Resubscribe(exchangeID, marketType, pair string) error {
+	s.calls <- pair
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("connection is reconnecting")
+	}
+	return nil
+}
+
+func (s *resubscribeSource) nextCall(t *testing.T, timeout time.Duration) {
+	t.Helper()
+	select {
+	case <-s.calls:
+	case <-time.After(timeout):
+		t.Fatal("pair was not resubscribed")
+	}
+}
+
+func bookMessage(seqNum int64, snapshot bool, bid string) *messaging.Message {
+	return &messaging.Message{
+		ExchangeID: "stream",
+		MarketType: exchange.MarketSpot,
+		Type:       messaging.TypeOrderBook,
+		Pair:       "BTC/USDT",
+		SeqNum:     seqNum,
+		OrderBook: &messaging.OrderBookData{
+			Bids:     []messaging.Level{{Price: decimal.RequireFromString(bid), Amount: decimal.NewFromInt(1)}},
+			Snapshot: snapshot,
+			Depth:    20,
+		},
+	}
+}
+
+func TestGapWithoutSnapshotProviderRetriesResubscribe(t *testing.T) {
+	source := newResubscribeSource(1)
+	m := NewManager(source)
+	defer m.Close()
+
+	if err := m.UpdateOrderBook(bookMessage(10, true, "100")); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if err := m.UpdateOrderBook(bookMessage(11, false, "100.5")); err != nil {
+		t.Fatalf("delta: %v", err)
+	}
+	// Дельта с PrevSeqNum мимо последнего SeqNum - разрыв
+	gap := bookMessage(13, false, "101")
+	gap.OrderBook.PrevSeqNum = 12
+	if err := m.UpdateOrderBook(gap); err != nil {
+		t.Fatalf("delta: %v", err)
+	}
+	if m.GetOrderBook("stream", "BTC/USDT", exchange.MarketSpot) != nil {
+		t.Fatal("book is valid after sequence gap")
+	}
+
+	// Первая переподписка не удалась, повтор идет через resyncRetryDelay
+	source.nextCall(t, time.Second)
+	source.nextCall(t, 2*resyncRetryDelay+time.Second)
+
+	// Снимок из потока после переподписки восстанавливает книгу
+	if err := m.UpdateOrderBook(bookMessage(20, true, "102")); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	book := m.GetOrderBook("stream", "BTC/USDT", exchange.MarketSpot)
+	if book == nil || book.SeqNum != 20 {
+		t.Fatalf("book after resubscribe = %+v", book)
+	}
+}
+
+func TestAbandonedResubscribeRestartsOnNextDelta(t *testing.T) {
+	source := newResubscribeSource(0)
+	m := NewManager(source)
+	defer m.Close()
+
+	if err := m.UpdateOrderBook(bookMessage(10, true, "100")); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+
+	// Все попытки переподписки исчерпаны: книга ждет снимка с пометкой о неудаче
+	key := exchange.GetOrderBookKey("stream", "BTC/USDT", exchange.MarketSpot)
+	m.mu.Lock()
+	m.books[key].status = statusResyncing
+	m.mu.Unlock()
+	m.abandonResync("stream", exchange.MarketSpot, "BTC/USDT")
+
+	if err := m.UpdateOrderBook(bookMessage(11, false, "101")); err != nil {
+		t.Fatalf("delta: %v", err)
+	}
+	source.nextCall(t, time.Second)
+
+	// После обычной инвалидации (переподключение пула) снимок придет сам, переподписка не нужна
+	m.UpdateOrderBook(&messaging.Message{
+		ExchangeID: "stream", MarketType: exchange.MarketSpot, Type: messaging.TypeBookInvalidated, Pair: "BTC/USDT",
+	})
+	if err := m.UpdateOrderBook(bookMessage(12, false, "101")); err != nil {
+		t.Fatalf("delta: %v", err)
+	}
+	select {
+	case <-source.calls:
+		t.Fatal("pair resubscribed while waiting for the stream snapshot")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// bookRecorder - подписчик, передающий опубликованные снимки книги в канал
+type bookRecorder struct {
+	books chan *exchange.OrderBook
+}
+
+func (r *bookRecorder) OnMessage(msg *messaging.Message) {
+	if book := BookFromMessage(msg); book != nil {
+		r.books <- book
+	}
+}
+func (r *bookRecorder) OnError(error)          {}
+func (r *bookRecorder) GetID() string          { return "book-recorder" }
+func (r *bookRecorder) MessageTypes() []string { return []string{messaging.TypeOrderBook} }
+
+// prices сводит сторону книги к строке цен
+func prices(side []exchange.Level) string {
+	var result []string
+	for _, level := range side {
+		result = append(result, level.Price.String())
+	}
+	return strings.Join(result, " ")
+}
+
+func TestDepthAppliedOnRead(t *testing.T) {
+	m := NewManager(newResubscribeSource(0))
+	defer m.Close()
+	recorder := &bookRecorder{books: make(chan *exchange.OrderBook, 16)}
+	if err := m.Subscribe(recorder, "stream", "BTC/USDT", exchange.MarketSpot); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	message := func(seq int64, snapshot bool, bids, asks []messaging.Level) *messaging.Message {
+		return &messaging.Message{
+			ExchangeID: "stream", MarketType: exchange.MarketSpot, Type: messaging.TypeOrderBook, Pair: "BTC/USDT",
+			SeqNum:    seq,
+			OrderBook: &messaging.OrderBookData{Bids: bids, Asks: asks, Snapshot: snapshot, Depth: 2},
+		}
+	}
+
+	for _, step := range []struct {
+		name       string
+		msg        *messaging.Message
+		bids, asks string
+	}{
+		{"snapshot deeper than subscription", message(1, true, levels("100", "1", "99", "1", "98", "1"), levels("101", "1", "102", "1")),
+			"100 99", "101 102"},
+		{"better bid pushes level out", message(2, false, levels("100.5", "1"), nil), "100.5 100", "101 102"},
+		{"pushed out level comes back", message(3, false, levels("100.5", "0"), nil), "100 99", "101 102"},
+		{"level below snapshot depth", message(4, false, levels("100", "0", "99", "0"), nil), "98", "101 102"},
+		{"asks pushed out and back", message(5, false, nil, levels("100.8", "1", "100.9", "1")), "98", "100.8 100.9"},
+		{"both asks removed", message(6, false, nil, levels("100.8", "0", "100.9", "0")), "98", "101 102"},
+		// Новый снимок заменяет всю книгу, включая уровни за глубиной
+		{"snapshot replaces hidden levels", message(7, true, levels("97", "1"), levels("103", "1")), "97", "103"},
+	} {
+		if err := m.UpdateOrderBook(step.msg); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		book := m.GetOrderBook("stream", "BTC/USDT", exchange.MarketSpot)
+		if book == nil {
+			t.Fatalf("%s: GetOrderBook = nil", step.name)
+		}
+		if prices(book.Bids) != step.bids || prices(book.Asks) != step.asks {
+			t.Errorf("%s: GetOrderBook = %s / %s, want %s / %s", step.name, prices(book.Bids), prices(book.Asks), step.bids, step.asks)
+		}
+
+		select {
+		case published := <-recorder.books:
+			if prices(published.Bids) != step.bids || prices(published.Asks) != step.asks || published.Depth != 2 {
+				t.Errorf("%s: published %s / %s depth %d, want %s / %s depth 2", step.name,
+					prices(published.Bids), prices(published.Asks), published.Depth, step.bids, step.asks)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s: book was not published", step.name)
+		}
+
+		consolidated := m.ConsolidatedBook("BTC/USDT", exchange.MarketSpot, nil, 0)
+		if len(consolidated.Bids) != len(book.Bids) || len(consolidated.Asks) != len(book.Asks) {
+			t.Errorf("%s: consolidated book has %d bids, %d asks, want %d, %d", step.name,
+				len(consolidated.Bids), len(consolidated.Asks), len(book.Bids), len(book.Asks))
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package orderbook
+
+import (
+	"sort"
+	"sync"
+)
+
+// ============================================================================
+// Кольцевой буфер записей по времени
+// ============================================================================
+
+// Ring - кольцевой буфер фиксированной емкости с выборкой по времени
+// При заполнении новая запись вытесняет самую старую.
+// Записи хранятся в порядке времени: запись со временем меньше предыдущей
+// (биржа прислала сообщения не по порядку) получает время предыдущей,
+// поэтому выборка по диапазону - двоичный поиск.
+// Один писатель и много читателей: запись и чтение берут только мьютекс буфера
+type Ring[T any] struct {
+	mu    sync.RWMutex
+	items []ringItem[T]
+	start int // индекс самой старой записи
+	size  int
+}
+
+type ringItem[T any] struct {
+	at    int64 // Unix μs
+	value T
+}
+
+// NewRing создает буфер на capacity записей (минимум одна)
+func NewRing[T any](capacity int) *Ring[T] {
+	return &Ring[T]{items: make([]ringItem[T], max(capacity, 1))}
+}
+
+// Push добавляет запись со временем at (Unix μs)
+func (r *Ring[T]) Push(at int64, value T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size > 0 {
+		if last := r.items[r.index(r.size-1)].at; at < last {
+			at = last
+		}
+	}
+	if r.size < len(r.items) {
+		r.items[r.index(r.size)] = ringItem[T]{at: at, value: value}
+		r.size++
+		return
+	}
+	r.items[r.start] = ringItem[T]{at: at, value: value}
+	r.start = (r.start + 1) % len(r.items)
+}
+
+// Range возвращает записи со временем в [from, to] (Unix μs) от старых к новым
+func (r *Ring[T]) Range(from, to int64) []T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	first := sort.Search(r.size, func(i int) bool { return r.items[r.index(i)].at >= from })
+	end := sort.Search(r.size, func(i int) bool { return r.items[r.index(i)].at > to })
+	if first >= end {
+		return nil
+	}
+	out := make([]T, 0, end-first)
+	for i := first; i < end; i++ {
+		out = append(out, r.items[r.index(i)].value)
+	}
+	return out
+}
+
+// Last возвращает до n последних записей от старых к новым, n <= 0 - все
+func (r *Ring[T]) Last(n int) []T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if n <= 0 || n > r.size {
+		n = r.size
+	}
+	out := make([]T, 0, n)
+	for i := r.size - n; i < r.size; i++ {
+		out = append(out, r.items[r.index(i)].value)
+	}
+	return out
+}
+
+// Len возвращает количество записей
+func (r *Ring[T]) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.size
+}
+
+// Cap возвращает емкость буфера
+func (r *Ring[T]) Cap() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.items)
+}
+
+// Resize меняет емкость буфера на capacity (минимум одна), сохраняя последние записи
+// Буфер меняется на месте под своим мьютексом, поэтому конкурентный Push не теряется
+func (r *Ring[T]) Resize(capacity int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	capacity = max(capacity, 1)
+	if capacity == len(r.items) {
+		return
+	}
+	items := make([]ringItem[T], capacity)
+	keep := min(r.size, capacity)
+	for i := 0; i < keep; i++ {
+		items[i] = r.items[r.index(r.size-keep+i)]
+	}
+	r.items = items
+	r.start = 0
+	r.size = keep
+}
+
+// index переводит логический номер записи (0 - самая старая) в индекс массива
+func (r *Ring[T]) index(i int) int {
+	return (r.start + i) % len(r.items)
+}
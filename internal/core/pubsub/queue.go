@@ -0,0 +1,184 @@
+package pubsub
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"trader/internal/core/messaging"
+)
+
+// overflowLogEvery - переполнение логируется на первом потерянном сообщении и далее каждые N
+const overflowLogEvery = 1000
+
+// item - элемент очереди: сообщение или ошибка
+type item struct {
+	msg *messaging.Message
+	err error
+}
+
+// queue - ограниченная очередь одного подписчика и горутина доставки
+type queue struct {
+	sub  Subscriber
+	opts QueueOptions
+	log  *slog.Logger
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	items    []item
+	closed   bool
+
+	// lost - потерянные сообщения, о которых подписчик еще не узнал через OnError
+	lost int
+	// dropped и coalesced - счетчики за все время для Stats
+	dropped   uint64
+	coalesced uint64
+}
+
+func newQueue(sub Subscriber, opts QueueOptions, log *slog.Logger) *queue {
+	if opts.Size <= 0 {
+		opts.Size = DefaultQueueSize
+	}
+	q := &queue{sub: sub, opts: opts, log: log}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	go q.run()
+	return q
+}
+
+// push ставит элемент в очередь по политике переполнения
+// С политикой Block ждет места в очереди, пока очередь не закрыта
+func (q *queue) push(it item) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+
+	if q.opts.Overflow == CoalesceBook && supersedes(it.msg) {
+		q.coalesce(it.msg)
+	}
+
+	if len(q.items) >= q.opts.Size {
+		if q.opts.Overflow == Block {
+			for len(q.items) >= q.opts.Size && !q.closed {
+				q.notFull.Wait()
+			}
+			if q.closed {
+				return
+			}
+		} else {
+			q.items[0] = item{}
+			q.items = q.items[1:]
+			q.lost++
+			q.dropped++
+			if q.dropped%overflowLogEvery == 1 {
+				q.log.Warn("pubsub queue overflow",
+					"subscriber_id", q.sub.GetID(),
+					"policy", q.opts.Overflow.String(),
+					"queue_size", q.opts.Size,
+					"dropped_total", q.dropped,
+				)
+			}
+		}
+	}
+
+	q.items = append(q.items, it)
+	q.notEmpty.Signal()
+}
+
+// coalesce убирает из очереди недоставленные сообщения того же типа по той же паре:
+// новый снимок книги (BBO) их полностью заменяет
+// Вызывается под q.mu
+func (q *queue) coalesce(msg *messaging.Message) {
+	kept := q.items[:0]
+	for _, queued := range q.items {
+		if queued.msg != nil && queued.msg.Type == msg.Type &&
+			queued.msg.ExchangeID == msg.ExchangeID &&
+			queued.msg.MarketType == msg.MarketType &&
+			queued.msg.Pair == msg.Pair {
+			q.coalesced++
+			continue
+		}
+		kept = append(kept, queued)
+	}
+	clear(q.items[len(kept):])
+	q.items = kept
+}
+
+// run доставляет элементы подписчику до закрытия очереди
+// О потерянных при переполнении сообщениях подписчик узнает через OnError(ErrQueueOverflow)
+func (q *queue) run() {
+	for {
+		q.mu.Lock()
+		for len(q.items) == 0 && q.lost == 0 && !q.closed {
+			q.notEmpty.Wait()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+
+		lost := q.lost
+		q.lost = 0
+
+		var next item
+		hasNext := len(q.items) > 0
+		if hasNext {
+			next = q.items[0]
+			q.items[0] = item{}
+			q.items = q.items[1:]
+			q.notFull.Signal()
+		}
+		q.mu.Unlock()
+
+		if lost > 0 {
+			q.sub.OnError(fmt.Errorf("%w: %d messages dropped", ErrQueueOverflow, lost))
+		}
+		if !hasNext {
+			continue
+		}
+		if next.err != nil {
+			q.sub.OnError(next.err)
+		} else {
+			q.sub.OnMessage(next.msg)
+		}
+	}
+}
+
+// close останавливает доставку, недоставленные элементы отбрасываются
+// Публикации, ждущие места (Block), освобождаются
+func (q *queue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.items = nil
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+	q.mu.Unlock()
+}
+
+// stats возвращает текущее состояние очереди
+func (q *queue) stats() SubscriberStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return SubscriberStats{
+		ID:        q.sub.GetID(),
+		Overflow:  q.opts.Overflow,
+		QueueSize: q.opts.Size,
+		Queued:    len(q.items),
+		Dropped:   q.dropped,
+		Coalesced: q.coalesced,
+	}
+}
+
+// supersedes сообщает заменяет ли сообщение предыдущие сообщения своего типа по паре:
+// снимок книги и BBO несут полное состояние, дельты и сделки - нет
+func supersedes(msg *messaging.Message) bool {
+	if msg != nil && msg.Type == messaging.TypeBBO {
+		return true
+	}
+	return msg != nil && msg.Type == messaging.TypeOrderBook &&
+		msg.OrderBook != nil && msg.OrderBook.Snapshot
+}
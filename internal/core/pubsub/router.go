@@ -0,0 +1,268 @@
+package pubsub
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+	"trader/internal/logger"
+)
+
+// Wildcard - сегмент ключа подписки, совпадающий с любым значением
+const Wildcard = "*"
+
+// Key возвращает ключ подписки на пару, сегменты могут быть Wildcard
+func Key(exchangeID, marketType, pair string) string {
+	return exchange.GetOrderBookKey(exchangeID, pair, marketType)
+}
+
+// pattern - разобранный ключ подписки
+type pattern struct {
+	exchangeID string
+	marketType string
+	pair       string
+}
+
+// parsePattern разбирает "exchange:market:pair", одиночный "*" - все пары всех бирж
+func parsePattern(key string) (pattern, error) {
+	if key == Wildcard {
+		return pattern{Wildcard, Wildcard, Wildcard}, nil
+	}
+	parts := strings.SplitN(key, ":", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return pattern{}, fmt.Errorf("%w: %q", ErrInvalidPattern, key)
+	}
+	return pattern{exchangeID: parts[0], marketType: parts[1], pair: parts[2]}, nil
+}
+
+func (p pattern) matches(exchangeID, marketType, pair string) bool {
+	return matchSegment(p.exchangeID, exchangeID) &&
+		matchSegment(p.marketType, marketType) &&
+		matchSegment(p.pair, pair)
+}
+
+func matchSegment(pattern, value string) bool {
+	return pattern == Wildcard || pattern == value
+}
+
+// subscription - подписчик, его ключи и очередь
+type subscription struct {
+	patterns map[string]pattern // key = исходный ключ подписки
+	// types - принимаемые типы сообщений (TypeFilter), nil = все
+	types map[string]struct{}
+	queue *queue
+}
+
+func (s *subscription) matches(exchangeID, marketType, pair string) bool {
+	for _, p := range s.patterns {
+		if p.matches(exchangeID, marketType, pair) {
+			return true
+		}
+	}
+	return false
+}
+
+// accepts сообщает нужен ли подписчику тип сообщения, "" (ошибки) нужен всегда
+func (s *subscription) accepts(msgType string) bool {
+	if s.types == nil || msgType == "" {
+		return true
+	}
+	_, ok := s.types[msgType]
+	return ok
+}
+
+// SubscriberStats - состояние очереди подписчика для диагностики
+type SubscriberStats struct {
+	ID        string
+	Overflow  OverflowPolicy
+	QueueSize int
+	// Queued - сообщений ждут доставки
+	Queued int
+	// Dropped - потеряно при переполнении за все время
+	Dropped uint64
+	// Coalesced - заменено более новыми снимками книги за все время
+	Coalesced uint64
+	// Patterns - ключи подписки
+	Patterns []string
+}
+
+// Router раздает сообщения подписчикам по ключам "exchange:market:pair"
+// Сообщение доставляется подписчику один раз, даже если совпало с несколькими его ключами
+// Безопасен для конкурентного использования
+type Router struct {
+	mu            sync.RWMutex
+	subscriptions map[string]*subscription // key = Subscriber.GetID()
+	closed        bool
+	log           *slog.Logger
+}
+
+// NewRouter создает пустой роутер
+func NewRouter() *Router {
+	return &Router{
+		subscriptions: make(map[string]*subscription),
+		log:           logger.Get("pubsub"),
+	}
+}
+
+// Subscribe подписывает sub на ключ "exchange:market:pair" (сегменты могут быть "*")
+// Очередь создается при первой подписке подписчика с параметрами из QueueConfigurer
+// (или DefaultQueueOptions), последующие подписки с тем же GetID используют ее же
+func (r *Router) Subscribe(sub Subscriber, key string) error {
+	p, err := parsePattern(key)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrRouterClosed
+	}
+
+	id := sub.GetID()
+	s, ok := r.subscriptions[id]
+	if !ok {
+		opts := DefaultQueueOptions
+		if configurer, ok := sub.(QueueConfigurer); ok {
+			opts = configurer.QueueOptions()
+		}
+		s = &subscription{
+			patterns: make(map[string]pattern),
+			queue:    newQueue(sub, opts, r.log),
+		}
+		if filter, ok := sub.(TypeFilter); ok {
+			s.types = make(map[string]struct{})
+			for _, msgType := range filter.MessageTypes() {
+				s.types[msgType] = struct{}{}
+			}
+		}
+		r.subscriptions[id] = s
+		r.log.Info("pubsub subscriber added",
+			"subscriber_id", id,
+			"policy", s.queue.opts.Overflow.String(),
+			"queue_size", s.queue.opts.Size,
+		)
+	}
+	s.patterns[key] = p
+	return nil
+}
+
+// Unsubscribe снимает подписку sub на ключ
+// Когда ключей не остается, очередь подписчика закрывается
+func (r *Router) Unsubscribe(sub Subscriber, key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := sub.GetID()
+	s, ok := r.subscriptions[id]
+	if !ok {
+		return
+	}
+	delete(s.patterns, key)
+	if len(s.patterns) == 0 {
+		r.remove(id, s)
+	}
+}
+
+// UnsubscribeAll снимает все подписки sub и закрывает его очередь
+func (r *Router) UnsubscribeAll(sub Subscriber) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := sub.GetID()
+	if s, ok := r.subscriptions[id]; ok {
+		r.remove(id, s)
+	}
+}
+
+// remove удаляет подписчика, вызывается под r.mu
+func (r *Router) remove(id string, s *subscription) {
+	delete(r.subscriptions, id)
+	s.queue.close()
+	r.log.Info("pubsub subscriber removed", "subscriber_id", id)
+}
+
+// Publish ставит сообщение в очереди подписчиков, чьи ключи совпали с парой сообщения
+// и которым нужен тип сообщения (TypeFilter)
+// Блокируется только если у совпавшего подписчика политика Block и очередь заполнена
+func (r *Router) Publish(msg *messaging.Message) {
+	if msg == nil {
+		return
+	}
+	for _, q := range r.match(msg.ExchangeID, msg.MarketType, msg.Pair, msg.Type) {
+		q.push(item{msg: msg})
+	}
+}
+
+// PublishError доставляет ошибку потока пары подписчикам через OnError
+func (r *Router) PublishError(exchangeID, marketType, pair string, err error) {
+	if err == nil {
+		return
+	}
+	for _, q := range r.match(exchangeID, marketType, pair, "") {
+		q.push(item{err: err})
+	}
+}
+
+// HasSubscribers сообщает есть ли подписчики на сообщения типа msgType по паре
+// Позволяет не собирать сообщение, которое некому доставить
+func (r *Router) HasSubscribers(exchangeID, marketType, pair, msgType string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, s := range r.subscriptions {
+		if s.accepts(msgType) && s.matches(exchangeID, marketType, pair) {
+			return true
+		}
+	}
+	return false
+}
+
+// match возвращает очереди подписчиков пары, принимающих тип msgType ("" - ошибки)
+func (r *Router) match(exchangeID, marketType, pair, msgType string) []*queue {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var queues []*queue
+	for _, s := range r.subscriptions {
+		if s.accepts(msgType) && s.matches(exchangeID, marketType, pair) {
+			queues = append(queues, s.queue)
+		}
+	}
+	return queues
+}
+
+// Stats возвращает состояние очередей подписчиков, отсортированное по id
+func (r *Router) Stats() []SubscriberStats {
+	r.mu.RLock()
+	stats := make([]SubscriberStats, 0, len(r.subscriptions))
+	for _, s := range r.subscriptions {
+		st := s.queue.stats()
+		for key := range s.patterns {
+			st.Patterns = append(st.Patterns, key)
+		}
+		sort.Strings(st.Patterns)
+		stats = append(stats, st)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ID < stats[j].ID })
+	return stats
+}
+
+// Close закрывает очереди всех подписчиков, дальнейшие Publish ничего не делают
+func (r *Router) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	for id, s := range r.subscriptions {
+		delete(r.subscriptions, id)
+		s.queue.close()
+	}
+}
@@ -0,0 +1,379 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+)
+
+// testSubscriber записывает доставленные сообщения и ошибки как "type:seq" и "overflow"
+// С hold первое сообщение не возвращается из OnMessage, пока hold не закрыт:
+// очередь подписчика копится, как у медленного писателя
+type testSubscriber struct {
+	id    string
+	opts  QueueOptions
+	types []string
+
+	hold    chan struct{}
+	started chan struct{}
+	once    sync.Once
+	events  chan string
+}
+
+func newTestSubscriber(id string, opts QueueOptions, hold bool) *testSubscriber {
+	s := &testSubscriber{
+		id:      id,
+		opts:    opts,
+		started: make(chan struct{}),
+		events:  make(chan string, 64),
+	}
+	if hold {
+		s.hold = make(chan struct{})
+	}
+	return s
+}
+
+func (s *testSubscriber) OnMessage(msg *messaging.Message) {
+	s.events <- fmt.Sprintf("%s:%d", msg.Type, msg.SeqNum)
+	s.once.Do(func() {
+		close(s.started)
+		if s.hold != nil {
+			<-s.hold
+		}
+	})
+}
+
+func (s *testSubscriber) OnError(err error) {
+	if errors.Is(err, ErrQueueOverflow) {
+		s.events <- "overflow"
+		return
+	}
+	s.events <- "error:" + err.Error()
+}
+
+func (s *testSubscriber) GetID() string              { return s.id }
+func (s *testSubscriber) QueueOptions() QueueOptions { return s.opts }
+
+// waitStarted ждет, пока подписчик получит первое сообщение и остановится на hold
+func (s *testSubscriber) waitStarted(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.started:
+	case <-time.After(time.Second):
+		t.Fatal("first message was not delivered")
+	}
+}
+
+// expect ждет следующие события подписчика
+func (s *testSubscriber) expect(t *testing.T, want ...string) {
+	t.Helper()
+	var got []string
+	for range want {
+		select {
+		case event := <-s.events:
+			got = append(got, event)
+		case <-time.After(time.Second):
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	select {
+	case event := <-s.events:
+		t.Fatalf("unexpected event %s after %v", event, want)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// filteredSubscriber - testSubscriber с TypeFilter
+type filteredSubscriber struct {
+	*testSubscriber
+}
+
+func (s filteredSubscriber) MessageTypes() []string { return s.types }
+
+func message(msgType string, seq int64) *messaging.Message {
+	return messageFor("binance", exchange.MarketSpot, "BTC/USDT", msgType, seq)
+}
+
+func messageFor(exchangeID, marketType, pair, msgType string, seq int64) *messaging.Message {
+	msg := &messaging.Message{ExchangeID: exchangeID, MarketType: marketType, Pair: pair, Type: msgType, SeqNum: seq}
+	if msgType == messaging.TypeOrderBook {
+		msg.OrderBook = &messaging.OrderBookData{Snapshot: true}
+	}
+	if msgType == messaging.TypeBBO {
+		msg.BBO = &messaging.BBOData{}
+	}
+	return msg
+}
+
+func delta(seq int64) *messaging.Message {
+	msg := message(messaging.TypeOrderBook, seq)
+	msg.OrderBook.Snapshot = false
+	return msg
+}
+
+func subscribe(t *testing.T, r *Router, sub Subscriber, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		if err := r.Subscribe(sub, key); err != nil {
+			t.Fatalf("Subscribe(%q): %v", key, err)
+		}
+	}
+}
+
+func stats(r *Router, id string) SubscriberStats {
+	for _, st := range r.Stats() {
+		if st.ID == id {
+			return st
+		}
+	}
+	return SubscriberStats{}
+}
+
+func TestPatternMatching(t *testing.T) {
+	for _, tc := range []struct {
+		key   string
+		match []string
+		miss  []string
+	}{
+		{
+			key:   "binance:spot:BTC/USDT",
+			match: []string{"binance:spot:BTC/USDT"},
+			miss:  []string{"binance:futures:BTC/USDT", "binance:spot:ETH/USDT", "bybit:spot:BTC/USDT"},
+		},
+		{
+			key:   "*",
+			match: []string{"binance:spot:BTC/USDT", "okx:futures:ETH/USDT"},
+		},
+		{
+			key:   "binance:*:*",
+			match: []string{"binance:spot:BTC/USDT", "binance:futures:ETH/USDT"},
+			miss:  []string{"bybit:spot:BTC/USDT"},
+		},
+		{
+			key:   "*:futures:*",
+			match: []string{"binance:futures:BTC/USDT", "okx:futures:ETH/USDT"},
+			miss:  []string{"binance:spot:BTC/USDT"},
+		},
+		{
+			key:   "*:*:BTC/USDT",
+			match: []string{"binance:spot:BTC/USDT", "okx:futures:BTC/USDT"},
+			miss:  []string{"binance:spot:BTC/USDC", "binance:spot:ETH/USDT"},
+		},
+		{
+			// Пара с ":" (опционы, фьючерсы с датой) - третий сегмент целиком
+			key:   "deribit:futures:BTC/USD:240628",
+			match: []string{"deribit:futures:BTC/USD:240628"},
+			miss:  []string{"deribit:futures:BTC/USD"},
+		},
+	} {
+		t.Run(tc.key, func(t *testing.T) {
+			p, err := parsePattern(tc.key)
+			if err != nil {
+				t.Fatalf("parsePattern: %v", err)
+			}
+			for _, key := range tc.match {
+				parts := strings.SplitN(key, ":", 3)
+				if !p.matches(parts[0], parts[1], parts[2]) {
+					t.Errorf("%q does not match %q", tc.key, key)
+				}
+			}
+			for _, key := range tc.miss {
+				parts := strings.SplitN(key, ":", 3)
+				if p.matches(parts[0], parts[1], parts[2]) {
+					t.Errorf("%q matches %q", tc.key, key)
+				}
+			}
+		})
+	}
+
+	for _, key := range []string{"", "binance", "binance:spot", "binance::BTC/USDT", ":spot:BTC/USDT", "binance:spot:", "**"} {
+		if _, err := parsePattern(key); !errors.Is(err, ErrInvalidPattern) {
+			t.Errorf("parsePattern(%q) = %v, want ErrInvalidPattern", key, err)
+		}
+	}
+}
+
+func TestRouterDeliversOnceAcrossMatchingPatterns(t *testing.T) {
+	r := NewRouter()
+	defer r.Close()
+
+	sub := newTestSubscriber("wildcards", DefaultQueueOptions, false)
+	subscribe(t, r, sub, "binance:spot:BTC/USDT", "binance:*:*", "*")
+	if err := r.Subscribe(sub, "binance:spot"); !errors.Is(err, ErrInvalidPattern) {
+		t.Fatalf("Subscribe invalid key = %v, want ErrInvalidPattern", err)
+	}
+
+	r.Publish(message(messaging.TypeTrade, 1))
+	r.Publish(messageFor("okx", exchange.MarketFutures, "ETH/USDT", messaging.TypeTrade, 2))
+	r.PublishError("binance", exchange.MarketSpot, "BTC/USDT", errors.New("gap"))
+	sub.expect(t, "trade:1", "trade:2", "error:gap")
+
+	// После снятия "*" и "binance:*:*" остается только точный ключ
+	r.Unsubscribe(sub, "*")
+	r.Unsubscribe(sub, "binance:*:*")
+	r.Publish(messageFor("okx", exchange.MarketFutures, "ETH/USDT", messaging.TypeTrade, 3))
+	r.Publish(message(messaging.TypeTrade, 4))
+	sub.expect(t, "trade:4")
+
+	r.Unsubscribe(sub, "binance:spot:BTC/USDT")
+	if got := len(r.Stats()); got != 0 {
+		t.Fatalf("subscribers after last Unsubscribe = %d, want 0", got)
+	}
+	if r.HasSubscribers("binance", exchange.MarketSpot, "BTC/USDT", messaging.TypeTrade) {
+		t.Error("HasSubscribers after last Unsubscribe = true")
+	}
+}
+
+func TestRouterTypeFilter(t *testing.T) {
+	r := NewRouter()
+	defer r.Close()
+
+	sub := filteredSubscriber{newTestSubscriber("bbo-only", DefaultQueueOptions, false)}
+	sub.types = []string{messaging.TypeBBO}
+	subscribe(t, r, sub, "binance:*:*")
+
+	if r.HasSubscribers("binance", exchange.MarketSpot, "BTC/USDT", messaging.TypeOrderBook) {
+		t.Error("HasSubscribers(orderbook) = true for BBO-only subscriber")
+	}
+	if !r.HasSubscribers("binance", exchange.MarketSpot, "BTC/USDT", messaging.TypeBBO) {
+		t.Error("HasSubscribers(bbo) = false")
+	}
+
+	r.Publish(message(messaging.TypeOrderBook, 1))
+	r.Publish(message(messaging.TypeBBO, 2))
+	r.Publish(message(messaging.TypeTrade, 3))
+	// Ошибки доставляются без учета фильтра
+	r.PublishError("binance", exchange.MarketSpot, "BTC/USDT", errors.New("gap"))
+	sub.expect(t, "bbo:2", "error:gap")
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	r := NewRouter()
+	defer r.Close()
+
+	sub := newTestSubscriber("drop", QueueOptions{Size: 2, Overflow: DropOldest}, true)
+	subscribe(t, r, sub, "*")
+
+	r.Publish(message(messaging.TypeTrade, 1))
+	sub.waitStarted(t)
+	for seq := int64(2); seq <= 5; seq++ {
+		r.Publish(message(messaging.TypeTrade, seq))
+	}
+	if st := stats(r, "drop"); st.Queued != 2 || st.Dropped != 2 {
+		t.Fatalf("stats while held = %+v, want 2 queued, 2 dropped", st)
+	}
+
+	// Подписчик узнает о потере до следующего сообщения
+	close(sub.hold)
+	sub.expect(t, "trade:1", "overflow", "trade:4", "trade:5")
+	if st := stats(r, "drop"); st.Queued != 0 || st.Dropped != 2 || st.Coalesced != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestOverflowCoalesceBook(t *testing.T) {
+	r := NewRouter()
+	defer r.Close()
+
+	sub := newTestSubscriber("coalesce", QueueOptions{Size: 5, Overflow: CoalesceBook}, true)
+	subscribe(t, r, sub, "*")
+
+	r.Publish(message(messaging.TypeOrderBook, 1))
+	sub.waitStarted(t)
+
+	r.Publish(message(messaging.TypeOrderBook, 2))
+	r.Publish(delta(3))
+	r.Publish(message(messaging.TypeTrade, 4))
+	r.Publish(message(messaging.TypeBBO, 5))
+	// Снимок другой пары не схлопывается с BTC/USDT
+	r.Publish(messageFor("binance", exchange.MarketSpot, "ETH/USDT", messaging.TypeOrderBook, 6))
+	// Снимок заменяет недоставленные снимок 2 и дельту 3, BBO 8 - BBO 5
+	r.Publish(message(messaging.TypeOrderBook, 7))
+	r.Publish(message(messaging.TypeBBO, 8))
+	if st := stats(r, "coalesce"); st.Queued != 4 || st.Coalesced != 3 || st.Dropped != 0 {
+		t.Fatalf("stats while held = %+v, want 4 queued, 3 coalesced, 0 dropped", st)
+	}
+
+	// Очередь заполняется: сделке 11 нечего заменить, она вытесняет самое старое сообщение
+	r.Publish(message(messaging.TypeOrderBook, 9))
+	r.Publish(message(messaging.TypeTrade, 10))
+	r.Publish(message(messaging.TypeTrade, 11))
+
+	close(sub.hold)
+	sub.expect(t, "orderbook:1", "overflow", "orderbook:6", "bbo:8", "orderbook:9", "trade:10", "trade:11")
+	if st := stats(r, "coalesce"); st.Coalesced != 4 || st.Dropped != 1 {
+		t.Errorf("stats = %+v, want 4 coalesced, 1 dropped", st)
+	}
+}
+
+func TestOverflowBlock(t *testing.T) {
+	r := NewRouter()
+	defer r.Close()
+
+	sub := newTestSubscriber("block", QueueOptions{Size: 1, Overflow: Block}, true)
+	subscribe(t, r, sub, "*")
+
+	r.Publish(message(messaging.TypeTrade, 1))
+	sub.waitStarted(t)
+	r.Publish(message(messaging.TypeTrade, 2))
+
+	published := make(chan struct{})
+	go func() {
+		r.Publish(message(messaging.TypeTrade, 3))
+		close(published)
+	}()
+	select {
+	case <-published:
+		t.Fatal("Publish into a full Block queue did not wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(sub.hold)
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish was not released after the subscriber caught up")
+	}
+	sub.expect(t, "trade:1", "trade:2", "trade:3")
+	if st := stats(r, "block"); st.Dropped != 0 {
+		t.Errorf("Dropped = %d, want 0", st.Dropped)
+	}
+}
+
+func TestCloseReleasesBlockedPublisher(t *testing.T) {
+	r := NewRouter()
+
+	sub := newTestSubscriber("block", QueueOptions{Size: 1, Overflow: Block}, true)
+	defer close(sub.hold)
+	subscribe(t, r, sub, "*")
+
+	r.Publish(message(messaging.TypeTrade, 1))
+	sub.waitStarted(t)
+	r.Publish(message(messaging.TypeTrade, 2))
+
+	published := make(chan struct{})
+	go func() {
+		r.Publish(message(messaging.TypeTrade, 3))
+		close(published)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	r.Close()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not release the blocked Publish")
+	}
+	if err := r.Subscribe(sub, "*"); !errors.Is(err, ErrRouterClosed) {
+		t.Errorf("Subscribe after Close = %v, want ErrRouterClosed", err)
+	}
+}
@@ -0,0 +1,91 @@
+// Package pubsub раздает обновления книг и сделок подписчикам внутри процесса
+//
+// Подписка оформляется на ключ "exchange:market:pair" (exchange.GetOrderBookKey),
+// любой сегмент ключа может быть "*". Каждый подписчик получает сообщения
+// из своей ограниченной очереди в отдельной горутине, поэтому медленный подписчик
+// (например, запись в ClickHouse) не задерживает остальных. Что делать при
+// переполнении очереди, подписчик выбирает через OverflowPolicy.
+package pubsub
+
+import (
+	"errors"
+
+	"trader/internal/core/messaging"
+)
+
+// Subscriber - получатель сообщений роутера
+// OnMessage и OnError вызываются последовательно из горутины очереди подписчика
+type Subscriber interface {
+	// OnMessage получает очередное сообщение по ключам подписки
+	OnMessage(msg *messaging.Message)
+	// OnError получает ошибки потока по ключам подписки (разрыв книги, переполнение очереди)
+	OnError(err error)
+	// GetID возвращает уникальный id подписчика, по нему объединяются подписки на разные ключи
+	GetID() string
+}
+
+// QueueConfigurer - подписчик задает размер и политику своей очереди
+// Подписчики без этого интерфейса получают DefaultQueueOptions
+type QueueConfigurer interface {
+	QueueOptions() QueueOptions
+}
+
+// TypeFilter - подписчику нужны только сообщения перечисленных типов
+// (например, только messaging.TypeBBO), остальные в его очередь не попадают
+// Подписчики без этого интерфейса получают сообщения всех типов, ошибки доставляются всегда
+type TypeFilter interface {
+	MessageTypes() []string
+}
+
+// OverflowPolicy - что делать с новым сообщением, если очередь подписчика заполнена
+type OverflowPolicy int
+
+const (
+	// DropOldest - вытеснить самое старое сообщение очереди
+	DropOldest OverflowPolicy = iota
+	// CoalesceBook - новый снимок книги (или BBO) заменяет еще не доставленные сообщения
+	// книги (BBO) той же пары (всегда, а не только при переполнении); если заменить нечего,
+	// вытесняется самое старое сообщение. Для подписчиков, которым нужна только
+	// актуальная книга (стратегии)
+	CoalesceBook
+	// Block - ждать места в очереди. Останавливает публикацию для всех подписчиков,
+	// подходит только для подписчиков, которые обязаны получить каждое сообщение
+	Block
+)
+
+// String возвращает имя политики для логов
+func (p OverflowPolicy) String() string {
+	switch p {
+	case DropOldest:
+		return "drop_oldest"
+	case CoalesceBook:
+		return "coalesce_book"
+	case Block:
+		return "block"
+	default:
+		return "unknown"
+	}
+}
+
+// DefaultQueueSize - размер очереди подписчика по умолчанию
+const DefaultQueueSize = 1024
+
+// QueueOptions - параметры очереди подписчика
+type QueueOptions struct {
+	// Size - максимум недоставленных сообщений, <= 0 = DefaultQueueSize
+	Size int
+	// Overflow - политика при переполнении
+	Overflow OverflowPolicy
+}
+
+// DefaultQueueOptions - очередь DefaultQueueSize с вытеснением старых сообщений
+var DefaultQueueOptions = QueueOptions{Size: DefaultQueueSize, Overflow: DropOldest}
+
+var (
+	// ErrQueueOverflow - подписчик не успевал разбирать очередь, часть сообщений потеряна
+	ErrQueueOverflow = errors.New("subscriber queue overflow")
+	// ErrInvalidPattern - ключ подписки не в формате "exchange:market:pair"
+	ErrInvalidPattern = errors.New("invalid subscription pattern")
+	// ErrRouterClosed - подписка на уже закрытый роутер
+	ErrRouterClosed = errors.New("pubsub router is closed")
+)
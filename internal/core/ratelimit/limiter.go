@@ -0,0 +1,116 @@
+// Package ratelimit реализует token bucket для исходящих запросов к биржам
+// (управляющие WS фреймы и REST). Ожидающие запросы образуют очередь,
+// ее глубина доступна через QueueDepth и пишется в логи вызывающей стороной
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// clock - источник времени limiter, тесты подменяют его ручными часами
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// After - time.After: с Go 1.23 неостановленный таймер освобождается сборщиком мусора
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Limiter - token bucket: rate токенов в секунду, не больше burst накопленных
+// nil *Limiter означает отсутствие ограничения, все методы безопасны для nil
+type Limiter struct {
+	rate  float64
+	burst float64
+	clock clock
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	waiting atomic.Int64
+}
+
+// New создает limiter с полным bucket
+// Возвращает nil если rate <= 0 (ограничения нет)
+func New(rate float64, burst int) *Limiter {
+	return newLimiter(rate, burst, systemClock{})
+}
+
+func newLimiter(rate float64, burst int, clk clock) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		clock:  clk,
+		tokens: float64(burst),
+		last:   clk.Now(),
+	}
+}
+
+// Wait ждет свободный токен и забирает его
+// Возвращает время ожидания в очереди или ошибку контекста
+// Токен резервируется сразу, поэтому ожидающие обслуживаются в порядке вызова Wait
+func (l *Limiter) Wait(ctx context.Context) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	delay := l.reserve()
+	if delay <= 0 {
+		return 0, nil
+	}
+
+	l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+
+	select {
+	case <-l.clock.After(delay):
+		return delay, nil
+	case <-ctx.Done():
+		l.cancel()
+		return 0, ctx.Err()
+	}
+}
+
+// reserve забирает токен (баланс может уйти в минус) и возвращает сколько ждать до его появления
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancel возвращает токен отмененного ожидания
+func (l *Limiter) cancel() {
+	l.mu.Lock()
+	l.tokens = min(l.burst, l.tokens+1)
+	l.mu.Unlock()
+}
+
+// QueueDepth возвращает число запросов, ожидающих токен
+func (l *Limiter) QueueDepth() int {
+	if l == nil {
+		return 0
+	}
+	return int(l.waiting.Load())
+}
@@ -0,0 +1,219 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// manualClock - часы, которые идут только через Advance
+// Каждое ожидание After сообщает свою длительность в blocked
+type manualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []manualTimer
+	blocked chan time.Duration
+}
+
+type manualTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newManualClock() *manualClock {
+	return &manualClock{now: time.Unix(1700000000, 0), blocked: make(chan time.Duration, 16)}
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.mu.Lock()
+	c.timers = append(c.timers, manualTimer{at: c.now.Add(d), ch: ch})
+	c.mu.Unlock()
+	c.blocked <- d
+	return ch
+}
+
+// Advance переводит часы и срабатывает наступившие таймеры
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.ch <- c.now
+	}
+	c.timers = pending
+}
+
+type waitResult struct {
+	wait time.Duration
+	err  error
+}
+
+// wait вызывает Wait и, если запрос встал в очередь, переводит часы на время ожидания
+func wait(t *testing.T, clock *manualClock, l *Limiter) time.Duration {
+	t.Helper()
+	done := make(chan waitResult, 1)
+	go func() {
+		wait, err := l.Wait(context.Background())
+		done <- waitResult{wait, err}
+	}()
+
+	var result waitResult
+	select {
+	case result = <-done:
+	case d := <-clock.blocked:
+		clock.Advance(d)
+		select {
+		case result = <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Wait did not return after the clock reached its token")
+		}
+	}
+	if result.err != nil {
+		t.Fatalf("Wait: %v", result.err)
+	}
+	return result.wait
+}
+
+func expectWaits(t *testing.T, clock *manualClock, l *Limiter, want ...time.Duration) {
+	t.Helper()
+	for i, w := range want {
+		if got := wait(t, clock, l); got != w {
+			t.Fatalf("request %d waited %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+const ms = time.Millisecond
+
+func TestLimiterBurstAndRefill(t *testing.T) {
+	clock := newManualClock()
+	l := newLimiter(2, 3, clock)
+
+	// Полный bucket: burst запросов без ожидания, дальше - по 1/rate
+	expectWaits(t, clock, l, 0, 0, 0, 500*ms, 500*ms)
+
+	// Простой копит токены, но не больше burst
+	clock.Advance(10 * time.Second)
+	expectWaits(t, clock, l, 0, 0, 0, 500*ms)
+
+	// Частичное пополнение: за 250ms накоплено полтокена
+	clock.Advance(250 * ms)
+	expectWaits(t, clock, l, 250*ms)
+}
+
+func TestLimiterReservesInCallOrder(t *testing.T) {
+	clock := newManualClock()
+	l := newLimiter(4, 1, clock)
+
+	// Токен резервируется при вызове: каждый следующий ждет дольше предыдущего
+	for i, want := range []time.Duration{0, 250 * ms, 500 * ms, 750 * ms} {
+		if got := l.reserve(); got != want {
+			t.Fatalf("reserve %d = %v, want %v", i+1, got, want)
+		}
+	}
+	clock.Advance(time.Second)
+	if got := l.reserve(); got != 0 {
+		t.Fatalf("reserve after the queue drained = %v, want 0", got)
+	}
+}
+
+func TestLimiterWaitCancelled(t *testing.T) {
+	clock := newManualClock()
+	l := newLimiter(1, 1, clock)
+	expectWaits(t, clock, l, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan waitResult, 1)
+	go func() {
+		wait, err := l.Wait(ctx)
+		done <- waitResult{wait, err}
+	}()
+	if d := <-clock.blocked; d != time.Second {
+		t.Fatalf("queued for %v, want 1s", d)
+	}
+	if depth := l.QueueDepth(); depth != 1 {
+		t.Fatalf("QueueDepth = %d, want 1", depth)
+	}
+
+	cancel()
+	result := <-done
+	if !errors.Is(result.err, context.Canceled) || result.wait != 0 {
+		t.Fatalf("cancelled Wait = %v, %v, want 0, context.Canceled", result.wait, result.err)
+	}
+	if depth := l.QueueDepth(); depth != 0 {
+		t.Fatalf("QueueDepth after cancel = %d, want 0", depth)
+	}
+
+	// Отмененный запрос вернул токен: через 1/rate токен снова есть
+	clock.Advance(time.Second)
+	expectWaits(t, clock, l, 0, time.Second)
+}
+
+func TestNilLimiter(t *testing.T) {
+	l := New(0, 10)
+	if l != nil {
+		t.Fatalf("New(0, 10) = %+v, want nil", l)
+	}
+	for range 100 {
+		if wait, err := l.Wait(context.Background()); wait != 0 || err != nil {
+			t.Fatalf("nil Wait = %v, %v", wait, err)
+		}
+	}
+	if depth := l.QueueDepth(); depth != 0 {
+		t.Errorf("nil QueueDepth = %d", depth)
+	}
+
+	// burst < 1 - хотя бы один запрос без ожидания
+	clock := newManualClock()
+	expectWaits(t, clock, newLimiter(10, 0, clock), 0, 100*ms)
+}
+
+// TestConnectionAndExchangeLimits проверяет разделение лимитов так же, как их применяет
+// ws.connection: фрейм ждет токен своего соединения, затем общий токен биржи (лимит на IP)
+func TestConnectionAndExchangeLimits(t *testing.T) {
+	clock := newManualClock()
+	exchange := newLimiter(4, 3, clock)
+	connA := newLimiter(2, 2, clock)
+	connB := newLimiter(2, 2, clock)
+
+	send := func(conn *Limiter) (connWait, exchangeWait time.Duration) {
+		t.Helper()
+		return wait(t, clock, conn), wait(t, clock, exchange)
+	}
+
+	for _, tc := range []struct {
+		name         string
+		conn         *Limiter
+		connWait     time.Duration
+		exchangeWait time.Duration
+	}{
+		{"A1", connA, 0, 0},
+		{"A2", connA, 0, 0},
+		{"B1", connB, 0, 0},
+		// У B есть свой токен, но burst биржи исчерпан: ждет лимит на IP
+		{"B2", connB, 0, 250 * ms},
+		// Через 250ms биржа накопила токен, а соединение A - только половину
+		{"A3", connA, 250 * ms, 0},
+		{"B3", connB, 0, 250 * ms},
+	} {
+		connWait, exchangeWait := send(tc.conn)
+		if connWait != tc.connWait || exchangeWait != tc.exchangeWait {
+			t.Fatalf("%s waited connection %v, exchange %v, want %v, %v",
+				tc.name, connWait, exchangeWait, tc.connWait, tc.exchangeWait)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package ws
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// reconnectBaseDelay - задержка перед первой попыткой переподключения
+	reconnectBaseDelay = 500 * time.Millisecond
+	// reconnectMaxDelay - верхняя граница задержки между попытками
+	reconnectMaxDelay = 30 * time.Second
+)
+
+// backoff - экспоненциальная задержка с jitter для переподключений
+// Задержка удваивается с каждой попыткой до max, фактическое ожидание выбирается
+// случайно в [delay/2, delay], чтобы соединения после общего сбоя не переподключались разом
+type backoff struct {
+	base    time.Duration
+	max     time.Duration
+	attempt int
+}
+
+func newBackoff(base, max time.Duration) *backoff {
+	return &backoff{base: base, max: max}
+}
+
+// next возвращает задержку перед очередной попыткой
+func (b *backoff) next() time.Duration {
+	delay := b.max
+	if b.attempt < 32 {
+		if d := b.base << b.attempt; d > 0 && d < b.max {
+			delay = d
+		}
+	}
+	b.attempt++
+
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// attempts возвращает номер последней попытки
+func (b *backoff) attempts() int {
+	return b.attempt
+}
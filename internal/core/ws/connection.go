@@ -0,0 +1,628 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"trader/internal/core/messaging"
+	"trader/internal/core/ratelimit"
+)
+
+const (
+	dialTimeout  = 10 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+var (
+	// errNotConnected - соединение переподключается, фреймы будут отправлены после восстановления
+	errNotConnected = errors.New("ws connection is reconnecting")
+	// errConnectionClosed - соединение закрыто локально и больше не используется
+	errConnectionClosed = errors.New("ws connection is closed")
+)
+
+// MessageHandler получает разобранные сообщения от всех соединений пула
+// Вызывается из горутины чтения соединения, поэтому не должен блокироваться надолго
+type MessageHandler func(msg *messaging.Message)
+
+// ConnectionState - состояние соединения пула
+type ConnectionState int
+
+const (
+	// StateConnected - соединение открыто, подписки активны
+	StateConnected ConnectionState = iota
+	// StateReconnecting - соединение потеряно, идут попытки переподключения
+	StateReconnecting
+	// StateClosed - соединение закрыто локально (отписка от всех пар или закрытие пула)
+	StateClosed
+)
+
+// String возвращает состояние для логов
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// connection - WebSocket соединение к бирже для пары (exchange, market type)
+// Переживает переподключения: при обрыве меняется только сокет ws, подписки и id сохраняются
+type connection struct {
+	id         string
+	exchangeID string
+	marketType string
+
+	// writeMu защищает ws, url и state: переподключение и отправка подписок
+	// выполняются под ним, поэтому подписка не может потеряться между обрывом и восстановлением
+	writeMu sync.Mutex
+	ws      *websocket.Conn
+	url     string
+	state   ConnectionState
+
+	// mu защищает pairs, eventID, lastFrameAt, pingSentAt, lastPongAt и rtt
+	mu sync.Mutex
+	// pairs - активные подписки соединения по паре
+	pairs map[string]*pairState
+	// eventID - event_id последней подписки, используется для корреляции ws_in
+	eventID string
+	// lastFrameAt - время последнего входящего фрейма любого типа (или открытия сокета)
+	lastFrameAt time.Time
+	// pingSentAt - время отправки ping без ответа, нулевое если ответа не ждем
+	pingSentAt time.Time
+	// lastPongAt - время последнего ответа на ping (или серверного ping), нулевое если не было
+	lastPongAt time.Time
+	// rtt - время ответа на последний ping, 0 пока не измерено
+	rtt time.Duration
+
+	// connLimit и exchangeLimit ограничивают частоту управляющих фреймов
+	// (RateLimits драйвера), nil = без ограничения
+	// exchangeLimit общий для всех соединений биржи
+	connLimit     *ratelimit.Limiter
+	exchangeLimit *ratelimit.Limiter
+
+	// ctx отменяется при локальном закрытии: прерывает ожидание в очереди лимитера и переподключение
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+}
+
+// queueStats - ожидание управляющих фреймов в очереди лимитеров
+type queueStats struct {
+	// depth - сколько фреймов уже ждали в очереди на момент постановки
+	depth int
+	// wait - суммарное время ожидания токенов
+	wait time.Duration
+}
+
+// waitMS возвращает время ожидания в миллисекундах для логов
+func (q queueStats) waitMS() float64 {
+	return float64(q.wait.Microseconds()) / 1000.0
+}
+
+// pairState - подписка на пару и статистика входящих сообщений по ней
+type pairState struct {
+	depth         int
+	requestID     string
+	subscribedAt  time.Time
+	lastMessageAt time.Time
+	messageCount  int64
+
+	// watchFrom - с какого момента отсчитывается тишина по паре (подписка, переподписка,
+	// переподключение), сообщения до него не учитываются
+	watchFrom time.Time
+	// staleResubscribes - сколько раз подряд пару переподписали из-за тишины
+	staleResubscribes int
+}
+
+// stalePair - пара, по которой дольше порога не было сообщений
+type stalePair struct {
+	pair      string
+	depth     int
+	requestID string
+	silence   time.Duration
+	// resubscribes - сколько раз пару уже переподписывали без результата
+	resubscribes int
+}
+
+// dialConnection открывает WS соединение к url
+// connLimit и exchangeLimit ограничивают управляющие фреймы соединения (nil = без ограничения)
+func dialConnection(dialer *websocket.Dialer, exchangeID, marketType, url string, connLimit, exchangeLimit *ratelimit.Limiter) (*connection, error) {
+	wsConn, err := dialSocket(dialer, url)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &connection{
+		id:            newEventID("ws-conn"),
+		exchangeID:    exchangeID,
+		marketType:    marketType,
+		url:           url,
+		ws:            wsConn,
+		state:         StateConnected,
+		pairs:         make(map[string]*pairState),
+		lastFrameAt:   time.Now().UTC(),
+		connLimit:     connLimit,
+		exchangeLimit: exchangeLimit,
+		ctx:           ctx,
+		cancel:        cancel,
+	}, nil
+}
+
+// dialSocket открывает сокет с таймаутом dialTimeout
+func dialSocket(dialer *websocket.Dialer, url string) (*websocket.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	wsConn, resp, err := dialer.DialContext(ctx, url, nil)
+	if resp != nil && resp.Body != nil {
+		_ = resp.Body.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", url, err)
+	}
+	return wsConn, nil
+}
+
+// writeFrames последовательно отправляет управляющие фреймы в соединение с учетом лимитов
+// Во время переподключения возвращает errNotConnected
+func (c *connection) writeFrames(frames [][]byte) (queueStats, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.state != StateConnected {
+		return queueStats{}, errNotConnected
+	}
+	return c.writeLocked(frames)
+}
+
+// writeReply отправляет ответ на служебный фрейм биржи (pong) вне очереди лимитеров:
+// биржи не учитывают ответы в лимитах, а задержка pong приводит к разрыву соединения
+func (c *connection) writeReply(frame []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.state != StateConnected {
+		return errNotConnected
+	}
+	return c.writeFrame(frame)
+}
+
+// writePing отправляет keepalive ping вне очереди лимитеров
+// frame = nil - WS ping control frame
+func (c *connection) writePing(frame []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.state != StateConnected {
+		return errNotConnected
+	}
+	if frame == nil {
+		return c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
+	}
+	return c.writeFrame(frame)
+}
+
+// writeLocked отправляет фреймы, перед каждым дожидаясь токена лимитера соединения и биржи
+// Ожидание прерывается локальным закрытием соединения
+func (c *connection) writeLocked(frames [][]byte) (queueStats, error) {
+	stats := queueStats{depth: c.connLimit.QueueDepth() + c.exchangeLimit.QueueDepth()}
+	for _, frame := range frames {
+		for _, limiter := range []*ratelimit.Limiter{c.connLimit, c.exchangeLimit} {
+			wait, err := limiter.Wait(c.ctx)
+			stats.wait += wait
+			if err != nil {
+				return stats, errConnectionClosed
+			}
+		}
+		if err := c.writeFrame(frame); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+func (c *connection) writeFrame(frame []byte) error {
+	if err := c.ws.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	if err := c.ws.WriteMessage(websocket.TextMessage, frame); err != nil {
+		return fmt.Errorf("write frame: %w", err)
+	}
+	return nil
+}
+
+// subscribe регистрирует пары и отправляет фреймы подписки
+// Если соединение переподключается, пары только запоминаются (queued = true):
+// подписка будет отправлена вместе с остальными после восстановления
+// stats - ожидание фреймов в очереди лимитеров
+func (c *connection) subscribe(pairs []string, depth int, eventID, requestID string, frames [][]byte) (queued bool, stats queueStats, err error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.state == StateClosed {
+		return false, queueStats{}, errConnectionClosed
+	}
+
+	added := c.addPairs(pairs, depth, eventID, requestID)
+	if c.state == StateReconnecting {
+		return true, queueStats{}, nil
+	}
+	if stats, err = c.writeLocked(frames); err != nil {
+		// Пары, подписанные раньше, остаются: они уже получают данные на этом соединении
+		c.removePairs(added, eventID)
+		return false, stats, err
+	}
+	return false, stats, nil
+}
+
+// addPairs регистрирует пары как подписанные на этом соединении
+// Для уже подписанной пары меняется только глубина, время подписки и статистика сохраняются
+// (отписку от потоков прежней глубины отправляет Pool, см. depthChanges)
+// requestID запоминается для корреляции событий watchdog с исходным запросом подписки
+// Возвращает пары, которых раньше не было на соединении
+func (c *connection) addPairs(pairs []string, depth int, eventID, requestID string) []string {
+	now := time.Now().UTC()
+	var added []string
+
+	c.mu.Lock()
+	for _, pair := range pairs {
+		if state, ok := c.pairs[pair]; ok {
+			state.depth = depth
+			if requestID != "" {
+				state.requestID = requestID
+			}
+			continue
+		}
+		c.pairs[pair] = &pairState{depth: depth, requestID: requestID, subscribedAt: now, watchFrom: now}
+		added = append(added, pair)
+	}
+	c.eventID = eventID
+	c.mu.Unlock()
+
+	return added
+}
+
+// depthChanges возвращает пары из pairs, уже подписанные на соединении с другой глубиной,
+// сгруппированные по прежней глубине
+func (c *connection) depthChanges(pairs []string, depth int) map[int][]string {
+	changed := make(map[int][]string)
+
+	c.mu.Lock()
+	for _, pair := range pairs {
+		if state, ok := c.pairs[pair]; ok && state.depth != depth {
+			changed[state.depth] = append(changed[state.depth], pair)
+		}
+	}
+	c.mu.Unlock()
+
+	return changed
+}
+
+// removePairs удаляет пары и возвращает их, сгруппированные по глубине подписки
+// Пары которых нет на соединении пропускаются
+func (c *connection) removePairs(pairs []string, eventID string) map[int][]string {
+	byDepth := make(map[int][]string)
+
+	c.mu.Lock()
+	for _, pair := range pairs {
+		state, ok := c.pairs[pair]
+		if !ok {
+			continue
+		}
+		byDepth[state.depth] = append(byDepth[state.depth], pair)
+		delete(c.pairs, pair)
+	}
+	c.eventID = eventID
+	c.mu.Unlock()
+
+	return byDepth
+}
+
+// pairsByDepth возвращает все активные подписки, сгруппированные по глубине
+func (c *connection) pairsByDepth() map[int][]string {
+	byDepth := make(map[int][]string)
+
+	c.mu.Lock()
+	for pair, state := range c.pairs {
+		byDepth[state.depth] = append(byDepth[state.depth], pair)
+	}
+	c.mu.Unlock()
+
+	return byDepth
+}
+
+// hasPair сообщает подписана ли пара на этом соединении
+func (c *connection) hasPair(pair string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.pairs[pair]
+	return ok
+}
+
+// pairInfo возвращает глубину и request_id подписки пары
+func (c *connection) pairInfo(pair string) (depth int, requestID string, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state, ok := c.pairs[pair]
+	if !ok {
+		return 0, "", false
+	}
+	return state.depth, state.requestID, true
+}
+
+// pairCount возвращает количество активных подписок на соединении
+func (c *connection) pairCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pairs)
+}
+
+// recordMessages учитывает входящие сообщения в статистике подписок
+// Сообщения по парам, которых нет на соединении (например, пришедшие после отписки), пропускаются
+func (c *connection) recordMessages(messages []*messaging.Message, receivedAt time.Time) {
+	c.mu.Lock()
+	for _, msg := range messages {
+		if state, ok := c.pairs[msg.Pair]; ok {
+			state.lastMessageAt = receivedAt
+			state.messageCount++
+			state.staleResubscribes = 0
+		}
+	}
+	c.mu.Unlock()
+}
+
+// recordFrame отмечает получение любого фрейма (в том числе служебного)
+func (c *connection) recordFrame(receivedAt time.Time) {
+	c.mu.Lock()
+	c.lastFrameAt = receivedAt
+	c.mu.Unlock()
+}
+
+// stalePairs возвращает пары без сообщений дольше threshold
+// и время тишины всего соединения (с последнего входящего фрейма)
+func (c *connection) stalePairs(now time.Time, threshold time.Duration) ([]stalePair, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var stale []stalePair
+	for pair, state := range c.pairs {
+		since := state.watchFrom
+		if state.lastMessageAt.After(since) {
+			since = state.lastMessageAt
+		}
+		if silence := now.Sub(since); silence > threshold {
+			stale = append(stale, stalePair{
+				pair:         pair,
+				depth:        state.depth,
+				requestID:    state.requestID,
+				silence:      silence,
+				resubscribes: state.staleResubscribes,
+			})
+		}
+	}
+	return stale, now.Sub(c.lastFrameAt)
+}
+
+// pingDue отмечает отправку ping
+// Если предыдущий ping еще без ответа, новый не отправляется и возвращается время ожидания ответа
+func (c *connection) pingDue(now time.Time) (pending time.Duration, send bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.pingSentAt.IsZero() {
+		return now.Sub(c.pingSentAt), false
+	}
+	c.pingSentAt = now
+	return 0, true
+}
+
+// pongReceived закрывает ожидание ping и возвращает измеренный RTT
+// false если ping не отправлялся (незапрошенный pong)
+func (c *connection) pongReceived(now time.Time) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pingSentAt.IsZero() {
+		return 0, false
+	}
+	c.rtt = now.Sub(c.pingSentAt)
+	c.pingSentAt = time.Time{}
+	c.lastPongAt = now
+	return c.rtt, true
+}
+
+// serverPingReceived фиксирует RTT, оцененный по серверному ping
+func (c *connection) serverPingReceived(now time.Time, rtt time.Duration) {
+	c.mu.Lock()
+	c.rtt = rtt
+	c.lastPongAt = now
+	c.mu.Unlock()
+}
+
+// currentRTT возвращает RTT последнего ping, 0 пока не измерено
+func (c *connection) currentRTT() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rtt
+}
+
+// markResubscribed перезапускает отсчет тишины по паре после переподписки
+func (c *connection) markResubscribed(pair, eventID string) {
+	now := time.Now().UTC()
+
+	c.mu.Lock()
+	if state, ok := c.pairs[pair]; ok {
+		state.watchFrom = now
+		state.staleResubscribes++
+	}
+	c.eventID = eventID
+	c.mu.Unlock()
+}
+
+// resetWatch перезапускает отсчет тишины по всем парам и соединению (новый сокет)
+func (c *connection) resetWatch(now time.Time) {
+	c.mu.Lock()
+	for _, state := range c.pairs {
+		state.watchFrom = now
+		state.staleResubscribes = 0
+	}
+	c.lastFrameAt = now
+	c.pingSentAt = time.Time{}
+	c.mu.Unlock()
+}
+
+// subscriptions возвращает копию состояния подписок соединения
+func (c *connection) subscriptions() []SubscriptionInfo {
+	state := c.currentState()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rtt, lastPongAt := c.rtt, c.lastPongAt
+	infos := make([]SubscriptionInfo, 0, len(c.pairs))
+	for pair, ps := range c.pairs {
+		infos = append(infos, SubscriptionInfo{
+			ExchangeID:      c.exchangeID,
+			MarketType:      c.marketType,
+			Pair:            pair,
+			Depth:           ps.depth,
+			ConnectionID:    c.id,
+			ConnectionState: state,
+			SubscribedAt:    ps.subscribedAt,
+			LastMessageAt:   ps.lastMessageAt,
+			MessageCount:    ps.messageCount,
+			RTT:             rtt,
+			LastPongAt:      lastPongAt,
+		})
+	}
+	return infos
+}
+
+// lastEventID возвращает event_id последней операции подписки
+func (c *connection) lastEventID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.eventID
+}
+
+// currentState возвращает состояние соединения
+func (c *connection) currentState() ConnectionState {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.state
+}
+
+// currentURL возвращает адрес текущего сокета (может меняться при переподключении)
+func (c *connection) currentURL() string {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.url
+}
+
+// markReconnecting переводит соединение в режим переподключения
+// Возвращает false если соединение уже закрыто локально
+func (c *connection) markReconnecting() bool {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.state == StateClosed {
+		return false
+	}
+	c.state = StateReconnecting
+	_ = c.ws.Close()
+	return true
+}
+
+// resume подставляет новый сокет и повторяет в нем все активные подписки
+// build строит фреймы подписки по парам, сгруппированным по глубине
+// Все выполняется под writeMu, поэтому подписки, поставленные в очередь во время
+// переподключения, попадают в повтор и не отправляются дважды
+// Повторные подписки тоже проходят через лимитеры: после массового обрыва
+// соединения биржи не должны превысить лимит на управляющие фреймы
+func (c *connection) resume(wsConn *websocket.Conn, url string, build func(byDepth map[int][]string) ([][]byte, error)) (queueStats, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.state == StateClosed {
+		_ = wsConn.Close()
+		return queueStats{}, errConnectionClosed
+	}
+
+	frames, err := build(c.pairsByDepth())
+	if err != nil {
+		_ = wsConn.Close()
+		return queueStats{}, err
+	}
+
+	c.ws = wsConn
+	c.url = url
+	stats, err := c.writeLocked(frames)
+	if err != nil {
+		_ = wsConn.Close()
+		return stats, err
+	}
+	c.state = StateConnected
+	c.resetWatch(time.Now().UTC())
+	return stats, nil
+}
+
+// recycle закрывает текущий сокет, не закрывая соединение:
+// readLoop получит ошибку чтения и переподключится с повтором подписок
+// eventID становится event_id повторных подписок после переподключения
+// Возвращает false если соединение сейчас не подключено
+func (c *connection) recycle(eventID string) bool {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.state != StateConnected {
+		return false
+	}
+	c.mu.Lock()
+	c.eventID = eventID
+	c.mu.Unlock()
+	_ = c.ws.Close()
+	return true
+}
+
+// close закрывает соединение, повторные вызовы игнорируются
+func (c *connection) close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.cancel()
+
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+
+		if c.state == StateConnected {
+			_ = c.ws.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(writeTimeout),
+			)
+		}
+		c.state = StateClosed
+		err = c.ws.Close()
+	})
+	return err
+}
+
+// isClosed сообщает было ли соединение закрыто локально
+func (c *connection) isClosed() bool {
+	select {
+	case <-c.ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,95 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"trader/internal/core/exchange"
+)
+
+// keepaliveLoop отправляет ping драйвера каждые PingInterval до закрытия соединения
+// Если ответ на предыдущий ping не пришел за интервал, соединение пересоздается
+func (p *Pool) keepaliveLoop(conn *connection, pinger exchange.Pinger) {
+	interval := pinger.PingInterval(conn.marketType)
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-conn.ctx.Done():
+			return
+		case now := <-ticker.C:
+			if conn.currentState() != StateConnected {
+				continue
+			}
+
+			pending, send := conn.pingDue(now.UTC())
+			if !send {
+				if pending >= interval && conn.recycle(conn.lastEventID()) {
+					p.log.Warn("ws pong timeout",
+						"connection_id", conn.id,
+						"exchange_id", conn.exchangeID,
+						"market_type", conn.marketType,
+						"pending_ms", pending.Milliseconds(),
+					)
+				}
+				continue
+			}
+
+			if err := conn.writePing(pinger.PingFrame(conn.marketType)); err != nil {
+				p.log.Warn("ws ping failed",
+					"connection_id", conn.id,
+					"exchange_id", conn.exchangeID,
+					"market_type", conn.marketType,
+					"error", err,
+				)
+			}
+		}
+	}
+}
+
+// watchPongs подключает обработчик pong control frame к сокету соединения
+// Обработчик вызывается из ReadMessage, поэтому ставится на каждый новый сокет
+func (p *Pool) watchPongs(conn *connection, socket *websocket.Conn) {
+	socket.SetPongHandler(func(string) error {
+		now := time.Now().UTC()
+		conn.recordFrame(now)
+		p.handlePong(conn, now, 0)
+		return nil
+	})
+}
+
+// handlePong фиксирует RTT соединения и логирует pong в ws_in
+func (p *Pool) handlePong(conn *connection, receivedAt time.Time, payloadSize int) {
+	if _, ok := conn.pongReceived(receivedAt); !ok {
+		return
+	}
+	p.logInbound(conn, "pong", conn.lastEventID(), "", payloadSize, "ok")
+}
+
+// handleServerPing оценивает RTT по метке времени серверного ping и логирует ping в ws_in
+// Метка переводится в локальное время по оценке часов биржи, задержка доставки ping
+// считается половиной RTT
+func (p *Pool) handleServerPing(conn *connection, receivedAt time.Time, sentAt int64, payloadSize int) {
+	var offset time.Duration
+	if estimate, ok := p.clock.Estimate(conn.exchangeID, conn.marketType); ok {
+		offset = estimate.OffsetAt(receivedAt)
+	}
+	delay := receivedAt.Sub(time.UnixMicro(sentAt).Add(-offset))
+	conn.serverPingReceived(receivedAt, 2*max(delay, 0))
+	p.logInbound(conn, "ping", conn.lastEventID(), "", payloadSize, "ok")
+}
+
+// rttBreakdown возвращает RTT соединения для детализации latency_ms, nil если RTT не измерен
+func rttBreakdown(conn *connection) map[string]float64 {
+	rtt := conn.currentRTT()
+	if rtt <= 0 {
+		return nil
+	}
+	return map[string]float64{"rtt": float64(rtt.Microseconds()) / 1000.0}
+}
@@ -0,0 +1,95 @@
+package ws
+
+import (
+	"fmt"
+	"time"
+
+	"trader/internal/core/exchange"
+)
+
+// Driver возвращает драйвер биржи, которым пользуется пул
+// Через него потребители потока (менеджер книг) обращаются к REST API биржи
+// с теми же лимитами запросов, что и пул
+func (p *Pool) Driver(exchangeID string) (exchange.ExchangeDriver, error) {
+	return p.driver(exchangeID)
+}
+
+// Resubscribe переподписывает пару на ее соединении, чтобы биржа прислала новый снимок книги
+// Нужен биржам без REST снимка: при разрыве последовательности книгу можно
+// восстановить только новой подпиской. Книга пары инвалидируется
+func (p *Pool) Resubscribe(exchangeID, marketType, pair string) error {
+	start := time.Now()
+	url := fmt.Sprintf("ws://%s/%s", exchangeID, marketType)
+
+	driver, err := p.driver(exchangeID)
+	if err != nil {
+		p.logOutRequest("WS_RESUBSCRIBE", "/resubscribe", url, 400, time.Since(start), "", err)
+		return err
+	}
+
+	p.opMu.Lock()
+	defer p.opMu.Unlock()
+
+	key := connectionKey(exchangeID, marketType)
+	conn := shardOf(p.shards(key), pair)
+	if conn == nil {
+		err := fmt.Errorf("pair %s is not subscribed on %s", pair, key)
+		p.logOutRequest("WS_RESUBSCRIBE", "/resubscribe", url, 404, time.Since(start), "", err)
+		return err
+	}
+	url = conn.currentURL()
+
+	depth, requestID, ok := conn.pairInfo(pair)
+	if !ok {
+		err := fmt.Errorf("pair %s is not subscribed on %s", pair, key)
+		p.logOutRequest("WS_RESUBSCRIBE", "/resubscribe", url, 404, time.Since(start), requestID, err)
+		return err
+	}
+
+	eventID := newEventID("ws-resub")
+	p.rememberCorrelation(eventID, requestID)
+
+	frames, err := resubscribeFrames(driver, marketType, pair, depth)
+	if err != nil {
+		p.logOutRequest("WS_RESUBSCRIBE", "/resubscribe", url, 400, time.Since(start), requestID, err)
+		return err
+	}
+
+	p.invalidatePairs(exchangeID, marketType, []string{pair})
+
+	queue, err := conn.writeFrames(frames)
+	if err != nil {
+		// Сбой записи означает обрыв: подписка будет повторена при переподключении
+		p.logOutRequest("WS_RESUBSCRIBE", "/resubscribe", url, 503, time.Since(start), requestID, err)
+		return err
+	}
+
+	p.wsOutLog.Info(
+		"ws resubscribe",
+		"event_id", eventID,
+		"request_id", requestID,
+		"connection_id", conn.id,
+		"exchange_id", exchangeID,
+		"market_type", marketType,
+		"pairs", pair,
+		"depth", depth,
+		"frames", len(frames),
+		"queue_depth", queue.depth,
+		"queue_wait_ms", queue.waitMS(),
+	)
+	p.logOutRequest("WS_RESUBSCRIBE", "/resubscribe", url, 200, time.Since(start), requestID, nil)
+	return nil
+}
+
+// resubscribeFrames собирает фреймы отписки и повторной подписки одной пары
+func resubscribeFrames(driver exchange.ExchangeDriver, marketType, pair string, depth int) ([][]byte, error) {
+	unsubscribe, err := driver.CreateUnsubscribeMessage(marketType, []string{pair}, depth)
+	if err != nil {
+		return nil, err
+	}
+	subscribe, err := driver.CreateSubscribeMessage(marketType, []string{pair}, depth)
+	if err != nil {
+		return nil, err
+	}
+	return append(unsubscribe, subscribe...), nil
+}
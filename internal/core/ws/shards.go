@@ -0,0 +1,189 @@
+package ws
+
+import (
+	"sort"
+
+	"trader/internal/core/exchange"
+)
+
+// shardAssignment - пары одной подписки, отправляемые в конкретное соединение
+type shardAssignment struct {
+	conn  *connection
+	pairs []string
+}
+
+// maxPairsPerConnection возвращает лимит пар на соединение из ConnectionLimits драйвера
+// 0 = без ограничения: все пары (exchange, market) живут в одном соединении
+func maxPairsPerConnection(driver exchange.ExchangeDriver, marketType string) int {
+	limiter, ok := driver.(exchange.ConnectionLimiter)
+	if !ok {
+		return 0
+	}
+	return limiter.ConnectionLimits(marketType).MaxPairs()
+}
+
+// assignShards распределяет пары по соединениям (exchange, market)
+// Уже подписанная пара остается на своем соединении (меняется только глубина),
+// новые пары занимают свободные места в существующих соединениях по порядку,
+// остаток уходит в новые соединения по maxPairs пар
+// Вызывается под opMu
+func (p *Pool) assignShards(exchangeID, marketType string, driver exchange.ExchangeDriver, pairs []string) ([]shardAssignment, error) {
+	key := connectionKey(exchangeID, marketType)
+	maxPairs := maxPairsPerConnection(driver, marketType)
+	shards := p.shards(key)
+
+	var assignments []shardAssignment
+	index := make(map[*connection]int)
+	assign := func(conn *connection, pair string) {
+		i, ok := index[conn]
+		if !ok {
+			i = len(assignments)
+			index[conn] = i
+			assignments = append(assignments, shardAssignment{conn: conn})
+		}
+		assignments[i].pairs = append(assignments[i].pairs, pair)
+	}
+
+	seen := make(map[string]bool, len(pairs))
+	var fresh []string
+	for _, pair := range pairs {
+		if seen[pair] {
+			continue
+		}
+		seen[pair] = true
+
+		if conn := shardOf(shards, pair); conn != nil {
+			assign(conn, pair)
+			continue
+		}
+		fresh = append(fresh, pair)
+	}
+
+	for _, conn := range shards {
+		if len(fresh) == 0 {
+			break
+		}
+		if conn.isClosed() {
+			continue
+		}
+		free := len(fresh)
+		if maxPairs > 0 {
+			free = min(free, maxPairs-conn.pairCount())
+		}
+		for _, pair := range fresh[:max(free, 0)] {
+			assign(conn, pair)
+		}
+		fresh = fresh[max(free, 0):]
+	}
+
+	for len(fresh) > 0 {
+		size := len(fresh)
+		if maxPairs > 0 {
+			size = min(size, maxPairs)
+		}
+		conn, err := p.dialShard(exchangeID, marketType, driver)
+		if err != nil {
+			return nil, err
+		}
+		for _, pair := range fresh[:size] {
+			assign(conn, pair)
+		}
+		fresh = fresh[size:]
+	}
+
+	return assignments, nil
+}
+
+// shardOf возвращает соединение, на котором уже подписана пара
+func shardOf(shards []*connection, pair string) *connection {
+	for _, conn := range shards {
+		if conn.hasPair(pair) {
+			return conn
+		}
+	}
+	return nil
+}
+
+// rebalance уплотняет подписки после отписки: пока соединений больше, чем нужно
+// для оставшихся пар, наименее загруженное соединение закрывается, а его пары
+// переподписываются на остальные. Для перенесенных пар рассылается TypeBookInvalidated,
+// книга восстановится по снимку из нового соединения
+// Вызывается под opMu
+func (p *Pool) rebalance(exchangeID, marketType string, driver exchange.ExchangeDriver, eventID string) {
+	maxPairs := maxPairsPerConnection(driver, marketType)
+	if maxPairs == 0 {
+		return
+	}
+
+	key := connectionKey(exchangeID, marketType)
+	for {
+		shards := p.shards(key)
+		total := 0
+		for _, conn := range shards {
+			total += conn.pairCount()
+		}
+		needed := (total + maxPairs - 1) / maxPairs
+		if len(shards) <= max(needed, 1) {
+			return
+		}
+
+		sort.SliceStable(shards, func(i, j int) bool {
+			return shards[i].pairCount() < shards[j].pairCount()
+		})
+		donor, targets := shards[0], shards[1:]
+
+		moved := donor.pairsByDepth()
+		p.dropConnection(key, donor)
+		_ = donor.close()
+
+		var movedPairs []string
+		for _, pairs := range moved {
+			movedPairs = append(movedPairs, pairs...)
+		}
+		p.invalidatePairs(exchangeID, marketType, movedPairs)
+
+		p.log.Info("ws rebalance",
+			"event_id", eventID,
+			"connection_id", donor.id,
+			"exchange_id", exchangeID,
+			"market_type", marketType,
+			"moved_pairs", len(movedPairs),
+			"shards", len(targets),
+		)
+
+		for depth, pairs := range moved {
+			for _, conn := range targets {
+				if len(pairs) == 0 {
+					break
+				}
+				free := min(len(pairs), maxPairs-conn.pairCount())
+				if free <= 0 {
+					continue
+				}
+				p.movePairs(conn, driver, pairs[:free], depth, eventID)
+				pairs = pairs[free:]
+			}
+		}
+	}
+}
+
+// movePairs подписывает перенесенные пары на соединении conn
+// Если запись не удалась, пары остаются зарегистрированными на соединении:
+// сбой записи означает обрыв, и подписка будет повторена при переподключении
+func (p *Pool) movePairs(conn *connection, driver exchange.ExchangeDriver, pairs []string, depth int, eventID string) {
+	frames, err := driver.CreateSubscribeMessage(conn.marketType, pairs, depth)
+	if err == nil {
+		_, _, err = conn.subscribe(pairs, depth, eventID, "", frames)
+	}
+	if err != nil {
+		conn.addPairs(pairs, depth, eventID, "")
+		p.log.Warn("ws rebalance subscribe failed",
+			"event_id", eventID,
+			"connection_id", conn.id,
+			"exchange_id", conn.exchangeID,
+			"market_type", conn.marketType,
+			"pairs", len(pairs),
+			"error", err,
+		)
+	}
+}
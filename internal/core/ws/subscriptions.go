@@ -0,0 +1,79 @@
+package ws
+
+import (
+	"sort"
+	"time"
+
+	"trader/internal/core/clock"
+)
+
+// SubscriptionInfo - состояние одной живой подписки пула
+// Используется для диагностики: подписана ли пара, на каком соединении и идут ли по ней данные
+type SubscriptionInfo struct {
+	ExchangeID string
+	MarketType string
+	Pair       string
+	Depth      int
+
+	// ConnectionID - id соединения (шарда), на котором подписана пара
+	ConnectionID string
+	// ConnectionState - состояние соединения (connected, reconnecting)
+	ConnectionState ConnectionState
+
+	// SubscribedAt - время первой подписки на пару (UTC)
+	SubscribedAt time.Time
+	// LastMessageAt - время получения последнего сообщения по паре, нулевое если сообщений не было
+	LastMessageAt time.Time
+	// MessageCount - сколько разобранных сообщений (книга, сделки) пришло по паре
+	MessageCount int64
+	// RTT - время ответа на последний keepalive ping соединения, 0 если не измерено
+	RTT time.Duration
+	// LastPongAt - время последнего ответа на ping или серверного ping, нулевое если не было
+	LastPongAt time.Time
+}
+
+// ClockEstimate возвращает оценку расхождения часов биржи с локальными
+// false если биржа не отдает серверное время или синхронизаций еще не было
+func (p *Pool) ClockEstimate(exchangeID, marketType string) (clock.Estimate, bool) {
+	return p.clock.Estimate(exchangeID, marketType)
+}
+
+// GetSubscriptions возвращает живые подписки (exchange, market), отсортированные по паре
+func (p *Pool) GetSubscriptions(exchangeID, marketType string) []SubscriptionInfo {
+	var infos []SubscriptionInfo
+	for _, conn := range p.shards(connectionKey(exchangeID, marketType)) {
+		infos = append(infos, conn.subscriptions()...)
+	}
+	sortSubscriptions(infos)
+	return infos
+}
+
+// GetSnapshot возвращает все живые подписки пула, отсортированные по бирже, рынку и паре
+func (p *Pool) GetSnapshot() []SubscriptionInfo {
+	p.connMu.RLock()
+	var conns []*connection
+	for _, shards := range p.connections {
+		conns = append(conns, shards...)
+	}
+	p.connMu.RUnlock()
+
+	var infos []SubscriptionInfo
+	for _, conn := range conns {
+		infos = append(infos, conn.subscriptions()...)
+	}
+	sortSubscriptions(infos)
+	return infos
+}
+
+func sortSubscriptions(infos []SubscriptionInfo) {
+	sort.Slice(infos, func(i, j int) bool {
+		a, b := infos[i], infos[j]
+		if a.ExchangeID != b.ExchangeID {
+			return a.ExchangeID < b.ExchangeID
+		}
+		if a.MarketType != b.MarketType {
+			return a.MarketType < b.MarketType
+		}
+		return a.Pair < b.Pair
+	})
+}
@@ -0,0 +1,173 @@
+package ws
+
+import (
+	"time"
+
+	"trader/internal/core/exchange"
+)
+
+const (
+	// DefaultStaleThreshold - порог тишины потока по умолчанию
+	DefaultStaleThreshold = 60 * time.Second
+	// staleCheckInterval - как часто watchdog проверяет подписки
+	staleCheckInterval = 5 * time.Second
+	// maxStaleResubscribes - сколько раз подряд пара переподписывается из-за тишины,
+	// после чего соединение пересоздается целиком
+	maxStaleResubscribes = 2
+)
+
+// SetStaleThreshold задает порог тишины для watchdog потоков
+// Биржи иногда держат TCP соединение живым, но перестают присылать данные по символу:
+// если по паре нет сообщений дольше threshold, пара переподписывается,
+// если молчит все соединение (или переподписка не помогла) - соединение пересоздается
+// threshold <= 0 выключает watchdog
+func (p *Pool) SetStaleThreshold(threshold time.Duration) {
+	p.staleThreshold.Store(int64(max(threshold, 0)))
+}
+
+// staleWatchLoop периодически проверяет подписки на тишину до закрытия пула
+func (p *Pool) staleWatchLoop() {
+	ticker := time.NewTicker(staleCheckInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		p.connMu.RLock()
+		closed := p.closed
+		p.connMu.RUnlock()
+		if closed {
+			return
+		}
+		p.checkStale(now.UTC())
+	}
+}
+
+// checkStale проверяет все подключенные соединения пула
+func (p *Pool) checkStale(now time.Time) {
+	threshold := time.Duration(p.staleThreshold.Load())
+	if threshold <= 0 {
+		return
+	}
+
+	// opMu: переподписка не должна пересекаться с Subscribe/Unsubscribe и перебалансировкой
+	p.opMu.Lock()
+	defer p.opMu.Unlock()
+
+	p.connMu.RLock()
+	var conns []*connection
+	for _, shards := range p.connections {
+		conns = append(conns, shards...)
+	}
+	drivers := make(map[string]exchange.ExchangeDriver, len(p.drivers))
+	for id, driver := range p.drivers {
+		drivers[id] = driver
+	}
+	p.connMu.RUnlock()
+
+	for _, conn := range conns {
+		if conn.currentState() != StateConnected {
+			continue
+		}
+		stale, connSilence := conn.stalePairs(now, threshold)
+		if len(stale) == 0 {
+			continue
+		}
+
+		if connSilence > threshold {
+			p.recycleStale(conn, len(stale), stale[0].requestID, connSilence, "connection_silent")
+			continue
+		}
+		if exhausted := exhaustedPair(stale); exhausted != nil {
+			p.recycleStale(conn, len(stale), exhausted.requestID, exhausted.silence, "resubscribe_failed")
+			continue
+		}
+		for _, pair := range stale {
+			p.resubscribeStale(conn, drivers[conn.exchangeID], pair)
+		}
+	}
+}
+
+// exhaustedPair возвращает пару, которую уже переподписывали maxStaleResubscribes раз
+func exhaustedPair(stale []stalePair) *stalePair {
+	for i := range stale {
+		if stale[i].resubscribes >= maxStaleResubscribes {
+			return &stale[i]
+		}
+	}
+	return nil
+}
+
+// resubscribeStale переподписывает молчащую пару на том же соединении
+// Книга пары инвалидируется: после подписки биржа пришлет новый снимок
+func (p *Pool) resubscribeStale(conn *connection, driver exchange.ExchangeDriver, pair stalePair) {
+	eventID := newEventID("ws-stale")
+	p.rememberCorrelation(eventID, pair.requestID)
+
+	logFields := []any{
+		"event_id", eventID,
+		"request_id", pair.requestID,
+		"connection_id", conn.id,
+		"exchange_id", conn.exchangeID,
+		"market_type", conn.marketType,
+		"pair", pair.pair,
+		"silence_ms", pair.silence.Milliseconds(),
+		"action", "resubscribe",
+	}
+	p.log.Warn("ws stale stream", logFields...)
+
+	if driver == nil {
+		return
+	}
+	frames, err := resubscribeFrames(driver, conn.marketType, pair.pair, pair.depth)
+	if err != nil {
+		p.log.Warn("ws stale resubscribe failed", append(logFields, "error", err)...)
+		return
+	}
+
+	p.invalidatePairs(conn.exchangeID, conn.marketType, []string{pair.pair})
+	conn.markResubscribed(pair.pair, eventID)
+
+	queue, err := conn.writeFrames(frames)
+	if err != nil {
+		// Сбой записи означает обрыв: подписка будет повторена при переподключении
+		p.log.Warn("ws stale resubscribe failed", append(logFields, "error", err)...)
+		return
+	}
+
+	p.wsOutLog.Info(
+		"ws stale resubscribe",
+		"event_id", eventID,
+		"request_id", pair.requestID,
+		"connection_id", conn.id,
+		"exchange_id", conn.exchangeID,
+		"market_type", conn.marketType,
+		"pairs", pair.pair,
+		"depth", pair.depth,
+		"frames", len(frames),
+		"queue_depth", queue.depth,
+		"queue_wait_ms", queue.waitMS(),
+	)
+}
+
+// recycleStale пересоздает молчащее соединение: закрывает сокет,
+// дальше readLoop переподключается и повторяет все подписки
+// requestID - request_id подписки пары, из-за которой соединение пересоздается;
+// повторные подписки после переподключения идут с тем же event_id
+func (p *Pool) recycleStale(conn *connection, stalePairs int, requestID string, silence time.Duration, reason string) {
+	eventID := newEventID("ws-stale")
+	if !conn.recycle(eventID) {
+		return
+	}
+	p.rememberCorrelation(eventID, requestID)
+
+	p.log.Warn("ws stale stream",
+		"event_id", eventID,
+		"request_id", requestID,
+		"connection_id", conn.id,
+		"exchange_id", conn.exchangeID,
+		"market_type", conn.marketType,
+		"stale_pairs", stalePairs,
+		"silence_ms", silence.Milliseconds(),
+		"action", "reconnect",
+		"reason", reason,
+	)
+}
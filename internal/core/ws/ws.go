@@ -4,12 +4,20 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/gorilla/websocket"
+
+	"trader/internal/core/clock"
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+	"trader/internal/core/ratelimit"
 	"trader/internal/logger"
 )
 
@@ -23,13 +31,39 @@ type correlationEntry struct {
 	createdAt time.Time
 }
 
+// ErrPoolClosed - операция над уже закрытым пулом
+var ErrPoolClosed = errors.New("ws pool is closed")
+
 // Pool управляет пулом WebSocket соединений
+// На каждую пару (exchange, market type) держится одно или несколько соединений (шардов):
+// если драйвер объявляет ConnectionLimits, пары распределяются так, чтобы не превысить лимит
 type Pool struct {
 	mu               sync.RWMutex
 	eventToRequestID map[string]correlationEntry
 	outReqLog        *slog.Logger
 	wsInLog          *slog.Logger
 	wsOutLog         *slog.Logger
+	log              *slog.Logger
+
+	// opMu сериализует Subscribe/Unsubscribe: распределение пар по шардам
+	// должно видеть согласованное число подписок на каждом соединении
+	opMu sync.Mutex
+
+	// connMu защищает drivers, limiters, connections, handlers и closed
+	connMu      sync.RWMutex
+	drivers     map[string]exchange.ExchangeDriver // key = exchangeID
+	limiters    map[string]*ratelimit.Limiter      // key = exchangeID, лимит управляющих фреймов на биржу
+	connections map[string][]*connection           // key = "exchange:market"
+	handlers    []MessageHandler
+	closed      bool
+
+	// staleThreshold - порог тишины потока для watchdog, 0 = watchdog выключен
+	staleThreshold atomic.Int64
+
+	// clock - оценка часов бирж, по ней заполняются ReceivedAt и ClockOffset сообщений
+	clock *clock.Tracker
+
+	dialer *websocket.Dialer
 }
 
 // NewPool создает новый WS pool с логгерами ws_in/ws_out
@@ -39,12 +73,60 @@ func NewPool() *Pool {
 		outReqLog:        logger.GetOutRequest("ws"),
 		wsInLog:          logger.GetWSIn("ws_in"),
 		wsOutLog:         logger.GetWSOut("ws_out"),
+		log:              logger.Get("ws"),
+		drivers:          make(map[string]exchange.ExchangeDriver),
+		limiters:         make(map[string]*ratelimit.Limiter),
+		clock:            clock.NewTracker(clock.DefaultSyncInterval),
+		connections:      make(map[string][]*connection),
+		dialer: &websocket.Dialer{
+			HandshakeTimeout: dialTimeout,
+			Proxy:            websocket.DefaultDialer.Proxy,
+		},
 	}
 
+	pool.staleThreshold.Store(int64(DefaultStaleThreshold))
+
 	go pool.correlationCleanupLoop()
+	go pool.staleWatchLoop()
 	return pool
 }
 
+// AddHandler добавляет получателя разобранных входящих сообщений
+func (p *Pool) AddHandler(handler MessageHandler) {
+	if handler == nil {
+		return
+	}
+	p.connMu.Lock()
+	p.handlers = append(p.handlers, handler)
+	p.connMu.Unlock()
+}
+
+// Close закрывает все соединения пула
+// После закрытия Subscribe возвращает ErrPoolClosed
+func (p *Pool) Close() error {
+	p.connMu.Lock()
+	if p.closed {
+		p.connMu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.clock.Close()
+	var conns []*connection
+	for key, shards := range p.connections {
+		conns = append(conns, shards...)
+		delete(p.connections, key)
+	}
+	p.connMu.Unlock()
+
+	var lastErr error
+	for _, conn := range conns {
+		if err := conn.close(); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
 // Subscribe подписывает на пары
 func (p *Pool) Subscribe(exchangeID, marketType string, pairs []string, depth int) error {
 	_, err := p.SubscribeWithRequestID(exchangeID, marketType, pairs, depth, "")
@@ -52,6 +134,10 @@ func (p *Pool) Subscribe(exchangeID, marketType string, pairs []string, depth in
 }
 
 // SubscribeWithRequestID подписывает на пары и прокидывает request_id в ws_out
+// Пары распределяются по соединениям с учетом ConnectionLimits драйвера:
+// уже подписанные пары остаются на своем соединении (при смене глубины потоки прежней
+// глубины отписываются перед подпиской), новые заполняют свободные места,
+// при нехватке места открываются дополнительные соединения
 // Возвращает event_id для корреляции входящих WS событий.
 func (p *Pool) SubscribeWithRequestID(exchangeID, marketType string, pairs []string, depth int, requestID string) (string, error) {
 	start := time.Now()
@@ -62,21 +148,86 @@ func (p *Pool) SubscribeWithRequestID(exchangeID, marketType string, pairs []str
 		return "", err
 	}
 
+	driver, err := p.driver(exchangeID)
+	if err != nil {
+		p.logOutRequest("WS_SUBSCRIBE", "/subscribe", url, 400, time.Since(start), requestID, err)
+		return "", err
+	}
+	depth = exchange.SubscriptionDepth(driver, marketType, depth)
+
+	// Проверяем пары до открытия соединений, чтобы не оставлять пустые шарды
+	if _, err := driver.CreateSubscribeMessage(marketType, pairs, depth); err != nil {
+		p.logOutRequest("WS_SUBSCRIBE", "/subscribe", url, 400, time.Since(start), requestID, err)
+		return "", fmt.Errorf("build subscribe frames: %w", err)
+	}
+
 	eventID := newEventID("ws-sub")
 	p.rememberCorrelation(eventID, requestID)
-	latencyMS := float64(time.Since(start).Microseconds()) / 1000.0
-	latencyField := p.buildWSLatencyField(p.wsOutLog, latencyMS, nil)
 
-	p.wsOutLog.Info(
-		"ws subscribe",
-		"event_id", eventID,
-		"request_id", requestID,
-		"exchange_id", exchangeID,
-		"market_type", marketType,
-		"pairs", strings.Join(pairs, ","),
-		"depth", depth,
-		"latency_ms", latencyField,
-	)
+	p.opMu.Lock()
+	defer p.opMu.Unlock()
+
+	assignments, err := p.assignShards(exchangeID, marketType, driver, pairs)
+	if err != nil {
+		p.closeEmptyShards(connectionKey(exchangeID, marketType))
+		p.logOutRequest("WS_SUBSCRIBE", "/subscribe", url, 502, time.Since(start), requestID, err)
+		return "", err
+	}
+
+	for _, assignment := range assignments {
+		conn := assignment.conn
+		url = conn.currentURL()
+
+		// Пара с другой глубиной сначала отписывается от прежних потоков:
+		// иначе они продолжают приходить и занимать место в ConnectionLimits
+		changed := conn.depthChanges(assignment.pairs, depth)
+		var frames [][]byte
+		var changedPairs []string
+		for oldDepth, pairs := range changed {
+			unsubscribe, err := driver.CreateUnsubscribeMessage(marketType, pairs, oldDepth)
+			if err != nil {
+				p.logOutRequest("WS_SUBSCRIBE", "/subscribe", url, 400, time.Since(start), requestID, err)
+				return "", fmt.Errorf("build unsubscribe frames: %w", err)
+			}
+			frames = append(frames, unsubscribe...)
+			changedPairs = append(changedPairs, pairs...)
+		}
+		subscribe, err := driver.CreateSubscribeMessage(marketType, assignment.pairs, depth)
+		if err != nil {
+			p.logOutRequest("WS_SUBSCRIBE", "/subscribe", url, 400, time.Since(start), requestID, err)
+			return "", fmt.Errorf("build subscribe frames: %w", err)
+		}
+		frames = append(frames, subscribe...)
+
+		queued, queue, err := conn.subscribe(assignment.pairs, depth, eventID, requestID, frames)
+		if err != nil {
+			p.closeEmptyShards(connectionKey(exchangeID, marketType))
+			p.logOutRequest("WS_SUBSCRIBE", "/subscribe", url, 502, time.Since(start), requestID, err)
+			return "", err
+		}
+		// Книга прежней глубины больше не обновляется, она восстановится по новому потоку
+		p.invalidatePairs(exchangeID, marketType, changedPairs)
+
+		latencyMS := float64(time.Since(start).Microseconds()) / 1000.0
+		latencyField := p.buildWSLatencyField(p.wsOutLog, latencyMS, rttBreakdown(conn))
+
+		p.wsOutLog.Info(
+			"ws subscribe",
+			"event_id", eventID,
+			"request_id", requestID,
+			"connection_id", conn.id,
+			"exchange_id", exchangeID,
+			"market_type", marketType,
+			"pairs", strings.Join(assignment.pairs, ","),
+			"depth", depth,
+			"depth_changed", len(changedPairs),
+			"frames", len(frames),
+			"queued", queued,
+			"queue_depth", queue.depth,
+			"queue_wait_ms", queue.waitMS(),
+			"latency_ms", latencyField,
+		)
+	}
 	p.logOutRequest("WS_SUBSCRIBE", "/subscribe", url, 200, time.Since(start), requestID, nil)
 
 	return eventID, nil
@@ -89,6 +240,7 @@ func (p *Pool) Unsubscribe(exchangeID, marketType string, pairs []string) error
 }
 
 // UnsubscribeWithRequestID отписывает пары и прокидывает request_id в ws_out
+// Опустевшие соединения закрываются, оставшиеся пары уплотняются в минимальное число шардов
 // Возвращает event_id для корреляции входящих WS событий.
 func (p *Pool) UnsubscribeWithRequestID(exchangeID, marketType string, pairs []string, requestID string) (string, error) {
 	start := time.Now()
@@ -99,23 +251,474 @@ func (p *Pool) UnsubscribeWithRequestID(exchangeID, marketType string, pairs []s
 		return "", err
 	}
 
+	driver, err := p.driver(exchangeID)
+	if err != nil {
+		p.logOutRequest("WS_UNSUBSCRIBE", "/unsubscribe", url, 400, time.Since(start), requestID, err)
+		return "", err
+	}
+
+	p.opMu.Lock()
+	defer p.opMu.Unlock()
+
+	key := connectionKey(exchangeID, marketType)
+	shards := p.shards(key)
+	if len(shards) == 0 {
+		err := fmt.Errorf("no active connection for %s", key)
+		p.logOutRequest("WS_UNSUBSCRIBE", "/unsubscribe", url, 404, time.Since(start), requestID, err)
+		return "", err
+	}
+	url = shards[0].currentURL()
+
 	eventID := newEventID("ws-unsub")
 	p.rememberCorrelation(eventID, requestID)
-	latencyMS := float64(time.Since(start).Microseconds()) / 1000.0
-	latencyField := p.buildWSLatencyField(p.wsOutLog, latencyMS, nil)
 
-	p.wsOutLog.Info(
-		"ws unsubscribe",
-		"event_id", eventID,
-		"request_id", requestID,
+	for _, conn := range shards {
+		removed := conn.removePairs(pairs, eventID)
+		if len(removed) == 0 {
+			continue
+		}
+		url = conn.currentURL()
+
+		frameCount := 0
+		var queue queueStats
+		var connPairs []string
+		for depth, depthPairs := range removed {
+			frames, err := driver.CreateUnsubscribeMessage(marketType, depthPairs, depth)
+			if err != nil {
+				p.logOutRequest("WS_UNSUBSCRIBE", "/unsubscribe", url, 400, time.Since(start), requestID, err)
+				return "", fmt.Errorf("build unsubscribe frames: %w", err)
+			}
+			// Во время переподключения отписываться не от чего: пары просто не будут повторены
+			stats, err := conn.writeFrames(frames)
+			queue.depth = max(queue.depth, stats.depth)
+			queue.wait += stats.wait
+			if err != nil && !errors.Is(err, errNotConnected) {
+				p.logOutRequest("WS_UNSUBSCRIBE", "/unsubscribe", url, 502, time.Since(start), requestID, err)
+				return "", err
+			}
+			frameCount += len(frames)
+			connPairs = append(connPairs, depthPairs...)
+		}
+
+		// Пустое соединение больше не нужно
+		if conn.pairCount() == 0 {
+			p.dropConnection(key, conn)
+			_ = conn.close()
+		}
+		latencyMS := float64(time.Since(start).Microseconds()) / 1000.0
+		latencyField := p.buildWSLatencyField(p.wsOutLog, latencyMS, rttBreakdown(conn))
+
+		p.wsOutLog.Info(
+			"ws unsubscribe",
+			"event_id", eventID,
+			"request_id", requestID,
+			"connection_id", conn.id,
+			"exchange_id", exchangeID,
+			"market_type", marketType,
+			"pairs", strings.Join(connPairs, ","),
+			"frames", frameCount,
+			"queue_depth", queue.depth,
+			"queue_wait_ms", queue.waitMS(),
+			"latency_ms", latencyField,
+		)
+	}
+
+	p.rebalance(exchangeID, marketType, driver, eventID)
+	p.logOutRequest("WS_UNSUBSCRIBE", "/unsubscribe", url, 200, time.Since(start), requestID, nil)
+
+	return eventID, nil
+}
+
+// driver возвращает драйвер биржи, создавая его через реестр exchange при первом обращении
+func (p *Pool) driver(exchangeID string) (exchange.ExchangeDriver, error) {
+	p.connMu.RLock()
+	closed := p.closed
+	driver, ok := p.drivers[exchangeID]
+	p.connMu.RUnlock()
+
+	if closed {
+		return nil, ErrPoolClosed
+	}
+	if ok {
+		return driver, nil
+	}
+
+	driver, err := exchange.NewDriver(exchangeID)
+	if err != nil {
+		return nil, err
+	}
+
+	p.connMu.Lock()
+	if existing, ok := p.drivers[exchangeID]; ok {
+		driver = existing
+	} else {
+		p.drivers[exchangeID] = driver
+		limit := rateLimits(driver).Exchange
+		p.limiters[exchangeID] = ratelimit.New(limit.PerSecond, limit.Burst)
+	}
+	p.connMu.Unlock()
+
+	return driver, nil
+}
+
+// rateLimits возвращает лимиты драйвера, нулевое значение если драйвер их не объявляет
+func rateLimits(driver exchange.ExchangeDriver) exchange.RateLimits {
+	if limited, ok := driver.(exchange.RateLimited); ok {
+		return limited.RateLimits()
+	}
+	return exchange.RateLimits{}
+}
+
+// dialShard открывает еще одно соединение для (exchange, market) и запускает его чтение
+func (p *Pool) dialShard(exchangeID, marketType string, driver exchange.ExchangeDriver) (*connection, error) {
+	url, err := resolveEndpoint(driver, marketType)
+	if err != nil {
+		return nil, err
+	}
+
+	p.connMu.RLock()
+	exchangeLimit := p.limiters[exchangeID]
+	p.connMu.RUnlock()
+	connLimit := rateLimits(driver).Connection
+
+	conn, err := dialConnection(p.dialer, exchangeID, marketType, url,
+		ratelimit.New(connLimit.PerSecond, connLimit.Burst), exchangeLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	key := connectionKey(exchangeID, marketType)
+	p.connMu.Lock()
+	if p.closed {
+		p.connMu.Unlock()
+		_ = conn.close()
+		return nil, ErrPoolClosed
+	}
+	p.connections[key] = append(p.connections[key], conn)
+	shardCount := len(p.connections[key])
+	p.connMu.Unlock()
+
+	p.log.Info("ws connected",
+		"connection_id", conn.id,
 		"exchange_id", exchangeID,
 		"market_type", marketType,
-		"pairs", strings.Join(pairs, ","),
-		"latency_ms", latencyField,
+		"url", url,
+		"shards", shardCount,
 	)
-	p.logOutRequest("WS_UNSUBSCRIBE", "/unsubscribe", url, 200, time.Since(start), requestID, nil)
 
-	return eventID, nil
+	if serverClock, ok := driver.(exchange.ServerClock); ok {
+		p.clock.Track(exchangeID, marketType, serverClock)
+	}
+
+	go p.readLoop(conn, conn.ws, driver)
+	if pinger, ok := driver.(exchange.Pinger); ok {
+		go p.keepaliveLoop(conn, pinger)
+	}
+	return conn, nil
+}
+
+// shards возвращает копию списка соединений (exchange, market)
+func (p *Pool) shards(key string) []*connection {
+	p.connMu.RLock()
+	defer p.connMu.RUnlock()
+	return append([]*connection(nil), p.connections[key]...)
+}
+
+// closeEmptyShards закрывает соединения без подписок (остаются после неудачной подписки)
+func (p *Pool) closeEmptyShards(key string) {
+	for _, conn := range p.shards(key) {
+		if conn.pairCount() == 0 {
+			p.dropConnection(key, conn)
+			_ = conn.close()
+		}
+	}
+}
+
+// dropConnection удаляет соединение из пула если оно все еще там
+func (p *Pool) dropConnection(key string, conn *connection) {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	shards := p.connections[key]
+	for i, current := range shards {
+		if current != conn {
+			continue
+		}
+		shards = append(shards[:i:i], shards[i+1:]...)
+		if len(shards) == 0 {
+			delete(p.connections, key)
+		} else {
+			p.connections[key] = shards
+		}
+		return
+	}
+}
+
+// readLoop читает входящие фреймы соединения, логирует их в ws_in
+// и передает разобранные сообщения обработчикам
+// При обрыве соединение переподключается и чтение продолжается из нового сокета
+func (p *Pool) readLoop(conn *connection, socket *websocket.Conn, driver exchange.ExchangeDriver) {
+	key := connectionKey(conn.exchangeID, conn.marketType)
+	defer p.dropConnection(key, conn)
+
+	pinger, _ := driver.(exchange.Pinger)
+	serverPinger, _ := driver.(exchange.ServerPinger)
+	p.watchPongs(conn, socket)
+	for {
+		_, data, err := socket.ReadMessage()
+		if err != nil {
+			if conn.isClosed() {
+				return
+			}
+			p.log.Warn("ws connection lost",
+				"connection_id", conn.id,
+				"exchange_id", conn.exchangeID,
+				"market_type", conn.marketType,
+				"error", err,
+			)
+			if socket = p.reconnect(conn, driver); socket == nil {
+				return
+			}
+			p.watchPongs(conn, socket)
+			continue
+		}
+
+		receivedAt := time.Now().UTC()
+		conn.recordFrame(receivedAt)
+		payloadSize := len(data)
+		data, decodeErr := decodeFrame(driver, data)
+		if decodeErr != nil {
+			p.logInbound(conn, "unknown", conn.lastEventID(), "", payloadSize, "decode_error")
+			p.log.Debug("ws frame decode failed",
+				"connection_id", conn.id,
+				"exchange_id", conn.exchangeID,
+				"market_type", conn.marketType,
+				"error", decodeErr,
+			)
+			continue
+		}
+
+		if pinger != nil && pinger.IsPong(conn.marketType, data) {
+			p.handlePong(conn, receivedAt, payloadSize)
+			continue
+		}
+
+		if serverPinger != nil {
+			if sentAt, ok := serverPinger.ServerPingTime(conn.marketType, data); ok {
+				p.handleServerPing(conn, receivedAt, sentAt, payloadSize)
+			}
+		}
+
+		if responder, ok := driver.(exchange.FrameResponder); ok {
+			if reply := responder.ReplyTo(conn.marketType, data); reply != nil {
+				if err := conn.writeReply(reply); err != nil {
+					p.log.Warn("ws reply failed",
+						"connection_id", conn.id,
+						"exchange_id", conn.exchangeID,
+						"error", err,
+					)
+				}
+				continue
+			}
+		}
+
+		messages, parseErr := driver.ParseMessage(conn.marketType, data)
+		messageType := "control"
+		status := "ok"
+		if parseErr != nil {
+			messageType = "unknown"
+			status = "parse_error"
+		} else if len(messages) > 0 {
+			messageType = messages[0].Type
+		}
+
+		p.logInbound(conn, messageType, conn.lastEventID(), "", payloadSize, status)
+		if parseErr != nil {
+			p.log.Debug("ws frame parse failed",
+				"connection_id", conn.id,
+				"exchange_id", conn.exchangeID,
+				"market_type", conn.marketType,
+				"error", parseErr,
+			)
+			continue
+		}
+
+		p.clock.Normalize(messages, receivedAt)
+		conn.recordMessages(messages, receivedAt)
+		p.dispatch(messages)
+	}
+}
+
+// reconnect восстанавливает оборванное соединение с экспоненциальной задержкой и jitter
+// Сразу после обрыва по всем парам соединения рассылается TypeBookInvalidated,
+// после подключения все активные подписки отправляются заново
+// Возвращает новый сокет или nil если соединение закрыто локально и восстанавливать его не нужно
+func (p *Pool) reconnect(conn *connection, driver exchange.ExchangeDriver) *websocket.Conn {
+	if !conn.markReconnecting() {
+		return nil
+	}
+	p.invalidateBooks(conn)
+
+	key := connectionKey(conn.exchangeID, conn.marketType)
+	delays := newBackoff(reconnectBaseDelay, reconnectMaxDelay)
+	for {
+		delay := delays.next()
+		timer := time.NewTimer(delay)
+		select {
+		case <-conn.ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		// Пока ждали, могли отписаться от всех пар
+		if conn.pairCount() == 0 {
+			p.dropConnection(key, conn)
+			_ = conn.close()
+			p.log.Info("ws reconnect cancelled, no active subscriptions",
+				"connection_id", conn.id,
+				"exchange_id", conn.exchangeID,
+				"market_type", conn.marketType,
+			)
+			return nil
+		}
+
+		socket, url, frameCount, queue, err := p.redial(conn, driver)
+		if err != nil {
+			if errors.Is(err, errConnectionClosed) {
+				return nil
+			}
+			p.log.Warn("ws reconnect failed",
+				"connection_id", conn.id,
+				"exchange_id", conn.exchangeID,
+				"market_type", conn.marketType,
+				"attempt", delays.attempts(),
+				"delay_ms", delay.Milliseconds(),
+				"error", err,
+			)
+			continue
+		}
+
+		p.log.Info("ws reconnected",
+			"connection_id", conn.id,
+			"exchange_id", conn.exchangeID,
+			"market_type", conn.marketType,
+			"url", url,
+			"attempts", delays.attempts(),
+		)
+		p.wsOutLog.Info(
+			"ws resubscribe",
+			"event_id", conn.lastEventID(),
+			"request_id", "",
+			"connection_id", conn.id,
+			"exchange_id", conn.exchangeID,
+			"market_type", conn.marketType,
+			"pairs", conn.pairCount(),
+			"frames", frameCount,
+			"queue_depth", queue.depth,
+			"queue_wait_ms", queue.waitMS(),
+		)
+		return socket
+	}
+}
+
+// redial открывает новый сокет и повторяет в нем подписки соединения
+// Возвращает сокет, его адрес, число отправленных фреймов и ожидание в очереди лимитеров
+func (p *Pool) redial(conn *connection, driver exchange.ExchangeDriver) (*websocket.Conn, string, int, queueStats, error) {
+	url, err := resolveEndpoint(driver, conn.marketType)
+	if err != nil {
+		return nil, "", 0, queueStats{}, err
+	}
+	socket, err := dialSocket(p.dialer, url)
+	if err != nil {
+		return nil, "", 0, queueStats{}, err
+	}
+
+	frameCount := 0
+	queue, err := conn.resume(socket, url, func(byDepth map[int][]string) ([][]byte, error) {
+		var frames [][]byte
+		for depth, pairs := range byDepth {
+			depthFrames, err := driver.CreateSubscribeMessage(conn.marketType, pairs, depth)
+			if err != nil {
+				return nil, fmt.Errorf("build subscribe frames: %w", err)
+			}
+			frames = append(frames, depthFrames...)
+		}
+		frameCount = len(frames)
+		return frames, nil
+	})
+	if err != nil {
+		return nil, "", 0, queue, err
+	}
+	return socket, url, frameCount, queue, nil
+}
+
+// invalidateBooks сообщает обработчикам, что книги пар соединения больше не актуальны
+// Состояние нужно сбросить и дождаться нового снимка после переподключения
+func (p *Pool) invalidateBooks(conn *connection) {
+	var pairs []string
+	for _, depthPairs := range conn.pairsByDepth() {
+		pairs = append(pairs, depthPairs...)
+	}
+	p.invalidatePairs(conn.exchangeID, conn.marketType, pairs)
+}
+
+// invalidatePairs рассылает TypeBookInvalidated по списку пар
+func (p *Pool) invalidatePairs(exchangeID, marketType string, pairs []string) {
+	now := time.Now().UTC().UnixMicro()
+	messages := make([]*messaging.Message, 0, len(pairs))
+	for _, pair := range pairs {
+		messages = append(messages, &messaging.Message{
+			Timestamp:  now,
+			ExchangeID: exchangeID,
+			MarketType: marketType,
+			Type:       messaging.TypeBookInvalidated,
+			Pair:       pair,
+		})
+	}
+	p.dispatch(messages)
+}
+
+// dispatch передает сообщения всем зарегистрированным обработчикам
+func (p *Pool) dispatch(messages []*messaging.Message) {
+	if len(messages) == 0 {
+		return
+	}
+
+	p.connMu.RLock()
+	handlers := p.handlers
+	p.connMu.RUnlock()
+
+	for _, msg := range messages {
+		for _, handler := range handlers {
+			handler(msg)
+		}
+	}
+}
+
+// resolveEndpoint возвращает WS URL, при необходимости запрашивая его у биржи
+func resolveEndpoint(driver exchange.ExchangeDriver, marketType string) (string, error) {
+	resolver, ok := driver.(exchange.EndpointResolver)
+	if !ok {
+		return driver.GetWSEndpoint(marketType)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	return resolver.ResolveWSEndpoint(ctx, marketType)
+}
+
+// decodeFrame распаковывает фрейм если драйвер объявил свой формат
+func decodeFrame(driver exchange.ExchangeDriver, data []byte) ([]byte, error) {
+	decoder, ok := driver.(exchange.FrameDecoder)
+	if !ok {
+		return data, nil
+	}
+	return decoder.DecodeFrame(data)
+}
+
+func connectionKey(exchangeID, marketType string) string {
+	return exchangeID + ":" + marketType
 }
 
 func (p *Pool) logOutRequest(method, path, url string, status int, latency time.Duration, requestID string, err error) {
@@ -152,8 +755,20 @@ func (p *Pool) logOutRequest(method, path, url string, status int, latency time.
 // LogInboundMessage логирует входящее WS событие в ws_in.
 // Если request_id пустой, пытается восстановить его по event_id.
 func (p *Pool) LogInboundMessage(exchangeID, marketType, messageType, eventID, requestID string, payloadSize int, status string) {
+	p.logInboundWithLatency(exchangeID, marketType, messageType, eventID, requestID, payloadSize, status, map[string]float64{})
+}
+
+// logInbound логирует входящее событие соединения, добавляя RTT соединения в детализацию latency_ms
+func (p *Pool) logInbound(conn *connection, messageType, eventID, requestID string, payloadSize int, status string) {
+	latencyBreakdown := rttBreakdown(conn)
+	if latencyBreakdown == nil {
+		latencyBreakdown = map[string]float64{}
+	}
+	p.logInboundWithLatency(conn.exchangeID, conn.marketType, messageType, eventID, requestID, payloadSize, status, latencyBreakdown)
+}
+
+func (p *Pool) logInboundWithLatency(exchangeID, marketType, messageType, eventID, requestID string, payloadSize int, status string, latencyBreakdown map[string]float64) {
 	inboundStart := time.Now()
-	latencyBreakdown := map[string]float64{}
 	if requestID == "" && eventID != "" {
 		correlatedRequestID, correlationLatencyMS, correlated := p.requestIDByEvent(eventID)
 		requestID = correlatedRequestID
@@ -0,0 +1,411 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+)
+
+const testExchangeID = "ws-test"
+
+// testEndpoint - адрес локальной биржи для тестового драйвера
+var (
+	testEndpointMu sync.Mutex
+	testEndpoint   string
+	registerOnce   sync.Once
+)
+
+// testDriver - драйвер локальной биржи: подписка {"op":"subscribe","args":[pairs],"depth":depth},
+// данные {"pair":"BTC/USDT","price":"1"} разбираются в TypeTrade
+type testDriver struct{}
+
+func (testDriver) GetExchangeID() string { return testExchangeID }
+func (testDriver) GetName() string       { return "WS test" }
+
+func (testDriver) GetWSEndpoint(string) (string, error) {
+	testEndpointMu.Lock()
+	defer testEndpointMu.Unlock()
+	return testEndpoint, nil
+}
+
+func (testDriver) CreateSubscribeMessage(_ string, pairs []string, depth int) ([][]byte, error) {
+	frame, err := json.Marshal(testFrame{Op: "subscribe", Args: pairs, Depth: depth})
+	return [][]byte{frame}, err
+}
+
+func (testDriver) CreateUnsubscribeMessage(_ string, pairs []string, depth int) ([][]byte, error) {
+	frame, err := json.Marshal(testFrame{Op: "unsubscribe", Args: pairs, Depth: depth})
+	return [][]byte{frame}, err
+}
+
+func (testDriver) ParseMessage(marketType string, data []byte) ([]*messaging.Message, error) {
+	var payload struct {
+		Pair  string `json:"pair"`
+		Price string `json:"price"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Pair == "" {
+		return nil, nil
+	}
+	return []*messaging.Message{{
+		ExchangeID: testExchangeID,
+		MarketType: marketType,
+		Type:       messaging.TypeTrade,
+		Pair:       payload.Pair,
+	}}, nil
+}
+
+type testFrame struct {
+	Op    string   `json:"op"`
+	Args  []string `json:"args"`
+	Depth int      `json:"depth"`
+}
+
+// testServer - локальная биржа: каждое новое соединение и каждый входящий фрейм
+// передаются в каналы теста
+type testServer struct {
+	*httptest.Server
+	conns  chan *websocket.Conn
+	frames chan testFrame
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	registerOnce.Do(func() {
+		exchange.Register(testExchangeID, func() exchange.ExchangeDriver { return testDriver{} })
+	})
+
+	s := &testServer{
+		conns:  make(chan *websocket.Conn, 8),
+		frames: make(chan testFrame, 64),
+	}
+	upgrader := websocket.Upgrader{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		s.conns <- conn
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var frame testFrame
+			if json.Unmarshal(data, &frame) == nil {
+				s.frames <- frame
+			}
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	testEndpointMu.Lock()
+	testEndpoint = "ws" + strings.TrimPrefix(s.URL, "http")
+	testEndpointMu.Unlock()
+	return s
+}
+
+func (s *testServer) nextConn(t *testing.T) *websocket.Conn {
+	t.Helper()
+	select {
+	case conn := <-s.conns:
+		return conn
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connection from pool")
+		return nil
+	}
+}
+
+func (s *testServer) nextFrame(t *testing.T) testFrame {
+	t.Helper()
+	select {
+	case frame := <-s.frames:
+		sort.Strings(frame.Args)
+		return frame
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame from pool")
+		return testFrame{}
+	}
+}
+
+// collect - обработчик пула, передающий сообщения в канал
+func collect(pool *Pool) chan *messaging.Message {
+	messages := make(chan *messaging.Message, 64)
+	pool.AddHandler(func(msg *messaging.Message) { messages <- msg })
+	return messages
+}
+
+func nextMessage(t *testing.T, messages chan *messaging.Message, msgType string) *messaging.Message {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-messages:
+			if msg.Type == msgType {
+				return msg
+			}
+		case <-deadline:
+			t.Fatalf("no %s message", msgType)
+			return nil
+		}
+	}
+}
+
+func TestPoolSubscribeAndDeliver(t *testing.T) {
+	server := newTestServer(t)
+	pool := NewPool()
+	defer pool.Close()
+	messages := collect(pool)
+
+	if err := pool.Subscribe(testExchangeID, "spot", []string{"BTC/USDT", "ETH/USDT"}, 10); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	conn := server.nextConn(t)
+
+	frame := server.nextFrame(t)
+	if frame.Op != "subscribe" || strings.Join(frame.Args, ",") != "BTC/USDT,ETH/USDT" {
+		t.Fatalf("subscribe frame = %+v", frame)
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"pair":"ETH/USDT","price":"1"}`)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	msg := nextMessage(t, messages, messaging.TypeTrade)
+	if msg.ExchangeID != testExchangeID || msg.MarketType != "spot" || msg.Pair != "ETH/USDT" {
+		t.Fatalf("message = %+v", msg)
+	}
+	if msg.ReceivedAt == 0 {
+		t.Error("ReceivedAt is not set")
+	}
+
+	subs := pool.GetSubscriptions(testExchangeID, "spot")
+	if len(subs) != 2 || subs[0].Pair != "BTC/USDT" || subs[1].Pair != "ETH/USDT" {
+		t.Fatalf("subscriptions = %+v", subs)
+	}
+	if subs[1].MessageCount != 1 || subs[0].MessageCount != 0 {
+		t.Errorf("message counts = %d, %d", subs[0].MessageCount, subs[1].MessageCount)
+	}
+
+	if err := pool.Unsubscribe(testExchangeID, "spot", []string{"BTC/USDT"}); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	frame = server.nextFrame(t)
+	if frame.Op != "unsubscribe" || strings.Join(frame.Args, ",") != "BTC/USDT" {
+		t.Fatalf("unsubscribe frame = %+v", frame)
+	}
+}
+
+func TestPoolDepthChangeUnsubscribesOldStreams(t *testing.T) {
+	server := newTestServer(t)
+	pool := NewPool()
+	defer pool.Close()
+	messages := collect(pool)
+
+	if err := pool.Subscribe(testExchangeID, "spot", []string{"BTC/USDT", "ETH/USDT"}, 20); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	conn := server.nextConn(t)
+	server.nextFrame(t)
+
+	// BTC/USDT переходит на другую глубину, SOL/USDT - новая пара той же подписки
+	if err := pool.Subscribe(testExchangeID, "spot", []string{"BTC/USDT", "SOL/USDT"}, 50); err != nil {
+		t.Fatalf("change depth: %v", err)
+	}
+	want := []testFrame{
+		{Op: "unsubscribe", Args: []string{"BTC/USDT"}, Depth: 20},
+		{Op: "subscribe", Args: []string{"BTC/USDT", "SOL/USDT"}, Depth: 50},
+	}
+	for _, w := range want {
+		frame := server.nextFrame(t)
+		if frame.Op != w.Op || strings.Join(frame.Args, ",") != strings.Join(w.Args, ",") || frame.Depth != w.Depth {
+			t.Fatalf("frame = %+v, want %+v", frame, w)
+		}
+	}
+	if msg := nextMessage(t, messages, messaging.TypeBookInvalidated); msg.Pair != "BTC/USDT" {
+		t.Errorf("invalidated pair = %s", msg.Pair)
+	}
+
+	depths := map[string]int{}
+	for _, sub := range pool.GetSubscriptions(testExchangeID, "spot") {
+		depths[sub.Pair] = sub.Depth
+	}
+	if depths["BTC/USDT"] != 50 || depths["ETH/USDT"] != 20 || depths["SOL/USDT"] != 50 {
+		t.Fatalf("depths = %v", depths)
+	}
+
+	// Отписка строит фреймы по текущей глубине пары
+	if err := pool.Unsubscribe(testExchangeID, "spot", []string{"BTC/USDT"}); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	if frame := server.nextFrame(t); frame.Op != "unsubscribe" || strings.Join(frame.Args, ",") != "BTC/USDT" || frame.Depth != 50 {
+		t.Fatalf("unsubscribe frame = %+v", frame)
+	}
+
+	// После переподключения повторяется только текущая глубина пар
+	_ = conn.Close()
+	server.nextConn(t)
+	resubscribed := map[int]string{}
+	for range 2 {
+		frame := server.nextFrame(t)
+		if frame.Op != "subscribe" {
+			t.Fatalf("frame after reconnect = %+v", frame)
+		}
+		resubscribed[frame.Depth] = strings.Join(frame.Args, ",")
+	}
+	if resubscribed[20] != "ETH/USDT" || resubscribed[50] != "SOL/USDT" {
+		t.Fatalf("resubscribed = %v", resubscribed)
+	}
+}
+
+func TestPoolReconnectResubscribes(t *testing.T) {
+	server := newTestServer(t)
+	pool := NewPool()
+	defer pool.Close()
+	messages := collect(pool)
+
+	if err := pool.Subscribe(testExchangeID, "futures", []string{"BTC/USDT", "SOL/USDT"}, 5); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	first := server.nextConn(t)
+	server.nextFrame(t)
+	connectionID := pool.GetSubscriptions(testExchangeID, "futures")[0].ConnectionID
+
+	// Биржа закрывает соединение: книги пар инвалидируются, пул переподключается с задержкой
+	closedAt := time.Now()
+	_ = first.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseGoingAway, "restart"), time.Now().Add(time.Second))
+	_ = first.Close()
+
+	invalidated := map[string]bool{}
+	for len(invalidated) < 2 {
+		msg := nextMessage(t, messages, messaging.TypeBookInvalidated)
+		invalidated[msg.Pair] = true
+	}
+	if !invalidated["BTC/USDT"] || !invalidated["SOL/USDT"] {
+		t.Fatalf("invalidated pairs = %v", invalidated)
+	}
+
+	second := server.nextConn(t)
+	if delay := time.Since(closedAt); delay < reconnectBaseDelay/2 {
+		t.Errorf("reconnected after %v, want backoff >= %v", delay, reconnectBaseDelay/2)
+	}
+	frame := server.nextFrame(t)
+	if frame.Op != "subscribe" || strings.Join(frame.Args, ",") != "BTC/USDT,SOL/USDT" {
+		t.Fatalf("resubscribe frame = %+v", frame)
+	}
+
+	if err := second.WriteMessage(websocket.TextMessage, []byte(`{"pair":"SOL/USDT","price":"2"}`)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if msg := nextMessage(t, messages, messaging.TypeTrade); msg.Pair != "SOL/USDT" {
+		t.Fatalf("message after reconnect = %+v", msg)
+	}
+
+	subs := pool.GetSubscriptions(testExchangeID, "futures")
+	if len(subs) != 2 {
+		t.Fatalf("subscriptions after reconnect = %+v", subs)
+	}
+	for _, sub := range subs {
+		if sub.ConnectionID != connectionID || sub.ConnectionState != StateConnected || sub.Depth != 5 {
+			t.Errorf("subscription after reconnect = %+v", sub)
+		}
+	}
+}
+
+func TestConnectionSubscribeFailureKeepsExistingPairs(t *testing.T) {
+	server := newTestServer(t)
+	endpoint, _ := testDriver{}.GetWSEndpoint("spot")
+	conn, err := dialConnection(&websocket.Dialer{}, testExchangeID, "spot", endpoint, nil, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.close()
+	server.nextConn(t)
+
+	frames, _ := testDriver{}.CreateSubscribeMessage("spot", []string{"BTC/USDT"}, 10)
+	if _, _, err := conn.subscribe([]string{"BTC/USDT"}, 10, "ev-1", "", frames); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	// Сокет оборван, а чтения нет: запись следующей подписки завершится ошибкой
+	_ = conn.ws.Close()
+	frames, _ = testDriver{}.CreateSubscribeMessage("spot", []string{"BTC/USDT", "ETH/USDT"}, 10)
+	if _, _, err := conn.subscribe([]string{"BTC/USDT", "ETH/USDT"}, 10, "ev-2", "", frames); err == nil {
+		t.Fatal("subscribe on closed socket succeeded")
+	}
+	if !conn.hasPair("BTC/USDT") {
+		t.Error("previously subscribed pair was removed")
+	}
+	if conn.hasPair("ETH/USDT") {
+		t.Error("failed pair stayed subscribed")
+	}
+}
+
+func TestRecycleStaleKeepsRequestID(t *testing.T) {
+	server := newTestServer(t)
+	pool := NewPool()
+	defer pool.Close()
+
+	if _, err := pool.SubscribeWithRequestID(testExchangeID, "spot", []string{"BTC/USDT"}, 10, "req-stale"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	server.nextConn(t)
+	server.nextFrame(t)
+	conn := pool.shards(connectionKey(testExchangeID, "spot"))[0]
+	subscribeEventID := conn.lastEventID()
+
+	// Соединение молчит дольше порога: пересоздается с новым event_id той же подписки
+	pool.checkStale(time.Now().UTC().Add(2 * DefaultStaleThreshold))
+	eventID := conn.lastEventID()
+	if eventID == subscribeEventID || !strings.HasPrefix(eventID, "ws-stale-") {
+		t.Fatalf("event_id after recycle = %q", eventID)
+	}
+	if requestID, _, ok := pool.requestIDByEvent(eventID); !ok || requestID != "req-stale" {
+		t.Errorf("request_id of %s = %q, %v", eventID, requestID, ok)
+	}
+
+	server.nextConn(t)
+	if frame := server.nextFrame(t); frame.Op != "subscribe" || strings.Join(frame.Args, ",") != "BTC/USDT" {
+		t.Fatalf("resubscribe frame = %+v", frame)
+	}
+}
+
+func TestServerPingRecordsRTT(t *testing.T) {
+	server := newTestServer(t)
+	pool := NewPool()
+	defer pool.Close()
+	endpoint, _ := testDriver{}.GetWSEndpoint("spot")
+	conn, err := dialConnection(&websocket.Dialer{}, testExchangeID, "spot", endpoint, nil, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.close()
+	server.nextConn(t)
+
+	// Ping отправлен сервером 40ms назад: задержка доставки - половина RTT
+	receivedAt := time.Now().UTC().Truncate(time.Microsecond)
+	pool.handleServerPing(conn, receivedAt, receivedAt.Add(-40*time.Millisecond).UnixMicro(), 32)
+	if rtt := conn.currentRTT(); rtt != 80*time.Millisecond {
+		t.Errorf("RTT = %v, want 80ms", rtt)
+	}
+
+	frames, _ := testDriver{}.CreateSubscribeMessage("spot", []string{"BTC/USDT"}, 10)
+	if _, _, err := conn.subscribe([]string{"BTC/USDT"}, 10, "ev-1", "", frames); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if info := conn.subscriptions()[0]; !info.LastPongAt.Equal(receivedAt) || info.RTT != 80*time.Millisecond {
+		t.Errorf("subscription = %+v", info)
+	}
+}
@@ -33,6 +33,8 @@ var (
 // Координирует работу всех компонентов и управляет их жизненным циклом
 type Manager struct {
 	cfg *config.Config
+	// runtime - пул, книги и подписки, построенные main
+	runtime Runtime
 	//	tradeData    *tradedata.Monitor
 	//	exchangeExec *exchange.Monitor
 	//	collector    *collectorevents.Monitor
@@ -63,9 +65,9 @@ const (
 	GracefulShutdownTimeout = 30 * time.Second
 )
 
-// New - создает новый менеджер с указанной конфигурацией
+// New - создает новый менеджер с указанной конфигурацией и рыночными компонентами
 // Инициализирует контекст и состояние из сохраненного на диске
-func New(cfg *config.Config) *Manager {
+func New(cfg *config.Config, runtime Runtime) *Manager {
 	// Создаем контекст который можно отменить (для shutdown)
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -81,7 +83,8 @@ func New(cfg *config.Config) *Manager {
 	//t := trade.NewMonitor(cfg, td, ee)
 
 	return &Manager{
-		cfg: cfg,
+		cfg:     cfg,
+		runtime: runtime,
 		//	tradeData:    td,
 		//	exchangeExec: ee,
 		//	collector:    ce,
@@ -105,6 +108,12 @@ func (m *Manager) Start() error {
 
 	// Логируем что начинаем запуск
 	logger.Get("manager").Info("Starting system components...")
+
+	// Задачи загружаются первыми: по ним подписывается пул, запускаются сборщики
+	// монитора и история пар
+	if err := m.startTasks(); err != nil {
+		return err
+	}
 	m.isRunning = true
 	m.startTime = time.Now()
 
@@ -222,6 +231,11 @@ func (m *Manager) shutdownComponents(done chan<- error) {
 	// log.Info("Stopping trade data monitor...")
 	// m.tradeData.Stop()
 
+	log.Info("Stopping market data runtime...")
+	if err := m.closeRuntime(); err != nil {
+		lastErr = err
+	}
+
 	// Cancel main context after all components stopped
 	m.cancel()
 
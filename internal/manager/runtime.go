@@ -0,0 +1,172 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/instrument"
+	"trader/internal/core/orderbook"
+	"trader/internal/core/ws"
+	"trader/internal/logger"
+	"trader/internal/monitor"
+	"trader/internal/monitor/clickhouse"
+	"trader/internal/task"
+	"trader/internal/trader"
+)
+
+// Runtime - рыночные компоненты демона, которые main строит по конфигурации
+// Manager применяет к ним задачи (ApplyTasks) и останавливает их при shutdown
+type Runtime struct {
+	// Tasks - периодическая загрузка задач из MySQL, передает изменения в ApplyTasks
+	// nil = MySQL не настроен, задачи не загружаются
+	Tasks *task.Fetcher
+	// Pool - WS соединения к биржам
+	Pool *ws.Pool
+	// Books - книги ордеров, собранные из сообщений Pool
+	Books *orderbook.Manager
+	// Subscriptions - подписки пула по задачам мониторинга и торговли
+	Subscriptions *task.SubscriptionManager
+	// Instruments - шаги цены и объема, минимальные ордера пар торговых задач
+	Instruments *instrument.Catalog
+	// Monitor - снимки книг задач мониторинга в ClickHouse, nil для роли trader
+	Monitor *monitor.Monitor
+	// Spool - дисковый буфер batch монитора на время недоступности ClickHouse, может быть nil
+	Spool *clickhouse.Spool
+	// History - последние книги и сделки пар задач мониторинга в памяти, nil для роли trader
+	History *orderbook.History
+	// Orders - проверка ордеров торговых задач перед отправкой, nil для роли monitor
+	Orders *trader.OrderChecker
+}
+
+// ErrTradingDisabled - демон запущен без роли trader
+var ErrTradingDisabled = errors.New("trading is disabled for this role")
+
+// instrumentsTimeout - предельное время загрузки инструментов при применении задач
+const instrumentsTimeout = 30 * time.Second
+
+// startTasks загружает задачи, применяет их и запускает периодическую загрузку
+// Без task.Fetcher (MySQL не настроен) демон работает без задач
+func (m *Manager) startTasks() error {
+	if m.runtime.Tasks == nil {
+		logger.Get("manager").Warn("task fetcher is disabled: mysql host is not configured")
+		return nil
+	}
+	if err := m.runtime.Tasks.Start(m.ctx, m.ApplyTasks); err != nil {
+		return fmt.Errorf("start task fetcher: %w", err)
+	}
+	return nil
+}
+
+// ApplyTasks приводит подписки пула, сборщики монитора и историю пар к новому списку задач
+// и загружает инструменты пар торговых задач
+// Вызывается task.Fetcher при старте и при каждом изменении задач
+func (m *Manager) ApplyTasks(tasks *task.TasksData) error {
+	diff, err := m.runtime.Subscriptions.Merge(tasks)
+	if err != nil {
+		return fmt.Errorf("merge tasks: %w", err)
+	}
+	if err := m.runtime.Subscriptions.ApplyDiff(diff); err != nil {
+		return err
+	}
+	if m.runtime.Monitor != nil {
+		m.runtime.Monitor.Sync(tasks.MonitoringTasks)
+	}
+	if m.runtime.History != nil {
+		if err := m.runtime.History.Sync(tasks.MonitoringTasks); err != nil {
+			return fmt.Errorf("sync history: %w", err)
+		}
+	}
+	m.loadInstruments(tasks.TradingTasks)
+
+	logger.Get("manager").Info("tasks applied",
+		"monitoring_tasks", len(tasks.MonitoringTasks),
+		"trading_tasks", len(tasks.TradingTasks),
+		"subscribed", len(diff.ToSubscribe),
+		"unsubscribed", len(diff.Unsubscribe),
+	)
+	return nil
+}
+
+// CheckOrder - проверка рыночного ордера задачи по текущей книге пары
+// с ограничениями задачи SLIPPAGE_PERCENT и MAX_AMOUNT_TRADE
+// Отправка ордера на биржу должна идти только после успешной проверки
+func (m *Manager) CheckOrder(t *exchange.TradingTask, side string, quantity decimal.Decimal) (exchange.Fill, error) {
+	if m.runtime.Orders == nil {
+		return exchange.Fill{}, ErrTradingDisabled
+	}
+	fill, err := m.runtime.Orders.Check(t, side, quantity)
+	if err != nil {
+		logger.Get("manager").Warn("order rejected by pre-trade check",
+			"trade_id", t.ID,
+			"exchange_id", t.ExchangeID,
+			"market_type", t.MarketType,
+			"pair", t.TradePair,
+			"side", side,
+			"quantity", quantity,
+			"error", err,
+		)
+	}
+	return fill, err
+}
+
+// loadInstruments заранее загружает инструменты пар торговых задач, чтобы ордер
+// не ждал REST биржи, а неизвестная бирже пара была видна в логе сразу
+// Ошибки не прерывают применение задач: Catalog повторит загрузку при обращении
+func (m *Manager) loadInstruments(tasks []*exchange.TradingTask) {
+	if m.runtime.Instruments == nil || len(tasks) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, instrumentsTimeout)
+	defer cancel()
+
+	for _, t := range tasks {
+		_, err := m.runtime.Instruments.Get(ctx, t.ExchangeID, t.MarketType, t.TradePair)
+		if err == nil || errors.Is(err, instrument.ErrNotSupported) {
+			continue
+		}
+		logger.Get("manager").Warn("instrument unavailable",
+			"exchange_id", t.ExchangeID,
+			"market_type", t.MarketType,
+			"pair", t.TradePair,
+			"error", err,
+		)
+	}
+}
+
+// closeRuntime останавливает компоненты в обратном порядке зависимостей:
+// загрузка задач (подписки больше не меняются), монитор отправляет накопленные снимки,
+// буфер закрывает сегмент с неотправленными, затем закрывается пул (новых сообщений нет),
+// затем книги и их подписчики
+func (m *Manager) closeRuntime() error {
+	var errs []error
+	if m.runtime.Tasks != nil {
+		if err := m.runtime.Tasks.Stop(); err != nil {
+			errs = append(errs, fmt.Errorf("stop task fetcher: %w", err))
+		}
+	}
+	if m.runtime.Monitor != nil {
+		m.runtime.Monitor.Stop()
+	}
+	if m.runtime.Spool != nil {
+		if err := m.runtime.Spool.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close clickhouse spool: %w", err))
+		}
+	}
+	if m.runtime.Pool != nil {
+		if err := m.runtime.Pool.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close ws pool: %w", err))
+		}
+	}
+	if m.runtime.History != nil {
+		m.runtime.History.Close()
+	}
+	if m.runtime.Books != nil {
+		m.runtime.Books.Close()
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,170 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"trader/internal/config"
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+	"trader/internal/core/orderbook"
+	"trader/internal/core/ws"
+	"trader/internal/task"
+	"trader/internal/task/tasktest"
+)
+
+const testExchangeID = "manager-test"
+
+var (
+	testEndpointMu sync.Mutex
+	testEndpoint   string
+	registerOnce   sync.Once
+)
+
+// testDriver - драйвер локальной биржи: подписка {"op":"subscribe","args":[pairs]}
+type testDriver struct{}
+
+func (testDriver) GetExchangeID() string { return testExchangeID }
+func (testDriver) GetName() string       { return "Manager test" }
+
+func (testDriver) GetWSEndpoint(string) (string, error) {
+	testEndpointMu.Lock()
+	defer testEndpointMu.Unlock()
+	return testEndpoint, nil
+}
+
+func (testDriver) CreateSubscribeMessage(_ string, pairs []string, _ int) ([][]byte, error) {
+	frame, err := json.Marshal(map[string]any{"op": "subscribe", "args": pairs})
+	return [][]byte{frame}, err
+}
+
+func (testDriver) CreateUnsubscribeMessage(_ string, pairs []string, _ int) ([][]byte, error) {
+	frame, err := json.Marshal(map[string]any{"op": "unsubscribe", "args": pairs})
+	return [][]byte{frame}, err
+}
+
+func (testDriver) ParseMessage(string, []byte) ([]*messaging.Message, error) {
+	return nil, nil
+}
+
+// startExchange поднимает локальную биржу, которая принимает подписки и молчит
+func startExchange(t *testing.T) {
+	t.Helper()
+	registerOnce.Do(func() {
+		exchange.Register(testExchangeID, func() exchange.ExchangeDriver { return testDriver{} })
+	})
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	testEndpointMu.Lock()
+	testEndpoint = "ws" + strings.TrimPrefix(server.URL, "http")
+	testEndpointMu.Unlock()
+}
+
+// subscribedPairs возвращает пары пула с глубиной подписки
+func subscribedPairs(pool *ws.Pool, marketType string) map[string]int {
+	pairs := make(map[string]int)
+	for _, sub := range pool.GetSubscriptions(testExchangeID, marketType) {
+		pairs[sub.Pair] = sub.Depth
+	}
+	return pairs
+}
+
+// historyPatterns возвращает ключи пар, которые записывает история
+func historyPatterns(books *orderbook.Manager) []string {
+	for _, stats := range books.SubscriberStats() {
+		if strings.HasPrefix(stats.ID, "orderbook-history-") {
+			patterns := append([]string(nil), stats.Patterns...)
+			sort.Strings(patterns)
+			return patterns
+		}
+	}
+	return nil
+}
+
+func waitFor(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFetchedTasksAreApplied(t *testing.T) {
+	startExchange(t)
+
+	db := tasktest.New()
+	db.SetMonitoring(tasktest.Monitoring{
+		ID: 1, ExchangeID: testExchangeID, MarketType: exchange.MarketSpot,
+		Base: "BTC", Quote: "USDT", OrderbookDepth: 20, RingBufferSize: 100,
+	})
+	db.SetTrades(tasktest.Trade{
+		ID: 7, ExchangeID: testExchangeID, MarketType: exchange.MarketSpot,
+		Base: "ETH", Quote: "USDT", StrategyID: "arbitrage", BBOOnly: true,
+	})
+
+	pool := ws.NewPool()
+	books := orderbook.NewManager(pool)
+	pool.AddHandler(books.HandleMessage)
+	m := New(&config.Config{}, Runtime{
+		Tasks:         task.NewFetcher(db.Open(), 20*time.Millisecond),
+		Pool:          pool,
+		Books:         books,
+		Subscriptions: task.NewSubscriptionManager(pool),
+		History:       orderbook.NewHistory(books, 10),
+	})
+	defer func() {
+		if err := m.closeRuntime(); err != nil {
+			t.Errorf("closeRuntime: %v", err)
+		}
+	}()
+
+	// Первая загрузка применяется до возврата из startTasks
+	if err := m.startTasks(); err != nil {
+		t.Fatalf("startTasks: %v", err)
+	}
+	pairs := subscribedPairs(pool, exchange.MarketSpot)
+	if len(pairs) != 2 || pairs["BTC/USDT"] != 20 || pairs["ETH/USDT"] != exchange.BBOFallbackDepth {
+		t.Fatalf("subscriptions after start = %v", pairs)
+	}
+	if got := historyPatterns(books); strings.Join(got, " ") != testExchangeID+":spot:BTC/USDT" {
+		t.Fatalf("history pairs after start = %v", got)
+	}
+
+	// Изменение задач применяется следующей загрузкой
+	db.SetMonitoring(tasktest.Monitoring{
+		ID: 2, ExchangeID: testExchangeID, MarketType: exchange.MarketSpot,
+		Base: "SOL", Quote: "USDT", OrderbookDepth: 50,
+	})
+	waitFor(t, "changed tasks to be applied", func() bool {
+		pairs := subscribedPairs(pool, exchange.MarketSpot)
+		history := historyPatterns(books)
+		return len(pairs) == 2 && pairs["SOL/USDT"] == 50 && pairs["ETH/USDT"] == exchange.BBOFallbackDepth &&
+			strings.Join(history, " ") == testExchangeID+":spot:SOL/USDT"
+	})
+
+}
@@ -0,0 +1,370 @@
+// Package clickhouse - клиент ClickHouse через HTTP интерфейс (порт 8123)
+//
+// Данные отправляются batch запросами INSERT ... FORMAT RowBinary или JSONEachRow,
+// запрос в query string, строки в теле. При Compression тело сжимается gzip.
+// Временные ошибки (сеть, 5xx) повторяются MaxRetries раз после первой попытки.
+// Клиент работает с любым HTTP сервером по адресу Host:Port, поэтому в тестах
+// его можно направить на httptest.Server.
+package clickhouse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"trader/internal/config"
+	"trader/internal/logger"
+)
+
+// Format - формат данных INSERT
+type Format string
+
+const (
+	// FormatRowBinary - бинарный построчный формат, самый компактный
+	FormatRowBinary Format = "RowBinary"
+	// FormatJSONEachRow - JSON объект на строку, колонки по именам полей
+	FormatJSONEachRow Format = "JSONEachRow"
+)
+
+const (
+	// requestTimeout - предельное время одного HTTP запроса вместе с чтением ответа
+	requestTimeout = 60 * time.Second
+	// retryDelay и maxRetryDelay - пауза перед повтором, удваивается с каждой попыткой
+	retryDelay    = 500 * time.Millisecond
+	maxRetryDelay = 10 * time.Second
+	// maxErrorBody - сколько байт ответа с ошибкой попадает в текст ошибки
+	maxErrorBody = 4096
+)
+
+// ServerError - ClickHouse ответил статусом, отличным от 200
+type ServerError struct {
+	StatusCode int
+	// Code - код исключения ClickHouse из заголовка X-ClickHouse-Exception-Code
+	Code    string
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("clickhouse status %d, code %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("clickhouse status %d: %s", e.StatusCode, e.Message)
+}
+
+// Temporary сообщает, имеет ли смысл повторить запрос:
+// 5xx и 429 - сервер перегружен или перезапускается, 4xx - ошибка в запросе или данных
+func (e *ServerError) Temporary() bool {
+	return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+}
+
+// Retryable сообщает, временная ли ошибка запроса: сеть недоступна или сервер вернул 5xx
+// Ошибки отмены контекста не повторяются
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var serverErr *ServerError
+	if errors.As(err, &serverErr) {
+		return serverErr.Temporary()
+	}
+	return true
+}
+
+// Batch - закодированные строки одной таблицы, готовые к отправке
+// Хранит тело запроса целиком, поэтому может быть повторен или сохранен на диск
+type Batch struct {
+	Table string
+	// Columns - колонки INSERT, для JSONEachRow могут быть пустыми (берутся из полей)
+	Columns []string
+	Format  Format
+	Data    []byte
+	Rows    int
+}
+
+// Query возвращает текст INSERT запроса batch
+func (b Batch) Query() string {
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO ")
+	sb.WriteString(b.Table)
+	if len(b.Columns) > 0 {
+		sb.WriteString(" (")
+		sb.WriteString(strings.Join(b.Columns, ", "))
+		sb.WriteString(")")
+	}
+	sb.WriteString(" FORMAT ")
+	sb.WriteString(string(b.Format))
+	return sb.String()
+}
+
+// EncodeJSONEachRow кодирует строки (структуры с json тегами по именам колонок) в JSONEachRow batch
+func EncodeJSONEachRow[R any](table string, rows []R) (Batch, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i := range rows {
+		if err := encoder.Encode(rows[i]); err != nil {
+			return Batch{}, fmt.Errorf("%s: encode row %d: %w", table, i, err)
+		}
+	}
+	return Batch{
+		Table:  table,
+		Format: FormatJSONEachRow,
+		Data:   buf.Bytes(),
+		Rows:   len(rows),
+	}, nil
+}
+
+// Client - клиент HTTP интерфейса ClickHouse
+// Безопасен для конкурентного использования
+type Client struct {
+	endpoint     string
+	database     string
+	username     string
+	password     string
+	compression  bool
+	maxRetries   int
+	maxBatchSize int
+	retryDelay   time.Duration
+
+	http *http.Client
+	log  *slog.Logger
+}
+
+// NewClient создает клиент по конфигурации, соединение не проверяется (см. Ping)
+func NewClient(cfg config.ClickHouseConfig) (*Client, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("clickhouse host is empty")
+	}
+
+	scheme := "http"
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.UseTLS {
+		scheme = "https"
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: cfg.TLSSkipVerify}
+	}
+	if cfg.ConnectTimeout > 0 {
+		timeout := time.Duration(cfg.ConnectTimeout) * time.Second
+		transport.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+		transport.TLSHandshakeTimeout = timeout
+	}
+
+	endpoint := url.URL{Scheme: scheme, Host: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)), Path: "/"}
+	return &Client{
+		endpoint:     endpoint.String(),
+		database:     cfg.Database,
+		username:     cfg.Username,
+		password:     cfg.Password,
+		compression:  cfg.Compression,
+		maxRetries:   max(cfg.MaxRetries, 0),
+		maxBatchSize: cfg.MaxBatchSize,
+		retryDelay:   retryDelay,
+		http:         &http.Client{Transport: transport, Timeout: requestTimeout},
+		log:          logger.Get("clickhouse"),
+	}, nil
+}
+
+// MaxBatchSize возвращает максимальное количество строк в одном INSERT, 0 - без ограничения
+func (c *Client) MaxBatchSize() int { return c.maxBatchSize }
+
+// Ping проверяет доступность сервера (GET /ping)
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"ping", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("clickhouse ping: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("clickhouse ping: %w", readServerError(resp))
+	}
+	return nil
+}
+
+// Exec выполняет запрос без результата (DDL, ALTER) с повторами
+func (c *Client) Exec(ctx context.Context, query string) error {
+	_, err := c.retry(ctx, query, nil, 0, false)
+	return err
+}
+
+// Query выполняет запрос и возвращает тело ответа в формате из FORMAT запроса
+func (c *Client) Query(ctx context.Context, query string) ([]byte, error) {
+	return c.retry(ctx, query, nil, 0, true)
+}
+
+// Send отправляет batch одним INSERT с повторами временных ошибок
+func (c *Client) Send(ctx context.Context, batch Batch) error {
+	if batch.Rows == 0 {
+		return nil
+	}
+	body := batch.Data
+	if c.compression {
+		compressed, err := gzipBody(body)
+		if err != nil {
+			return fmt.Errorf("%s: compress batch: %w", batch.Table, err)
+		}
+		body = compressed
+	}
+
+	start := time.Now()
+	if _, err := c.retry(ctx, batch.Query(), body, batch.Rows, false); err != nil {
+		return fmt.Errorf("insert %d rows into %s: %w", batch.Rows, batch.Table, err)
+	}
+	c.log.Debug("batch inserted",
+		"table", batch.Table,
+		"format", batch.Format,
+		"rows", batch.Rows,
+		"bytes", len(batch.Data),
+		"sent_bytes", len(body),
+		"latency_ms", float64(time.Since(start).Microseconds())/1000.0,
+	)
+	return nil
+}
+
+// InsertJSONEachRow отправляет строки в формате JSONEachRow частями по MaxBatchSize
+func InsertJSONEachRow[R any](ctx context.Context, c *Client, table string, rows []R) error {
+	for _, chunk := range chunks(rows, c.maxBatchSize) {
+		batch, err := EncodeJSONEachRow(table, chunk)
+		if err != nil {
+			return err
+		}
+		if err := c.Send(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InsertRowBinary отправляет строки в формате RowBinary частями по MaxBatchSize
+func InsertRowBinary[R RowBinaryRow](ctx context.Context, c *Client, table string, columns []string, rows []R) error {
+	for _, chunk := range chunks(rows, c.maxBatchSize) {
+		batch, err := EncodeRowBinary(table, columns, chunk)
+		if err != nil {
+			return err
+		}
+		if err := c.Send(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunks делит строки на части не больше size, size <= 0 - одна часть
+func chunks[R any](rows []R, size int) [][]R {
+	if size <= 0 || len(rows) <= size {
+		return [][]R{rows}
+	}
+	parts := make([][]R, 0, (len(rows)+size-1)/size)
+	for start := 0; start < len(rows); start += size {
+		parts = append(parts, rows[start:min(start+size, len(rows))])
+	}
+	return parts
+}
+
+// retry выполняет запрос и повторяет его до maxRetries раз, пока ошибка временная
+func (c *Client) retry(ctx context.Context, query string, body []byte, rows int, readBody bool) ([]byte, error) {
+	delay := c.retryDelay
+	for attempt := 1; ; attempt++ {
+		out, err := c.do(ctx, query, body, readBody)
+		if err == nil {
+			return out, nil
+		}
+		if attempt > c.maxRetries || !Retryable(err) {
+			return nil, err
+		}
+
+		c.log.Warn("clickhouse request failed, retrying",
+			"attempt", attempt,
+			"max_retries", c.maxRetries,
+			"rows", rows,
+			"retry_in_ms", delay.Milliseconds(),
+			"error", err,
+		)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// do выполняет один POST запрос: query в query string, данные в теле
+func (c *Client) do(ctx context.Context, query string, body []byte, readBody bool) ([]byte, error) {
+	params := url.Values{}
+	if c.database != "" {
+		params.Set("database", c.database)
+	}
+	var reader io.Reader
+	if body != nil {
+		params.Set("query", query)
+		reader = bytes.NewReader(body)
+	} else {
+		reader = strings.NewReader(query)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"?"+params.Encode(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if c.username != "" {
+		req.Header.Set("X-ClickHouse-User", c.username)
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+	if body != nil && c.compression {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, readServerError(resp)
+	}
+	if !readBody {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, nil
+	}
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	return out, nil
+}
+
+func readServerError(resp *http.Response) error {
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return &ServerError{
+		StatusCode: resp.StatusCode,
+		Code:       resp.Header.Get("X-ClickHouse-Exception-Code"),
+		Message:    strings.TrimSpace(string(snippet)),
+	}
+}
+
+func gzipBody(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,213 @@
+package clickhouse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"trader/internal/config"
+)
+
+// request - запрос, полученный тестовым сервером
+type request struct {
+	query    string
+	database string
+	encoding string
+	body     []byte
+}
+
+// server - HTTP интерфейс ClickHouse, который отвечает статусами из statuses по очереди,
+// после них - 200
+type server struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []request
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, _ = io.ReadAll(reader)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, request{
+		query:    r.URL.Query().Get("query"),
+		database: r.URL.Query().Get("database"),
+		encoding: r.Header.Get("Content-Encoding"),
+		body:     body,
+	})
+	if len(s.statuses) > 0 {
+		status := s.statuses[0]
+		s.statuses = s.statuses[1:]
+		w.Header().Set("X-ClickHouse-Exception-Code", "999")
+		http.Error(w, "Code: 999. DB::Exception: test failure", status)
+	}
+}
+
+func (s *server) received() []request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]request(nil), s.requests...)
+}
+
+// newTestServer поднимает тестовый сервер и направляет на него cfg
+func newTestServer(t *testing.T, cfg *config.ClickHouseConfig, statuses ...int) *server {
+	t.Helper()
+	srv := &server{statuses: statuses}
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	u, _ := url.Parse(ts.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	cfg.Host = host
+	cfg.Port, _ = strconv.Atoi(port)
+	if cfg.Database == "" {
+		cfg.Database = "crypto"
+	}
+	return srv
+}
+
+// newTestClient направляет клиент на тестовый сервер без пауз между повторами
+func newTestClient(t *testing.T, cfg config.ClickHouseConfig, statuses ...int) (*Client, *server) {
+	t.Helper()
+	srv := newTestServer(t, &cfg, statuses...)
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client.retryDelay = 0
+	return client, srv
+}
+
+// tradeRow - строка с UInt64 и String колонками
+type tradeRow struct {
+	id   uint64
+	side string
+}
+
+func (r tradeRow) EncodeRowBinary(w *RowBinary) error {
+	w.UInt64(r.id)
+	w.String(r.side)
+	w.EndRow()
+	return nil
+}
+
+func TestInsertRowBinary(t *testing.T) {
+	for _, compression := range []bool{false, true} {
+		t.Run("compression="+strconv.FormatBool(compression), func(t *testing.T) {
+			client, srv := newTestClient(t, config.ClickHouseConfig{Compression: compression})
+
+			rows := []tradeRow{{1, "buy"}, {2, "sell"}}
+			if err := InsertRowBinary(context.Background(), client, "trades", []string{"id", "side"}, rows); err != nil {
+				t.Fatalf("InsertRowBinary: %v", err)
+			}
+
+			requests := srv.received()
+			if len(requests) != 1 {
+				t.Fatalf("got %d requests, want 1", len(requests))
+			}
+			req := requests[0]
+			if req.query != "INSERT INTO trades (id, side) FORMAT RowBinary" || req.database != "crypto" {
+				t.Errorf("query = %q, database = %q", req.query, req.database)
+			}
+			if wantEncoding := map[bool]string{true: "gzip"}[compression]; req.encoding != wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", req.encoding, wantEncoding)
+			}
+
+			var want []byte
+			for _, row := range rows {
+				want = binary.LittleEndian.AppendUint64(want, row.id)
+				want = append(want, byte(len(row.side)))
+				want = append(want, row.side...)
+			}
+			if !bytes.Equal(req.body, want) {
+				t.Errorf("body = %x, want %x", req.body, want)
+			}
+		})
+	}
+}
+
+func TestInsertJSONEachRowSplitsBatches(t *testing.T) {
+	client, srv := newTestClient(t, config.ClickHouseConfig{Compression: true, MaxBatchSize: 2})
+
+	type row struct {
+		ID   int    `json:"id"`
+		Side string `json:"side"`
+	}
+	rows := []row{{1, "buy"}, {2, "sell"}, {3, "buy"}}
+	if err := InsertJSONEachRow(context.Background(), client, "trades", rows); err != nil {
+		t.Fatalf("InsertJSONEachRow: %v", err)
+	}
+
+	requests := srv.received()
+	want := []string{
+		"{\"id\":1,\"side\":\"buy\"}\n{\"id\":2,\"side\":\"sell\"}\n",
+		"{\"id\":3,\"side\":\"buy\"}\n",
+	}
+	if len(requests) != len(want) {
+		t.Fatalf("got %d requests, want %d", len(requests), len(want))
+	}
+	for i, req := range requests {
+		if req.query != "INSERT INTO trades FORMAT JSONEachRow" || req.encoding != "gzip" {
+			t.Errorf("request %d: query = %q, Content-Encoding = %q", i, req.query, req.encoding)
+		}
+		if string(req.body) != want[i] {
+			t.Errorf("request %d: body = %q, want %q", i, req.body, want[i])
+		}
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	batch := Batch{Table: "trades", Format: FormatJSONEachRow, Data: []byte("{\"id\":1}\n"), Rows: 1}
+
+	for _, tc := range []struct {
+		name       string
+		maxRetries int
+		statuses   []int
+		requests   int
+		// status - ожидаемый статус ServerError, 0 - успешная отправка
+		status int
+	}{
+		{"5xx retried until success", 2, []int{503, 500}, 3, 0},
+		{"5xx retries exhausted", 2, []int{503, 503, 503, 503}, 3, 503},
+		{"429 retried", 1, []int{429}, 2, 0},
+		{"4xx not retried", 3, []int{400}, 1, 400},
+		{"no retries", 0, []int{502}, 1, 502},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, srv := newTestClient(t, config.ClickHouseConfig{MaxRetries: tc.maxRetries}, tc.statuses...)
+
+			err := client.Send(context.Background(), batch)
+			if got := len(srv.received()); got != tc.requests {
+				t.Errorf("requests = %d, want %d", got, tc.requests)
+			}
+			if tc.status == 0 {
+				if err != nil {
+					t.Fatalf("Send: %v", err)
+				}
+				return
+			}
+			var serverErr *ServerError
+			if !errors.As(err, &serverErr) || serverErr.StatusCode != tc.status || serverErr.Code != "999" {
+				t.Fatalf("Send error = %v, want ServerError %d", err, tc.status)
+			}
+		})
+	}
+}
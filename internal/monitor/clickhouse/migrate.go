@@ -0,0 +1,171 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"trader/internal/config"
+	"trader/internal/logger"
+)
+
+// ============================================================================
+// Применение миграций схемы
+// ============================================================================
+
+// Migrator применяет Migrations к базе из конфигурации
+// Запросы идут без database в параметрах: база создается самой миграцией,
+// все таблицы указываются с именем базы
+type Migrator struct {
+	client     *Client
+	schema     Schema
+	migrations []Migration
+	log        *slog.Logger
+}
+
+// MigrationStatus - версия схемы и время ее применения (нулевое, если не применена)
+type MigrationStatus struct {
+	Version     int
+	Description string
+	AppliedAt   time.Time
+}
+
+// NewMigrator создает мигратор по конфигурации ClickHouse
+// ReplicationFactor > 1 выбирает ReplicatedMergeTree, Cluster - DDL с ON CLUSTER
+func NewMigrator(cfg config.ClickHouseConfig) (*Migrator, error) {
+	if cfg.Database == "" {
+		return nil, errors.New("clickhouse database is empty")
+	}
+	database := cfg.Database
+	cfg.Database = ""
+	client, err := NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := append([]Migration(nil), Migrations...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return &Migrator{
+		client:     client,
+		schema:     Schema{Database: database, Replicated: cfg.ReplicationFactor > 1, Cluster: cfg.Cluster},
+		migrations: migrations,
+		log:        logger.Get("clickhouse"),
+	}, nil
+}
+
+// Run создает базу и таблицу версий и применяет непримененные миграции по возрастанию
+// Возвращает количество примененных миграций. Остановка на первой ошибке:
+// следующий запуск продолжит с нее
+func (m *Migrator) Run(ctx context.Context) (int, error) {
+	if err := m.prepare(ctx); err != nil {
+		return 0, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		start := time.Now()
+		for i, statement := range migration.Statements(m.schema) {
+			if err := m.client.Exec(ctx, statement); err != nil {
+				return count, fmt.Errorf("migration %d (%s) statement %d: %w", migration.Version, migration.Description, i+1, err)
+			}
+		}
+		if err := m.record(ctx, migration); err != nil {
+			return count, err
+		}
+		count++
+
+		m.log.Info("clickhouse migration applied",
+			"database", m.schema.Database,
+			"version", migration.Version,
+			"description", migration.Description,
+			"replicated", m.schema.Replicated,
+			"cluster", m.schema.Cluster,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000.0,
+		)
+	}
+	return count, nil
+}
+
+// Status возвращает все известные версии схемы с отметкой о применении
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.prepare(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		statuses = append(statuses, MigrationStatus{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   applied[migration.Version],
+		})
+	}
+	return statuses, nil
+}
+
+// prepare создает базу и таблицу версий
+func (m *Migrator) prepare(ctx context.Context) error {
+	if err := m.client.Exec(ctx, "CREATE DATABASE IF NOT EXISTS "+m.schema.Database+m.schema.OnCluster()); err != nil {
+		return fmt.Errorf("create database %s: %w", m.schema.Database, err)
+	}
+	if err := m.client.Exec(ctx, migrationsTable(m.schema)); err != nil {
+		return fmt.Errorf("create %s: %w", TableMigrations, err)
+	}
+	return nil
+}
+
+// applied возвращает примененные версии и время их применения
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	query := fmt.Sprintf("SELECT version, toUnixTimestamp64Micro(applied_at) FROM %s FORMAT TabSeparated",
+		m.schema.Table(TableMigrations))
+	out, err := m.client.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", TableMigrations, err)
+	}
+
+	applied := make(map[int]time.Time)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line == "" {
+			continue
+		}
+		versionField, appliedField, _ := strings.Cut(line, "\t")
+		version, err := strconv.Atoi(versionField)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s row %q: %w", TableMigrations, line, err)
+		}
+		micros, _ := strconv.ParseInt(appliedField, 10, 64)
+		applied[version] = time.UnixMicro(micros).UTC()
+	}
+	return applied, nil
+}
+
+// record отмечает миграцию примененной
+func (m *Migrator) record(ctx context.Context, migration Migration) error {
+	query := fmt.Sprintf("INSERT INTO %s (version, description, applied_at) VALUES (%d, '%s', fromUnixTimestamp64Micro(%d))",
+		m.schema.Table(TableMigrations), migration.Version, escapeString(migration.Description), time.Now().UnixMicro())
+	if err := m.client.Exec(ctx, query); err != nil {
+		return fmt.Errorf("record migration %d: %w", migration.Version, err)
+	}
+	return nil
+}
+
+// escapeString экранирует строковый литерал для запроса
+func escapeString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
@@ -0,0 +1,55 @@
+package clickhouse
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"trader/internal/config"
+)
+
+func TestMigrateOnCluster(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  config.ClickHouseConfig
+		// onCluster - все DDL должны идти с ON CLUSTER
+		onCluster bool
+	}{
+		{"replicated cluster", config.ClickHouseConfig{ReplicationFactor: 2, Cluster: "trader"}, true},
+		{"replicated without cluster", config.ClickHouseConfig{ReplicationFactor: 2}, false},
+		{"single server ignores cluster", config.ClickHouseConfig{ReplicationFactor: 1, Cluster: "trader"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := tc.cfg
+			srv := newTestServer(t, &cfg)
+			migrator, err := NewMigrator(cfg)
+			if err != nil {
+				t.Fatalf("NewMigrator: %v", err)
+			}
+			if _, err := migrator.Run(context.Background()); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			// DDL без данных передается в теле запроса
+			var ddl []string
+			for _, req := range srv.received() {
+				if statement := string(req.body); strings.HasPrefix(statement, "CREATE ") {
+					ddl = append(ddl, statement)
+				}
+			}
+			// База, таблица версий и таблицы миграций
+			if want := 2 + len(Migrations); len(ddl) != want {
+				t.Fatalf("got %d DDL statements, want %d", len(ddl), want)
+			}
+			if !strings.HasPrefix(ddl[1], "CREATE TABLE IF NOT EXISTS crypto.schema_migrations") {
+				t.Errorf("second statement = %q, want versions table", ddl[1])
+			}
+			for _, statement := range ddl {
+				header, _, _ := strings.Cut(statement, "\n")
+				if got := strings.Contains(header, " ON CLUSTER trader"); got != tc.onCluster {
+					t.Errorf("ON CLUSTER = %v, want %v: %s", got, tc.onCluster, header)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package clickhouse
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/shopspring/decimal"
+)
+
+// ============================================================================
+// Кодирование строк в формат RowBinary
+// ============================================================================
+
+// RowBinary накапливает строки в формате RowBinary: значения колонок подряд,
+// числа little-endian, строки и массивы с длиной в varint
+// Порядок значений должен совпадать с колонками INSERT
+type RowBinary struct {
+	buf  []byte
+	rows int
+}
+
+// ErrDecimalOverflow - значение не помещается в Decimal128 (больше 38 значащих цифр)
+var ErrDecimalOverflow = errors.New("decimal overflows Decimal128")
+
+// decimal128Limit - 10^38, предел модуля целого Decimal(38, S)
+var decimal128Limit = new(big.Int).Exp(big.NewInt(10), big.NewInt(38), nil)
+
+// RowBinaryRow - строка, умеющая записать себя в RowBinary
+// Ошибка означает, что значение строки нельзя записать без потери (ErrDecimalOverflow)
+type RowBinaryRow interface {
+	EncodeRowBinary(w *RowBinary) error
+}
+
+// EndRow отмечает конец строки, используется для подсчета строк batch
+func (w *RowBinary) EndRow() { w.rows++ }
+
+// Rows возвращает количество законченных строк
+func (w *RowBinary) Rows() int { return w.rows }
+
+// Bytes возвращает закодированные данные
+func (w *RowBinary) Bytes() []byte { return w.buf }
+
+// Len возвращает размер закодированных данных в байтах
+func (w *RowBinary) Len() int { return len(w.buf) }
+
+// UVarInt пишет беззнаковый LEB128 (длины строк и массивов)
+func (w *RowBinary) UVarInt(v uint64) { w.buf = binary.AppendUvarint(w.buf, v) }
+
+// ArrayLen пишет длину Array(T), затем вызывающий пишет элементы
+func (w *RowBinary) ArrayLen(n int) { w.UVarInt(uint64(n)) }
+
+// String пишет String
+func (w *RowBinary) String(v string) {
+	w.UVarInt(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+// Bool пишет Bool (UInt8 0/1)
+func (w *RowBinary) Bool(v bool) {
+	if v {
+		w.UInt8(1)
+		return
+	}
+	w.UInt8(0)
+}
+
+// UInt8 пишет UInt8 и Enum8
+func (w *RowBinary) UInt8(v uint8) { w.buf = append(w.buf, v) }
+
+// UInt16 пишет UInt16
+func (w *RowBinary) UInt16(v uint16) { w.buf = binary.LittleEndian.AppendUint16(w.buf, v) }
+
+// UInt32 пишет UInt32 и DateTime (секунды Unix)
+func (w *RowBinary) UInt32(v uint32) { w.buf = binary.LittleEndian.AppendUint32(w.buf, v) }
+
+// UInt64 пишет UInt64
+func (w *RowBinary) UInt64(v uint64) { w.buf = binary.LittleEndian.AppendUint64(w.buf, v) }
+
+// Int64 пишет Int64
+func (w *RowBinary) Int64(v int64) { w.UInt64(uint64(v)) }
+
+// Float64 пишет Float64
+func (w *RowBinary) Float64(v float64) { w.UInt64(math.Float64bits(v)) }
+
+// DateTime64 пишет DateTime64(6) из Unix микросекунд (формат Timestamp сообщений)
+func (w *RowBinary) DateTime64(micros int64) { w.Int64(micros) }
+
+// Decimal128 пишет Decimal(P, scale) с P от 19 до 38: целое value * 10^scale,
+// 16 байт little-endian в дополнительном коде. Лишние знаки после scale отбрасываются
+// Значение, чье целое не меньше 10^38 по модулю, не пишется: ErrDecimalOverflow
+func (w *RowBinary) Decimal128(v decimal.Decimal, scale int32) error {
+	n := v.Shift(scale).BigInt()
+	if new(big.Int).Abs(n).Cmp(decimal128Limit) >= 0 {
+		return fmt.Errorf("%w: %s with scale %d", ErrDecimalOverflow, v, scale)
+	}
+
+	var out [16]byte
+	if n.Sign() < 0 {
+		// Дополнительный код: 2^128 + n
+		n = new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), 128))
+	}
+	raw := n.Bytes() // big-endian, не длиннее 16 байт после проверки предела
+	for i, b := range raw {
+		out[len(raw)-1-i] = b
+	}
+	w.buf = append(w.buf, out[:]...)
+	return nil
+}
+
+// Reset очищает буфер для переиспользования
+func (w *RowBinary) Reset() {
+	w.buf = w.buf[:0]
+	w.rows = 0
+}
+
+// EncodeRowBinary кодирует строки в RowBinary batch таблицы
+// Строка, которую нельзя записать, отменяет весь batch
+func EncodeRowBinary[R RowBinaryRow](table string, columns []string, rows []R) (Batch, error) {
+	if len(columns) == 0 {
+		return Batch{}, fmt.Errorf("%s: RowBinary insert needs column list", table)
+	}
+	var w RowBinary
+	for i, row := range rows {
+		if err := row.EncodeRowBinary(&w); err != nil {
+			return Batch{}, fmt.Errorf("%s: row %d: %w", table, i, err)
+		}
+		w.EndRow()
+	}
+	return Batch{
+		Table:   table,
+		Columns: columns,
+		Format:  FormatRowBinary,
+		Data:    w.Bytes(),
+		Rows:    w.Rows(),
+	}, nil
+}
@@ -0,0 +1,79 @@
+package clickhouse
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+)
+
+func TestDecimal128(t *testing.T) {
+	max38 := strings.Repeat("9", 20) + "." + strings.Repeat("9", 18)
+
+	for _, tc := range []struct {
+		name  string
+		value string
+		scale int32
+		// want - 16 байт little-endian в hex, "" - ErrDecimalOverflow
+		want string
+	}{
+		{name: "zero", value: "0", scale: 18, want: "00000000000000000000000000000000"},
+		{name: "one", value: "1", scale: 0, want: "01000000000000000000000000000000"},
+		{name: "minus one", value: "-1", scale: 0, want: "ffffffffffffffffffffffffffffffff"},
+		{name: "scaled", value: "1.5", scale: 2, want: "96000000000000000000000000000000"},
+		{name: "extra digits dropped", value: "0.129", scale: 2, want: "0c000000000000000000000000000000"},
+		{name: "one with scale 18", value: "1", scale: 18, want: "000064a7b3b6e00d0000000000000000"},
+		// 10^38 - 1: наибольшее значение Decimal(38, 18)
+		{name: "max precision", value: max38, scale: 18, want: "ffffffff3f228a097ac4865aa84c3b4b"},
+		{name: "min precision", value: "-" + max38, scale: 18, want: "01000000c0dd75f6853b79a557b3c4b4"},
+		{name: "overflow", value: "100000000000000000000", scale: 18},
+		{name: "negative overflow", value: "-100000000000000000000", scale: 18},
+		// Раньше старшие байты отбрасывались и в колонку попадало чужое число
+		{name: "beyond int128", value: "1e40", scale: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var w RowBinary
+			err := w.Decimal128(decimal.RequireFromString(tc.value), tc.scale)
+			if tc.want == "" {
+				if !errors.Is(err, ErrDecimalOverflow) {
+					t.Fatalf("Decimal128(%s) error = %v, want ErrDecimalOverflow", tc.value, err)
+				}
+				if w.Len() != 0 {
+					t.Errorf("overflowing value wrote %d bytes", w.Len())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decimal128(%s): %v", tc.value, err)
+			}
+			if got := hex.EncodeToString(w.Bytes()); got != tc.want {
+				t.Errorf("Decimal128(%s) = %s, want %s", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncodeRowBinaryRejectsOverflow(t *testing.T) {
+	level := func(price string) exchange.Level {
+		return exchange.Level{Price: decimal.RequireFromString(price), Amount: decimal.NewFromInt(1)}
+	}
+	rows := []SnapshotRow{
+		{ExchangeID: exchange.Binance, Pair: "BTC/USDT", Bids: []exchange.Level{level("100")}},
+		{ExchangeID: exchange.Binance, Pair: "SHIB/USDT", Asks: []exchange.Level{level("1e21")}},
+	}
+
+	if _, err := EncodeRowBinary(TableOrderBookSnapshots, SnapshotColumns, rows[:1]); err != nil {
+		t.Fatalf("EncodeRowBinary: %v", err)
+	}
+	batch, err := EncodeRowBinary(TableOrderBookSnapshots, SnapshotColumns, rows)
+	if !errors.Is(err, ErrDecimalOverflow) {
+		t.Fatalf("EncodeRowBinary error = %v, want ErrDecimalOverflow", err)
+	}
+	if !strings.Contains(err.Error(), "row 1: asks") || batch.Rows != 0 || batch.Data != nil {
+		t.Errorf("EncodeRowBinary = %+v, %v, want empty batch with row 1 asks error", batch, err)
+	}
+}
@@ -0,0 +1,151 @@
+package clickhouse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ============================================================================
+// Схемы таблиц ClickHouse
+// ============================================================================
+
+// Таблицы с рыночными данными партиционированы по дню и отсортированы по
+// (exchange_id, market_type, pair, timestamp): запросы почти всегда по одной паре
+// за интервал времени. Старые партиции удаляются по TTL.
+// Цены и объемы - Decimal(38, 18) (DecimalScale), время - DateTime64(6) в UTC (Unix μs).
+
+const (
+	// TableTrades - сделки бирж
+	TableTrades = "trades"
+	// TableBBO - лучшие bid/ask
+	TableBBO = "bbo"
+	// TableMigrations - примененные версии схемы
+	TableMigrations = "schema_migrations"
+)
+
+const (
+	// SnapshotsTTLDays, TradesTTLDays, BBOTTLDays - сколько дней хранятся данные таблиц
+	SnapshotsTTLDays = 90
+	TradesTTLDays    = 180
+	BBOTTLDays       = 30
+)
+
+// Schema - параметры, от которых зависит текст DDL
+type Schema struct {
+	Database string
+	// Replicated - таблицы на ReplicatedMergeTree (ReplicationFactor > 1)
+	// На серверах должны быть объявлены макросы {shard} и {replica}
+	Replicated bool
+	// Cluster - кластер для ON CLUSTER, учитывается только при Replicated
+	Cluster string
+}
+
+// Table возвращает полное имя таблицы с базой данных
+func (s Schema) Table(name string) string {
+	if s.Database == "" {
+		return name
+	}
+	return s.Database + "." + name
+}
+
+// OnCluster возвращает " ON CLUSTER <cluster>" для DDL реплицируемой схемы,
+// иначе пустую строку: DDL выполняется только на сервере подключения
+func (s Schema) OnCluster() string {
+	if !s.Replicated || s.Cluster == "" {
+		return ""
+	}
+	return " ON CLUSTER " + s.Cluster
+}
+
+// Engine возвращает движок таблицы: MergeTree или ReplicatedMergeTree
+// с путем в ZooKeeper/Keeper по базе и имени таблицы
+func (s Schema) Engine() string {
+	if s.Replicated {
+		return "ReplicatedMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}')"
+	}
+	return "MergeTree"
+}
+
+// Migration - версия схемы
+type Migration struct {
+	// Version - номер версии, применяются по возрастанию
+	Version int
+	// Description - что меняет версия, сохраняется в TableMigrations
+	Description string
+	// Statements возвращает DDL версии. Каждый запрос должен быть идемпотентным
+	// (IF NOT EXISTS), чтобы прерванную миграцию можно было применить повторно
+	Statements func(s Schema) []string
+}
+
+// Migrations - все версии схемы по возрастанию
+// Новые изменения добавляются новой версией в конец, примененные версии не меняются
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create orderbook_snapshots",
+		Statements: func(s Schema) []string {
+			return []string{marketTable(s, TableOrderBookSnapshots, `
+	timestamp DateTime64(6, 'UTC'),
+	book_timestamp DateTime64(6, 'UTC'),
+	exchange_id LowCardinality(String),
+	market_type LowCardinality(String),
+	pair LowCardinality(String),
+	depth UInt16,
+	bids Array(Tuple(price Decimal(38, 18), amount Decimal(38, 18))),
+	asks Array(Tuple(price Decimal(38, 18), amount Decimal(38, 18))),
+	sequence_num Int64`, SnapshotsTTLDays)}
+		},
+	},
+	{
+		Version:     2,
+		Description: "create trades",
+		Statements: func(s Schema) []string {
+			return []string{marketTable(s, TableTrades, `
+	timestamp DateTime64(6, 'UTC'),
+	received_at DateTime64(6, 'UTC'),
+	exchange_id LowCardinality(String),
+	market_type LowCardinality(String),
+	pair LowCardinality(String),
+	trade_id String,
+	side LowCardinality(String),
+	price Decimal(38, 18),
+	amount Decimal(38, 18)`, TradesTTLDays)}
+		},
+	},
+	{
+		Version:     3,
+		Description: "create bbo",
+		Statements: func(s Schema) []string {
+			return []string{marketTable(s, TableBBO, `
+	timestamp DateTime64(6, 'UTC'),
+	received_at DateTime64(6, 'UTC'),
+	exchange_id LowCardinality(String),
+	market_type LowCardinality(String),
+	pair LowCardinality(String),
+	bid_price Decimal(38, 18),
+	bid_amount Decimal(38, 18),
+	ask_price Decimal(38, 18),
+	ask_amount Decimal(38, 18)`, BBOTTLDays)}
+		},
+	},
+}
+
+// migrationsTable возвращает DDL таблицы версий
+func migrationsTable(s Schema) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s%s (
+	version UInt32,
+	description String,
+	applied_at DateTime64(6, 'UTC')
+) ENGINE = %s
+ORDER BY version`, s.Table(TableMigrations), s.OnCluster(), s.Engine())
+}
+
+// marketTable возвращает DDL таблицы рыночных данных с колонкой timestamp
+func marketTable(s Schema, name, columns string, ttlDays int) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s%s (%s
+) ENGINE = %s
+PARTITION BY toDate(timestamp)
+ORDER BY (exchange_id, market_type, pair, timestamp)
+TTL toDateTime(timestamp) + INTERVAL %d DAY DELETE
+SETTINGS ttl_only_drop_parts = 1`, s.Table(name), s.OnCluster(), strings.TrimRight(columns, "\n"), s.Engine(), ttlDays)
+}
@@ -0,0 +1,522 @@
+package clickhouse
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"trader/internal/logger"
+)
+
+// ============================================================================
+// Дисковый буфер batch на время недоступности ClickHouse
+// ============================================================================
+
+// Batch, который не удалось отправить из-за недоступности сервера, дописывается
+// в сегмент на диске (append-only файл). Пока на диске есть непереданные batch,
+// новые тоже пишутся в сегменты, чтобы сохранить порядок вставки.
+// Фоновая горутина переотправляет сегменты от старых к новым сразу при запуске,
+// а затем, пока на диске есть batch, проверяет сервер и продолжает после его возврата.
+// Если сегменты занимают больше лимита, удаляются самые старые.
+// Доставка "хотя бы один раз": при остановке во время переотправки
+// запись, отправка которой не успела отметиться, будет отправлена повторно.
+
+const (
+	// DefaultSpoolSegmentBytes - размер сегмента, после которого начинается новый
+	DefaultSpoolSegmentBytes = 64 << 20
+	// spoolRetryInterval - как часто проверять сервер, пока на диске есть batch
+	spoolRetryInterval = 5 * time.Second
+	// spoolSendTimeout - предельное время переотправки одного batch
+	spoolSendTimeout = 2 * time.Minute
+	// segmentExt - расширение файлов сегментов, имя - номер сегмента
+	segmentExt = ".seg"
+	// offsetFile - номер сегмента и смещение первой непереданной записи
+	offsetFile = "replay.offset"
+	// recordHeaderSize - длина метаданных, длина данных и CRC32 записи
+	recordHeaderSize = 12
+)
+
+// errCorruptRecord - запись сегмента оборвана (остановка во время записи) или повреждена
+var errCorruptRecord = errors.New("corrupt spool record")
+
+// recordMeta - метаданные batch в записи сегмента
+type recordMeta struct {
+	Table   string   `json:"table"`
+	Columns []string `json:"columns,omitempty"`
+	Format  Format   `json:"format"`
+	Rows    int      `json:"rows"`
+}
+
+// segment - файл сегмента
+type segment struct {
+	seq  uint64
+	path string
+	size int64
+}
+
+// Spool отправляет batch через Client, а при недоступности ClickHouse - на диск
+// Реализует monitor.Sink. Безопасен для конкурентного использования
+type Spool struct {
+	client       *Client
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+	log          *slog.Logger
+
+	mu       sync.Mutex
+	segments []*segment // от старых к новым, последний может быть открыт на запись
+	active   *os.File   // открытый последний сегмент, nil если сегмент закрыт
+	total    int64
+	nextSeq  uint64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewSpool открывает каталог буфера (создает, если нет) и запускает переотправку
+// сегментов, оставшихся с прошлого запуска. maxBytes <= 0 - без лимита
+func NewSpool(client *Client, dir string, maxBytes int64) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create spool dir: %w", err)
+	}
+	s := &Spool{
+		client:       client,
+		dir:          dir,
+		maxBytes:     maxBytes,
+		segmentBytes: DefaultSpoolSegmentBytes,
+		log:          logger.Get("clickhouse"),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	if maxBytes > 0 {
+		s.segmentBytes = min(s.segmentBytes, max(maxBytes/4, 1))
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if len(s.segments) > 0 {
+		s.log.Warn("clickhouse spool has pending batches",
+			"dir", dir,
+			"segments", len(s.segments),
+			"bytes", s.total,
+		)
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// MaxBatchSize - monitor.Sink
+func (s *Spool) MaxBatchSize() int { return s.client.MaxBatchSize() }
+
+// Send отправляет batch или сохраняет его на диск, если сервер недоступен
+// Ошибка возвращается только если сервер отверг данные (4xx) или не удалось записать диск
+func (s *Spool) Send(ctx context.Context, batch Batch) error {
+	if batch.Rows == 0 {
+		return nil
+	}
+	if s.Pending() == 0 {
+		err := s.client.Send(ctx, batch)
+		if err == nil || rejected(err) {
+			return err
+		}
+		s.log.Warn("clickhouse unavailable, spooling batch",
+			"table", batch.Table,
+			"rows", batch.Rows,
+			"error", err,
+		)
+	}
+
+	if err := s.append(batch); err != nil {
+		return fmt.Errorf("spool %d rows of %s: %w", batch.Rows, batch.Table, err)
+	}
+	return nil
+}
+
+// Pending возвращает размер непереданных данных на диске в байтах
+func (s *Spool) Pending() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
+
+// Close останавливает переотправку и закрывает сегмент
+// Непереданные batch остаются на диске до следующего запуска
+func (s *Spool) Close() error {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seal()
+}
+
+// rejected - сервер доступен, но отверг batch: повтор не поможет
+func rejected(err error) bool {
+	var serverErr *ServerError
+	return errors.As(err, &serverErr) && !serverErr.Temporary()
+}
+
+// ============================================================================
+// Запись сегментов
+// ============================================================================
+
+// append дописывает batch в последний сегмент, вытесняя старые сегменты сверх лимита
+func (s *Spool) append(batch Batch) error {
+	record, err := encodeRecord(batch)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active != nil && s.segments[len(s.segments)-1].size+int64(len(record)) > s.segmentBytes {
+		if err := s.seal(); err != nil {
+			return err
+		}
+	}
+	if s.active == nil {
+		if err := s.openSegment(); err != nil {
+			return err
+		}
+	}
+	s.evict(int64(len(record)))
+
+	current := s.segments[len(s.segments)-1]
+	n, err := s.active.Write(record)
+	current.size += int64(n)
+	s.total += int64(n)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// openSegment создает новый сегмент, вызывается под s.mu
+func (s *Spool) openSegment() error {
+	seq := s.nextSeq
+	path := filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open spool segment: %w", err)
+	}
+	s.nextSeq++
+	s.active = file
+	s.segments = append(s.segments, &segment{seq: seq, path: path})
+	return nil
+}
+
+// seal закрывает последний сегмент, следующая запись начнет новый. Вызывается под s.mu
+func (s *Spool) seal() error {
+	if s.active == nil {
+		return nil
+	}
+	file := s.active
+	s.active = nil
+	syncErr := file.Sync()
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return syncErr
+}
+
+// evict удаляет самые старые закрытые сегменты, пока новая запись не помещается в лимит
+// Вызывается под s.mu
+func (s *Spool) evict(incoming int64) {
+	if s.maxBytes <= 0 {
+		return
+	}
+	for s.total+incoming > s.maxBytes && len(s.segments) > 1 {
+		oldest := s.segments[0]
+		if err := os.Remove(oldest.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.log.Error("spool segment eviction failed", "segment", oldest.path, "error", err)
+		}
+		s.segments = s.segments[1:]
+		s.total -= oldest.size
+		s.log.Error("spool size limit reached, oldest batches dropped",
+			"segment", filepath.Base(oldest.path),
+			"bytes", oldest.size,
+			"max_bytes", s.maxBytes,
+		)
+	}
+}
+
+// load находит сегменты прошлого запуска
+func (s *Spool) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("read spool dir: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, &segment{seq: seq, path: filepath.Join(s.dir, name), size: info.Size()})
+		s.total += info.Size()
+		s.nextSeq = max(s.nextSeq, seq+1)
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].seq < s.segments[j].seq })
+	return nil
+}
+
+// encodeRecord кодирует batch в запись сегмента:
+// длина метаданных, длина данных, CRC32 метаданных и данных, метаданные (JSON), данные
+func encodeRecord(batch Batch) ([]byte, error) {
+	meta, err := json.Marshal(recordMeta{
+		Table:   batch.Table,
+		Columns: batch.Columns,
+		Format:  batch.Format,
+		Rows:    batch.Rows,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	record := make([]byte, recordHeaderSize, recordHeaderSize+len(meta)+len(batch.Data))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(meta)))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(len(batch.Data)))
+	record = append(record, meta...)
+	record = append(record, batch.Data...)
+	binary.LittleEndian.PutUint32(record[8:12], crc32.ChecksumIEEE(record[recordHeaderSize:]))
+	return record, nil
+}
+
+// readRecord читает очередную запись, io.EOF - конец сегмента
+func readRecord(r io.Reader) (Batch, int64, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Batch{}, 0, io.EOF
+		}
+		return Batch{}, 0, errCorruptRecord
+	}
+	metaLen := binary.LittleEndian.Uint32(header[0:4])
+	dataLen := binary.LittleEndian.Uint32(header[4:8])
+
+	payload := make([]byte, int(metaLen)+int(dataLen))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return Batch{}, 0, errCorruptRecord
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[8:12]) {
+		return Batch{}, 0, errCorruptRecord
+	}
+
+	var meta recordMeta
+	if err := json.Unmarshal(payload[:metaLen], &meta); err != nil {
+		return Batch{}, 0, errCorruptRecord
+	}
+	batch := Batch{
+		Table:   meta.Table,
+		Columns: meta.Columns,
+		Format:  meta.Format,
+		Data:    payload[metaLen:],
+		Rows:    meta.Rows,
+	}
+	return batch, int64(recordHeaderSize + len(payload)), nil
+}
+
+// ============================================================================
+// Переотправка
+// ============================================================================
+
+// run переотправляет сегменты, пока есть что отправлять и сервер доступен
+// Сегменты прошлого запуска отправляются сразу, не дожидаясь первой проверки
+func (s *Spool) run() {
+	defer close(s.done)
+
+	if s.Pending() > 0 {
+		s.replay()
+	}
+
+	ticker := time.NewTicker(spoolRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		if s.Pending() == 0 {
+			continue
+		}
+		s.replay()
+	}
+}
+
+// replay отправляет сегменты от старых к новым до первой ошибки
+func (s *Spool) replay() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := s.client.Ping(ctx); err != nil {
+		s.log.Debug("clickhouse still unavailable", "pending_bytes", s.Pending(), "error", err)
+		return
+	}
+
+	start := time.Now()
+	sent := 0
+	for {
+		s.mu.Lock()
+		if len(s.segments) == 0 {
+			s.mu.Unlock()
+			break
+		}
+		current := s.segments[0]
+		if len(s.segments) == 1 {
+			// Последний сегмент закрывается: новые batch пойдут в следующий
+			if err := s.seal(); err != nil {
+				s.log.Error("spool segment close failed", "segment", current.path, "error", err)
+			}
+		}
+		s.mu.Unlock()
+
+		n, err := s.replaySegment(ctx, current)
+		sent += n
+		if err != nil {
+			s.log.Warn("spool replay paused",
+				"segment", filepath.Base(current.path),
+				"sent_batches", sent,
+				"pending_bytes", s.Pending(),
+				"error", err,
+			)
+			return
+		}
+		s.removeSegment(current)
+	}
+
+	if sent > 0 {
+		s.log.Info("spool replayed",
+			"batches", sent,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000.0,
+		)
+	}
+}
+
+// replaySegment отправляет записи сегмента начиная с сохраненного смещения
+// Возвращает количество отправленных batch и ошибку, из-за которой отправка прервана
+func (s *Spool) replaySegment(ctx context.Context, seg *segment) (int, error) {
+	file, err := os.Open(seg.path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Сегмент вытеснен по лимиту размера
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	offset := s.loadOffset(seg.seq)
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+
+	reader := bufio.NewReader(file)
+	sent := 0
+	for {
+		batch, size, err := readRecord(reader)
+		if errors.Is(err, io.EOF) {
+			return sent, nil
+		}
+		if err != nil {
+			s.log.Error("spool segment truncated, rest of segment dropped",
+				"segment", filepath.Base(seg.path),
+				"offset", offset,
+				"error", err,
+			)
+			return sent, nil
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, spoolSendTimeout)
+		err = s.client.Send(sendCtx, batch)
+		cancel()
+		if err != nil && !rejected(err) {
+			return sent, err
+		}
+		if err != nil {
+			s.log.Error("spooled batch rejected by clickhouse, dropped",
+				"table", batch.Table,
+				"rows", batch.Rows,
+				"error", err,
+			)
+		}
+
+		sent++
+		offset += size
+		s.saveOffset(seg.seq, offset)
+	}
+}
+
+// removeSegment удаляет полностью отправленный сегмент
+func (s *Spool) removeSegment(seg *segment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.segments) > 0 && s.segments[0] == seg {
+		s.segments = s.segments[1:]
+		s.total -= seg.size
+	}
+	if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.log.Error("spool segment remove failed", "segment", seg.path, "error", err)
+	}
+	if err := os.Remove(filepath.Join(s.dir, offsetFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.log.Error("spool offset remove failed", "error", err)
+	}
+}
+
+// loadOffset возвращает смещение первой непереданной записи сегмента seq
+func (s *Spool) loadOffset(seq uint64) int64 {
+	data, err := os.ReadFile(filepath.Join(s.dir, offsetFile))
+	if err != nil {
+		return 0
+	}
+	seqField, offsetField, ok := strings.Cut(strings.TrimSpace(string(data)), " ")
+	if !ok || seqField != strconv.FormatUint(seq, 10) {
+		return 0
+	}
+	offset, err := strconv.ParseInt(offsetField, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return offset
+}
+
+// saveOffset запоминает смещение, чтобы после перезапуска не отправлять записи повторно
+func (s *Spool) saveOffset(seq uint64, offset int64) {
+	data := fmt.Sprintf("%d %d\n", seq, offset)
+	if err := os.WriteFile(filepath.Join(s.dir, offsetFile), []byte(data), 0o644); err != nil {
+		s.log.Warn("spool offset save failed", "error", err)
+	}
+}
@@ -0,0 +1,56 @@
+package clickhouse
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"trader/internal/config"
+)
+
+func TestSpoolReplaysOnStartup(t *testing.T) {
+	dir := t.TempDir()
+	batch := Batch{Table: "trades", Format: FormatJSONEachRow, Data: []byte("{\"id\":1}\n"), Rows: 1}
+
+	// Сервер недоступен: batch уходит на диск
+	down, _ := newTestClient(t, config.ClickHouseConfig{}, 503, 503, 503)
+	spool, err := NewSpool(down, dir, 0)
+	if err != nil {
+		t.Fatalf("NewSpool: %v", err)
+	}
+	if err := spool.Send(context.Background(), batch); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if spool.Pending() == 0 {
+		t.Fatal("batch was not spooled")
+	}
+	if err := spool.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Следующий запуск отправляет сегменты сразу, не дожидаясь spoolRetryInterval
+	up, srv := newTestClient(t, config.ClickHouseConfig{})
+	spool, err = NewSpool(up, dir, 0)
+	if err != nil {
+		t.Fatalf("NewSpool: %v", err)
+	}
+	defer spool.Close()
+
+	deadline := time.Now().Add(spoolRetryInterval / 2)
+	for spool.Pending() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("spooled batch was not replayed on startup")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var inserted []string
+	for _, req := range srv.received() {
+		if req.query != "" {
+			inserted = append(inserted, req.query+" "+string(req.body))
+		}
+	}
+	if want := "INSERT INTO trades FORMAT JSONEachRow {\"id\":1}\n"; len(inserted) != 1 || inserted[0] != want {
+		t.Errorf("inserted = %q, want %q", inserted, want)
+	}
+}
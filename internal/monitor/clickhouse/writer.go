@@ -0,0 +1,92 @@
+package clickhouse
+
+import (
+	"fmt"
+
+	"trader/internal/core/exchange"
+)
+
+// ============================================================================
+// Строки таблиц монитора
+// ============================================================================
+
+// DecimalScale - знаков после запятой в колонках Decimal(38, 18) цен и объемов
+const DecimalScale = 18
+
+// TableOrderBookSnapshots - таблица периодических снимков книг
+const TableOrderBookSnapshots = "orderbook_snapshots"
+
+// SnapshotColumns - колонки TableOrderBookSnapshots в порядке SnapshotRow.EncodeRowBinary
+var SnapshotColumns = []string{
+	"timestamp", "book_timestamp", "exchange_id", "market_type", "pair", "depth", "bids", "asks", "sequence_num",
+}
+
+// SnapshotRow - снимок книги пары в момент выборки
+type SnapshotRow struct {
+	// Timestamp - время выборки (Unix μs)
+	Timestamp int64
+	// BookTimestamp - время последнего обновления книги от биржи (Unix μs)
+	BookTimestamp int64
+	ExchangeID    string
+	MarketType    string
+	Pair          string
+	// Depth - глубина выборки задачи, 0 - полная книга
+	Depth  int
+	Bids   []exchange.Level
+	Asks   []exchange.Level
+	SeqNum int64
+}
+
+// NewSnapshotRow делает строку из книги, оставляя depth лучших уровней (depth <= 0 - все)
+func NewSnapshotRow(book *exchange.OrderBook, depth int, sampledAt int64) SnapshotRow {
+	return SnapshotRow{
+		Timestamp:     sampledAt,
+		BookTimestamp: book.Timestamp,
+		ExchangeID:    book.ExchangeID,
+		MarketType:    book.MarketType,
+		Pair:          book.Pair,
+		Depth:         depth,
+		Bids:          topLevels(book.Bids, depth),
+		Asks:          topLevels(book.Asks, depth),
+		SeqNum:        book.SeqNum,
+	}
+}
+
+// EncodeRowBinary пишет строку в порядке SnapshotColumns
+func (r SnapshotRow) EncodeRowBinary(w *RowBinary) error {
+	w.DateTime64(r.Timestamp)
+	w.DateTime64(r.BookTimestamp)
+	w.String(r.ExchangeID)
+	w.String(r.MarketType)
+	w.String(r.Pair)
+	w.UInt16(uint16(max(r.Depth, 0)))
+	if err := encodeLevels(w, r.Bids); err != nil {
+		return fmt.Errorf("bids: %w", err)
+	}
+	if err := encodeLevels(w, r.Asks); err != nil {
+		return fmt.Errorf("asks: %w", err)
+	}
+	w.Int64(r.SeqNum)
+	return nil
+}
+
+// encodeLevels пишет Array(Tuple(price Decimal(38, 18), amount Decimal(38, 18)))
+func encodeLevels(w *RowBinary, levels []exchange.Level) error {
+	w.ArrayLen(len(levels))
+	for _, level := range levels {
+		if err := w.Decimal128(level.Price, DecimalScale); err != nil {
+			return err
+		}
+		if err := w.Decimal128(level.Amount, DecimalScale); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func topLevels(levels []exchange.Level, depth int) []exchange.Level {
+	if depth > 0 && len(levels) > depth {
+		levels = levels[:depth]
+	}
+	return levels
+}
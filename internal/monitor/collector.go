@@ -0,0 +1,219 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+	"trader/internal/core/orderbook"
+	"trader/internal/core/pubsub"
+	"trader/internal/monitor/clickhouse"
+)
+
+// flushTimeout - предельное время отправки одного batch вместе с повторами клиента
+const flushTimeout = 2 * time.Minute
+
+// collectorQueueSize - очередь подписчика сборщика: снимки книги схлопываются,
+// в очереди остаются только сбросы книги и последний снимок
+const collectorQueueSize = 16
+
+var collectorSeq atomic.Uint64
+
+// collector выбирает снимки книги одной пары и отправляет их batch
+// Последний снимок пишет горутина очереди подписчика (OnMessage), читает горутина run
+// Остальные поля, кроме stop и done, используются только горутиной run
+type collector struct {
+	id         string
+	exchangeID string
+	marketType string
+	pair       string
+	settings   settings
+
+	mu sync.Mutex
+	// book - последний снимок книги пары, nil пока книга невалидна
+	book *exchange.OrderBook
+	// updates - число обновлений book, защищает от записи устаревшей книги при старте
+	updates uint64
+
+	books BookSource
+	sink  Sink
+	log   *slog.Logger
+
+	rows []clickhouse.SnapshotRow
+	// skipped - выборок подряд без валидной книги, для лога
+	skipped int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newCollector(exchangeID, marketType, pair string, s settings, books BookSource, sink Sink, log *slog.Logger) *collector {
+	return &collector{
+		id:         fmt.Sprintf("monitor-%d", collectorSeq.Add(1)),
+		exchangeID: exchangeID,
+		marketType: marketType,
+		pair:       pair,
+		settings:   s,
+		books:      books,
+		sink:       sink,
+		log:        log.With("exchange_id", exchangeID, "market_type", marketType, "pair", pair),
+		rows:       make([]clickhouse.SnapshotRow, 0, s.batchSize),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// start подписывает сборщик на книгу пары и запускает выборку
+func (c *collector) start() error {
+	if err := c.books.Subscribe(c, c.exchangeID, c.pair, c.marketType); err != nil {
+		return fmt.Errorf("subscribe %s: %w", exchange.GetOrderBookKey(c.exchangeID, c.pair, c.marketType), err)
+	}
+
+	// Снимок приходит только после изменения книги: до него берется текущая книга менеджера,
+	// если подписчик еще ничего не получил
+	c.mu.Lock()
+	updates := c.updates
+	c.mu.Unlock()
+	if book := c.books.GetOrderBook(c.exchangeID, c.pair, c.marketType); book != nil {
+		c.mu.Lock()
+		if c.updates == updates {
+			c.book = book
+		}
+		c.mu.Unlock()
+	}
+
+	go c.run()
+	return nil
+}
+
+// run выбирает книгу каждые saveInterval и отправляет batch по размеру или по batchInterval
+func (c *collector) run() {
+	defer close(c.done)
+
+	save := time.NewTicker(c.settings.saveInterval)
+	defer save.Stop()
+	flush := time.NewTicker(c.settings.batchInterval)
+	defer flush.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			c.flush()
+			return
+		case now := <-save.C:
+			c.sample(now)
+			if len(c.rows) >= c.settings.batchSize {
+				c.flush()
+				flush.Reset(c.settings.batchInterval)
+			}
+		case <-flush.C:
+			c.flush()
+		}
+	}
+}
+
+// close отписывает сборщик, останавливает его и ждет отправки накопленных строк
+func (c *collector) close() {
+	c.books.Unsubscribe(c, c.exchangeID, c.pair, c.marketType)
+	close(c.stop)
+	<-c.done
+}
+
+// OnMessage - pubsub.Subscriber, запоминает последний снимок книги пары
+// TypeBookInvalidated сбрасывает книгу до следующего снимка
+func (c *collector) OnMessage(msg *messaging.Message) {
+	switch msg.Type {
+	case messaging.TypeOrderBook:
+		if book := orderbook.BookFromMessage(msg); book != nil {
+			c.setBook(book)
+		}
+	case messaging.TypeBookInvalidated:
+		c.setBook(nil)
+	}
+}
+
+// OnError - pubsub.Subscriber, разрыв последовательности делает книгу невалидной
+// до следующего снимка; потерянные при переполнении снимки заменит следующий
+func (c *collector) OnError(err error) {
+	if errors.Is(err, pubsub.ErrQueueOverflow) {
+		return
+	}
+	c.setBook(nil)
+}
+
+// GetID - pubsub.Subscriber
+func (c *collector) GetID() string { return c.id }
+
+// MessageTypes - pubsub.TypeFilter: снимки книги и сигналы ее сброса
+func (c *collector) MessageTypes() []string {
+	return []string{messaging.TypeOrderBook, messaging.TypeBookInvalidated}
+}
+
+// QueueOptions - pubsub.QueueConfigurer: сборщику нужна только последняя книга
+func (c *collector) QueueOptions() pubsub.QueueOptions {
+	return pubsub.QueueOptions{Size: collectorQueueSize, Overflow: pubsub.CoalesceBook}
+}
+
+func (c *collector) setBook(book *exchange.OrderBook) {
+	c.mu.Lock()
+	c.book = book
+	c.updates++
+	c.mu.Unlock()
+}
+
+// sample добавляет строку с последней книгой пары
+func (c *collector) sample(now time.Time) {
+	c.mu.Lock()
+	book := c.book
+	c.mu.Unlock()
+	if book == nil {
+		// Книга ждет снимка (старт, разрыв последовательности): пропуск лучше устаревших данных
+		c.skipped++
+		if c.skipped == 1 {
+			c.log.Debug("orderbook not synced, sample skipped")
+		}
+		return
+	}
+	if c.skipped > 0 {
+		c.log.Debug("orderbook synced, sampling resumed", "skipped_samples", c.skipped)
+		c.skipped = 0
+	}
+	c.rows = append(c.rows, clickhouse.NewSnapshotRow(book, c.settings.depth, now.UnixMicro()))
+}
+
+// flush отправляет накопленные строки частями не больше MaxBatchSize получателя
+// При ошибке получателя строки части теряются
+func (c *collector) flush() {
+	if len(c.rows) == 0 {
+		return
+	}
+	rows := c.rows
+	c.rows = make([]clickhouse.SnapshotRow, 0, c.settings.batchSize)
+
+	size := c.sink.MaxBatchSize()
+	if size <= 0 {
+		size = len(rows)
+	}
+	for start := 0; start < len(rows); start += size {
+		part := rows[start:min(start+size, len(rows))]
+		if err := c.send(part); err != nil {
+			c.log.Error("snapshot batch dropped", "rows", len(part), "error", err)
+		}
+	}
+}
+
+func (c *collector) send(rows []clickhouse.SnapshotRow) error {
+	batch, err := clickhouse.EncodeRowBinary(clickhouse.TableOrderBookSnapshots, clickhouse.SnapshotColumns, rows)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	return c.sink.Send(ctx, batch)
+}
@@ -0,0 +1,308 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+	"trader/internal/core/pubsub"
+	"trader/internal/logger"
+	"trader/internal/monitor/clickhouse"
+)
+
+// fakeSource - менеджер книг: хранит книги и подписки по ID подписчика
+type fakeSource struct {
+	mu            sync.Mutex
+	books         map[string]*exchange.OrderBook // key = exchange.GetOrderBookKey()
+	subscriptions map[string]string              // subscriber ID -> key книги
+	failPairs     map[string]bool
+	// beforeGet вызывается в GetOrderBook до чтения книги
+	beforeGet func()
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{
+		books:         make(map[string]*exchange.OrderBook),
+		subscriptions: make(map[string]string),
+		failPairs:     make(map[string]bool),
+	}
+}
+
+func (s *fakeSource) Subscribe(subscriber pubsub.Subscriber, exchangeID, pair, marketType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failPairs[pair] {
+		return errors.New("pair is not streamed")
+	}
+	s.subscriptions[subscriber.GetID()] = exchange.GetOrderBookKey(exchangeID, pair, marketType)
+	return nil
+}
+
+func (s *fakeSource) Unsubscribe(subscriber pubsub.Subscriber, _, _, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subscriptions, subscriber.GetID())
+}
+
+func (s *fakeSource) GetOrderBook(exchangeID, pair, marketType string) *exchange.OrderBook {
+	if s.beforeGet != nil {
+		s.beforeGet()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.books[exchange.GetOrderBookKey(exchangeID, pair, marketType)]
+}
+
+// subscribed возвращает ключи книг активных подписок
+func (s *fakeSource) subscribed() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make(map[string]int)
+	for _, key := range s.subscriptions {
+		keys[key]++
+	}
+	return keys
+}
+
+// fakeSink запоминает размеры отправленных batch
+type fakeSink struct {
+	maxBatchSize int
+	// failSend - номер отправки (с 1), которая вернет ошибку
+	failSend int
+	// release - если задан, Send ждет его закрытия, сообщив о начале в sending
+	release chan struct{}
+	sending chan struct{}
+	sent    chan int
+
+	mu    sync.Mutex
+	sends int
+	rows  []int
+}
+
+func newFakeSink() *fakeSink {
+	return &fakeSink{sending: make(chan struct{}, 16), sent: make(chan int, 64)}
+}
+
+func (s *fakeSink) Send(ctx context.Context, batch clickhouse.Batch) error {
+	if s.release != nil {
+		s.sending <- struct{}{}
+		select {
+		case <-s.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	s.mu.Lock()
+	s.sends++
+	failed := s.sends == s.failSend
+	s.rows = append(s.rows, batch.Rows)
+	s.mu.Unlock()
+	s.sent <- batch.Rows
+	if failed {
+		return errors.New("clickhouse unavailable")
+	}
+	return nil
+}
+
+func (s *fakeSink) MaxBatchSize() int { return s.maxBatchSize }
+
+func (s *fakeSink) batches() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.rows...)
+}
+
+const testPair = "BTC/USDT"
+
+func dec(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func testBook(seq int64) *exchange.OrderBook {
+	return &exchange.OrderBook{
+		ExchangeID: exchange.Binance,
+		Pair:       testPair,
+		MarketType: exchange.MarketSpot,
+		Bids:       []exchange.Level{{Price: dec("100"), Amount: dec("1")}, {Price: dec("99"), Amount: dec("2")}},
+		Asks:       []exchange.Level{{Price: dec("101"), Amount: dec("1")}, {Price: dec("102"), Amount: dec("3")}},
+		SeqNum:     seq,
+	}
+}
+
+// snapshot - снимок книги так же, как его публикует менеджер книг
+func snapshot(seq int64) *messaging.Message {
+	return &messaging.Message{
+		ExchangeID: exchange.Binance,
+		MarketType: exchange.MarketSpot,
+		Type:       messaging.TypeOrderBook,
+		Pair:       testPair,
+		SeqNum:     seq,
+		OrderBook: &messaging.OrderBookData{
+			Bids:     []messaging.Level{{Price: dec("100"), Amount: dec("1")}, {Price: dec("99"), Amount: dec("2")}},
+			Asks:     []messaging.Level{{Price: dec("101"), Amount: dec("1")}},
+			Snapshot: true,
+		},
+	}
+}
+
+// idle - параметры, при которых run не выбирает и не отправляет сам
+var idle = settings{depth: 1, batchSize: 100, batchInterval: time.Hour, saveInterval: time.Hour}
+
+func newTestCollector(source *fakeSource, sink *fakeSink, s settings) *collector {
+	return newCollector(exchange.Binance, exchange.MarketSpot, testPair, s, source, sink, logger.Get("monitor"))
+}
+
+func TestCollectorSamplesLatestBook(t *testing.T) {
+	source := newFakeSource()
+	source.books[exchange.GetOrderBookKey(exchange.Binance, testPair, exchange.MarketSpot)] = testBook(1)
+	sink := newFakeSink()
+	c := newTestCollector(source, sink, idle)
+	if err := c.start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	// Выборки делает тест, run ждет часовых тикеров и не трогает строки до close
+	rows := 0
+	for _, step := range []struct {
+		name string
+		do   func()
+		// seq - SeqNum книги строки, 0 - выборка пропущена
+		seq int64
+	}{
+		{"book from manager before first snapshot", func() {}, 1},
+		{"snapshot", func() { c.OnMessage(snapshot(2)) }, 2},
+		{"delta is not a book", func() {
+			delta := snapshot(3)
+			delta.OrderBook.Snapshot = false
+			c.OnMessage(delta)
+		}, 2},
+		{"book invalidated", func() {
+			c.OnMessage(&messaging.Message{ExchangeID: exchange.Binance, MarketType: exchange.MarketSpot,
+				Type: messaging.TypeBookInvalidated, Pair: testPair})
+		}, 0},
+		{"snapshot after invalidation", func() { c.OnMessage(snapshot(4)) }, 4},
+		{"queue overflow keeps book", func() { c.OnError(pubsub.ErrQueueOverflow) }, 4},
+		{"stream error drops book", func() { c.OnError(errors.New("sequence gap")) }, 0},
+	} {
+		step.do()
+		c.sample(time.Unix(1700000000, 0))
+		if step.seq == 0 {
+			if len(c.rows) != rows {
+				t.Fatalf("%s: sample added a row without valid book", step.name)
+			}
+			continue
+		}
+		rows++
+		if len(c.rows) != rows {
+			t.Fatalf("%s: %d rows, want %d", step.name, len(c.rows), rows)
+		}
+		row := c.rows[rows-1]
+		if row.SeqNum != step.seq || len(row.Bids) != 1 || len(row.Asks) != 1 || row.Depth != 1 {
+			t.Fatalf("%s: row seq %d, %d bids, %d asks, depth %d, want seq %d with 1 level per side",
+				step.name, row.SeqNum, len(row.Bids), len(row.Asks), row.Depth, step.seq)
+		}
+		if row.Timestamp != 1700000000*1e6 {
+			t.Errorf("%s: row timestamp %d", step.name, row.Timestamp)
+		}
+	}
+
+	// close отписывает сборщик и отправляет накопленные строки
+	c.close()
+	if got := sink.batches(); len(got) != 1 || got[0] != rows {
+		t.Errorf("batches after close = %v, want [%d]", got, rows)
+	}
+	if subs := source.subscribed(); len(subs) != 0 {
+		t.Errorf("subscriptions after close = %v", subs)
+	}
+}
+
+func TestCollectorStartKeepsNewerSnapshot(t *testing.T) {
+	source := newFakeSource()
+	key := exchange.GetOrderBookKey(exchange.Binance, testPair, exchange.MarketSpot)
+	source.books[key] = testBook(1)
+	c := newTestCollector(source, newFakeSink(), idle)
+
+	// Снимок пришел подписчику между подпиской и чтением книги менеджера:
+	// прочитанная книга старше и не должна его затереть
+	source.beforeGet = func() { c.OnMessage(snapshot(5)) }
+	if err := c.start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer c.close()
+
+	c.mu.Lock()
+	seq := c.book.SeqNum
+	c.mu.Unlock()
+	if seq != 5 {
+		t.Errorf("book seq after start = %d, want 5", seq)
+	}
+
+	failed := newTestCollector(source, newFakeSink(), idle)
+	source.failPairs[testPair] = true
+	if err := failed.start(); err == nil {
+		t.Error("start with failed subscription = nil, want error")
+	}
+}
+
+func TestCollectorFlushSplitsBatches(t *testing.T) {
+	// Вторая часть не отправилась: строки теряются, остальные части уходят
+	sink := newFakeSink()
+	sink.maxBatchSize = 2
+	sink.failSend = 2
+	c := newTestCollector(newFakeSource(), sink, idle)
+	c.setBook(testBook(1))
+	for range 5 {
+		c.sample(time.Now())
+	}
+
+	c.flush()
+	if got := sink.batches(); len(got) != 3 || got[0] != 2 || got[1] != 2 || got[2] != 1 {
+		t.Errorf("batches = %v, want [2 2 1]", got)
+	}
+	if len(c.rows) != 0 {
+		t.Errorf("%d rows left after flush", len(c.rows))
+	}
+
+	c.flush()
+	if got := sink.batches(); len(got) != 3 {
+		t.Errorf("empty flush sent a batch: %v", got)
+	}
+}
+
+func TestCollectorRunFlushesBySizeAndInterval(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		s    settings
+		// rows - строк в первом batch, 0 - сколько успело накопиться за интервал
+		rows int
+	}{
+		{"batch size", settings{batchSize: 3, batchInterval: time.Hour, saveInterval: 5 * time.Millisecond}, 3},
+		{"batch interval", settings{batchSize: 1000, batchInterval: 50 * time.Millisecond, saveInterval: 5 * time.Millisecond}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			source := newFakeSource()
+			source.books[exchange.GetOrderBookKey(exchange.Binance, testPair, exchange.MarketSpot)] = testBook(1)
+			sink := newFakeSink()
+			c := newTestCollector(source, sink, tc.s)
+			if err := c.start(); err != nil {
+				t.Fatalf("start: %v", err)
+			}
+			defer c.close()
+
+			select {
+			case rows := <-sink.sent:
+				if rows == 0 || tc.rows != 0 && rows != tc.rows {
+					t.Errorf("batch of %d rows, want %d", rows, tc.rows)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("no batch sent")
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+// Package monitor - роль Monitor: периодические снимки книг в ClickHouse
+//
+// Каждая задача мониторинга (exchange.MonitoringTask) получает свой сборщик.
+// Сборщик подписан на снимки книги пары в менеджере книг (pubsub) и хранит последний,
+// раз в SaveIntervalSec обрезает его до OrderbookDepth и копит строки.
+// Очередь сборщика схлопывает снимки, поэтому медленная запись в ClickHouse
+// не задерживает доставку книг стратегиям. Batch отправляется,
+// когда набралось BatchSize строк или прошло BatchIntervalSec с прошлой отправки.
+// Нулевые параметры задачи берутся из config.MonitorConfig
+// (кроме OrderbookDepth: 0 - полная книга).
+package monitor
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"trader/internal/config"
+	"trader/internal/core/exchange"
+	"trader/internal/core/pubsub"
+	"trader/internal/logger"
+	"trader/internal/monitor/clickhouse"
+)
+
+// BookSource - источник книг, реализуется orderbook.Manager
+type BookSource interface {
+	// Subscribe подписывает на снимки книги пары и сигналы ее сброса
+	Subscribe(subscriber pubsub.Subscriber, exchangeID, pair, marketType string) error
+	// Unsubscribe снимает подписку, сделанную Subscribe
+	Unsubscribe(subscriber pubsub.Subscriber, exchangeID, pair, marketType string)
+	// GetOrderBook возвращает копию книги, nil если книга невалидна
+	// Нужна для первой выборки: снимок приходит подписчику только после изменения книги
+	GetOrderBook(exchangeID, pair, marketType string) *exchange.OrderBook
+}
+
+// Sink - получатель готовых batch, реализуется clickhouse.Client
+// и clickhouse.Spool (сохраняет batch на диск, пока ClickHouse недоступен)
+type Sink interface {
+	Send(ctx context.Context, batch clickhouse.Batch) error
+	// MaxBatchSize - максимум строк в одном batch, 0 - без ограничения
+	MaxBatchSize() int
+}
+
+// settings - параметры выборки одной пары
+type settings struct {
+	depth         int
+	batchSize     int
+	batchInterval time.Duration
+	saveInterval  time.Duration
+}
+
+// Monitor держит сборщики снимков по задачам мониторинга
+// Безопасен для конкурентного использования
+type Monitor struct {
+	cfg   config.MonitorConfig
+	books BookSource
+	sink  Sink
+	log   *slog.Logger
+
+	mu         sync.Mutex
+	collectors map[string]*collector // key = exchange.GetMonitoringTaskKey()
+}
+
+// New создает монитор, сборщики запускаются через Sync
+func New(cfg config.MonitorConfig, books BookSource, sink Sink) *Monitor {
+	return &Monitor{
+		cfg:        cfg,
+		books:      books,
+		sink:       sink,
+		log:        logger.Get("monitor"),
+		collectors: make(map[string]*collector),
+	}
+}
+
+// Sync приводит сборщики к списку задач: запускает новые, останавливает удаленные
+// и перезапускает задачи с измененными параметрами
+// Остановленный сборщик отправляет накопленные строки, Sync ждет отправки
+// без блокировки монитора
+// Задачи разных пользователей на одну пару объединяются: берется большая глубина
+// и меньшие интервалы и размер batch
+func (m *Monitor) Sync(tasks []*exchange.MonitoringTask) {
+	wanted := make(map[string]settings, len(tasks))
+	keys := make(map[string]*exchange.MonitoringTask, len(tasks))
+	for _, task := range tasks {
+		key := exchange.GetMonitoringTaskKey(*task)
+		s := m.settings(task)
+		if prev, ok := wanted[key]; ok {
+			s = mergeSettings(prev, s)
+		}
+		wanted[key] = s
+		keys[key] = task
+	}
+
+	m.mu.Lock()
+	var stopped []*collector
+	for key, c := range m.collectors {
+		if s, ok := wanted[key]; ok && s == c.settings {
+			continue
+		}
+		stopped = append(stopped, c)
+		delete(m.collectors, key)
+	}
+
+	for key, s := range wanted {
+		if _, ok := m.collectors[key]; ok {
+			continue
+		}
+		task := keys[key]
+		c := newCollector(task.ExchangeID, task.MarketType, task.TradePair, s, m.books, m.sink, m.log)
+		if err := c.start(); err != nil {
+			m.log.Error("monitoring start failed",
+				"exchange_id", task.ExchangeID,
+				"market_type", task.MarketType,
+				"pair", task.TradePair,
+				"error", err,
+			)
+			continue
+		}
+		m.collectors[key] = c
+
+		m.log.Info("monitoring started",
+			"exchange_id", task.ExchangeID,
+			"market_type", task.MarketType,
+			"pair", task.TradePair,
+			"depth", s.depth,
+			"batch_size", s.batchSize,
+			"batch_interval_sec", s.batchInterval.Seconds(),
+			"save_interval_sec", s.saveInterval.Seconds(),
+		)
+	}
+	m.mu.Unlock()
+
+	// Отправка накопленных строк может длиться до flushTimeout:
+	// монитор в это время не заблокирован для Sync и Stop
+	closeCollectors(stopped)
+}
+
+// Stop останавливает все сборщики, отправив накопленные строки
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	stopped := make([]*collector, 0, len(m.collectors))
+	for key, c := range m.collectors {
+		stopped = append(stopped, c)
+		delete(m.collectors, key)
+	}
+	m.mu.Unlock()
+
+	closeCollectors(stopped)
+}
+
+// closeCollectors параллельно останавливает сборщики и ждет отправки их строк
+func closeCollectors(collectors []*collector) {
+	var wg sync.WaitGroup
+	for _, c := range collectors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.close()
+		}()
+	}
+	wg.Wait()
+}
+
+// settings возвращает параметры задачи, подставляя значения по умолчанию
+func (m *Monitor) settings(task *exchange.MonitoringTask) settings {
+	s := settings{
+		depth:         task.OrderbookDepth,
+		batchSize:     task.BatchSize,
+		batchInterval: time.Duration(task.BatchIntervalSec) * time.Second,
+		saveInterval:  time.Duration(task.SaveIntervalSec) * time.Second,
+	}
+	if s.depth < 0 {
+		s.depth = m.cfg.OrderBookDepth
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = max(m.cfg.BatchSize, 1)
+	}
+	if s.batchInterval <= 0 {
+		s.batchInterval = time.Duration(max(m.cfg.BatchInterval, 1)) * time.Second
+	}
+	if s.saveInterval <= 0 {
+		s.saveInterval = time.Duration(max(m.cfg.SaveInterval, 1)) * time.Second
+	}
+	return s
+}
+
+// mergeSettings объединяет параметры двух задач одной пары
+func mergeSettings(a, b settings) settings {
+	depth := max(a.depth, b.depth)
+	if a.depth == 0 || b.depth == 0 {
+		depth = 0
+	}
+	return settings{
+		depth:         depth,
+		batchSize:     min(a.batchSize, b.batchSize),
+		batchInterval: min(a.batchInterval, b.batchInterval),
+		saveInterval:  min(a.saveInterval, b.saveInterval),
+	}
+}
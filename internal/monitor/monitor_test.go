@@ -0,0 +1,145 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"trader/internal/config"
+	"trader/internal/core/exchange"
+)
+
+var testConfig = config.MonitorConfig{OrderBookDepth: 20, BatchSize: 10, BatchInterval: 3600, SaveInterval: 3600}
+
+func task(exchangeID, pair string, depth, batchSize int) *exchange.MonitoringTask {
+	return &exchange.MonitoringTask{
+		ExchangeID:     exchangeID,
+		MarketType:     exchange.MarketSpot,
+		TradePair:      pair,
+		OrderbookDepth: depth,
+		BatchSize:      batchSize,
+	}
+}
+
+// running возвращает сборщики монитора по ключу задачи
+func running(m *Monitor) map[string]*collector {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	collectors := make(map[string]*collector, len(m.collectors))
+	for key, c := range m.collectors {
+		collectors[key] = c
+	}
+	return collectors
+}
+
+func TestMonitorSync(t *testing.T) {
+	source := newFakeSource()
+	source.failPairs["DOGE/USDT"] = true
+	m := New(testConfig, source, newFakeSink())
+	defer m.Stop()
+
+	binance := exchange.GetMonitoringTaskKey(*task(exchange.Binance, testPair, 0, 0))
+	okx := exchange.GetMonitoringTaskKey(*task(exchange.OKX, testPair, 0, 0))
+	binanceBook := exchange.GetOrderBookKey(exchange.Binance, testPair, exchange.MarketSpot)
+	okxBook := exchange.GetOrderBookKey(exchange.OKX, testPair, exchange.MarketSpot)
+
+	// Две задачи одной пары - один сборщик: большая глубина, меньший batch
+	// Пару без потока книг сборщик не получает
+	m.Sync([]*exchange.MonitoringTask{
+		task(exchange.Binance, testPair, 10, 100),
+		task(exchange.Binance, testPair, 50, 0),
+		task(exchange.OKX, testPair, -1, 5),
+		task(exchange.Binance, "DOGE/USDT", 10, 10),
+	})
+	first := running(m)
+	if len(first) != 2 {
+		t.Fatalf("collectors = %d, want 2", len(first))
+	}
+	hour := time.Hour
+	if s := first[binance].settings; s != (settings{depth: 50, batchSize: 10, batchInterval: hour, saveInterval: hour}) {
+		t.Errorf("binance settings = %+v", s)
+	}
+	if s := first[okx].settings; s != (settings{depth: 20, batchSize: 5, batchInterval: hour, saveInterval: hour}) {
+		t.Errorf("okx settings = %+v", s)
+	}
+	if subs := source.subscribed(); len(subs) != 2 || subs[binanceBook] != 1 || subs[okxBook] != 1 {
+		t.Errorf("subscriptions = %v", subs)
+	}
+
+	// Полная книга (0) побеждает любую глубину; неизмененная задача не перезапускается
+	m.Sync([]*exchange.MonitoringTask{
+		task(exchange.Binance, testPair, 10, 100),
+		task(exchange.Binance, testPair, 50, 0),
+		task(exchange.OKX, testPair, 0, 5),
+		task(exchange.OKX, testPair, 50, 5),
+	})
+	second := running(m)
+	if second[binance] != first[binance] {
+		t.Error("unchanged binance collector was restarted")
+	}
+	if second[okx] == first[okx] || second[okx].settings.depth != 0 {
+		t.Errorf("okx collector not restarted with full depth: %+v", second[okx].settings)
+	}
+	if subs := source.subscribed(); len(subs) != 2 || subs[okxBook] != 1 {
+		t.Errorf("subscriptions after restart = %v, want one per book", subs)
+	}
+
+	m.Sync(nil)
+	if len(running(m)) != 0 {
+		t.Error("collectors left after Sync(nil)")
+	}
+	if subs := source.subscribed(); len(subs) != 0 {
+		t.Errorf("subscriptions after Sync(nil) = %v", subs)
+	}
+}
+
+func TestMonitorSyncDoesNotBlockWhileFlushing(t *testing.T) {
+	source := newFakeSource()
+	source.books[exchange.GetOrderBookKey(exchange.Binance, testPair, exchange.MarketSpot)] = testBook(1)
+	sink := newFakeSink()
+	sink.release = make(chan struct{})
+	m := New(testConfig, source, sink)
+	defer m.Stop()
+
+	m.Sync([]*exchange.MonitoringTask{task(exchange.Binance, testPair, 0, 0)})
+	for _, c := range running(m) {
+		// Сборщик ждет часовых тикеров: строку для отправки при остановке добавляет тест
+		c.sample(time.Now())
+	}
+
+	removed := make(chan struct{})
+	go func() {
+		defer close(removed)
+		m.Sync(nil)
+	}()
+	select {
+	case <-sink.sending:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stopped collector did not flush")
+	}
+
+	// Пока остановленный сборщик отправляет строки, монитор принимает новые задачи
+	synced := make(chan struct{})
+	go func() {
+		defer close(synced)
+		m.Sync([]*exchange.MonitoringTask{task(exchange.OKX, testPair, 0, 0)})
+	}()
+	select {
+	case <-synced:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Sync blocked while another Sync was flushing")
+	}
+	select {
+	case <-removed:
+		t.Fatal("Sync returned before the stopped collector flushed")
+	default:
+	}
+
+	close(sink.release)
+	<-removed
+	if got := sink.batches(); len(got) != 1 || got[0] != 1 {
+		t.Errorf("batches = %v, want [1]", got)
+	}
+	if _, ok := running(m)[exchange.GetMonitoringTaskKey(*task(exchange.OKX, testPair, 0, 0))]; !ok || len(running(m)) != 1 {
+		t.Errorf("collectors = %v, want okx only", running(m))
+	}
+}
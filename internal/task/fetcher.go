@@ -5,19 +5,34 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net"
+	"reflect"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
+	"github.com/shopspring/decimal"
+
+	"trader/internal/config"
 	"trader/internal/core/exchange"
+	"trader/internal/logger"
 )
 
+// TasksHandler применяет загруженные задачи (Manager.ApplyTasks)
+type TasksHandler func(tasks *TasksData) error
+
 // Fetcher периодически загружает задачи мониторинга и торговли из MySQL
+// и передает их обработчику, когда они изменились
 type Fetcher struct {
 	db       *sql.DB
 	interval time.Duration
+	handler  TasksHandler
 
 	lastMonitoring []*exchange.MonitoringTask
 	lastTrading    []*exchange.TradingTask
+	// applied - последние загруженные задачи переданы обработчику без ошибки
+	applied bool
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -33,7 +48,25 @@ type TasksData struct {
 	TradingTasks    []*exchange.TradingTask
 }
 
+// OpenDB открывает пул соединений к MySQL с задачами
+func OpenDB(cfg config.MySQLConfig) (*sql.DB, error) {
+	dsn := mysql.NewConfig()
+	dsn.Net = "tcp"
+	dsn.Addr = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	dsn.DBName = cfg.Database
+	dsn.User = cfg.Username
+	dsn.Passwd = cfg.Password
+	dsn.Timeout = time.Duration(cfg.ConnectTimeout) * time.Second
+
+	connector, err := mysql.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("mysql config: %w", err)
+	}
+	return sql.OpenDB(connector), nil
+}
+
 // NewFetcher создает новый Fetcher
+// Fetcher владеет db и закрывает его в Stop
 func NewFetcher(db *sql.DB, interval time.Duration) *Fetcher {
 	return &Fetcher{
 		db:             db,
@@ -43,14 +76,19 @@ func NewFetcher(db *sql.DB, interval time.Duration) *Fetcher {
 	}
 }
 
-// Start запускает фоновый горутин для периодической загрузки задач
-func (f *Fetcher) Start(ctx context.Context) error {
+// Start загружает задачи, передает их handler и запускает фоновый горутин
+// для периодической загрузки: handler вызывается только когда задачи изменились
+// (или предыдущее применение завершилось ошибкой)
+func (f *Fetcher) Start(ctx context.Context, handler TasksHandler) error {
 	f.ctx, f.cancel = context.WithCancel(ctx)
+	f.handler = handler
 
 	// Сначала загружаем один раз при старте
 	if err := f.fetchTasks(); err != nil {
+		f.cancel()
 		return fmt.Errorf("initial fetch failed: %w", err)
 	}
+	f.apply()
 
 	f.wg.Add(1)
 	go f.fetchLoop()
@@ -58,11 +96,13 @@ func (f *Fetcher) Start(ctx context.Context) error {
 	return nil
 }
 
-// Stop останавливает фоновый горутин
+// Stop останавливает фоновый горутин и закрывает соединение с БД
 func (f *Fetcher) Stop() error {
-	f.cancel()
+	if f.cancel != nil {
+		f.cancel()
+	}
 	f.wg.Wait()
-	return nil
+	return f.db.Close()
 }
 
 // GetLast возвращает последние загруженные данные
@@ -91,13 +131,39 @@ func (f *Fetcher) fetchLoop() {
 		case <-ticker.C:
 			// Ошибки логируем, но не прерываем цикл
 			if err := f.fetchTasks(); err != nil {
-				// TODO: логирование
-				fmt.Printf("fetch error: %v\n", err)
+				logger.Get("task").Warn("tasks fetch failed", "error", err)
+				continue
 			}
+			f.apply()
 		}
 	}
 }
 
+// apply передает последние загруженные задачи обработчику, если они еще не применены
+// Ошибка применения логируется, задачи будут переданы повторно после следующей загрузки
+func (f *Fetcher) apply() {
+	f.mu.Lock()
+	applied := f.applied
+	f.mu.Unlock()
+	if applied || f.handler == nil {
+		return
+	}
+
+	tasks := f.GetLast()
+	if err := f.handler(tasks); err != nil {
+		logger.Get("task").Error("tasks apply failed",
+			"monitoring_tasks", len(tasks.MonitoringTasks),
+			"trading_tasks", len(tasks.TradingTasks),
+			"error", err,
+		)
+		return
+	}
+
+	f.mu.Lock()
+	f.applied = true
+	f.mu.Unlock()
+}
+
 // fetchTasks загружает задачи из MySQL
 func (f *Fetcher) fetchTasks() error {
 	monitoring, err := f.fetchMonitoringTasks()
@@ -113,6 +179,9 @@ func (f *Fetcher) fetchTasks() error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	if !reflect.DeepEqual(monitoring, f.lastMonitoring) || !reflect.DeepEqual(trading, f.lastTrading) {
+		f.applied = false
+	}
 	f.lastMonitoring = monitoring
 	f.lastTrading = trading
 
@@ -275,11 +344,11 @@ func (f *Fetcher) fetchTradingTasks() ([]*exchange.TradingTask, error) {
 			baseSymbol             string
 			quoteSymbol            string
 			strategyID             string
-			maxAmountTrade         float64
+			maxAmountTrade         decimal.Decimal
 			maxOpenOrders          int
-			maxPositionSize        float64
+			maxPositionSize        decimal.Decimal
 			strategyUpdateInterval int
-			slippagePercent        float64
+			slippagePercent        decimal.Decimal
 			enableBacktest         bool
 			finProtection          bool
 			bboOnly                bool
@@ -302,12 +371,10 @@ func (f *Fetcher) fetchTradingTasks() ([]*exchange.TradingTask, error) {
 		pair := fmt.Sprintf("%s/%s", baseSymbol, quoteSymbol)
 
 		// Упаковываем параметры в JSON
+		// Денежные лимиты (DECIMAL) передаются полями задачи без перевода во float
 		params := map[string]interface{}{
-			"max_amount_trade":             maxAmountTrade,
 			"max_open_orders":              maxOpenOrders,
-			"max_position_size":            maxPositionSize,
 			"strategy_update_interval_sec": strategyUpdateInterval,
-			"slippage_percent":             slippagePercent,
 			"enable_backtest":              enableBacktest,
 			"fin_protection":               finProtection,
 			"bbo_only":                     bboOnly,
@@ -325,7 +392,11 @@ func (f *Fetcher) fetchTradingTasks() ([]*exchange.TradingTask, error) {
 			TradePairID:       pairID,
 			TradePair:         pair,
 			StrategyID:        strategyID,
+			MaxAmountTrade:    maxAmountTrade,
+			MaxPositionSize:   maxPositionSize,
+			SlippagePercent:   slippagePercent,
 			StrategyParams:    string(paramsJSON),
+			BBOOnly:           bboOnly,
 			ExchangeAccountID: exchangeAccountID,
 		}
 
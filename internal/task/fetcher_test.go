@@ -0,0 +1,159 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"trader/internal/core/exchange"
+	"trader/internal/task/tasktest"
+)
+
+// recorder - обработчик задач, запоминающий каждый вызов
+type recorder struct {
+	mu    sync.Mutex
+	calls []*TasksData
+	err   error
+}
+
+func (r *recorder) handle(tasks *TasksData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, tasks)
+	return r.err
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.calls)
+}
+
+func (r *recorder) setErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+// waitQueries ждет еще n загрузок задач (по два запроса на загрузку)
+func waitQueries(t *testing.T, db *tasktest.DB, n int) {
+	t.Helper()
+	target := db.Queries() + 2*n
+	deadline := time.Now().Add(5 * time.Second)
+	for db.Queries() < target {
+		if time.Now().After(deadline) {
+			t.Fatalf("no %d fetches", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFetcherAppliesChangedTasks(t *testing.T) {
+	db := tasktest.New()
+	db.SetMonitoring(tasktest.Monitoring{ID: 1, ExchangeID: "binance", MarketType: exchange.MarketSpot, Base: "BTC", Quote: "USDT", OrderbookDepth: 20})
+
+	fetcher := NewFetcher(db.Open(), 10*time.Millisecond)
+	handler := &recorder{}
+	if err := fetcher.Start(context.Background(), handler.handle); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer fetcher.Stop()
+
+	if handler.count() != 1 || handler.calls[0].MonitoringTasks[0].TradePair != "BTC/USDT" {
+		t.Fatalf("handler calls after start = %d", handler.count())
+	}
+
+	// Задачи не менялись - обработчик не вызывается
+	waitQueries(t, db, 3)
+	if handler.count() != 1 {
+		t.Fatalf("handler calls without changes = %d", handler.count())
+	}
+
+	// Ошибка применения: те же задачи передаются повторно
+	handler.setErr(errors.New("apply failed"))
+	db.SetMonitoring(tasktest.Monitoring{ID: 1, ExchangeID: "binance", MarketType: exchange.MarketSpot, Base: "BTC", Quote: "USDT", OrderbookDepth: 50})
+	waitQueries(t, db, 3)
+	if calls := handler.count(); calls < 3 {
+		t.Fatalf("handler calls after failed apply = %d", calls)
+	}
+	handler.setErr(nil)
+	waitQueries(t, db, 2)
+	calls := handler.count()
+	waitQueries(t, db, 3)
+	if handler.count() != calls {
+		t.Fatalf("handler called again after successful apply: %d -> %d", calls, handler.count())
+	}
+
+	// Ошибка загрузки не вызывает обработчик и не останавливает цикл
+	db.SetError(errors.New("connection refused"))
+	waitQueries(t, db, 2)
+	db.SetError(nil)
+	db.SetMonitoring()
+	waitQueries(t, db, 2)
+	handler.mu.Lock()
+	last := handler.calls[len(handler.calls)-1]
+	handler.mu.Unlock()
+	if len(last.MonitoringTasks) != 0 {
+		t.Fatalf("last applied tasks = %+v", last.MonitoringTasks)
+	}
+}
+
+func TestFetcherInitialFetchError(t *testing.T) {
+	db := tasktest.New()
+	db.SetError(errors.New("access denied"))
+
+	fetcher := NewFetcher(db.Open(), time.Second)
+	handler := &recorder{}
+	if err := fetcher.Start(context.Background(), handler.handle); err == nil {
+		t.Fatal("Start succeeded without tasks")
+	}
+	if handler.count() != 0 {
+		t.Errorf("handler called %d times", handler.count())
+	}
+	if err := fetcher.Stop(); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+}
+
+func TestFetchTradingTasksKeepsDecimalLimits(t *testing.T) {
+	db := tasktest.New()
+	db.SetTrades(tasktest.Trade{
+		ID: 3, ExchangeID: "okx", MarketType: exchange.MarketFutures, Base: "BTC", Quote: "USDT",
+		StrategyID:      "arbitrage",
+		MaxAmountTrade:  "1234.567890123456789",
+		MaxPositionSize: "0.30000000000000001",
+		SlippagePercent: "0.1",
+	})
+
+	fetcher := NewFetcher(db.Open(), time.Second)
+	handler := &recorder{}
+	if err := fetcher.Start(context.Background(), handler.handle); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer fetcher.Stop()
+
+	tasks := fetcher.GetLast().TradingTasks
+	if len(tasks) != 1 {
+		t.Fatalf("trading tasks = %d", len(tasks))
+	}
+	task := tasks[0]
+	for _, tc := range []struct {
+		name string
+		got  string
+		want string
+	}{
+		// float64 превратил бы эти значения в 1234.5678901234568 и 0.3
+		{"MAX_AMOUNT_TRADE", task.MaxAmountTrade.String(), "1234.567890123456789"},
+		{"MAX_POSITION_SIZE", task.MaxPositionSize.String(), "0.30000000000000001"},
+		{"SLIPPAGE_PERCENT", task.SlippagePercent.String(), "0.1"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s = %s, want %s", tc.name, tc.got, tc.want)
+		}
+	}
+	if task.TradePair != "BTC/USDT" || task.MarketType != exchange.MarketFutures {
+		t.Errorf("task = %+v", task)
+	}
+}
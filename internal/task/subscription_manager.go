@@ -33,7 +33,7 @@ type Subscription struct {
 	ExchangeID string   // binance, bybit и т.д.
 	MarketType string   // spot или futures
 	Pairs      []string // ["BTC/USDT", "ETH/USDT", ...]
-	Depth      int      // 20, 50 или 0 (полная книга) - для мониторинга
+	Depth      int      // 20, 50, 0 (полная книга) или exchange.DepthBBO (только лучшие цены)
 }
 
 // NewSubscriptionManager создает новый менеджер подписок
@@ -79,64 +79,91 @@ func (sm *SubscriptionManager) Merge(newTasks *TasksData) (*SubscriptionDiff, er
 	}, nil
 }
 
-// computeSubscribe вычисляет новые подписки
-// Возвращает пары которых не было в старом состоянии
-func (sm *SubscriptionManager) computeSubscribe(
-	newMonitoring map[string]*exchange.MonitoringTask,
-	newTrading map[string]*exchange.TradingTask,
-) []*Subscription {
-	// Объединяем мониторинг и торговлю по (exchangeID, marketType)
-	// Для каждой пары берем максимальную глубину
-	pairsByExchangeMarket := make(map[string]map[string]int) // key1 = "exchange:market", key2 = pair, value = depth
-
-	// Добавляем пары из мониторинга
-	for key, task := range newMonitoring {
-		if _, exists := sm.lastMonitoring[key]; !exists {
-			// Это новая пара мониторинга
-			emKey := fmt.Sprintf("%s:%s", task.ExchangeID, task.MarketType)
-			if _, ok := pairsByExchangeMarket[emKey]; !ok {
-				pairsByExchangeMarket[emKey] = make(map[string]int)
-			}
-			// Для мониторинга используем его глубину
-			pairsByExchangeMarket[emKey][task.TradePair] = task.OrderbookDepth
+// tradingBookDepth - глубина книги для торговой задачи, если пара не нужна мониторингу
+const tradingBookDepth = 50
+
+// pairDepths вычисляет глубину подписки каждой пары по всему набору задач
+// key1 = "exchange:market", key2 = pair, value = depth
+// Глубина пары не зависит от того, какие задачи новые: книга, нужная мониторингу
+// или торговле, всегда важнее потока лучших цен BBO_ONLY задачи
+func pairDepths(
+	monitoring map[string]*exchange.MonitoringTask,
+	trading map[string]*exchange.TradingTask,
+) map[string]map[string]int {
+	pairsByExchangeMarket := make(map[string]map[string]int)
+	pairs := func(exchangeID, marketType string) map[string]int {
+		emKey := fmt.Sprintf("%s:%s", exchangeID, marketType)
+		if _, ok := pairsByExchangeMarket[emKey]; !ok {
+			pairsByExchangeMarket[emKey] = make(map[string]int)
 		}
+		return pairsByExchangeMarket[emKey]
 	}
 
-	// Добавляем пары из торговли (они всегда нужны независимо от depth)
-	for key, task := range newTrading {
-		if _, exists := sm.lastTrading[key]; !exists {
-			// Это новая пара торговли
-			emKey := fmt.Sprintf("%s:%s", task.ExchangeID, task.MarketType)
-			if _, ok := pairsByExchangeMarket[emKey]; !ok {
-				pairsByExchangeMarket[emKey] = make(map[string]int)
-			}
-			// Для торговли глубина не так важна, но нужна какая-то
-			// Если уже есть из мониторинга, берем его, иначе 50 по умолчанию
-			if _, hasMonitoring := pairsByExchangeMarket[emKey][task.TradePair]; !hasMonitoring {
-				pairsByExchangeMarket[emKey][task.TradePair] = 50
-			}
+	// Для мониторинга используем его глубину, из нескольких задач пары - наибольшую
+	for _, task := range monitoring {
+		depths := pairs(task.ExchangeID, task.MarketType)
+		if depth, ok := depths[task.TradePair]; ok {
+			depths[task.TradePair] = widerDepth(depth, task.OrderbookDepth)
+		} else {
+			depths[task.TradePair] = task.OrderbookDepth
 		}
 	}
 
-	// Преобразуем в список Subscription
-	var result []*Subscription
-	for emKey, pairs := range pairsByExchangeMarket {
-		if len(pairs) > 0 {
-			var pairNames []string
-			var depth int // берем первую (они одинаковые на одном em)
+	// Для торговли глубина не так важна, но нужна какая-то:
+	// если пара уже есть из мониторинга, берем его глубину, иначе tradingBookDepth
+	// BBO_ONLY задаче хватает потока лучших цен, пока книга пары не нужна другим задачам
+	for _, task := range trading {
+		depths := pairs(task.ExchangeID, task.MarketType)
+		depth, ok := depths[task.TradePair]
+		switch {
+		case !ok && task.BBOOnly:
+			depths[task.TradePair] = exchange.DepthBBO
+		case !ok, depth == exchange.DepthBBO && !task.BBOOnly:
+			depths[task.TradePair] = tradingBookDepth
+		}
+	}
 
-			for pairName, d := range pairs {
-				pairNames = append(pairNames, pairName)
-				if depth == 0 {
-					depth = d
-				}
+	return pairsByExchangeMarket
+}
+
+// widerDepth возвращает глубину, покрывающую обе: любая книга важнее DepthBBO,
+// полная книга (0) важнее ограниченной
+func widerDepth(a, b int) int {
+	switch {
+	case a == exchange.DepthBBO:
+		return b
+	case b == exchange.DepthBBO:
+		return a
+	case a == 0 || b == 0:
+		return 0
+	default:
+		return max(a, b)
+	}
+}
+
+// computeSubscribe вычисляет новые подписки
+// Возвращает пары которых не было в старом состоянии и пары, у которых изменилась глубина
+func (sm *SubscriptionManager) computeSubscribe(
+	newMonitoring map[string]*exchange.MonitoringTask,
+	newTrading map[string]*exchange.TradingTask,
+) []*Subscription {
+	current := pairDepths(sm.lastMonitoring, sm.lastTrading)
+
+	// Группируем пары по глубине: одна подписка на каждую глубину (exchange, market)
+	var result []*Subscription
+	for emKey, pairs := range pairDepths(newMonitoring, newTrading) {
+		pairsByDepth := make(map[int][]string)
+		for pairName, depth := range pairs {
+			if subscribed, ok := current[emKey][pairName]; ok && subscribed == depth {
+				continue
 			}
+			pairsByDepth[depth] = append(pairsByDepth[depth], pairName)
+		}
 
-			// Парсим ключ
-			var em string
-			_, _ = fmt.Sscanf(emKey, "%s", &em)
-			parts := splitExchangeMarket(emKey)
-			if len(parts) == 2 {
+		// Парсим ключ
+		parts := splitExchangeMarket(emKey)
+		if len(parts) == 2 {
+			for depth, pairNames := range pairsByDepth {
 				result = append(result, &Subscription{
 					ExchangeID: parts[0],
 					MarketType: parts[1],
@@ -151,56 +178,33 @@ func (sm *SubscriptionManager) computeSubscribe(
 }
 
 // computeUnsubscribe вычисляет отписки
-// Возвращает пары которые были в старом состоянии но нет в новом
+// Возвращает пары которые были в старом состоянии, но не нужны ни одной новой задаче
 func (sm *SubscriptionManager) computeUnsubscribe(
 	newMonitoring map[string]*exchange.MonitoringTask,
 	newTrading map[string]*exchange.TradingTask,
 ) []*Subscription {
-	pairsByExchangeMarket := make(map[string]map[string]bool) // key1 = "exchange:market", key2 = pair
-
-	// Проверяем старые задачи мониторинга
-	for key := range sm.lastMonitoring {
-		if _, exists := newMonitoring[key]; !exists {
-			// Эта задача была удалена
-			task := sm.lastMonitoring[key]
-			emKey := fmt.Sprintf("%s:%s", task.ExchangeID, task.MarketType)
-			if _, ok := pairsByExchangeMarket[emKey]; !ok {
-				pairsByExchangeMarket[emKey] = make(map[string]bool)
-			}
-			pairsByExchangeMarket[emKey][task.TradePair] = true
-		}
-	}
-
-	// Проверяем старые задачи торговли
-	for key := range sm.lastTrading {
-		if _, exists := newTrading[key]; !exists {
-			// Эта задача была удалена
-			task := sm.lastTrading[key]
-			emKey := fmt.Sprintf("%s:%s", task.ExchangeID, task.MarketType)
-			if _, ok := pairsByExchangeMarket[emKey]; !ok {
-				pairsByExchangeMarket[emKey] = make(map[string]bool)
-			}
-			pairsByExchangeMarket[emKey][task.TradePair] = true
-		}
-	}
+	next := pairDepths(newMonitoring, newTrading)
 
 	// Преобразуем в список Subscription
 	var result []*Subscription
-	for emKey, pairs := range pairsByExchangeMarket {
-		if len(pairs) > 0 {
-			var pairNames []string
-			for pairName := range pairs {
+	for emKey, pairs := range pairDepths(sm.lastMonitoring, sm.lastTrading) {
+		var pairNames []string
+		for pairName := range pairs {
+			if _, ok := next[emKey][pairName]; !ok {
 				pairNames = append(pairNames, pairName)
 			}
+		}
+		if len(pairNames) == 0 {
+			continue
+		}
 
-			parts := splitExchangeMarket(emKey)
-			if len(parts) == 2 {
-				result = append(result, &Subscription{
-					ExchangeID: parts[0],
-					MarketType: parts[1],
-					Pairs:      pairNames,
-				})
-			}
+		parts := splitExchangeMarket(emKey)
+		if len(parts) == 2 {
+			result = append(result, &Subscription{
+				ExchangeID: parts[0],
+				MarketType: parts[1],
+				Pairs:      pairNames,
+			})
 		}
 	}
 
@@ -211,6 +215,9 @@ func (sm *SubscriptionManager) computeUnsubscribe(
 func (sm *SubscriptionManager) ApplyDiff(diff *SubscriptionDiff) error {
 	// Подписаться на новые пары
 	for _, sub := range diff.ToSubscribe {
+		if err := sm.validateSubscription(sub); err != nil {
+			return fmt.Errorf("subscribe failed for %s:%s: %w", sub.ExchangeID, sub.MarketType, err)
+		}
 		if err := sm.wsPool.Subscribe(sub.ExchangeID, sub.MarketType, sub.Pairs, sub.Depth); err != nil {
 			return fmt.Errorf("subscribe failed for %s:%s: %w", sub.ExchangeID, sub.MarketType, err)
 		}
@@ -226,6 +233,26 @@ func (sm *SubscriptionManager) ApplyDiff(diff *SubscriptionDiff) error {
 	return nil
 }
 
+// LiveSubscriptions возвращает подписки, которые действительно активны в WS Pool
+// В отличие от задач, учитывает фактическое соединение и поток сообщений по паре
+func (sm *SubscriptionManager) LiveSubscriptions(exchangeID, marketType string) []ws.SubscriptionInfo {
+	return sm.wsPool.GetSubscriptions(exchangeID, marketType)
+}
+
+// validateSubscription проверяет что для биржи есть драйвер и он поддерживает рынок
+// Позволяет отклонить задачу до открытия соединения
+// Драйвер берется у пула: тот же экземпляр затем используется для подписки
+func (sm *SubscriptionManager) validateSubscription(sub *Subscription) error {
+	driver, err := sm.wsPool.Driver(sub.ExchangeID)
+	if err != nil {
+		return err
+	}
+	if _, err := driver.GetWSEndpoint(sub.MarketType); err != nil {
+		return err
+	}
+	return nil
+}
+
 // splitExchangeMarket парсит ключ формата "exchange:market"
 func splitExchangeMarket(key string) []string {
 	parts := make([]string, 0)
@@ -0,0 +1,94 @@
+package task
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"trader/internal/core/exchange"
+)
+
+// subscriptions сводит подписки дельты к строкам "exchange:market:depth:pairs" для сравнения
+func subscriptions(subs []*Subscription) []string {
+	var result []string
+	for _, sub := range subs {
+		pairs := append([]string(nil), sub.Pairs...)
+		sort.Strings(pairs)
+		result = append(result, fmt.Sprintf("%s:%s:%d:%s", sub.ExchangeID, sub.MarketType, sub.Depth, strings.Join(pairs, ",")))
+	}
+	sort.Strings(result)
+	return result
+}
+
+func monitoring(pair string, depth int) *exchange.MonitoringTask {
+	return &exchange.MonitoringTask{ExchangeID: "binance", MarketType: exchange.MarketSpot, TradePair: pair, OrderbookDepth: depth}
+}
+
+func trading(pair, strategy string, bboOnly bool) *exchange.TradingTask {
+	return &exchange.TradingTask{ExchangeID: "binance", MarketType: exchange.MarketSpot, TradePair: pair, StrategyID: strategy, BBOOnly: bboOnly}
+}
+
+func TestMergeDepthFromAllTasks(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		before      *TasksData
+		after       *TasksData
+		subscribe   []string
+		unsubscribe []string
+	}{
+		{
+			name:      "BBO_ONLY task joins monitored pair",
+			before:    &TasksData{MonitoringTasks: []*exchange.MonitoringTask{monitoring("BTC/USDT", 50)}},
+			after:     &TasksData{MonitoringTasks: []*exchange.MonitoringTask{monitoring("BTC/USDT", 50)}, TradingTasks: []*exchange.TradingTask{trading("BTC/USDT", "arb", true)}},
+			subscribe: nil,
+		},
+		{
+			name:      "monitoring task joins BBO_ONLY pair",
+			before:    &TasksData{TradingTasks: []*exchange.TradingTask{trading("BTC/USDT", "arb", true)}},
+			after:     &TasksData{MonitoringTasks: []*exchange.MonitoringTask{monitoring("BTC/USDT", 20)}, TradingTasks: []*exchange.TradingTask{trading("BTC/USDT", "arb", true)}},
+			subscribe: []string{"binance:spot:20:BTC/USDT"},
+		},
+		{
+			name:      "monitoring task leaves BBO_ONLY pair",
+			before:    &TasksData{MonitoringTasks: []*exchange.MonitoringTask{monitoring("BTC/USDT", 20)}, TradingTasks: []*exchange.TradingTask{trading("BTC/USDT", "arb", true)}},
+			after:     &TasksData{TradingTasks: []*exchange.TradingTask{trading("BTC/USDT", "arb", true)}},
+			subscribe: []string{"binance:spot:-1:BTC/USDT"},
+		},
+		{
+			name:      "book trading task joins BBO_ONLY pair",
+			before:    &TasksData{TradingTasks: []*exchange.TradingTask{trading("ETH/USDT", "arb", true)}},
+			after:     &TasksData{TradingTasks: []*exchange.TradingTask{trading("ETH/USDT", "arb", true), trading("ETH/USDT", "grid", false)}},
+			subscribe: []string{"binance:spot:50:ETH/USDT"},
+		},
+		{
+			name:      "full book wins over limited depth",
+			before:    &TasksData{},
+			after:     &TasksData{MonitoringTasks: []*exchange.MonitoringTask{monitoring("BTC/USDT", 20), {ExchangeID: "binance", MarketType: exchange.MarketFutures, TradePair: "BTC/USDT", OrderbookDepth: 0}}, TradingTasks: []*exchange.TradingTask{trading("ETH/USDT", "grid", false)}},
+			subscribe: []string{"binance:futures:0:BTC/USDT", "binance:spot:20:BTC/USDT", "binance:spot:50:ETH/USDT"},
+		},
+		{
+			name:        "pair still used by another task is kept",
+			before:      &TasksData{MonitoringTasks: []*exchange.MonitoringTask{monitoring("BTC/USDT", 50), monitoring("ETH/USDT", 50)}, TradingTasks: []*exchange.TradingTask{trading("BTC/USDT", "grid", false)}},
+			after:       &TasksData{TradingTasks: []*exchange.TradingTask{trading("BTC/USDT", "grid", false)}},
+			unsubscribe: []string{"binance:spot:0:ETH/USDT"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sm := NewSubscriptionManager(nil)
+			if _, err := sm.Merge(tc.before); err != nil {
+				t.Fatalf("Merge before: %v", err)
+			}
+			diff, err := sm.Merge(tc.after)
+			if err != nil {
+				t.Fatalf("Merge after: %v", err)
+			}
+			if got := subscriptions(diff.ToSubscribe); strings.Join(got, " ") != strings.Join(tc.subscribe, " ") {
+				t.Errorf("ToSubscribe = %v, want %v", got, tc.subscribe)
+			}
+			if got := subscriptions(diff.Unsubscribe); strings.Join(got, " ") != strings.Join(tc.unsubscribe, " ") {
+				t.Errorf("Unsubscribe = %v, want %v", got, tc.unsubscribe)
+			}
+		})
+	}
+}
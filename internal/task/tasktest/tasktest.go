@@ -0,0 +1,188 @@
+// Package tasktest - база задач в памяти для тестов task.Fetcher и его обработчиков
+//
+// DB отвечает на запросы Fetcher строками MONITORING и TRADE, заданными тестом,
+// в том виде, в каком их возвращает драйвер MySQL (DECIMAL - байтовой строкой).
+// Строки можно менять между загрузками, чтобы проверить применение изменений задач.
+package tasktest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Monitoring - строка задачи мониторинга (MONITORING + пара)
+type Monitoring struct {
+	ID               int
+	ExchangeID       string
+	MarketType       string
+	Base             string
+	Quote            string
+	OrderbookDepth   int
+	BatchSize        int
+	BatchIntervalSec int
+	RingBufferSize   int
+	SaveIntervalSec  int
+}
+
+// Trade - строка торговой задачи (TRADE + пара)
+// Колонки DECIMAL задаются строками, пустая строка = 0
+type Trade struct {
+	ID                int
+	ExchangeID        string
+	MarketType        string
+	Base              string
+	Quote             string
+	StrategyID        string
+	MaxAmountTrade    string
+	MaxOpenOrders     int
+	MaxPositionSize   string
+	SlippagePercent   string
+	BBOOnly           bool
+	ExchangeAccountID int
+}
+
+// DB - таблицы задач в памяти
+type DB struct {
+	mu         sync.Mutex
+	monitoring []Monitoring
+	trades     []Trade
+	err        error
+	queries    int
+}
+
+// New создает пустую базу задач
+func New() *DB {
+	return &DB{}
+}
+
+// SetMonitoring заменяет активные задачи мониторинга
+func (d *DB) SetMonitoring(rows ...Monitoring) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.monitoring = rows
+}
+
+// SetTrades заменяет активные торговые задачи
+func (d *DB) SetTrades(rows ...Trade) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.trades = rows
+}
+
+// SetError задает ошибку всех следующих запросов, nil - запросы снова успешны
+func (d *DB) SetError(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = err
+}
+
+// Queries возвращает количество выполненных запросов
+func (d *DB) Queries() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.queries
+}
+
+// Open возвращает *sql.DB поверх таблиц, который можно передать в task.NewFetcher
+func (d *DB) Open() *sql.DB {
+	return sql.OpenDB(connector{db: d})
+}
+
+// query возвращает строки запроса Fetcher: задачи мониторинга или торговли
+func (d *DB) query(query string) (driver.Rows, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.queries++
+	if d.err != nil {
+		return nil, d.err
+	}
+	switch {
+	case strings.Contains(query, "FROM MONITORING"):
+		values := make([][]driver.Value, 0, len(d.monitoring))
+		for _, m := range d.monitoring {
+			values = append(values, []driver.Value{
+				int64(m.ID), int64(0), true, m.ExchangeID, m.ExchangeID, int64(0),
+				int64(0), int64(0), m.MarketType, m.Base, m.Quote,
+				int64(m.OrderbookDepth), int64(m.BatchSize), int64(m.BatchIntervalSec),
+				int64(m.RingBufferSize), int64(m.SaveIntervalSec),
+			})
+		}
+		return &rows{columns: 16, values: values}, nil
+	case strings.Contains(query, "FROM TRADE "):
+		values := make([][]driver.Value, 0, len(d.trades))
+		for _, t := range d.trades {
+			values = append(values, []driver.Value{
+				int64(t.ID), int64(0), int64(0), true,
+				t.ExchangeID, t.ExchangeID, int64(0),
+				int64(0), int64(0), t.MarketType,
+				t.Base, t.Quote, t.StrategyID,
+				decimalValue(t.MaxAmountTrade), int64(t.MaxOpenOrders), decimalValue(t.MaxPositionSize),
+				int64(0), decimalValue(t.SlippagePercent),
+				false, false, t.BBOOnly,
+				int64(t.ExchangeAccountID),
+			})
+		}
+		return &rows{columns: 22, values: values}, nil
+	default:
+		return nil, fmt.Errorf("tasktest: unexpected query %q", query)
+	}
+}
+
+// decimalValue - колонка DECIMAL в виде, в котором ее возвращает драйвер MySQL
+func decimalValue(value string) []byte {
+	if value == "" {
+		value = "0"
+	}
+	return []byte(value)
+}
+
+type connector struct {
+	db *DB
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return conn(c), nil }
+func (c connector) Driver() driver.Driver                        { return c }
+func (c connector) Open(string) (driver.Conn, error)             { return conn(c), nil }
+
+type conn struct {
+	db *DB
+}
+
+func (c conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) > 0 {
+		return nil, errors.New("tasktest: query arguments are not supported")
+	}
+	return c.db.query(query)
+}
+
+func (conn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("tasktest: prepared statements are not supported")
+}
+func (conn) Close() error { return nil }
+func (conn) Begin() (driver.Tx, error) {
+	return nil, errors.New("tasktest: transactions are not supported")
+}
+
+type rows struct {
+	columns int
+	values  [][]driver.Value
+}
+
+func (r *rows) Columns() []string { return make([]string, r.columns) }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -1,98 +1,221 @@
 package trader
 
 import (
-"context"
-"database/sql"
-"fmt"
-"sync"
-"time"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+	"trader/internal/core/pubsub"
+	"trader/internal/logger"
 )
 
+// arbitrageQueueSize - очередь подписчика арбитража: BBO схлопываются по паре,
+// поэтому размер ограничивает число пар с недоставленными ценами
+const arbitrageQueueSize = pubsub.DefaultQueueSize
+
+var arbitrageSeq atomic.Uint64
+
+// BookSubscriber - подписка на обновления книг, реализуется orderbook.Manager
+type BookSubscriber interface {
+	// Subscribe подписывает на пару, любой аргумент может быть pubsub.Wildcard
+	Subscribe(subscriber pubsub.Subscriber, exchangeID, pair, marketType string) error
+	// Unsubscribe снимает подписку, сделанную Subscribe с теми же аргументами
+	Unsubscribe(subscriber pubsub.Subscriber, exchangeID, pair, marketType string)
+}
+
+// watch - ключ подписки обработчика
+type watch struct {
+	exchangeID string
+	pair       string
+	marketType string
+}
+
 // ArbitrageTransHandler отслеживает ARBITRAGE_TRANS записи
+// Лучшие цены пар арбитража приходят подпиской на менеджер книг (pubsub):
+// очередь схлопывает BBO, поэтому обработчик всегда видит последние цены
 type ArbitrageTransHandler struct {
-db            *sql.DB
-lastCheckedID int64
-ctx           context.Context
-cancel        context.CancelFunc
-wg            sync.WaitGroup
+	db            *sql.DB
+	books         BookSubscriber
+	id            string
+	log           *slog.Logger
+	lastCheckedID int64
+	ctx           context.Context
+	cancel        context.CancelFunc
+	wg            sync.WaitGroup
+
+	mu      sync.RWMutex
+	watched map[string]watch             // key = pubsub.Key()
+	quotes  map[string]messaging.BBOData // key = exchange.GetOrderBookKey()
 }
 
 // NewArbitrageTransHandler создает новый обработчик
-func NewArbitrageTransHandler(db *sql.DB) *ArbitrageTransHandler {
-return &ArbitrageTransHandler{
-db:            db,
-lastCheckedID: 0,
-}
+func NewArbitrageTransHandler(db *sql.DB, books BookSubscriber) *ArbitrageTransHandler {
+	return &ArbitrageTransHandler{
+		db:            db,
+		books:         books,
+		id:            fmt.Sprintf("arbitrage-%d", arbitrageSeq.Add(1)),
+		log:           logger.Get("trader"),
+		lastCheckedID: 0,
+		watched:       make(map[string]watch),
+		quotes:        make(map[string]messaging.BBOData),
+	}
 }
 
 // Start запускает фоновый горутин
 func (h *ArbitrageTransHandler) Start(ctx context.Context, pollInterval time.Duration) error {
-h.ctx, h.cancel = context.WithCancel(ctx)
-h.wg.Add(1)
-go h.pollLoop(pollInterval)
-return nil
+	h.ctx, h.cancel = context.WithCancel(ctx)
+	h.wg.Add(1)
+	go h.pollLoop(pollInterval)
+	return nil
 }
 
-// Stop останавливает мониторинг
+// Stop останавливает мониторинг и снимает подписки на цены
 func (h *ArbitrageTransHandler) Stop() error {
-h.cancel()
-h.wg.Wait()
-return nil
+	if h.cancel != nil {
+		h.cancel()
+	}
+	h.wg.Wait()
+
+	h.mu.Lock()
+	watched := h.watched
+	h.watched = make(map[string]watch)
+	h.quotes = make(map[string]messaging.BBOData)
+	h.mu.Unlock()
+
+	for _, w := range watched {
+		h.books.Unsubscribe(h, w.exchangeID, w.pair, w.marketType)
+	}
+	return nil
 }
 
-// pollLoop периодически проверяет новые записи
-func (h *ArbitrageTransHandler) pollLoop(pollInterval time.Duration) {
-defer h.wg.Done()
-ticker := time.NewTicker(pollInterval)
-defer ticker.Stop()
+// Watch подписывает обработчик на лучшие цены пары, любой аргумент может быть pubsub.Wildcard
+func (h *ArbitrageTransHandler) Watch(exchangeID, pair, marketType string) error {
+	if err := h.books.Subscribe(h, exchangeID, pair, marketType); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.watched[pubsub.Key(exchangeID, marketType, pair)] = watch{exchangeID: exchangeID, pair: pair, marketType: marketType}
+	h.mu.Unlock()
+	return nil
+}
 
-for {
-select {
-case <-h.ctx.Done():
-return
-case <-ticker.C:
-if err := h.checkNewTransactions(); err != nil {
-fmt.Printf("check arbitrage error: %v\n", err)
+// Unwatch снимает подписку, сделанную Watch с теми же аргументами
+// Последние цены пар остаются до сброса книги
+func (h *ArbitrageTransHandler) Unwatch(exchangeID, pair, marketType string) {
+	h.mu.Lock()
+	delete(h.watched, pubsub.Key(exchangeID, marketType, pair))
+	h.mu.Unlock()
+	h.books.Unsubscribe(h, exchangeID, pair, marketType)
 }
+
+// Quote возвращает последние лучшие цены пары
+// false если цен еще нет или книга пары сброшена
+func (h *ArbitrageTransHandler) Quote(exchangeID, pair, marketType string) (messaging.BBOData, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	bbo, ok := h.quotes[exchange.GetOrderBookKey(exchangeID, pair, marketType)]
+	return bbo, ok
 }
+
+// OnMessage - pubsub.Subscriber, запоминает лучшие цены пары
+// TypeBookInvalidated убирает цены пары до следующего BBO
+func (h *ArbitrageTransHandler) OnMessage(msg *messaging.Message) {
+	key := exchange.GetOrderBookKey(msg.ExchangeID, msg.Pair, msg.MarketType)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch msg.Type {
+	case messaging.TypeBBO:
+		if msg.BBO != nil {
+			h.quotes[key] = *msg.BBO
+		}
+	case messaging.TypeBookInvalidated:
+		delete(h.quotes, key)
+	}
+}
+
+// OnError - pubsub.Subscriber, потерянные при переполнении BBO заменит следующий
+func (h *ArbitrageTransHandler) OnError(err error) {
+	if errors.Is(err, pubsub.ErrQueueOverflow) {
+		return
+	}
+	h.log.Warn("arbitrage quotes stream error", "subscriber_id", h.id, "error", err)
+}
+
+// GetID - pubsub.Subscriber
+func (h *ArbitrageTransHandler) GetID() string { return h.id }
+
+// MessageTypes - pubsub.TypeFilter: лучшие цены и сигналы сброса книги
+func (h *ArbitrageTransHandler) MessageTypes() []string {
+	return []string{messaging.TypeBBO, messaging.TypeBookInvalidated}
+}
+
+// QueueOptions - pubsub.QueueConfigurer: нужны только последние цены пары
+func (h *ArbitrageTransHandler) QueueOptions() pubsub.QueueOptions {
+	return pubsub.QueueOptions{Size: arbitrageQueueSize, Overflow: pubsub.CoalesceBook}
 }
+
+// pollLoop периодически проверяет новые записи
+func (h *ArbitrageTransHandler) pollLoop(pollInterval time.Duration) {
+	defer h.wg.Done()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.checkNewTransactions(); err != nil {
+				h.log.Error("check arbitrage failed", "error", err)
+			}
+		}
+	}
 }
 
 // checkNewTransactions загружает новые транзакции
 func (h *ArbitrageTransHandler) checkNewTransactions() error {
-query := `SELECT ID, TRADE_ID, STATUS, AMOUNT, CALC_PRFIT, DATE_CREATE, DATE_MODIFY
+	query := `SELECT ID, TRADE_ID, STATUS, AMOUNT, CALC_PRFIT, DATE_CREATE, DATE_MODIFY
 FROM ARBITRAGE_TRANS WHERE STATUS = 1 AND ID > ? ORDER BY ID ASC`
 
-rows, err := h.db.QueryContext(h.ctx, query, h.lastCheckedID)
-if err != nil {
-return err
-}
-defer rows.Close()
+	rows, err := h.db.QueryContext(h.ctx, query, h.lastCheckedID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
-for rows.Next() {
-var id, tradeID int64
-var status int
-var amount, profit sql.NullFloat64
-var created, modified time.Time
+	for rows.Next() {
+		var id, tradeID int64
+		var status int
+		var amount, profit sql.NullFloat64
+		var created, modified time.Time
 
-if err := rows.Scan(&id, &tradeID, &status, &amount, &profit, &created, &modified); err != nil {
-return err
-}
+		if err := rows.Scan(&id, &tradeID, &status, &amount, &profit, &created, &modified); err != nil {
+			return err
+		}
 
-h.lastCheckedID = id
-}
+		h.lastCheckedID = id
+	}
 
-return rows.Err()
+	return rows.Err()
 }
 
 // RecoverSuspendedTransactions восстанавливает транзакции
 func (h *ArbitrageTransHandler) RecoverSuspendedTransactions() (int, error) {
-result, err := h.db.ExecContext(h.ctx, 
-`UPDATE ARBITRAGE_TRANS SET STATUS = 1, DATE_MODIFY = NOW() WHERE STATUS = 3`)
-if err != nil {
-return 0, err
-}
+	result, err := h.db.ExecContext(h.ctx,
+		`UPDATE ARBITRAGE_TRANS SET STATUS = 1, DATE_MODIFY = NOW() WHERE STATUS = 3`)
+	if err != nil {
+		return 0, err
+	}
 
-affected, err := result.RowsAffected()
-return int(affected), err
+	affected, err := result.RowsAffected()
+	return int(affected), err
 }
@@ -0,0 +1,93 @@
+package trader
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"trader/internal/core/exchange"
+	"trader/internal/core/messaging"
+	"trader/internal/core/orderbook"
+	"trader/internal/core/pubsub"
+)
+
+// bboSource - источник менеджера книг для потоков лучших цен: драйвер не нужен
+type bboSource struct{}
+
+func (bboSource) Driver(exchangeID string) (exchange.ExchangeDriver, error) {
+	return nil, errors.New("no driver")
+}
+
+func (bboSource) Resubscribe(string, string, string) error { return nil }
+
+func bboMessage(exchangeID, pair, bid, ask string) *messaging.Message {
+	return &messaging.Message{
+		ExchangeID: exchangeID,
+		MarketType: exchange.MarketSpot,
+		Pair:       pair,
+		Type:       messaging.TypeBBO,
+		BBO: &messaging.BBOData{
+			BidPrice: level(bid, "1").Price, BidAmount: level(bid, "1").Amount,
+			AskPrice: level(ask, "1").Price, AskAmount: level(ask, "1").Amount,
+		},
+	}
+}
+
+func waitQuote(t *testing.T, h *ArbitrageTransHandler, exchangeID, pair string, ok func(messaging.BBOData, bool) bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		bbo, found := h.Quote(exchangeID, pair, exchange.MarketSpot)
+		if ok(bbo, found) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s %s quote = %+v, %v", exchangeID, pair, bbo, found)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestArbitrageHandlerReceivesWatchedQuotes(t *testing.T) {
+	books := orderbook.NewManager(bboSource{})
+	defer books.Close()
+
+	h := NewArbitrageTransHandler(nil, books)
+	if err := h.Watch(pubsub.Wildcard, "BTC/USDT", exchange.MarketSpot); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	for _, msg := range []*messaging.Message{
+		bboMessage("binance", "BTC/USDT", "100", "101"),
+		bboMessage("okx", "BTC/USDT", "100.5", "102"),
+		bboMessage("binance", "ETH/USDT", "10", "11"),
+	} {
+		if err := books.UpdateOrderBook(msg); err != nil {
+			t.Fatalf("UpdateOrderBook: %v", err)
+		}
+	}
+	waitQuote(t, h, "okx", "BTC/USDT", func(bbo messaging.BBOData, ok bool) bool {
+		return ok && bbo.BidPrice.Equal(level("100.5", "1").Price)
+	})
+	waitQuote(t, h, "binance", "BTC/USDT", func(bbo messaging.BBOData, ok bool) bool {
+		return ok && bbo.AskPrice.Equal(level("101", "1").Price)
+	})
+	if _, ok := h.Quote("binance", "ETH/USDT", exchange.MarketSpot); ok {
+		t.Error("quote of a pair outside Watch was stored")
+	}
+
+	// Сброс книги убирает цены пары до следующего BBO
+	if err := books.UpdateOrderBook(&messaging.Message{
+		ExchangeID: "binance", MarketType: exchange.MarketSpot, Pair: "BTC/USDT", Type: messaging.TypeBookInvalidated,
+	}); err != nil {
+		t.Fatalf("UpdateOrderBook invalidated: %v", err)
+	}
+	waitQuote(t, h, "binance", "BTC/USDT", func(_ messaging.BBOData, ok bool) bool { return !ok })
+
+	if err := h.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if got := len(books.SubscriberStats()); got != 0 {
+		t.Errorf("subscribers after Stop = %d, want 0", got)
+	}
+}
@@ -5,6 +5,8 @@ import (
 "fmt"
 "sync"
 "time"
+
+"github.com/shopspring/decimal"
 )
 
 // TradeHistoryLogger логирует ордера в TRADE_HISTORY
@@ -16,6 +18,7 @@ maxBuffer int
 }
 
 // OrderExecution описывает исполненный ордер
+// Цены и объемы в decimal: пишутся в DECIMAL(30,12) TRADE_HISTORY без потери точности
 type OrderExecution struct {
 TradeID           int
 OrderID           string
@@ -24,13 +27,13 @@ TradePairID       int
 TradePair         string
 ExchangeAccountID int
 Side              string
-Price             float64
-Amount            float64
-Commission        float64
+Price             decimal.Decimal
+Amount            decimal.Decimal
+Commission        decimal.Decimal
 CommissionAsset   string
 Status            string
 ExecutedAtMicros  int64
-ProfitLoss        *float64
+ProfitLoss        decimal.NullDecimal
 }
 
 // NewTradeHistoryLogger создает новый логгер
@@ -122,8 +125,8 @@ return nil
 }
 
 // GetTotalProfitLoss вычисляет общий P&L
-func (l *TradeHistoryLogger) GetTotalProfitLoss(tradeID int) (float64, error) {
-var totalPL float64
+func (l *TradeHistoryLogger) GetTotalProfitLoss(tradeID int) (decimal.Decimal, error) {
+var totalPL decimal.Decimal
 err := l.db.QueryRow(
 `SELECT COALESCE(SUM(PROFIT_LOSS), 0) FROM TRADE_HISTORY WHERE TRADE_ID = ? AND PROFIT_LOSS IS NOT NULL`,
 tradeID).Scan(&totalPL)
@@ -0,0 +1,58 @@
+package trader
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+)
+
+// ErrNoOrderBook - книга пары не синхронизирована, ордер нельзя проверить по ликвидности
+var ErrNoOrderBook = errors.New("order book is not available")
+
+// OrderLimits - ограничения рыночного ордера торговой задачи, нулевой лимит не проверяется
+type OrderLimits struct {
+	// MaxNotional - TRADE.MAX_AMOUNT_TRADE, предельная стоимость ордера в котируемом активе
+	MaxNotional decimal.Decimal
+	// MaxSlippagePercent - TRADE.SLIPPAGE_PERCENT, предельное проскальзывание от mid в процентах
+	MaxSlippagePercent decimal.Decimal
+}
+
+// TaskLimits возвращает ограничения ордера задачи из колонок TRADE
+func TaskLimits(task *exchange.TradingTask) OrderLimits {
+	return OrderLimits{
+		MaxNotional:        task.MaxAmountTrade,
+		MaxSlippagePercent: task.SlippagePercent,
+	}
+}
+
+// BookSource - источник текущих книг, реализуется orderbook.Manager
+type BookSource interface {
+	// GetOrderBook возвращает копию книги, nil если книга невалидна
+	GetOrderBook(exchangeID, pair, marketType string) *exchange.OrderBook
+}
+
+// OrderChecker - проверка рыночного ордера перед отправкой на биржу по текущей книге пары
+// и ограничениям задачи. Исполнитель ордеров обязан вызвать Check до отправки
+type OrderChecker struct {
+	books BookSource
+}
+
+// NewOrderChecker создает проверку по книгам books
+func NewOrderChecker(books BookSource) *OrderChecker {
+	return &OrderChecker{books: books}
+}
+
+// Check оценивает исполнение рыночного ордера задачи объемом quantity (в единицах книги)
+// и возвращает ошибку, если стоимость больше MAX_AMOUNT_TRADE, проскальзывание больше
+// SLIPPAGE_PERCENT, объема книги не хватает или книга пары не синхронизирована
+func (c *OrderChecker) Check(task *exchange.TradingTask, side string, quantity decimal.Decimal) (exchange.Fill, error) {
+	limits := TaskLimits(task)
+	book := c.books.GetOrderBook(task.ExchangeID, task.TradePair, task.MarketType)
+	if book == nil {
+		return exchange.Fill{}, fmt.Errorf("%w: %s %s %s", ErrNoOrderBook, task.ExchangeID, task.MarketType, task.TradePair)
+	}
+	return book.CheckOrder(side, quantity, limits.MaxNotional, limits.MaxSlippagePercent)
+}
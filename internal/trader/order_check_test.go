@@ -0,0 +1,65 @@
+package trader
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"trader/internal/core/exchange"
+)
+
+type bookSource map[string]*exchange.OrderBook
+
+func (s bookSource) GetOrderBook(exchangeID, pair, marketType string) *exchange.OrderBook {
+	return s[exchange.GetOrderBookKey(exchangeID, pair, marketType)]
+}
+
+func level(price, amount string) exchange.Level {
+	return exchange.Level{Price: decimal.RequireFromString(price), Amount: decimal.RequireFromString(amount)}
+}
+
+func TestOrderCheckerAppliesTaskLimits(t *testing.T) {
+	books := bookSource{
+		exchange.GetOrderBookKey("binance", "BTC/USDT", exchange.MarketSpot): {
+			ExchangeID: "binance", Pair: "BTC/USDT", MarketType: exchange.MarketSpot,
+			Bids: []exchange.Level{level("99", "1")},
+			Asks: []exchange.Level{level("101", "1"), level("103", "1")},
+		},
+	}
+	checker := NewOrderChecker(books)
+	task := &exchange.TradingTask{
+		ID: 7, ExchangeID: "binance", MarketType: exchange.MarketSpot, TradePair: "BTC/USDT",
+		MaxAmountTrade:  decimal.RequireFromString("250"),
+		SlippagePercent: decimal.RequireFromString("1.5"),
+	}
+
+	for _, tc := range []struct {
+		name     string
+		quantity string
+		err      error
+	}{
+		// VWAP 101, mid 100: проскальзывание 1%, стоимость 101
+		{"within limits", "1", nil},
+		// VWAP 102: проскальзывание 2%
+		{"slippage above SLIPPAGE_PERCENT", "2", exchange.ErrSlippageExceeded},
+		{"book too thin", "3", exchange.ErrInsufficientLiquidity},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := checker.Check(task, exchange.SideBuy, decimal.RequireFromString(tc.quantity))
+			if !errors.Is(err, tc.err) {
+				t.Errorf("Check = %v, want %v", err, tc.err)
+			}
+		})
+	}
+
+	task.MaxAmountTrade, task.SlippagePercent = decimal.RequireFromString("100"), decimal.Zero
+	if _, err := checker.Check(task, exchange.SideBuy, decimal.NewFromInt(1)); !errors.Is(err, exchange.ErrAmountExceeded) {
+		t.Errorf("Check above MAX_AMOUNT_TRADE = %v", err)
+	}
+
+	task.TradePair = "ETH/USDT"
+	if _, err := checker.Check(task, exchange.SideBuy, decimal.NewFromInt(1)); !errors.Is(err, ErrNoOrderBook) {
+		t.Errorf("Check without book = %v", err)
+	}
+}